@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/metrics"
+	"github.com/romshark/taskhub/api/respcache"
+	"golang.org/x/exp/slog"
+)
+
+// ServerAdmin serves operational endpoints that
+// must not be exposed to the public.
+type ServerAdmin struct {
+	log              *slog.Logger
+	persistedQueries *gqlpq.PersistedQueries
+	responseCache    *respcache.Cache
+	metrics          *metrics.Metrics
+}
+
+// NewAdminServer creates a new admin server.
+// responseCache is optional.
+func NewAdminServer(
+	log *slog.Logger,
+	persistedQueries *gqlpq.PersistedQueries,
+	responseCache *respcache.Cache,
+	operationMetrics *metrics.Metrics,
+) *ServerAdmin {
+	return &ServerAdmin{
+		log:              log,
+		persistedQueries: persistedQueries,
+		responseCache:    responseCache,
+		metrics:          operationMetrics,
+	}
+}
+
+// Health is the response of GET /health.
+type Health struct {
+	// Status is "ok" if all components are healthy, otherwise "degraded".
+	Status           string       `json:"status"`
+	PersistedQueries gqlpq.Status `json:"persistedQueries"`
+}
+
+func (s *ServerAdmin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/health":
+		if r.Method != http.MethodGet {
+			httpNotFound(w)
+			return
+		}
+		s.serveHealth(w)
+	case "/cache":
+		if r.Method != http.MethodGet || s.responseCache == nil {
+			httpNotFound(w)
+			return
+		}
+		s.writeJSON(w, "cache stats", s.responseCache.Stats())
+	case "/metrics":
+		if r.Method != http.MethodGet {
+			httpNotFound(w)
+			return
+		}
+		s.serveMetrics(w)
+	default:
+		httpNotFound(w)
+	}
+}
+
+// serveHealth always responds with 200 OK since a failed persisted queries
+// reload doesn't prevent the server from serving the last good set.
+func (s *ServerAdmin) serveHealth(w http.ResponseWriter) {
+	h := Health{
+		Status:           "ok",
+		PersistedQueries: s.persistedQueries.Status(),
+	}
+	if h.PersistedQueries.LastError != "" {
+		h.Status = "degraded"
+	}
+	s.writeJSON(w, "health", h)
+}
+
+// serveMetrics writes the operation metrics in the Prometheus text format
+// including all currently persisted queries, even if they were never called.
+func (s *ServerAdmin) serveMetrics(w http.ResponseWriter) {
+	queries := s.persistedQueries.Queries()
+	persisted := make([]metrics.Operation, len(queries))
+	for i, q := range queries {
+		persisted[i] = metrics.Operation{
+			Name: q.Key, Type: string(q.Operation),
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := s.metrics.WritePrometheus(w, persisted); err != nil {
+		s.log.Info("writing metrics response", slog.Any("error", err))
+	}
+}
+
+func (s *ServerAdmin) writeJSON(w http.ResponseWriter, what string, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Info("writing "+what+" response", slog.Any("error", err))
+	}
+}
@@ -7,24 +7,36 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/romshark/taskhub/api/broadcast"
 	"github.com/romshark/taskhub/api/dataprovider"
 	"github.com/romshark/taskhub/api/gqlpq"
 	"github.com/romshark/taskhub/api/graph"
+	"github.com/romshark/taskhub/api/graph/model"
 	"github.com/romshark/taskhub/api/jwt"
+	"github.com/romshark/taskhub/api/metrics"
 	"github.com/romshark/taskhub/api/passhash"
+	"github.com/romshark/taskhub/api/ratelimit"
 	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/api/respcache"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/vektah/gqlparser/v2/ast"
 	"golang.org/x/exp/slog"
 )
 
+// maxBodySize is the maximum size of a request body in bytes.
+// Persisted queries can define a lower limit using @maxBodySize.
+const maxBodySize = 1 << 20
+
 // TimeProviderLive provides live time.
 type TimeProviderLive struct{}
 
@@ -55,22 +67,97 @@ type ServerProduction struct {
 	log              *slog.Logger
 	gqlHandler       http.Handler
 	persistedQueries *gqlpq.PersistedQueries
+	dataProvider     dataprovider.DataProvider
+	rateLimiter      *ratelimit.Limiter
+	responseCache    *respcache.Cache
+	metrics          *metrics.Metrics
+	jwtKeys          *jwt.KeySet
+	openAPI          openAPIDocument
 }
 
 func (s *ServerProduction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/query":
+		s.serveAPQ(w, r)
+		return
+	case "/e":
+		s.serveBatch(w, r)
+		return
+	case "/openapi.json":
+		s.serveOpenAPI(w, r)
+		return
+	case "/.well-known/jwks.json":
+		s.serveJWKS(w, r)
+		return
+	}
 	key, ok := strings.CutPrefix(r.URL.Path, "/e/")
 	if !ok {
 		httpNotFound(w)
 		return
 	}
-	query := s.persistedQueries.GetQuery(key)
-	if query == "" {
+	q := s.persistedQueries.Get(key)
+	if q == nil {
 		httpNotFound(w)
 		return
 	}
+	if !s.authorize(w, r, q) {
+		return
+	}
+	query := q.Encoded
+
+	if q.Operation == ast.Subscription && (transport.Websocket{}).Supports(r) {
+		// The websocket handshake is always a GET request,
+		// the subscription itself is started over the socket.
+		s.gqlHandler.ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		if q.Operation != ast.Query {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(
+				w, "GET is only allowed for queries", http.StatusMethodNotAllowed,
+			)
+			return
+		}
+		variables := []byte(r.URL.Query().Get("variables"))
+		if len(variables) > 0 {
+			if !json.Valid(variables) {
+				http.Error(w, "invalid variables JSON", http.StatusBadRequest)
+				return
+			}
+			variables = bytes.TrimLeft(variables, " \n\r\t")
+			if variables[0] != '{' {
+				http.Error(
+					w, "variables must be an object", http.StatusBadRequest,
+				)
+				return
+			}
+			r.Body = makeQueryWithVars(query, variables)
+		} else {
+			r.Body = makeQuery(query)
+		}
+		// Execute as POST since the GET transport of gqlgen
+		// expects the query in the URL.
+		r.Method = http.MethodPost
+		r.Header.Set("Content-Type", "application/json")
+		s.serveCacheable(w, r, q, variables)
+		return
+	}
 
-	originalBody, err := io.ReadAll(r.Body)
+	limit := int64(maxBodySize)
+	if q.Metadata.MaxBodySize > 0 && q.Metadata.MaxBodySize < limit {
+		limit = q.Metadata.MaxBodySize
+	}
+	originalBody, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
 	if err != nil {
+		var errMaxBytes *http.MaxBytesError
+		if errors.As(err, &errMaxBytes) {
+			http.Error(
+				w, "request body too large", http.StatusRequestEntityTooLarge,
+			)
+			return
+		}
 		s.log.Info("reading request body", slog.Any("error", err))
 		return
 	}
@@ -96,9 +183,110 @@ func (s *ServerProduction) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.Body = makeQuery(query)
 	}
 
+	if s.responseCache != nil && q.Metadata.CacheTTL > 0 {
+		s.execute(r, q, originalBody).writeTo(w)
+		return
+	}
 	s.gqlHandler.ServeHTTP(w, r)
 }
 
+// hasAnyRole returns true if u has any of the global roles.
+// The free-text User.Role isn't considered since users can change it.
+func hasAnyRole(u *model.User, roles []string) bool {
+	for _, r := range roles {
+		if r == gqlpq.RoleAdmin && u.Admin {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize enforces the authentication, role and rate limit requirements
+// declared in the metadata of q and responds with an error
+// returning false if any of them isn't met.
+func (s *ServerProduction) authorize(
+	w http.ResponseWriter, r *http.Request, q *gqlpq.Query,
+) bool {
+	if status, msg := s.checkAccess(r, q); status != 0 {
+		http.Error(w, msg, status)
+		return false
+	}
+	setDeprecationHeaders(w.Header(), q.Metadata)
+	return true
+}
+
+// checkAccess enforces the sunset, authentication, role and rate limit
+// requirements declared in the metadata of q and logs calls of deprecated
+// queries. Returns the HTTP status code and message of the rejection,
+// or 0 if the request is allowed.
+func (s *ServerProduction) checkAccess(
+	r *http.Request, q *gqlpq.Query,
+) (status int, msg string) {
+	reqCtx := reqctx.GetRequestContext(r.Context())
+	reject := func(status int, msg string) (int, string) {
+		s.log.Info(
+			"rejected persisted query",
+			slog.String("requestID", reqCtx.RequestID),
+			slog.String("persistedQueryName", q.Key),
+			slog.String("reason", msg),
+		)
+		return status, msg
+	}
+
+	if !q.Metadata.Sunset.IsZero() && !time.Now().Before(q.Metadata.Sunset) {
+		return reject(http.StatusGone, "persisted query sunset")
+	}
+
+	if q.Metadata.AuthRequired && reqCtx.UserID == "" {
+		return reject(http.StatusUnauthorized, "authentication required")
+	}
+
+	if len(q.Metadata.Roles) > 0 {
+		u, err := s.dataProvider.UserByID(r.Context(), reqCtx.UserID)
+		if err != nil || !hasAnyRole(u, q.Metadata.Roles) {
+			return reject(http.StatusForbidden, "role not allowed")
+		}
+	}
+
+	if l := q.Metadata.RateLimit; l.Requests > 0 {
+		client := "user:" + reqCtx.UserID
+		if reqCtx.UserID == "" {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			client = "ip:" + host
+		}
+		if !s.rateLimiter.Allow(
+			q.Key+" "+client, l.Requests, l.Period, time.Now(),
+		) {
+			return reject(http.StatusTooManyRequests, "rate limit exceeded")
+		}
+	}
+
+	if !q.Metadata.Deprecated.IsZero() {
+		s.log.Warn(
+			"called deprecated persisted query",
+			slog.String("requestID", reqCtx.RequestID),
+			slog.String("persistedQueryName", q.Key),
+			slog.String("userID", reqCtx.UserID),
+			slog.String("userAgent", r.UserAgent()),
+		)
+	}
+	return 0, ""
+}
+
+// setDeprecationHeaders sets the Deprecation and Sunset response headers
+// (RFC 9745, RFC 8594) if declared in m.
+func setDeprecationHeaders(h http.Header, m gqlpq.Metadata) {
+	if !m.Deprecated.IsZero() {
+		h.Set("Deprecation", "@"+strconv.FormatInt(m.Deprecated.Unix(), 10))
+	}
+	if !m.Sunset.IsZero() {
+		h.Set("Sunset", m.Sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
 type Mode int8
 
 const (
@@ -109,37 +297,61 @@ const (
 func NewServer(
 	log *slog.Logger,
 	mode Mode,
-	jwtSecret []byte,
+	jwtKeys *jwt.KeySet,
 	dataProvider dataprovider.DataProvider,
 	persistedQueries *gqlpq.PersistedQueries,
+	broadcastOptions broadcast.Options,
+	responseCache *respcache.Cache,
+	operationMetrics *metrics.Metrics,
 ) (http.Handler, error) {
+	passwordHasher := passhash.NewPasswordHasherBcrypt(0)
 	gqlResolver := graph.NewResolver(
 		dataProvider,
-		jwt.NewJWTGenerator(jwtSecret),
-		passhash.NewPasswordHasherBcrypt(0),
+		jwt.NewJWTGenerator(jwtKeys),
+		passwordHasher,
 		new(TimeProviderLive),
+		broadcastOptions,
 	)
+	if responseCache != nil {
+		gqlResolver.OnTaskOrProjectChange(responseCache.Invalidate)
+		gqlResolver.OnAccessChange(responseCache.Invalidate)
+	}
+	operationMetrics.ObserveBroadcasts(gqlResolver.BroadcastStats)
 	conf := graph.Config{Resolvers: gqlResolver}
 
 	srv := handler.NewDefaultServer(graph.NewExecutableSchema(conf))
 	srv.AddTransport(&transport.Websocket{})
 	srv.AroundResponses(newGQLMiddlewareLogResponses(log))
-
-	withContextMiddleware := newMiddlewareSetRequestContext(srv, log, jwtSecret)
+	srv.AroundResponses(newGQLMiddlewareMetrics(operationMetrics))
+	srv.AroundOperations(newGQLMiddlewareSubscriptionMetrics(operationMetrics))
+	srv.AroundOperations(newGQLMiddlewareRequireScopes())
+	srv.AroundOperations(newGQLMiddlewareRequireTwoFactor(dataProvider))
 
 	prodSrv := &ServerProduction{
 		log:              log,
-		gqlHandler:       withContextMiddleware,
+		gqlHandler:       srv,
 		persistedQueries: persistedQueries,
+		dataProvider:     dataProvider,
+		rateLimiter:      ratelimit.New(),
+		responseCache:    responseCache,
+		metrics:          operationMetrics,
+		jwtKeys:          jwtKeys,
+	}
+	revocations := sessionRevocations{dataProvider: dataProvider}
+	apiKeys := apiKeyAuthenticator{
+		dataProvider:   dataProvider,
+		passwordHasher: passwordHasher,
 	}
 	if mode == ModeDebug {
 		play := playground.Handler("GraphQL Playground", "/query")
-		return &ServerDebug{
+		return newMiddlewareSetRequestContext(&ServerDebug{
 			playgroundHandler: play,
 			productionServer:  prodSrv,
-		}, nil
+		}, log, jwtKeys, revocations, apiKeys), nil
 	}
-	return prodSrv, nil
+	return newMiddlewareSetRequestContext(
+		prodSrv, log, jwtKeys, revocations, apiKeys,
+	), nil
 }
 
 func newGQLMiddlewareLogResponses(log *slog.Logger) func(
@@ -176,10 +388,57 @@ func newGQLMiddlewareLogResponses(log *slog.Logger) func(
 	}
 }
 
+// newGQLMiddlewareMetrics records queries and mutations in m
+// once their response is written, including requests
+// with invalid variables.
+func newGQLMiddlewareMetrics(m *metrics.Metrics) graphql.ResponseMiddleware {
+	return func(
+		ctx context.Context, next graphql.ResponseHandler,
+	) *graphql.Response {
+		resp := next(ctx)
+		op := graphql.GetOperationContext(ctx).Operation
+		if op == nil || op.Operation == ast.Subscription {
+			// Subscriptions respond with every event
+			// and are recorded by newGQLMiddlewareSubscriptionMetrics.
+			return resp
+		}
+		reqCtx := reqctx.GetRequestContext(ctx)
+		m.Observe(
+			reqCtx.PersistedQueryName, string(op.Operation),
+			time.Since(reqCtx.Start),
+			resp != nil && len(resp.Errors) > 0, time.Now(),
+		)
+		return resp
+	}
+}
+
+// newGQLMiddlewareSubscriptionMetrics records subscriptions in m
+// once they're established.
+func newGQLMiddlewareSubscriptionMetrics(
+	m *metrics.Metrics,
+) graphql.OperationMiddleware {
+	return func(
+		ctx context.Context, next graphql.OperationHandler,
+	) graphql.ResponseHandler {
+		h := next(ctx)
+		op := graphql.GetOperationContext(ctx).Operation
+		if op.Operation == ast.Subscription {
+			reqCtx := reqctx.GetRequestContext(ctx)
+			m.Observe(
+				reqCtx.PersistedQueryName, string(op.Operation),
+				time.Since(reqCtx.Start), false, time.Now(),
+			)
+		}
+		return h
+	}
+}
+
 func newMiddlewareSetRequestContext(
 	next http.Handler,
 	log *slog.Logger,
-	jwtSecret []byte,
+	jwtKeys *jwt.KeySet,
+	revocations jwt.RevocationStore,
+	apiKeys apiKeyAuthenticator,
 ) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var persistedQueryName string
@@ -187,16 +446,37 @@ func newMiddlewareSetRequestContext(
 			persistedQueryName = s
 		}
 
-		userID, err := jwt.GetUserID(jwtSecret, r, time.Now())
+		var (
+			userID, sessionID string
+			apiKey            *model.APIKey
+			err               error
+		)
+		if key, ok := bearerAPIKey(r); ok {
+			apiKey, err = apiKeys.Authenticate(r.Context(), key, time.Now())
+			if err == nil {
+				userID = apiKey.User.ID
+			}
+		} else {
+			userID, sessionID, err = jwt.GetUserID(
+				jwtKeys, r, time.Now(), revocations,
+			)
+		}
 		switch {
 		case err == nil:
+		case errors.Is(err, errAPIKeyInvalid):
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
 		case errors.Is(err, jwt.ErrTokenInvalid):
 			http.Error(w, "invalid bearer token", http.StatusUnauthorized)
 			return
 		case errors.Is(err, jwt.ErrTokenExpired):
 			http.Error(w, "expired bearer token", http.StatusUnauthorized)
 			return
+		case errors.Is(err, jwt.ErrTokenRevoked):
+			http.Error(w, "revoked bearer token", http.StatusUnauthorized)
+			return
 		default:
+			log.Error("checking bearer token", slog.Any("error", err))
 			http.Error(
 				w,
 				http.StatusText(http.StatusInternalServerError),
@@ -208,10 +488,43 @@ func newMiddlewareSetRequestContext(
 		ctx := reqctx.WithRequestContext(
 			r.Context(), log, userID, persistedQueryName, time.Now(),
 		)
+		reqCtx := reqctx.GetRequestContext(ctx)
+		reqCtx.SessionID = sessionID
+		if apiKey != nil {
+			reqCtx.APIKeyID = apiKey.ID
+			reqCtx.APIKeyScopes = apiKey.Scopes
+		}
+		reqCtx.UserAgent = r.UserAgent()
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// sessionRevocations considers the sessions of access tokens revoked
+// if they were revoked, don't exist or belong to deactivated users.
+type sessionRevocations struct{ dataProvider dataprovider.DataProvider }
+
+var _ jwt.RevocationStore = sessionRevocations{}
+
+func (s sessionRevocations) IsRevoked(
+	ctx context.Context, userID, sessionID string,
+) (bool, error) {
+	session, err := s.dataProvider.SessionByID(ctx, sessionID)
+	switch {
+	case errors.Is(err, dataprovider.ErrNotFound):
+		return true, nil
+	case err != nil:
+		return false, err
+	}
+	if session.Revoked != nil || session.User.ID != userID {
+		return true, nil
+	}
+	user, err := s.dataProvider.UserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.Deactivated != nil, nil
+}
+
 func httpNotFound(w http.ResponseWriter) {
 	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 }
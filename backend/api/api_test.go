@@ -0,0 +1,390 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/romshark/taskhub/api/broadcast"
+	"github.com/romshark/taskhub/api/dataprovider/inmem"
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/graph"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/jwt"
+	"github.com/romshark/taskhub/api/metrics"
+	"github.com/romshark/taskhub/api/passhash"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestServeDeprecated(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"current": "query A { foo }",
+		"legacy/v1/a": "# @deprecated 2024-01-31 Use current.\n" +
+			"# @sunset 2999-01-01\nquery B { foo }",
+		"legacy/v0/a": "# @deprecated 2019-01-01\n" +
+			"# @sunset 2020-01-01\nquery C { foo }",
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r = r.WithContext(reqctx.WithRequestContext(
+			context.Background(), s.log, "", "", time.Now(),
+		))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/e/current")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Deprecation"))
+	require.Empty(t, w.Header().Get("Sunset"))
+
+	w = get("/e/legacy/v1/a")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "@1706659200", w.Header().Get("Deprecation"))
+	require.Equal(t, "Tue, 01 Jan 2999 00:00:00 GMT", w.Header().Get("Sunset"))
+
+	w = get("/e/legacy/v0/a")
+	require.Equal(t, http.StatusGone, w.Code)
+	require.Equal(t, "persisted query sunset\n", w.Body.String())
+
+	w = get("/e/legacy")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestServeRoles makes sure users can't gain the roles required
+// by @roles by changing their own free-text role.
+func TestServeRoles(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"a": "# @roles admin\nquery A { foo }",
+	})
+	dataProvider := &inmem.Inmem{Users: []*model.User{
+		{ID: "admin", Email: "admin@test.com", DisplayName: "Admin", Admin: true},
+		{ID: "u1", Email: "u1@test.com", DisplayName: "User One"},
+	}}
+	s.dataProvider = dataProvider
+	get := func(userID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/e/a", nil)
+		r = r.WithContext(reqctx.WithRequestContext(
+			context.Background(), s.log, userID, "", time.Now(),
+		))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusUnauthorized, get("").Code)
+	require.Equal(t, http.StatusOK, get("admin").Code)
+	require.Equal(t, http.StatusForbidden, get("u1").Code)
+
+	_, err := dataProvider.UpdateUser(
+		reqctx.WithRequestContext(
+			context.Background(), s.log, "u1", "", time.Now(),
+		),
+		"u1", "u1@test.com", "User One", "admin", "", nil, nil, nil,
+	)
+	require.NoError(t, err)
+	w := get("u1")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, "role not allowed\n", w.Body.String())
+}
+
+// TestServeSubscriptionWebsocket makes sure persisted subscriptions
+// can be started over websocket even though the handshake is a GET request.
+func TestServeSubscriptionWebsocket(t *testing.T) {
+	keys := jwt.NewKeySetHS256([]byte("secret"))
+	dataProvider := inmem.NewFake()
+	const userID = "user_cedric_maude"
+	now := time.Now()
+	session, err := dataProvider.CreateSession(
+		context.Background(), now, userID, "", "hash", now.Add(time.Hour),
+	)
+	require.NoError(t, err)
+	token, err := jwt.NewJWTGenerator(keys).GenerateJWT(
+		userID, session.ID, now, time.Minute,
+	)
+	require.NoError(t, err)
+
+	pq, err := gqlpq.New("graph")
+	require.NoError(t, err)
+	require.NoError(t, pq.Load("../persisted_queries"))
+	h, err := NewServer(
+		slog.New(slog.NewTextHandler(io.Discard, nil)), ModeProduction,
+		keys, dataProvider, pq, broadcast.Options{}, nil, metrics.New(),
+	)
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	d := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, resp, err := d.Dial(
+		"ws"+strings.TrimPrefix(srv.URL, "http")+"/e/sub_task_upsert", header,
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	type message struct {
+		ID      string         `json:"id,omitempty"`
+		Type    string         `json:"type"`
+		Payload map[string]any `json:"payload,omitempty"`
+	}
+	read := func() (m message) {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		require.NoError(t, conn.ReadJSON(&m))
+		return m
+	}
+	require.NoError(t, conn.WriteJSON(message{Type: "connection_init"}))
+	require.Equal(t, "connection_ack", read().Type)
+	var query string
+	require.NoError(t, json.Unmarshal(
+		[]byte(pq.Get("sub_task_upsert").Encoded), &query,
+	))
+	require.NoError(t, conn.WriteJSON(message{
+		ID: "1", Type: "subscribe", Payload: map[string]any{"query": query},
+	}))
+
+	// The subscription is started asynchronously, keep creating
+	// tasks until the first event is received.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		body := `{"title":"Task","projectID":"` +
+			dataProvider.Projects[0].ID + `"}`
+		for {
+			r, _ := http.NewRequest(
+				http.MethodPost, srv.URL+"/e/mut_create_task",
+				strings.NewReader(body),
+			)
+			r.Header.Set("Authorization", "Bearer "+token)
+			r.Header.Set("Content-Type", "application/json")
+			if resp, err := http.DefaultClient.Do(r); err == nil {
+				resp.Body.Close()
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}()
+
+	m := read()
+	require.Equal(t, "next", m.Type, "payload: %v", m.Payload)
+	require.Equal(t, "1", m.ID)
+	upsert := m.Payload["data"].(map[string]any)["taskUpsert"]
+	require.Equal(t, "Task", upsert.(map[string]any)["title"])
+}
+
+func TestMiddlewareSetRequestContext(t *testing.T) {
+	keys := jwt.NewKeySetHS256([]byte("secret"))
+	dataProvider := inmem.NewFake()
+	const userID = "user_ryan_lindsey"
+	now := time.Now()
+	session, err := dataProvider.CreateSession(
+		context.Background(), now, userID, "", "hash", now.Add(time.Hour),
+	)
+	require.NoError(t, err)
+	token, err := jwt.NewJWTGenerator(keys).GenerateJWT(
+		userID, session.ID, now, time.Minute,
+	)
+	require.NoError(t, err)
+
+	var got *reqctx.RequestContext
+	h := newMiddlewareSetRequestContext(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = reqctx.GetRequestContext(r.Context())
+		}),
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		keys, sessionRevocations{dataProvider: dataProvider},
+		apiKeyAuthenticator{dataProvider: dataProvider},
+	)
+	serve := func() *httptest.ResponseRecorder {
+		got = nil
+		r := httptest.NewRequest(http.MethodPost, "/e/qry_user", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		r.Header.Set("User-Agent", "test-agent")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve()
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, userID, got.UserID)
+	require.Equal(t, session.ID, got.SessionID)
+	require.Equal(t, "test-agent", got.UserAgent)
+	require.Equal(t, "qry_user", got.PersistedQueryName)
+
+	ctx := reqctx.WithRequestContext(
+		context.Background(), slog.Default(), userID, "", now,
+	)
+	_, err = dataProvider.RevokeSession(ctx, session.ID, now)
+	require.NoError(t, err)
+
+	w = serve()
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, "revoked bearer token\n", w.Body.String())
+	require.Nil(t, got)
+}
+
+func TestMiddlewareSetRequestContextAPIKey(t *testing.T) {
+	dataProvider := inmem.NewFake()
+	hasher := passhash.NewPasswordHasherBcrypt(0)
+	const userID = "user_ryan_lindsey"
+	now := time.Now()
+	ctx := reqctx.WithRequestContext(
+		context.Background(), slog.Default(), userID, "", now,
+	)
+	hash, err := hasher.HashPassword([]byte("secret"))
+	require.NoError(t, err)
+	k, err := dataProvider.CreateAPIKey(
+		ctx, now, userID, "CI", []model.APIKeyScope{
+			model.APIKeyScopeReadTasks,
+		}, hash,
+	)
+	require.NoError(t, err)
+
+	var got *reqctx.RequestContext
+	h := newMiddlewareSetRequestContext(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = reqctx.GetRequestContext(r.Context())
+		}),
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		jwt.NewKeySetHS256([]byte("secret")),
+		sessionRevocations{dataProvider: dataProvider},
+		apiKeyAuthenticator{
+			dataProvider:   dataProvider,
+			passwordHasher: hasher,
+		},
+	)
+	serve := func(key string) *httptest.ResponseRecorder {
+		got = nil
+		r := httptest.NewRequest(http.MethodPost, "/e/qry_user", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(graph.APIKeyPrefix + k.ID + ".secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, userID, got.UserID)
+	require.Equal(t, k.ID, got.APIKeyID)
+	require.Equal(t, []model.APIKeyScope{
+		model.APIKeyScopeReadTasks,
+	}, got.APIKeyScopes)
+	require.Empty(t, got.SessionID)
+
+	k, err = dataProvider.APIKeyByID(ctx, k.ID)
+	require.NoError(t, err)
+	require.NotNil(t, k.LastUsed)
+
+	for _, key := range []string{
+		graph.APIKeyPrefix + k.ID + ".wrong",
+		graph.APIKeyPrefix + "apikey_unknown.secret",
+		graph.APIKeyPrefix + k.ID,
+	} {
+		w = serve(key)
+		require.Equal(t, http.StatusUnauthorized, w.Code, key)
+		require.Equal(t, "invalid API key\n", w.Body.String())
+		require.Nil(t, got)
+	}
+
+	_, err = dataProvider.RevokeAPIKey(ctx, k.ID, now)
+	require.NoError(t, err)
+	w = serve(graph.APIKeyPrefix + k.ID + ".secret")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Nil(t, got)
+}
+
+// TestServeAPIKeyAdminScope makes sure API keys with only the ADMIN scope
+// can manage API keys but can't execute other mutations.
+func TestServeAPIKeyAdminScope(t *testing.T) {
+	keys := jwt.NewKeySetHS256([]byte("secret"))
+	dataProvider := inmem.NewFake()
+	hasher := passhash.NewPasswordHasherBcrypt(0)
+	const userID = "user_cedric_maude"
+	now := time.Now()
+	ctx := reqctx.WithRequestContext(
+		context.Background(), slog.Default(), userID, "", now,
+	)
+	hash, err := hasher.HashPassword([]byte("secret"))
+	require.NoError(t, err)
+	k, err := dataProvider.CreateAPIKey(
+		ctx, now, userID, "Admin", []model.APIKeyScope{
+			model.APIKeyScopeAdmin,
+		}, hash,
+	)
+	require.NoError(t, err)
+	other, err := dataProvider.CreateAPIKey(
+		ctx, now, userID, "CI", []model.APIKeyScope{
+			model.APIKeyScopeReadTasks,
+		}, hash,
+	)
+	require.NoError(t, err)
+
+	pq, err := gqlpq.New("graph")
+	require.NoError(t, err)
+	require.NoError(t, pq.Load("../persisted_queries"))
+	h, err := NewServer(
+		slog.New(slog.NewTextHandler(io.Discard, nil)), ModeProduction,
+		keys, dataProvider, pq, broadcast.Options{}, nil, metrics.New(),
+	)
+	require.NoError(t, err)
+
+	for _, td := range []struct {
+		name        string
+		body        string
+		expectError string
+	}{
+		{
+			name:        "mut_create_api_key",
+			body:        `{"name":"Bot","scopes":["READ_TASKS"]}`,
+			expectError: "",
+		},
+		{
+			name:        "mut_revoke_api_key",
+			body:        `{"id":"` + other.ID + `"}`,
+			expectError: "",
+		},
+		{
+			name: "mut_create_task",
+			body: `{"title":"Task","projectID":"` +
+				dataProvider.Projects[0].ID + `"}`,
+			expectError: "API key lacks scope WRITE_TASKS",
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			r := httptest.NewRequest(
+				http.MethodPost, "/e/"+td.name, strings.NewReader(td.body),
+			)
+			r.Header.Set("Authorization", "Bearer "+
+				graph.APIKeyPrefix+k.ID+".secret")
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp struct {
+				Errors []struct{ Message string } `json:"errors"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			if td.expectError == "" {
+				require.Empty(t, resp.Errors)
+				return
+			}
+			require.Len(t, resp.Errors, 1)
+			require.Equal(t, td.expectError, resp.Errors[0].Message)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph"
+	"github.com/romshark/taskhub/api/graph/model"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// errAPIKeyInvalid is returned for API keys that don't exist, were revoked,
+// belong to deactivated users or have a secret that doesn't match.
+var errAPIKeyInvalid = errors.New("API key invalid")
+
+// apiKeyAuthenticator authenticates clients by API keys.
+type apiKeyAuthenticator struct {
+	dataProvider   dataprovider.DataProvider
+	passwordHasher graph.PasswordHasher
+}
+
+// bearerAPIKey returns the API key in the Authorization header of r.
+// Returns ok=false if r has no such header or its bearer token
+// isn't an API key.
+func bearerAPIKey(r *http.Request) (key string, ok bool) {
+	key, ok = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return key, ok && strings.HasPrefix(key, graph.APIKeyPrefix)
+}
+
+// Authenticate returns the API key identified by key and updates
+// its last use time if it's older than graph.APIKeyLastUsedResolution.
+// Returns errAPIKeyInvalid if the key isn't valid.
+func (a apiKeyAuthenticator) Authenticate(
+	ctx context.Context, key string, now time.Time,
+) (*model.APIKey, error) {
+	id, secret, ok := graph.ParseAPIKey(key)
+	if !ok {
+		return nil, errAPIKeyInvalid
+	}
+	k, err := a.dataProvider.APIKeyByID(ctx, id)
+	switch {
+	case errors.Is(err, dataprovider.ErrNotFound):
+		return nil, errAPIKeyInvalid
+	case err != nil:
+		return nil, err
+	}
+	if k.Revoked != nil {
+		return nil, errAPIKeyInvalid
+	}
+	ok, _ = a.passwordHasher.ComparePassword(
+		[]byte(secret), []byte(k.SecretHash),
+	)
+	if !ok {
+		return nil, errAPIKeyInvalid
+	}
+	user, err := a.dataProvider.UserByID(ctx, k.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Deactivated != nil {
+		return nil, errAPIKeyInvalid
+	}
+
+	if k.LastUsed == nil || now.Sub(*k.LastUsed) >= graph.APIKeyLastUsedResolution {
+		if err := a.dataProvider.MarkAPIKeyUsed(ctx, id, now); err != nil {
+			return nil, fmt.Errorf("marking API key used: %w", err)
+		}
+	}
+	return k, nil
+}
+
+// adminScopeFields are the mutation fields requiring the ADMIN scope
+// instead of the WRITE_TASKS scope, which is checked by their resolvers.
+var adminScopeFields = []string{
+	"revokeSession",
+	"revokeAllSessions",
+	"createApiKey",
+	"revokeApiKey",
+	"deactivateUser",
+}
+
+// newGQLMiddlewareRequireScopes rejects queries and subscriptions
+// of clients authenticated by API keys without the READ_TASKS scope
+// and mutations of API keys without the WRITE_TASKS scope,
+// except for mutations selecting only adminScopeFields,
+// which require the ADMIN scope.
+func newGQLMiddlewareRequireScopes() graphql.OperationMiddleware {
+	return func(
+		ctx context.Context, next graphql.OperationHandler,
+	) graphql.ResponseHandler {
+		scope := model.APIKeyScopeReadTasks
+		op := graphql.GetOperationContext(ctx).Operation
+		if op != nil && op.Operation == ast.Mutation {
+			scope = model.APIKeyScopeWriteTasks
+			if selectsOnly(ctx, adminScopeFields) {
+				scope = model.APIKeyScopeAdmin
+			}
+		}
+		if auth.HasScope(ctx, scope) {
+			return next(ctx)
+		}
+		return graphql.OneShot(graphql.ErrorResponse(
+			ctx, "API key lacks scope %s", scope,
+		))
+	}
+}
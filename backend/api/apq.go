@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/reqctx"
+	"golang.org/x/exp/slog"
+)
+
+// Error codes of the Automatic Persisted Queries protocol.
+const (
+	errAPQNotFound     = "PersistedQueryNotFound"
+	errAPQNotFoundCode = "PERSISTED_QUERY_NOT_FOUND"
+)
+
+// apqExtension is the extensions.persistedQuery object
+// of the Automatic Persisted Queries protocol.
+type apqExtension struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+// apqRequest is a GraphQL request using the Automatic Persisted Queries protocol.
+type apqRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName"`
+	Variables     json.RawMessage `json:"variables"`
+	Extensions    struct {
+		PersistedQuery *apqExtension `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// serveAPQ handles the Automatic Persisted Queries protocol on /query.
+// Only hashes of allowlisted persisted queries are accepted,
+// a query provided by the client is never executed,
+// the allowlisted query is executed instead.
+func (s *ServerProduction) serveAPQ(w http.ResponseWriter, r *http.Request) {
+	var req apqRequest
+	var bodySize int64
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			req.Variables = json.RawMessage(v)
+		}
+		if v := q.Get("extensions"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Extensions); err != nil {
+				http.Error(w, "invalid extensions JSON", http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			var errMaxBytes *http.MaxBytesError
+			if errors.As(err, &errMaxBytes) {
+				http.Error(
+					w, "request body too large",
+					http.StatusRequestEntityTooLarge,
+				)
+				return
+			}
+			s.log.Info("reading request body", slog.Any("error", err))
+			return
+		}
+		r.Body.Close()
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, "invalid request JSON", http.StatusBadRequest)
+			return
+		}
+		bodySize = int64(len(body))
+	default:
+		http.Error(
+			w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed,
+		)
+		return
+	}
+
+	if req.Variables != nil {
+		if !json.Valid(req.Variables) {
+			http.Error(w, "invalid variables JSON", http.StatusBadRequest)
+			return
+		}
+		req.Variables = bytes.TrimLeft(req.Variables, " \n\r\t")
+		if req.Variables[0] != '{' && !bytes.Equal(req.Variables, []byte("null")) {
+			http.Error(
+				w, "variables must be an object", http.StatusBadRequest,
+			)
+			return
+		}
+	}
+
+	ext := req.Extensions.PersistedQuery
+	if ext == nil {
+		http.Error(
+			w, "only persisted queries are allowed", http.StatusForbidden,
+		)
+		return
+	}
+	if ext.Version != 1 {
+		http.Error(
+			w, "unsupported persisted query version", http.StatusBadRequest,
+		)
+		return
+	}
+	if req.Query != "" &&
+		!strings.EqualFold(gqlpq.Hash(req.Query), ext.SHA256Hash) {
+		http.Error(
+			w, "provided sha256Hash does not match query",
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	q := s.persistedQueries.GetByHash(ext.SHA256Hash)
+	if q == nil {
+		s.log.Info(
+			"rejected persisted query hash",
+			slog.String("sha256Hash", ext.SHA256Hash),
+		)
+		writeGQLError(w, errAPQNotFound, errAPQNotFoundCode)
+		return
+	}
+
+	reqctx.GetRequestContext(r.Context()).PersistedQueryName = q.Key
+	if !s.authorize(w, r, q) {
+		return
+	}
+	if q.Metadata.MaxBodySize > 0 && bodySize > q.Metadata.MaxBodySize {
+		http.Error(
+			w, "request body too large", http.StatusRequestEntityTooLarge,
+		)
+		return
+	}
+
+	query := q.Encoded
+	if r.Method == http.MethodGet {
+		// Keep the GET method to prevent mutations over GET.
+		var queryText string
+		if err := json.Unmarshal([]byte(query), &queryText); err != nil {
+			panic(err) // Queries are encoded by gqlpq
+		}
+		params := url.Values{"query": {queryText}}
+		if req.OperationName != "" {
+			params.Set("operationName", req.OperationName)
+		}
+		if req.Variables != nil {
+			params.Set("variables", string(req.Variables))
+		}
+		r.URL.RawQuery = params.Encode()
+	} else {
+		r.Body = makeAPQQuery(query, req.OperationName, req.Variables)
+		r.ContentLength = -1
+		r.Header.Set("Content-Type", "application/json")
+	}
+	s.gqlHandler.ServeHTTP(w, r)
+}
+
+func makeAPQQuery(
+	query, operationName string, variablesJSON []byte,
+) io.ReadCloser {
+	b := new(bytes.Buffer)
+	b.WriteString(`{"query":`)
+	b.WriteString(query)
+	if operationName != "" {
+		n, _ := json.Marshal(operationName)
+		b.WriteString(`,"operationName":`)
+		b.Write(n)
+	}
+	if variablesJSON != nil {
+		b.WriteString(`,"variables":`)
+		b.Write(variablesJSON)
+	}
+	b.WriteString(`}`)
+	return io.NopCloser(b)
+}
+
+// writeGQLError writes a GraphQL response containing a single error.
+func writeGQLError(w http.ResponseWriter, message, code string) {
+	type gqlError struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	}
+	var resp struct {
+		Errors []gqlError `json:"errors"`
+	}
+	e := gqlError{Message: message}
+	e.Extensions.Code = code
+	resp.Errors = append(resp.Errors, e)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeBodyTooLarge(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"a": "query A($x: Int) { foo(x: $x) }",
+	})
+	body := `{"variables":{"x":"` + strings.Repeat("x", maxBodySize) + `"}}`
+
+	for _, path := range []string{"/query", "/e/a"} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r = r.WithContext(reqctx.WithRequestContext(
+			context.Background(), s.log, "", "", time.Now(),
+		))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		require.Equal(t, http.StatusRequestEntityTooLarge, w.Code, path)
+		require.Equal(t, "request body too large\n", w.Body.String(), path)
+	}
+}
+
+func TestServeAPQ(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"a":      "query A($x: Int) { foo(x: $x) }",
+		"authed": "# @auth\nquery B { foo }",
+	})
+	var executed string
+	h := s.gqlHandler
+	s.gqlHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		executed = r.URL.Query().Get("query")
+		h.ServeHTTP(w, r)
+	})
+	hashA := s.persistedQueries.Get("a").Hash
+	hashAuthed := s.persistedQueries.Get("authed").Hash
+	extension := func(version int, hash string) string {
+		return `{"persistedQuery":{"version":` + strconv.Itoa(version) +
+			`,"sha256Hash":"` + hash + `"}}`
+	}
+
+	for _, td := range []struct {
+		name         string
+		method       string
+		params       url.Values
+		body         string
+		userID       string
+		expectStatus int
+		expectBody   string
+		expectQuery  string
+	}{
+		{
+			name:         "missing_extension",
+			method:       http.MethodPost,
+			body:         `{"query":"query A($x: Int) { foo(x: $x) }"}`,
+			expectStatus: http.StatusForbidden,
+			expectBody:   "only persisted queries are allowed\n",
+		},
+		{
+			name:         "unsupported_version",
+			method:       http.MethodPost,
+			body:         `{"extensions":` + extension(2, hashA) + `}`,
+			expectStatus: http.StatusBadRequest,
+			expectBody:   "unsupported persisted query version\n",
+		},
+		{
+			name:   "hash_mismatch",
+			method: http.MethodPost,
+			body: `{"query":"query X { foo }","extensions":` +
+				extension(1, hashA) + `}`,
+			expectStatus: http.StatusBadRequest,
+			expectBody:   "provided sha256Hash does not match query\n",
+		},
+		{
+			name:   "unknown_hash",
+			method: http.MethodPost,
+			body: `{"extensions":` +
+				extension(1, gqlpq.Hash("query X { foo }")) + `}`,
+			expectStatus: http.StatusOK,
+			expectBody: `{"errors":[{"message":"PersistedQueryNotFound",` +
+				`"extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}` + "\n",
+		},
+		{
+			name:   "get_hash_mismatch",
+			method: http.MethodGet,
+			params: url.Values{
+				"query":      {"query X { foo }"},
+				"extensions": {extension(1, hashA)},
+				"variables":  {`{"x":1}`},
+			},
+			expectStatus: http.StatusBadRequest,
+			expectBody:   "provided sha256Hash does not match query\n",
+		},
+		{
+			name:   "get_known_hash",
+			method: http.MethodGet,
+			params: url.Values{
+				"extensions": {extension(1, hashA)},
+				"variables":  {`{"x":1}`},
+			},
+			expectStatus: http.StatusOK,
+			expectBody:   `{"data":{"body":"","name":"a"}}`,
+			expectQuery:  "query A($x: Int) { foo(x: $x) }",
+		},
+		{
+			name:         "auth_required",
+			method:       http.MethodPost,
+			body:         `{"extensions":` + extension(1, hashAuthed) + `}`,
+			expectStatus: http.StatusUnauthorized,
+			expectBody:   "authentication required\n",
+		},
+		{
+			name:         "auth",
+			method:       http.MethodPost,
+			body:         `{"extensions":` + extension(1, hashAuthed) + `}`,
+			userID:       "u1",
+			expectStatus: http.StatusOK,
+			expectBody: `{"data":{"body":"{\"query\":` +
+				`\"# @auth\\nquery B { foo }\"}","name":"authed"}}`,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			executed = ""
+			r := httptest.NewRequest(
+				td.method, "/query?"+td.params.Encode(),
+				strings.NewReader(td.body),
+			)
+			r = r.WithContext(reqctx.WithRequestContext(
+				context.Background(), s.log, td.userID, "", time.Now(),
+			))
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			require.Equal(t, td.expectStatus, w.Code)
+			require.Equal(t, td.expectBody, w.Body.String())
+			require.Equal(t, td.expectQuery, executed)
+		})
+	}
+}
@@ -3,8 +3,11 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 
+	"github.com/romshark/taskhub/api/graph/model"
 	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/slices"
 )
 
 // RequireAuthenticated returns nil if the client is authenticated,
@@ -30,6 +33,39 @@ func RequireOwner(ctx context.Context, ownerID string) error {
 	return nil
 }
 
+// RequireAnyOwner returns nil if the client is authenticated and
+// is any of the owners of the resource, otherwise returns
+// ErrUnauthenticated or ErrUnauthorized respectively.
+func RequireAnyOwner(ctx context.Context, ownerIDs ...string) error {
+	c := reqctx.GetRequestContext(ctx)
+	if c.UserID == "" {
+		return ErrUnauthenticated
+	}
+	for _, id := range ownerIDs {
+		if c.UserID == id {
+			return nil
+		}
+	}
+	return ErrUnauthorized
+}
+
+// HasScope returns true if the client isn't authenticated by an API key
+// or if its API key has scope s.
+func HasScope(ctx context.Context, s model.APIKeyScope) bool {
+	c := reqctx.GetRequestContext(ctx)
+	return c.APIKeyID == "" || slices.Contains(c.APIKeyScopes, s)
+}
+
+// RequireScope returns nil if the client isn't authenticated by an API key
+// or if its API key has scope s, otherwise returns an error
+// wrapping ErrUnauthorized.
+func RequireScope(ctx context.Context, s model.APIKeyScope) error {
+	if !HasScope(ctx, s) {
+		return fmt.Errorf("%w: API key lacks scope %s", ErrUnauthorized, s)
+	}
+	return nil
+}
+
 var (
 	ErrUnauthenticated = errors.New("unauthenticated")
 	ErrUnauthorized    = errors.New("unauthorized")
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/vektah/gqlparser/v2/ast"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// maxBatchSize is the maximum number of operations per batch.
+	maxBatchSize = 16
+
+	// maxBatchBodySize is the maximum size of a batch request body in bytes.
+	maxBatchBodySize = 1 << 20
+)
+
+// bodyHeaders are the headers describing the body of the batch request
+// which don't apply to the requests of its operations.
+var bodyHeaders = []string{
+	"Content-Length", "Content-Encoding", "Content-Type", "Content-MD5", "Digest",
+}
+
+// batchEntry is an operation of a batch request.
+type batchEntry struct {
+	Name      string          `json:"name"`
+	Variables json.RawMessage `json:"variables"`
+}
+
+// batchResult is the result of a batchEntry.
+type batchResult struct {
+	Name string `json:"name"`
+
+	// Status is the HTTP status code the operation
+	// would have been responded to with if requested individually.
+	Status int `json:"status"`
+
+	// Error is set if the operation wasn't executed.
+	Error string `json:"error,omitempty"`
+
+	// Response is the GraphQL response if the operation was executed.
+	Response json.RawMessage `json:"response,omitempty"`
+
+	// Deprecation and Sunset are the values of the Deprecation and Sunset
+	// headers the operation would have been responded to with
+	// if requested individually.
+	Deprecation string `json:"deprecation,omitempty"`
+	Sunset      string `json:"sunset,omitempty"`
+}
+
+// serveBatch executes a JSON array of persisted queries sent to POST /e
+// and responds with a JSON array of results in the same order.
+// Every operation is subject to the same requirements as an individual
+// request and a rejected operation doesn't affect the others.
+// Operations are executed sequentially unless the parallel query
+// parameter is true. Subscriptions can't be batched.
+func (s *ServerProduction) serveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(
+			w, http.StatusText(http.StatusMethodNotAllowed),
+			http.StatusMethodNotAllowed,
+		)
+		return
+	}
+	var parallel bool
+	if v := r.URL.Query().Get("parallel"); v != "" {
+		var err error
+		if parallel, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid parallel parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBodySize))
+	if err != nil {
+		var errMaxBytes *http.MaxBytesError
+		if errors.As(err, &errMaxBytes) {
+			http.Error(
+				w, "request body too large", http.StatusRequestEntityTooLarge,
+			)
+			return
+		}
+		s.log.Info("reading request body", slog.Any("error", err))
+		return
+	}
+	r.Body.Close()
+
+	var entries []batchEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		http.Error(
+			w, "body must contain an array of operations", http.StatusBadRequest,
+		)
+		return
+	}
+	if len(entries) < 1 || len(entries) > maxBatchSize {
+		http.Error(
+			w, "batch must contain 1 to "+strconv.Itoa(maxBatchSize)+" operations",
+			http.StatusBadRequest,
+		)
+		return
+	}
+
+	results := make([]batchResult, len(entries))
+	if parallel {
+		var wg sync.WaitGroup
+		wg.Add(len(entries))
+		for i := range entries {
+			go func(i int) {
+				defer wg.Done()
+				results[i] = s.executeBatchEntry(r, entries[i])
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range entries {
+			results[i] = s.executeBatchEntry(r, entries[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		s.log.Info("writing batch response", slog.Any("error", err))
+	}
+}
+
+func (s *ServerProduction) executeBatchEntry(
+	r *http.Request, e batchEntry,
+) batchResult {
+	res := batchResult{Name: e.Name}
+	fail := func(status int, msg string) batchResult {
+		res.Status, res.Error = status, msg
+		return res
+	}
+
+	q := s.persistedQueries.Get(e.Name)
+	if q == nil {
+		return fail(http.StatusNotFound, http.StatusText(http.StatusNotFound))
+	}
+	if q.Operation == ast.Subscription {
+		return fail(http.StatusBadRequest, "subscriptions can't be batched")
+	}
+
+	variables := bytes.TrimSpace(e.Variables)
+	if bytes.Equal(variables, []byte("null")) {
+		variables = nil
+	}
+	if len(variables) > 0 && variables[0] != '{' {
+		return fail(http.StatusBadRequest, "variables must be an object")
+	}
+	if q.Metadata.MaxBodySize > 0 &&
+		int64(len(variables)) > q.Metadata.MaxBodySize {
+		return fail(http.StatusRequestEntityTooLarge, "request body too large")
+	}
+
+	req, err := http.NewRequestWithContext(
+		reqctx.WithPersistedQueryName(r.Context(), q.Key),
+		http.MethodPost, "/e/"+q.Key, nil,
+	)
+	if err != nil {
+		s.log.Error("creating batch entry request", slog.Any("error", err))
+		return fail(
+			http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError),
+		)
+	}
+	req.RemoteAddr = r.RemoteAddr
+	req.Header = r.Header.Clone()
+	for _, h := range bodyHeaders {
+		req.Header.Del(h)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if status, msg := s.checkAccess(req, q); status != 0 {
+		return fail(status, msg)
+	}
+	h := http.Header{}
+	setDeprecationHeaders(h, q.Metadata)
+	res.Deprecation, res.Sunset = h.Get("Deprecation"), h.Get("Sunset")
+
+	if len(variables) > 0 {
+		req.Body = makeQueryWithVars(q.Encoded, variables)
+	} else {
+		req.Body = makeQuery(q.Encoded)
+	}
+	b := s.execute(req, q, variables)
+	res.Status, res.Response = b.status, b.body.Bytes()
+	return res
+}
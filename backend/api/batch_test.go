@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/ratelimit"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+// newTestServer creates a production server with the given persisted
+// queries and a GraphQL handler echoing the persisted query name
+// and the request body.
+func newTestServer(t *testing.T, queries map[string]string) *ServerProduction {
+	t.Helper()
+	schemaDir, dir := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string) {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	write(schemaDir, "schema.graphqls", `
+		type Query { foo(x: Int): Int! }
+		type Subscription { foo: Int! }
+	`)
+	for n, q := range queries {
+		write(dir, n+".graphql", q)
+	}
+	pq, err := gqlpq.New(schemaDir)
+	require.NoError(t, err)
+	require.NoError(t, pq.Load(dir))
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &ServerProduction{
+		log: log,
+		gqlHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			resp, err := json.Marshal(map[string]any{"data": map[string]any{
+				"name": reqctx.GetRequestContext(r.Context()).PersistedQueryName,
+				"body": string(body),
+			}})
+			require.NoError(t, err)
+			_, _ = w.Write(resp)
+		}),
+		persistedQueries: pq,
+		rateLimiter:      ratelimit.New(),
+	}
+}
+
+func TestServeBatch(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"a":      "query A($x: Int) { foo(x: $x) }",
+		"authed": "# @auth\nquery B { foo }",
+		"small":  "# @maxBodySize 8\nquery C($x: Int) { foo(x: $x) }",
+		"sub":    "subscription { foo }",
+	})
+
+	for _, parallel := range []string{"", "?parallel=true"} {
+		t.Run("parallel="+parallel, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/e"+parallel, strings.NewReader(`[
+				{"name": "a", "variables": {"x": 1}},
+				{"name": "a"},
+				{"name": "authed"},
+				{"name": "small", "variables": {"x": 123456789}},
+				{"name": "sub"},
+				{"name": "missing"},
+				{"name": "a", "variables": [1]}
+			]`))
+			r = r.WithContext(reqctx.WithRequestContext(
+				context.Background(), s.log, "", "", time.Now(),
+			))
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var results []batchResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+			for i := range results {
+				results[i].Response = nil
+			}
+			require.Equal(t, []batchResult{
+				{Name: "a", Status: http.StatusOK},
+				{Name: "a", Status: http.StatusOK},
+				{
+					Name: "authed", Status: http.StatusUnauthorized,
+					Error: "authentication required",
+				},
+				{
+					Name: "small", Status: http.StatusRequestEntityTooLarge,
+					Error: "request body too large",
+				},
+				{
+					Name: "sub", Status: http.StatusBadRequest,
+					Error: "subscriptions can't be batched",
+				},
+				{Name: "missing", Status: http.StatusNotFound, Error: "Not Found"},
+				{
+					Name: "a", Status: http.StatusBadRequest,
+					Error: "variables must be an object",
+				},
+			}, results)
+
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+			require.JSONEq(t, `{"data":{
+				"name": "a",
+				"body": "{\"query\":\"query A($x: Int) { foo(x: $x) }\",\"variables\":{\"x\": 1}}"
+			}}`, string(results[0].Response))
+		})
+	}
+}
+
+func TestServeBatchInvalid(t *testing.T) {
+	s := newTestServer(t, map[string]string{"a": "query { foo }"})
+	for _, td := range []struct {
+		name   string
+		method string
+		body   string
+		expect int
+	}{
+		{"method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"object", http.MethodPost, `{"name":"a"}`, http.StatusBadRequest},
+		{"empty", http.MethodPost, `[]`, http.StatusBadRequest},
+		{
+			"too_many", http.MethodPost,
+			"[" + strings.Repeat(`{"name":"a"},`, maxBatchSize) + `{"name":"a"}]`,
+			http.StatusBadRequest,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			r := httptest.NewRequest(td.method, "/e", strings.NewReader(td.body))
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, r)
+			require.Equal(t, td.expect, w.Code)
+		})
+	}
+}
+
+// TestServeBatchDeprecated makes sure batched calls of deprecated queries
+// are logged with the user agent of the client and report the deprecation.
+func TestServeBatchDeprecated(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"current": "query A { foo }",
+		"legacy/v1/a": "# @deprecated 2024-01-31 Use current.\n" +
+			"# @sunset 2999-01-01\nquery B { foo }",
+	})
+	var logs bytes.Buffer
+	s.log = slog.New(slog.NewTextHandler(&logs, nil))
+	var userAgents []string
+	h := s.gqlHandler
+	s.gqlHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		require.Empty(t, r.Header.Get("Content-Encoding"))
+		h.ServeHTTP(w, r)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/e", strings.NewReader(
+		`[{"name": "current"}, {"name": "legacy/v1/a"}]`,
+	))
+	r.Header.Set("User-Agent", "test-agent")
+	r.Header.Set("Content-Encoding", "identity")
+	r = r.WithContext(reqctx.WithRequestContext(
+		context.Background(), s.log, "", "", time.Now(),
+	))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, []string{"test-agent", "test-agent"}, userAgents)
+	require.Contains(t, logs.String(), "called deprecated persisted query")
+	require.Contains(t, logs.String(), "userAgent=test-agent")
+
+	var results []batchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	for i := range results {
+		results[i].Response = nil
+	}
+	require.Equal(t, []batchResult{
+		{Name: "current", Status: http.StatusOK},
+		{
+			Name: "legacy/v1/a", Status: http.StatusOK,
+			Deprecation: "@1706659200",
+			Sunset:      "Tue, 01 Jan 2999 00:00:00 GMT",
+		},
+	}, results)
+}
@@ -2,19 +2,107 @@ package broadcast
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
+// DefaultQueueSize is the per-subscriber queue capacity used
+// when Options.QueueSize is zero.
+const DefaultQueueSize = 64
+
+// Policy defines what happens when a notification arrives
+// while a subscriber's queue is full.
+type Policy int8
+
+const (
+	// PolicyDropOldest discards the oldest queued notification
+	// to make room for the new one.
+	PolicyDropOldest Policy = 0
+
+	// PolicyDropNewest discards the new notification.
+	PolicyDropNewest Policy = 1
+
+	// PolicyDisconnect discards the new notification and closes the
+	// subscription, the subscriber is expected to resubscribe.
+	PolicyDisconnect Policy = 2
+)
+
+func (p Policy) String() string {
+	switch p {
+	case PolicyDropOldest:
+		return "drop_oldest"
+	case PolicyDropNewest:
+		return "drop_newest"
+	case PolicyDisconnect:
+		return "disconnect"
+	}
+	return fmt.Sprintf("Policy(%d)", p)
+}
+
+// Options configures a Broadcast.
+type Options struct {
+	// QueueSize is the number of notifications buffered per subscriber.
+	// Defaults to DefaultQueueSize if zero.
+	QueueSize int
+
+	// Policy applies when a subscriber's queue is full.
+	Policy Policy
+}
+
+// Stats is a snapshot of the metrics of a Broadcast.
+type Stats struct {
+	// Subscribers is the number of active subscriptions.
+	Subscribers int
+
+	// Delivered is the total number of notifications
+	// received by subscribers.
+	Delivered uint64
+
+	// Dropped is the total number of notifications
+	// discarded due to full subscriber queues.
+	Dropped uint64
+
+	// Disconnected is the total number of subscriptions
+	// closed by PolicyDisconnect.
+	Disconnected uint64
+}
+
 // Broadcast allows for concurrent subscriber notification.
+// Every subscriber is given a bounded queue drained by its own goroutine,
+// so a slow subscriber never delays the delivery to others.
 type Broadcast[T any] struct {
+	queueSize int
+	policy    Policy
+
 	lock          sync.RWMutex
 	idCounter     uint64
-	subscriptions map[uint64]chan<- T
+	subscriptions map[uint64]*subscriber[T]
+	observers     []func(T)
+
+	delivered    atomic.Uint64
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
 }
 
-func New[T any]() *Broadcast[T] {
+// New creates a new broadcast.
+// Panics if opts.QueueSize is negative or opts.Policy is unknown.
+func New[T any](opts Options) *Broadcast[T] {
+	if opts.QueueSize < 0 {
+		panic(fmt.Errorf("negative queue size: %d", opts.QueueSize))
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	switch opts.Policy {
+	case PolicyDropOldest, PolicyDropNewest, PolicyDisconnect:
+	default:
+		panic(fmt.Errorf("unknown policy: %d", opts.Policy))
+	}
 	return &Broadcast[T]{
-		subscriptions: make(map[uint64]chan<- T),
+		queueSize:     opts.QueueSize,
+		policy:        opts.Policy,
+		subscriptions: make(map[uint64]*subscriber[T]),
 	}
 }
 
@@ -25,34 +113,163 @@ func (s *Broadcast[T]) Len() int {
 	return len(s.subscriptions)
 }
 
-// Notify sends t to all subscription channels in a blocking manner.
+// Stats returns the current metrics.
+func (s *Broadcast[T]) Stats() Stats {
+	return Stats{
+		Subscribers:  s.Len(),
+		Delivered:    s.delivered.Load(),
+		Dropped:      s.dropped.Load(),
+		Disconnected: s.disconnected.Load(),
+	}
+}
+
+// Notify calls all observers and enqueues t for all subscribers
+// without blocking. Subscribers with a full queue are handled
+// according to the policy.
 // Notify acquires a shared lock and is therefore safe to be called concurrently.
 func (s *Broadcast[T]) Notify(ctx context.Context, t T) error {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
-	for _, s := range s.subscriptions {
-		s <- t
+	for _, fn := range s.observers {
+		fn(t)
+	}
+	for _, sub := range s.subscriptions {
+		if sub.push(t, s.policy) {
+			continue
+		}
+		s.dropped.Add(1)
+		if s.policy == PolicyDisconnect && sub.disconnect() {
+			s.disconnected.Add(1)
+		}
 	}
 	return nil
 }
 
+// Observe registers fn to be called synchronously by Notify,
+// so fn must not block. Unlike subscribers, observers are never
+// unregistered and are called before Notify returns.
+// Observe acquires an exlusive lock and is
+// therefore safe to be called concurrently.
+func (s *Broadcast[T]) Observe(fn func(T)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.observers = append(s.observers, fn)
+}
+
 // Subscribe registers c to receive notifications on.
-// The channel is closed and unregistered when ctx is canceled.
+// The channel is closed and unregistered when ctx is canceled
+// or when the subscriber is disconnected by PolicyDisconnect.
 // Subscribe acquires an exlusive lock and is
 // therefore safe to be called concurrently.
 func (s *Broadcast[T]) Subscribe(ctx context.Context, c chan<- T) error {
+	sub := &subscriber[T]{
+		queue:  make([]T, s.queueSize),
+		signal: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
 	s.lock.Lock()
 	s.idCounter++
 	id := s.idCounter
-	s.subscriptions[id] = c
+	s.subscriptions[id] = sub
 	s.lock.Unlock()
 	go func() {
-		<-ctx.Done()
+		sub.deliver(ctx, c, &s.delivered)
 		// Subscription canceled
 		s.lock.Lock()
-		close(s.subscriptions[id])
 		delete(s.subscriptions, id)
 		s.lock.Unlock()
+		close(c)
 	}()
 	return nil
 }
+
+// subscriber is a bounded ring buffer queue of notifications.
+type subscriber[T any] struct {
+	lock      sync.Mutex
+	queue     []T
+	head, len int
+	signal    chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// push enqueues t and returns true, or returns false if either t
+// or the oldest element (in case of PolicyDropOldest) was dropped.
+func (s *subscriber[T]) push(t T, policy Policy) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.len == len(s.queue) {
+		if policy != PolicyDropOldest {
+			return false
+		}
+		// The queue is full, the slot of the oldest element
+		// becomes the tail for t.
+		s.queue[s.head] = t
+		s.head = (s.head + 1) % len(s.queue)
+		return false
+	}
+	s.queue[(s.head+s.len)%len(s.queue)] = t
+	s.len++
+	s.signalReady()
+	return true
+}
+
+// pop dequeues the oldest element. Returns false if the queue is empty.
+func (s *subscriber[T]) pop() (t T, ok bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.len == 0 {
+		return t, false
+	}
+	t = s.queue[s.head]
+	var zero T
+	s.queue[s.head] = zero // Allow t to be garbage collected
+	s.head = (s.head + 1) % len(s.queue)
+	s.len--
+	return t, true
+}
+
+func (s *subscriber[T]) signalReady() {
+	select {
+	case s.signal <- struct{}{}:
+	default: // Already signaled
+	}
+}
+
+// disconnect stops delivery and returns true,
+// or returns false if delivery was already stopped.
+func (s *subscriber[T]) disconnect() (stopped bool) {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		stopped = true
+	})
+	return stopped
+}
+
+// deliver forwards queued notifications to c until
+// either ctx is canceled or the subscriber is disconnected.
+func (s *subscriber[T]) deliver(
+	ctx context.Context, c chan<- T, delivered *atomic.Uint64,
+) {
+	for {
+		t, ok := s.pop()
+		if !ok {
+			select {
+			case <-s.signal:
+				continue
+			case <-s.stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case c <- t:
+			delivered.Add(1)
+		case <-s.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
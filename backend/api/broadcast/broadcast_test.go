@@ -3,13 +3,14 @@ package broadcast_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/romshark/taskhub/api/broadcast"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNotify(t *testing.T) {
-	b := broadcast.New[int]()
+	b := broadcast.New[int](broadcast.Options{})
 
 	sub1, sub2 := make(chan int, 1), make(chan int, 1)
 	err := b.Subscribe(context.Background(), sub1)
@@ -25,8 +26,20 @@ func TestNotify(t *testing.T) {
 	require.Equal(t, 42, <-sub2)
 }
 
+func TestObserve(t *testing.T) {
+	b := broadcast.New[int](broadcast.Options{})
+
+	var observed []int
+	b.Observe(func(v int) { observed = append(observed, v) })
+
+	require.NoError(t, b.Notify(context.Background(), 1))
+	require.NoError(t, b.Notify(context.Background(), 2))
+	require.Equal(t, []int{1, 2}, observed)
+	require.Zero(t, b.Len())
+}
+
 func TestClose(t *testing.T) {
-	b := broadcast.New[int]()
+	b := broadcast.New[int](broadcast.Options{})
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -56,3 +69,139 @@ func TestClose(t *testing.T) {
 
 	require.Equal(t, 0, b.Len())
 }
+
+func TestNotifyNonBlocking(t *testing.T) {
+	b := broadcast.New[int](broadcast.Options{QueueSize: 2})
+
+	// Nobody ever reads from stalled.
+	stalled := make(chan int)
+	err := b.Subscribe(context.Background(), stalled)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			require.NoError(t, b.Notify(context.Background(), i))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notify blocked on a stalled subscriber")
+	}
+}
+
+func TestSlowConsumerIsolation(t *testing.T) {
+	const total = 100
+	const queueSize = 4
+
+	for _, td := range []struct {
+		name   string
+		policy broadcast.Policy
+		check  func(t *testing.T, received []int)
+	}{
+		{
+			name:   "drop_oldest",
+			policy: broadcast.PolicyDropOldest,
+			check: func(t *testing.T, received []int) {
+				// The most recent notifications are retained.
+				require.GreaterOrEqual(t, len(received), queueSize)
+				require.Equal(t, total-1, received[len(received)-1])
+				require.IsIncreasing(t, received)
+			},
+		},
+		{
+			name:   "drop_newest",
+			policy: broadcast.PolicyDropNewest,
+			check: func(t *testing.T, received []int) {
+				// The earliest notifications are retained.
+				require.GreaterOrEqual(t, len(received), queueSize)
+				for i, v := range received {
+					require.Equal(t, i, v)
+				}
+			},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			b := broadcast.New[int](broadcast.Options{
+				QueueSize: queueSize,
+				Policy:    td.policy,
+			})
+
+			fast, slow := make(chan int), make(chan int)
+			require.NoError(t, b.Subscribe(context.Background(), fast))
+			require.NoError(t, b.Subscribe(context.Background(), slow))
+
+			// The fast consumer receives every notification
+			// while the slow consumer isn't reading at all.
+			for i := 0; i < total; i++ {
+				require.NoError(t, b.Notify(context.Background(), i))
+				require.Equal(t, i, receive(t, fast))
+			}
+
+			s := b.Stats()
+			require.Equal(t, 2, s.Subscribers)
+			require.Zero(t, s.Disconnected)
+			require.Greater(t, s.Dropped, uint64(0))
+
+			// Everything not dropped is eventually
+			// delivered to the slow consumer.
+			received := make([]int, total-int(s.Dropped))
+			for i := range received {
+				received[i] = receive(t, slow)
+			}
+			td.check(t, received)
+
+			require.Equal(t, uint64(total), s.Dropped+uint64(len(received)))
+			require.Eventually(t, func() bool {
+				return b.Stats().Delivered == uint64(total+len(received))
+			}, 5*time.Second, time.Millisecond)
+		})
+	}
+}
+
+func TestSlowConsumerDisconnect(t *testing.T) {
+	b := broadcast.New[int](broadcast.Options{
+		QueueSize: 4,
+		Policy:    broadcast.PolicyDisconnect,
+	})
+
+	fast, slow := make(chan int), make(chan int)
+	require.NoError(t, b.Subscribe(context.Background(), fast))
+	require.NoError(t, b.Subscribe(context.Background(), slow))
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, b.Notify(context.Background(), i))
+		require.Equal(t, i, receive(t, fast))
+	}
+
+	// The slow consumer's channel is closed once its queue overflowed.
+	for {
+		if _, ok := <-slow; !ok {
+			break
+		}
+	}
+	require.Equal(t, 1, b.Len())
+
+	s := b.Stats()
+	require.Equal(t, 1, s.Subscribers)
+	require.Equal(t, uint64(1), s.Disconnected)
+	require.Greater(t, s.Dropped, uint64(0))
+
+	// The fast consumer remains subscribed.
+	require.NoError(t, b.Notify(context.Background(), 100))
+	require.Equal(t, 100, receive(t, fast))
+}
+
+func receive[T any](t *testing.T, c <-chan T) T {
+	t.Helper()
+	select {
+	case v, ok := <-c:
+		require.True(t, ok, "channel closed")
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	panic("unreachable")
+}
@@ -2,6 +2,7 @@ package dataprovider
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/romshark/taskhub/api/graph/model"
@@ -12,36 +13,66 @@ type DataProvider interface {
 	Writer
 }
 
+var (
+	// ErrNotFound is wrapped by errors of sessions and API keys
+	// that don't exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrRefreshTokenInvalid is returned when rotating the refresh token of
+	// a session that doesn't exist, expired, was revoked or belongs to a
+	// deactivated user, or if the refresh token isn't the current one.
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+
+	// ErrTwoFactorCodeUsed is returned when using a TOTP time step
+	// or a recovery code that was already used.
+	ErrTwoFactorCodeUsed = errors.New("two-factor code already used")
+
+	// ErrRefreshTokenReused is returned when rotating the refresh token
+	// of a session using the previous refresh token.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
 // Reader reads the data source
+//
+// Paginated lists are ordered by the given order and direction,
+// tasks and projects are ordered by creation time and users by
+// display name if order is nil. Ties are broken by creation time and ID
+// in ascending order. Page cursors must be derived from the same order.
+//
+// Reading projects, tasks and comments requires permission.View in their
+// project. Single projects, tasks and comments and the relations of
+// a single project, task or comment fail with auth.ErrUnauthorized
+// otherwise, lists omit the projects and tasks the client can't view.
 type Reader interface {
 	UserByEmail(ctx context.Context, email string) (*model.User, error)
 	UserByID(ctx context.Context, id string) (*model.User, error)
 	ProjectByID(ctx context.Context, id string) (*model.Project, error)
 	TaskByID(ctx context.Context, id string) (*model.Task, error)
+	CommentByID(ctx context.Context, id string) (*model.Comment, error)
 
 	GetUsers(
 		ctx context.Context,
 		filters *model.UsersFilters,
 		order *model.UsersOrder,
 		orderAsc bool,
-		limit *int,
-	) ([]*model.User, error)
+		page Page,
+	) (*model.UserConnection, error)
 
 	GetProjects(
 		ctx context.Context,
 		filters *model.ProjectsFilters,
 		order *model.ProjectsOrder,
 		orderAsc bool,
-		limit *int,
-	) ([]*model.Project, error)
+		page Page,
+	) (*model.ProjectConnection, error)
 
 	GetTasks(
 		ctx context.Context,
 		filters *model.TasksFilters,
 		order *model.TasksOrder,
 		orderAsc bool,
-		limit *int,
-	) ([]*model.Task, error)
+		page Page,
+	) (*model.TaskConnection, error)
 
 	// GetProjectMembers returns all users that are assigned to tasks
 	// or have reported tasks from the given project.
@@ -66,7 +97,10 @@ type Reader interface {
 	GetTasksByProject(
 		ctx context.Context,
 		projectID string,
-	) ([]*model.Task, error)
+		order *model.TasksOrder,
+		orderAsc bool,
+		page Page,
+	) (*model.TaskConnection, error)
 
 	// GetUserProjects returns all projects that the tasks are assigned to
 	// where the given user is either assignee or reporter.
@@ -79,17 +113,67 @@ type Reader interface {
 	GetTasksAssignedToUser(
 		ctx context.Context,
 		userID string,
-	) ([]*model.Task, error)
+		order *model.TasksOrder,
+		orderAsc bool,
+		page Page,
+	) (*model.TaskConnection, error)
 
 	// GetTasksReportedByUser returns all tasks reported by the given user.
 	GetTasksReportedByUser(
 		ctx context.Context,
 		userID string,
 	) ([]*model.Task, error)
+
+	// GetTaskComments returns all comments of the given task
+	// including replies in order of creation.
+	GetTaskComments(
+		ctx context.Context,
+		taskID string,
+	) ([]*model.Comment, error)
+
+	// GetCommentReplies returns the direct replies to the given comment
+	// in order of creation.
+	GetCommentReplies(
+		ctx context.Context,
+		commentID string,
+	) ([]*model.Comment, error)
+
+	// SessionByID returns the given session including revoked ones.
+	// Returns an error wrapping ErrNotFound if the session doesn't exist.
+	SessionByID(ctx context.Context, id string) (*model.Session, error)
+
+	// GetUserSessions returns all sessions of the given user that weren't
+	// revoked including expired ones in order of creation.
+	// Only the user itself is allowed to read its sessions.
+	GetUserSessions(
+		ctx context.Context,
+		userID string,
+	) ([]*model.Session, error)
+
+	// Organization returns the settings shared by all users.
+	Organization(ctx context.Context) (*model.Organization, error)
+
+	// APIKeyByID returns the given API key including revoked ones.
+	// Returns an error wrapping ErrNotFound if the API key doesn't exist.
+	APIKeyByID(ctx context.Context, id string) (*model.APIKey, error)
+
+	// GetUserAPIKeys returns all API keys of the given user that weren't
+	// revoked in order of creation.
+	// Only the user itself and admins are allowed to read its API keys.
+	GetUserAPIKeys(
+		ctx context.Context,
+		userID string,
+	) ([]*model.APIKey, error)
 }
 
 // Writer reads from and writes to the data source
+//
+// Write access to projects, tasks and comments is governed by the project
+// role of the client as defined by package permission.
+// Admins are allowed to perform all actions.
 type Writer interface {
+	// CreateUser creates a user.
+	// The first user ever created is granted the admin role.
 	CreateUser(
 		ctx context.Context,
 		email string,
@@ -101,6 +185,8 @@ type Writer interface {
 		subordinates []string,
 	) (*model.User, error)
 
+	// UpdateUser updates the given user.
+	// Only the user itself and admins are allowed to update it.
 	UpdateUser(
 		ctx context.Context,
 		id string,
@@ -113,6 +199,8 @@ type Writer interface {
 		subordinates []string,
 	) (*model.User, error)
 
+	// CreateProject creates a project and grants the OWNER role to owners,
+	// or to the client if owners is empty.
 	CreateProject(
 		ctx context.Context,
 		creation time.Time,
@@ -122,6 +210,10 @@ type Writer interface {
 		owners []string,
 	) (*model.Project, error)
 
+	// UpdateProject updates the given project and grants the OWNER role
+	// to owners. Users that are no longer owners lose their role.
+	// Requires permission.UpdateProject and, if the set of owners changes,
+	// permission.ManageRoles.
 	UpdateProject(
 		ctx context.Context,
 		id string,
@@ -131,6 +223,8 @@ type Writer interface {
 		owners []string,
 	) (*model.Project, error)
 
+	// CreateTask creates a task in the given project.
+	// Requires permission.CreateTask in the project.
 	CreateTask(
 		ctx context.Context,
 		creation time.Time,
@@ -147,6 +241,9 @@ type Writer interface {
 		relatesTo []string,
 	) (*model.Task, error)
 
+	// UpdateTask updates the given task. Requires permission.UpdateTask in
+	// the current project of the task and permission.CreateTask in the
+	// project the task is moved to.
 	UpdateTask(
 		ctx context.Context,
 		id string,
@@ -162,4 +259,216 @@ type Writer interface {
 		blocks []string,
 		relatesTo []string,
 	) (*model.Task, error)
+
+	// DeleteTask deletes the given task including its comments and removes
+	// it from the Blocks and RelatesTo lists of all other tasks.
+	// Tasks of archived projects can't be deleted.
+	// Requires permission.DeleteTask.
+	DeleteTask(ctx context.Context, id string) error
+
+	// ArchiveProject archives the given active project.
+	// Archived projects and their tasks can neither be updated nor
+	// deleted and no tasks can be created in or moved to them.
+	// Requires permission.ArchiveProject.
+	ArchiveProject(
+		ctx context.Context,
+		id string,
+		archived time.Time,
+	) (*model.Project, error)
+
+	// UnarchiveProject reactivates the given archived project.
+	// Requires permission.ArchiveProject.
+	UnarchiveProject(ctx context.Context, id string) (*model.Project, error)
+
+	// DeactivateUser deactivates the given active user and removes it from
+	// the Assignees of all tasks and revokes all of its project roles.
+	// Only the user itself, its manager and admins are allowed
+	// to deactivate it. Deactivated users remain reporters of their tasks
+	// but can't be made assignees or be granted project roles.
+	DeactivateUser(
+		ctx context.Context,
+		id string,
+		deactivated time.Time,
+	) (*model.User, error)
+
+	// AddComment adds a comment authored by the authenticated client
+	// to the given task. parent must be a comment of the same task if any.
+	// Comments can't be added to tasks of archived projects.
+	// Requires permission.Comment.
+	AddComment(
+		ctx context.Context,
+		creation time.Time,
+		task string,
+		body string,
+		parent *string,
+	) (*model.Comment, error)
+
+	// EditComment changes the body of the given comment.
+	// Only the author is allowed to edit a comment.
+	EditComment(
+		ctx context.Context,
+		id string,
+		body string,
+		edited time.Time,
+	) (*model.Comment, error)
+
+	// DeleteComment deletes the given comment including all of its replies.
+	// Comments of other authors require permission.ModerateComments.
+	DeleteComment(ctx context.Context, id string) error
+
+	// GrantProjectRole grants role in the given project to the given
+	// active user replacing its current role if any.
+	// Requires permission.ManageRoles and an active project.
+	GrantProjectRole(
+		ctx context.Context,
+		projectID string,
+		userID string,
+		role model.ProjectRole,
+	) (*model.Project, error)
+
+	// RevokeProjectRole revokes the role of the given user in the given
+	// project. Requires permission.ManageRoles and an active project.
+	// Projects without owners can only be managed by admins.
+	RevokeProjectRole(
+		ctx context.Context,
+		projectID string,
+		userID string,
+	) (*model.Project, error)
+
+	// SetUserAdmin grants or revokes the admin role of the given user.
+	// Only admins are allowed to change the admin role
+	// and admins can't revoke their own admin role.
+	SetUserAdmin(
+		ctx context.Context,
+		id string,
+		admin bool,
+	) (*model.User, error)
+
+	// CreateSession creates a session of the given active user
+	// identified by the hash of its first refresh token.
+	// The password of the user is expected to be verified by the caller.
+	CreateSession(
+		ctx context.Context,
+		creation time.Time,
+		userID string,
+		userAgent string,
+		refreshTokenHash string,
+		expires time.Time,
+	) (*model.Session, error)
+
+	// RotateRefreshToken replaces the current refresh token hash of the
+	// given session with newRefreshTokenHash and extends its expiration
+	// if refreshTokenHash is the current one. If refreshTokenHash is the
+	// previous one, which indicates that the refresh token was stolen,
+	// the session is revoked and ErrRefreshTokenReused is returned.
+	// Otherwise returns ErrRefreshTokenInvalid.
+	RotateRefreshToken(
+		ctx context.Context,
+		id string,
+		refreshTokenHash string,
+		newRefreshTokenHash string,
+		refreshed time.Time,
+		expires time.Time,
+	) (*model.Session, error)
+
+	// RevokeSession revokes the given session.
+	// Only the user of the session is allowed to revoke it.
+	RevokeSession(
+		ctx context.Context,
+		id string,
+		revoked time.Time,
+	) (*model.Session, error)
+
+	// RevokeUserSessions revokes all sessions of the given user that weren't
+	// revoked yet and returns the number of revoked sessions.
+	// Only the user itself is allowed to revoke its sessions.
+	RevokeUserSessions(
+		ctx context.Context,
+		userID string,
+		revoked time.Time,
+	) (int, error)
+
+	// CreateAPIKey creates an API key of the given active user identified
+	// by the hash of its secret. Keys of other users than the client are
+	// service keys and can only be created by admins. The ADMIN scope can
+	// only be granted to keys of admins.
+	CreateAPIKey(
+		ctx context.Context,
+		creation time.Time,
+		userID string,
+		name string,
+		scopes []model.APIKeyScope,
+		secretHash string,
+	) (*model.APIKey, error)
+
+	// RevokeAPIKey revokes the given API key.
+	// Only the user of the API key and admins are allowed to revoke it.
+	RevokeAPIKey(
+		ctx context.Context,
+		id string,
+		revoked time.Time,
+	) (*model.APIKey, error)
+
+	// MarkAPIKeyUsed sets the last use time of the given API key.
+	// The secret of the API key is expected to be verified by the caller.
+	MarkAPIKeyUsed(ctx context.Context, id string, used time.Time) error
+
+	// SetTOTPSecret sets the pending TOTP secret of the given user.
+	// Fails if the user already enabled two-factor authentication.
+	// Only the user itself is allowed to set its secret.
+	SetTOTPSecret(
+		ctx context.Context,
+		userID string,
+		secret string,
+	) (*model.User, error)
+
+	// EnableTwoFactor enables two-factor authentication of the given user
+	// with a pending TOTP secret. step is the time step of the code the
+	// enrollment was confirmed with, which is expected to be verified
+	// by the caller.
+	// Only the user itself is allowed to enable two-factor authentication.
+	EnableTwoFactor(
+		ctx context.Context,
+		userID string,
+		step int64,
+		recoveryCodeHashes []string,
+	) (*model.User, error)
+
+	// DisableTwoFactor disables two-factor authentication of the given user
+	// and removes its TOTP secret and recovery codes.
+	// Users are allowed to disable it unless the organization requires it.
+	// Admins are allowed to disable it for any user.
+	DisableTwoFactor(ctx context.Context, userID string) (*model.User, error)
+
+	// SetRecoveryCodes replaces the recovery codes of the given user
+	// with two-factor authentication enabled.
+	// Only the user itself is allowed to replace its recovery codes.
+	SetRecoveryCodes(
+		ctx context.Context,
+		userID string,
+		recoveryCodeHashes []string,
+	) (*model.User, error)
+
+	// UseTOTPStep records the time step of an accepted code of the given
+	// user. Returns ErrTwoFactorCodeUsed if step isn't later than the
+	// step of the last accepted code.
+	// The code is expected to be verified by the caller.
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+
+	// UseRecoveryCode removes the recovery code hash of the given user.
+	// Returns ErrTwoFactorCodeUsed if the user has no such recovery code.
+	// The recovery code is expected to be verified by the caller.
+	UseRecoveryCode(
+		ctx context.Context,
+		userID string,
+		recoveryCodeHash string,
+	) error
+
+	// SetTwoFactorRequired defines whether all users are required
+	// to enable two-factor authentication.
+	// Only admins are allowed to change it.
+	SetTwoFactorRequired(
+		ctx context.Context,
+		required bool,
+	) (*model.Organization, error)
 }
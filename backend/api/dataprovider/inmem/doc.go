@@ -1,2 +1,3 @@
-// Package inmem provides an in-memory data provider.
+// Package inmem provides an in-memory data provider
+// that can optionally be persisted to disk using a journal.
 package inmem
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,6 +12,8 @@ import (
 	"github.com/romshark/taskhub/api/auth"
 	"github.com/romshark/taskhub/api/dataprovider"
 	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/permission"
+	"github.com/romshark/taskhub/api/reqctx"
 	"github.com/romshark/taskhub/slices"
 )
 
@@ -21,6 +24,13 @@ type Inmem struct {
 	Users    []*model.User
 	Tasks    []*model.Task
 	Projects []*model.Project
+	Comments []*model.Comment
+	Sessions []*model.Session
+	APIKeys  []*model.APIKey
+
+	OrganizationSettings model.Organization
+
+	journal *journal
 }
 
 func (p *Inmem) UserByEmail(
@@ -51,40 +61,103 @@ func (p *Inmem) UserByID(
 
 func (p *Inmem) ProjectByID(
 	ctx context.Context, id string,
-) (project *model.Project, err error) {
+) (*model.Project, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
-	if project = p.projectByID(id); project == nil {
-		return project, fmt.Errorf("project %q not found", id)
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	project := p.projectByID(id)
+	if project == nil {
+		return nil, fmt.Errorf("project %q not found", id)
+	}
+	if err := p.requirePermission(ctx, project, permission.View); err != nil {
+		return nil, err
 	}
 	return project, nil
 }
 
 func (p *Inmem) TaskByID(
 	ctx context.Context, id string,
-) (task *model.Task, err error) {
+) (*model.Task, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
-	if task = p.taskByID(id); task == nil {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	task := p.taskByID(id)
+	if task == nil {
 		return nil, fmt.Errorf("task %q not found", id)
 	}
+	if err := p.requirePermission(
+		ctx, task.Project, permission.View,
+	); err != nil {
+		return nil, err
+	}
 	return task, nil
 }
 
+func (p *Inmem) CommentByID(
+	ctx context.Context, id string,
+) (*model.Comment, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	c := p.commentByID(id)
+	if c == nil {
+		return nil, fmt.Errorf("comment %q not found", id)
+	}
+	if err := p.requirePermission(
+		ctx, c.Task.Project, permission.View,
+	); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
 func (p *Inmem) GetUsers(
 	ctx context.Context,
 	filters *model.UsersFilters,
 	order *model.UsersOrder,
 	orderAsc bool,
-	limit *int,
-) ([]*model.User, error) {
+	page dataprovider.Page,
+) (*model.UserConnection, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
+	users := p.filterUsers(filters)
+	nodes, cursors, info := dataprovider.Paginate(
+		users,
+		func(u *model.User) dataprovider.Cursor {
+			return dataprovider.UserCursor(order, u)
+		},
+		orderAsc, page,
+	)
+	c := &model.UserConnection{
+		Edges:      make([]*model.UserEdge, len(nodes)),
+		PageInfo:   info,
+		TotalCount: len(users),
+	}
+	for i, n := range nodes {
+		c.Edges[i] = &model.UserEdge{Cursor: cursors[i], Node: n}
+	}
+	return c, nil
+}
+
+func (p *Inmem) filterUsers(filters *model.UsersFilters) []*model.User {
 	users := slices.Copy(p.Users)
 	if filters != nil {
+		if filters.Name != "" {
+			name := strings.ToLower(filters.Name)
+			users = slices.FilterInPlace(users, func(u *model.User) (ok bool) {
+				return strings.Contains(strings.ToLower(u.DisplayName), name)
+			})
+		}
 		if filters.Projects != nil {
 			users = slices.FilterInPlace(users, func(u *model.User) (ok bool) {
 				projectIDs := []string{}
@@ -104,12 +177,14 @@ func (p *Inmem) GetUsers(
 				}
 				return slices.IsSubset(filters.Projects, projectIDs)
 			})
-			if len(users) < 1 {
-				return nil, nil
-			}
+		}
+		if filters.Deactivated != nil {
+			users = slices.FilterInPlace(users, func(u *model.User) (ok bool) {
+				return (u.Deactivated != nil) == *filters.Deactivated
+			})
 		}
 	}
-	return slices.SortAndLimit(users, sortFnUsers(order, orderAsc), limitInt(limit)), nil
+	return users
 }
 
 func (p *Inmem) GetProjects(
@@ -117,28 +192,49 @@ func (p *Inmem) GetProjects(
 	filters *model.ProjectsFilters,
 	order *model.ProjectsOrder,
 	orderAsc bool,
-	limit *int,
-) ([]*model.Project, error) {
+	page dataprovider.Page,
+) (*model.ProjectConnection, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	projects := p.filterProjects(filters)
+	projects = slices.FilterInPlace(projects, func(x *model.Project) bool {
+		return p.canView(ctx, x)
+	})
+	counts := p.projectCounts(order)
+	nodes, cursors, info := dataprovider.Paginate(
+		projects,
+		func(x *model.Project) dataprovider.Cursor {
+			return dataprovider.ProjectCursor(order, x, counts[x])
+		},
+		orderAsc, page,
+	)
+	c := &model.ProjectConnection{
+		Edges:      make([]*model.ProjectEdge, len(nodes)),
+		PageInfo:   info,
+		TotalCount: len(projects),
+	}
+	for i, n := range nodes {
+		c.Edges[i] = &model.ProjectEdge{Cursor: cursors[i], Node: n}
+	}
+	return c, nil
+}
+
+func (p *Inmem) filterProjects(filters *model.ProjectsFilters) []*model.Project {
 	projects := slices.Copy(p.Projects)
 	if filters != nil {
 		if filters.CreatedAfter != nil {
 			projects = slices.FilterInPlace(projects, func(p *model.Project) (ok bool) {
 				return p.Creation.Unix() > filters.CreatedAfter.Unix()
 			})
-			if len(projects) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.CreatedBefore != nil {
 			projects = slices.FilterInPlace(projects, func(p *model.Project) (ok bool) {
 				return p.Creation.Unix() < filters.CreatedBefore.Unix()
 			})
-			if len(projects) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.Members != nil {
 			projects = slices.FilterInPlace(projects, func(proj *model.Project) (ok bool) {
@@ -156,16 +252,14 @@ func (p *Inmem) GetProjects(
 				}
 				return slices.IsSubset(filters.Members, memberIDs)
 			})
-			if len(projects) < 1 {
-				return nil, nil
-			}
+		}
+		if filters.Archived != nil {
+			projects = slices.FilterInPlace(projects, func(p *model.Project) (ok bool) {
+				return (p.Archived != nil) == *filters.Archived
+			})
 		}
 	}
-	return slices.SortAndLimit(
-		projects,
-		p.sortFnProjects(order, orderAsc),
-		limitInt(limit),
-	), nil
+	return projects
 }
 
 func (p *Inmem) GetTasks(
@@ -173,75 +267,70 @@ func (p *Inmem) GetTasks(
 	filters *model.TasksFilters,
 	order *model.TasksOrder,
 	orderAsc bool,
-	limit *int,
-) ([]*model.Task, error) {
+	page dataprovider.Page,
+) (*model.TaskConnection, error) {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
 
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	tasks := p.viewableTasks(ctx, p.filterTasks(filters))
+	return newTaskConnection(tasks, order, orderAsc, page), nil
+}
+
+func (p *Inmem) filterTasks(filters *model.TasksFilters) []*model.Task {
 	tasks := slices.Copy(p.Tasks)
 	if filters != nil {
 		if filters.CreatedAfter != nil {
 			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
 				return t.Creation.Unix() > filters.CreatedAfter.Unix()
 			})
-			if len(tasks) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.CreatedBefore != nil {
 			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
 				return t.Creation.Unix() < filters.CreatedBefore.Unix()
 			})
-			if len(tasks) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.Assignees != nil {
 			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
 				return slices.IsSubsetGet(filters.Assignees, t.Assignees, getUserID)
 			})
-			if len(tasks) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.Reporters != nil {
 			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
 				return slices.IsSubsetGet(filters.Reporters, t.Reporters, getUserID)
 			})
-			if len(tasks) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.Tags != nil {
 			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
 				return slices.IsSubset(filters.Tags, t.Tags)
 			})
-			if len(tasks) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.Status != nil {
 			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
 				return slices.Contains(filters.Status, t.Status)
 			})
-			if len(tasks) < 1 {
-				return nil, nil
-			}
 		}
 		if filters.Projects != nil {
 			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
 				return t.Project != nil &&
 					slices.Contains(filters.Projects, t.Project.ID)
 			})
-			if len(tasks) < 1 {
-				return nil, nil
-			}
+		}
+		if filters.Archived != nil {
+			tasks = slices.FilterInPlace(tasks, func(t *model.Task) (ok bool) {
+				return (t.Project.Archived != nil) == *filters.Archived
+			})
 		}
 	}
-	return slices.SortAndLimit(tasks, sortFnTasks(order, orderAsc), limitInt(limit)), nil
+	return tasks
 }
 
 func (p *Inmem) CreateUser(ctx context.Context, email string, passwordHash string, displayName string, role string, location string, manager *string, subordinates []string) (*model.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
 	for _, u := range p.Users {
 		if u.DisplayName == displayName {
 			return nil, errors.New("non-unique displayName")
@@ -265,19 +354,25 @@ func (p *Inmem) CreateUser(ctx context.Context, email string, passwordHash strin
 		if u == nil {
 			return nil, fmt.Errorf("subordinate user %q not found", s)
 		}
+		subordinateUsers = slices.AppendUnique(subordinateUsers, u)
 	}
 
 	newUser := &model.User{
-		ID:           "user_" + makeID(displayName),
+		ID:           p.newUserID(displayName),
 		Email:        email,
 		DisplayName:  displayName,
 		Role:         role,
 		Location:     location,
 		Manager:      managerUser,
 		Subordinates: subordinateUsers,
+		Admin:        len(p.Users) == 0,
 		PasswordHash: passwordHash,
 	}
+	if err := p.journal.logUser(newUser); err != nil {
+		return nil, err
+	}
 	p.Users = append(p.Users, newUser)
+	p.compactIfNeeded()
 	return newUser, nil
 }
 
@@ -294,11 +389,16 @@ func (p *Inmem) UpdateUser(ctx context.Context, id string, email string, display
 		return nil, fmt.Errorf("user %q not found", id)
 	}
 
-	if err := auth.RequireOwner(ctx, id); err != nil {
-		return nil, err
+	if !p.isAdmin(ctx) {
+		if err := auth.RequireOwner(ctx, id); err != nil {
+			return nil, err
+		}
 	}
 
 	for _, u := range p.Users {
+		if u == user {
+			continue
+		}
 		if u.DisplayName == displayName {
 			return nil, errors.New("non-unique displayName")
 		}
@@ -335,13 +435,20 @@ func (p *Inmem) UpdateUser(ctx context.Context, id string, email string, display
 		subordinateUsers = slices.AppendUnique(subordinateUsers, u)
 	}
 
-	user.Email = email
-	user.DisplayName = displayName
-	user.Role = role
-	user.Location = location
-	user.PersonalStatus = personalStatusText
-	user.Manager = managerUser
-	user.Subordinates = subordinateUsers
+	updated := *user
+	updated.Email = email
+	updated.DisplayName = displayName
+	updated.Role = role
+	updated.Location = location
+	updated.PersonalStatus = personalStatusText
+	updated.Manager = managerUser
+	updated.Subordinates = subordinateUsers
+
+	if err := p.journal.logUser(&updated); err != nil {
+		return nil, err
+	}
+	*user = updated
+	p.compactIfNeeded()
 
 	return user, nil
 }
@@ -364,6 +471,14 @@ func (p *Inmem) CreateTask(ctx context.Context, creation time.Time, title string
 	if assignedProject == nil {
 		return nil, fmt.Errorf("project %q not found", project)
 	}
+	if err := p.requirePermission(
+		ctx, assignedProject, permission.CreateTask,
+	); err != nil {
+		return nil, err
+	}
+	if assignedProject.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", project)
+	}
 
 	var usersAssignees []*model.User
 	for _, id := range assignees {
@@ -371,11 +486,14 @@ func (p *Inmem) CreateTask(ctx context.Context, creation time.Time, title string
 		if user == nil {
 			return nil, fmt.Errorf("assignee user %q not found", id)
 		}
+		if user.Deactivated != nil {
+			return nil, fmt.Errorf("assignee user %q is deactivated", id)
+		}
 		usersAssignees = slices.AppendUnique(usersAssignees, user)
 	}
 
 	var usersReporters []*model.User
-	for _, id := range assignees {
+	for _, id := range reporters {
 		u := p.userByID(id)
 		if u == nil {
 			return nil, fmt.Errorf("reporter user %q not found", id)
@@ -402,21 +520,25 @@ func (p *Inmem) CreateTask(ctx context.Context, creation time.Time, title string
 	}
 
 	newTask := &model.Task{
-		ID:          "task_" + makeID(title),
+		ID:          p.newTaskID(title),
 		Title:       title,
 		Description: description,
 		Priority:    priority,
 		Status:      status,
 		Creation:    creation,
 		Due:         due,
-		Tags:        tags,
+		Tags:        dedupe(tags),
 		Project:     assignedProject,
 		Assignees:   usersAssignees,
 		Reporters:   usersReporters,
 		RelatesTo:   relatesToTasks,
 		Blocks:      blocksTasks,
 	}
+	if err := p.journal.logTask(newTask); err != nil {
+		return nil, err
+	}
 	p.Tasks = append(p.Tasks, newTask)
+	p.compactIfNeeded()
 	return newTask, nil
 }
 
@@ -447,9 +569,17 @@ func (p *Inmem) UpdateTask(
 	if task == nil {
 		return nil, fmt.Errorf("task %q not found", id)
 	}
+	if err := p.requirePermission(
+		ctx, task.Project, permission.UpdateTask,
+	); err != nil {
+		return nil, err
+	}
+	if task.Project.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", task.Project.ID)
+	}
 
 	for _, t := range p.Tasks {
-		if t.Title == title {
+		if t != task && t.Title == title {
 			return nil, errors.New("non-unique title")
 		}
 	}
@@ -464,6 +594,16 @@ func (p *Inmem) UpdateTask(
 	if assignedProject == nil {
 		return nil, fmt.Errorf("project %q not found", project)
 	}
+	if assignedProject != task.Project {
+		if err := p.requirePermission(
+			ctx, assignedProject, permission.CreateTask,
+		); err != nil {
+			return nil, err
+		}
+	}
+	if assignedProject.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", project)
+	}
 
 	var usersAssignees []*model.User
 	for _, id := range assignees {
@@ -471,11 +611,14 @@ func (p *Inmem) UpdateTask(
 		if user == nil {
 			return nil, fmt.Errorf("assignee user %q not found", id)
 		}
+		if user.Deactivated != nil {
+			return nil, fmt.Errorf("assignee user %q is deactivated", id)
+		}
 		usersAssignees = slices.AppendUnique(usersAssignees, user)
 	}
 
 	var usersReporters []*model.User
-	for _, id := range assignees {
+	for _, id := range reporters {
 		u := p.userByID(id)
 		if u == nil {
 			return nil, fmt.Errorf("reporter user %q not found", id)
@@ -507,17 +650,24 @@ func (p *Inmem) UpdateTask(
 		relatesToTasks = slices.AppendUnique(relatesToTasks, t)
 	}
 
-	task.Status = status
-	task.Priority = priority
-	task.Description = description
-	task.Tags = tags
-	task.Due = due
-	task.Reporters = usersReporters
-	task.Title = title
-	task.Project = assignedProject
-	task.Assignees = usersAssignees
-	task.Blocks = blocksTasks
-	task.RelatesTo = relatesToTasks
+	updated := *task
+	updated.Status = status
+	updated.Priority = priority
+	updated.Description = description
+	updated.Tags = dedupe(tags)
+	updated.Due = due
+	updated.Reporters = usersReporters
+	updated.Title = title
+	updated.Project = assignedProject
+	updated.Assignees = usersAssignees
+	updated.Blocks = blocksTasks
+	updated.RelatesTo = relatesToTasks
+
+	if err := p.journal.logTask(&updated); err != nil {
+		return nil, err
+	}
+	*task = updated
+	p.compactIfNeeded()
 
 	return task, nil
 }
@@ -547,23 +697,27 @@ func (p *Inmem) CreateProject(
 		}
 	}
 
-	var ownerUsers []*model.User
-	for _, id := range owners {
-		u := p.userByID(id)
-		if u == nil {
-			return nil, fmt.Errorf("owner user %q not found", id)
-		}
-		ownerUsers = slices.AppendUnique(ownerUsers, u)
+	if len(owners) == 0 {
+		owners = []string{reqctx.GetRequestContext(ctx).UserID}
+	}
+	ownerUsers, err := p.owners(owners)
+	if err != nil {
+		return nil, err
 	}
 
 	newProject := &model.Project{
-		ID:          "project_" + makeID(name),
+		ID:          p.newProjectID(name),
 		Name:        name,
 		Description: description,
 		Slug:        slug,
 		Creation:    creation,
-		Owners:      ownerUsers,
+		Roles:       withOwners(nil, ownerUsers),
+	}
+	if err := p.journal.logProject(newProject); err != nil {
+		return nil, err
 	}
+	p.Projects = append(p.Projects, newProject)
+	p.compactIfNeeded()
 	return newProject, nil
 }
 
@@ -580,8 +734,19 @@ func (p *Inmem) UpdateProject(ctx context.Context, id string, name string, descr
 	if project == nil {
 		return nil, fmt.Errorf("project %q not found", id)
 	}
+	if err := p.requirePermission(
+		ctx, project, permission.UpdateProject,
+	); err != nil {
+		return nil, err
+	}
+	if project.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", id)
+	}
 
 	for _, p := range p.Projects {
+		if p == project {
+			continue
+		}
 		if p.Name == name {
 			return nil, errors.New("non-unique project name")
 		}
@@ -590,138 +755,1085 @@ func (p *Inmem) UpdateProject(ctx context.Context, id string, name string, descr
 		}
 	}
 
-	var ownerUsers []*model.User
-	for _, id := range owners {
-		u := p.userByID(id)
-		if u == nil {
-			return nil, fmt.Errorf("owner user %q not found", id)
+	ownerUsers, err := p.owners(owners)
+	if err != nil {
+		return nil, err
+	}
+	if current := project.Owners(); !slices.IsSubset(ownerUsers, current) ||
+		!slices.IsSubset(current, ownerUsers) {
+		if err := p.requirePermission(
+			ctx, project, permission.ManageRoles,
+		); err != nil {
+			return nil, err
 		}
-		ownerUsers = slices.AppendUnique(ownerUsers, u)
 	}
 
-	project.Name = name
-	project.Description = description
-	project.Slug = slug
-	project.Owners = ownerUsers
+	updated := *project
+	updated.Name = name
+	updated.Description = description
+	updated.Slug = slug
+	updated.Roles = withOwners(project.Roles, ownerUsers)
+
+	if err := p.journal.logProject(&updated); err != nil {
+		return nil, err
+	}
+	*project = updated
+	p.compactIfNeeded()
 
 	return project, nil
 }
 
-func (p *Inmem) GetProjectMembers(
-	ctx context.Context,
-	projectID string,
-) ([]*model.User, error) {
-	m := []*model.User{}
-	for _, t := range p.Tasks {
-		if t.Project.ID != projectID {
-			continue
-		}
-		for _, u := range t.Assignees {
-			m = slices.AppendUnique(m, u)
-		}
-		for _, u := range t.Reporters {
-			m = slices.AppendUnique(m, u)
-		}
+func (p *Inmem) DeleteTask(ctx context.Context, id string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return err
 	}
-	return m, nil
-}
 
-func (p *Inmem) GetBlockingTasks(
-	ctx context.Context,
-	taskID string,
-) ([]*model.Task, error) {
-	task := p.taskByID(taskID)
+	task := p.taskByID(id)
 	if task == nil {
-		return nil, fmt.Errorf("task %q not found", taskID)
+		return fmt.Errorf("task %q not found", id)
+	}
+	if err := p.requirePermission(
+		ctx, task.Project, permission.DeleteTask,
+	); err != nil {
+		return err
+	}
+	if task.Project.Archived != nil {
+		return fmt.Errorf("project %q is archived", task.Project.ID)
 	}
 
-	blockedBy := []*model.Task{}
+	if err := p.journal.logTaskDeletion(id); err != nil {
+		return err
+	}
+	tasks := make([]*model.Task, 0, len(p.Tasks)-1)
 	for _, t := range p.Tasks {
 		if t == task {
 			continue
 		}
-		for _, tb := range t.Blocks {
-			if tb == task {
-				blockedBy = slices.AppendUnique(blockedBy, t)
-			}
+		if slices.Contains(t.Blocks, task) || slices.Contains(t.RelatesTo, task) {
+			updated := *t
+			updated.Blocks = without(t.Blocks, task)
+			updated.RelatesTo = without(t.RelatesTo, task)
+			*t = updated
 		}
+		tasks = append(tasks, t)
 	}
-	return blockedBy, nil
+	p.Tasks = tasks
+	p.Comments = slices.FilterInPlace(
+		slices.Copy(p.Comments),
+		func(c *model.Comment) bool { return c.Task != task },
+	)
+	p.compactIfNeeded()
+
+	return nil
 }
 
-func (p *Inmem) GetRelatedTasks(
-	ctx context.Context,
-	taskID string,
-) ([]*model.Task, error) {
-	task := p.taskByID(taskID)
-	if task == nil {
-		return nil, fmt.Errorf("task %q not found", taskID)
+func (p *Inmem) ArchiveProject(
+	ctx context.Context, id string, archived time.Time,
+) (*model.Project, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
 	}
 
-	relatesTo := []*model.Task{}
-	relatesTo = append(relatesTo, task.RelatesTo...)
-	for _, t := range p.Tasks {
-		if t == task {
-			continue
-		}
-		for _, tr := range t.RelatesTo {
-			if tr == task {
-				relatesTo = slices.AppendUnique(relatesTo, t)
-			}
-		}
+	project := p.projectByID(id)
+	if project == nil {
+		return nil, fmt.Errorf("project %q not found", id)
+	}
+	if err := p.requirePermission(
+		ctx, project, permission.ArchiveProject,
+	); err != nil {
+		return nil, err
+	}
+	if project.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", id)
+	}
+
+	updated := *project
+	updated.Archived = &archived
+
+	if err := p.journal.logProject(&updated); err != nil {
+		return nil, err
 	}
-	return relatesTo, nil
+	*project = updated
+	p.compactIfNeeded()
+
+	return project, nil
 }
 
-func (p *Inmem) GetTasksByProject(
-	ctx context.Context,
-	projectID string,
-) ([]*model.Task, error) {
-	project := p.projectByID(projectID)
+func (p *Inmem) UnarchiveProject(
+	ctx context.Context, id string,
+) (*model.Project, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	project := p.projectByID(id)
 	if project == nil {
-		return nil, fmt.Errorf("project %q not found", projectID)
+		return nil, fmt.Errorf("project %q not found", id)
+	}
+	if err := p.requirePermission(
+		ctx, project, permission.ArchiveProject,
+	); err != nil {
+		return nil, err
+	}
+	if project.Archived == nil {
+		return nil, fmt.Errorf("project %q is not archived", id)
 	}
 
-	tasks := []*model.Task{}
-	for _, t := range p.Tasks {
-		if t.Project == project {
-			tasks = append(tasks, t)
-		}
+	updated := *project
+	updated.Archived = nil
+
+	if err := p.journal.logProject(&updated); err != nil {
+		return nil, err
 	}
-	return tasks, nil
+	*project = updated
+	p.compactIfNeeded()
+
+	return project, nil
 }
 
-func (p *Inmem) GetUserProjects(
-	ctx context.Context,
-	userID string,
-) ([]*model.Project, error) {
-	user := p.userByID(userID)
+func (p *Inmem) DeactivateUser(
+	ctx context.Context, id string, deactivated time.Time,
+) (*model.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	user := p.userByID(id)
 	if user == nil {
-		return nil, fmt.Errorf("user %q not found", userID)
+		return nil, fmt.Errorf("user %q not found", id)
+	}
+	owners := []string{id}
+	if user.Manager != nil {
+		owners = append(owners, user.Manager.ID)
+	}
+	if !p.isAdmin(ctx) {
+		if err := auth.RequireAnyOwner(ctx, owners...); err != nil {
+			return nil, err
+		}
+	}
+	if user.Deactivated != nil {
+		return nil, fmt.Errorf("user %q is deactivated", id)
 	}
 
-	projects := []*model.Project{}
+	updated := *user
+	updated.Deactivated = &deactivated
+
+	// Assignees and roles of the deactivated user are removed
+	// when restoring the journal.
+	if err := p.journal.logUser(&updated); err != nil {
+		return nil, err
+	}
+	*user = updated
 	for _, t := range p.Tasks {
-		for _, u := range t.Assignees {
-			if u == user {
-				projects = slices.AppendUnique(projects, t.Project)
-				break
-			}
+		if slices.Contains(t.Assignees, user) {
+			updated := *t
+			updated.Assignees = without(t.Assignees, user)
+			*t = updated
 		}
-		for _, u := range t.Reporters {
-			if u == user {
+	}
+	for _, x := range p.Projects {
+		if permission.RoleOf(x, id) != "" {
+			updated := *x
+			updated.Roles = withoutGrant(x.Roles, id)
+			*x = updated
+		}
+	}
+	p.compactIfNeeded()
+
+	return user, nil
+}
+
+func (p *Inmem) AddComment(
+	ctx context.Context,
+	creation time.Time,
+	task string,
+	body string,
+	parent *string,
+) (*model.Comment, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	authorID := reqctx.GetRequestContext(ctx).UserID
+	author := p.userByID(authorID)
+	if author == nil {
+		return nil, fmt.Errorf("author user %q not found", authorID)
+	}
+
+	t := p.taskByID(task)
+	if t == nil {
+		return nil, fmt.Errorf("task %q not found", task)
+	}
+	if err := p.requirePermission(ctx, t.Project, permission.Comment); err != nil {
+		return nil, err
+	}
+	if t.Project.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", t.Project.ID)
+	}
+
+	var parentComment *model.Comment
+	if parent != nil {
+		if parentComment = p.commentByID(*parent); parentComment == nil {
+			return nil, fmt.Errorf("parent comment %q not found", *parent)
+		}
+		if parentComment.Task != t {
+			return nil, errors.New("parent comment belongs to another task")
+		}
+	}
+
+	newComment := &model.Comment{
+		ID:       p.newCommentID(),
+		Task:     t,
+		Author:   author,
+		Body:     body,
+		Creation: creation,
+		Parent:   parentComment,
+	}
+	if err := p.journal.logComment(newComment); err != nil {
+		return nil, err
+	}
+	p.Comments = append(p.Comments, newComment)
+	p.compactIfNeeded()
+	return newComment, nil
+}
+
+func (p *Inmem) EditComment(
+	ctx context.Context, id string, body string, edited time.Time,
+) (*model.Comment, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	comment := p.commentByID(id)
+	if comment == nil {
+		return nil, fmt.Errorf("comment %q not found", id)
+	}
+	if err := auth.RequireOwner(ctx, comment.Author.ID); err != nil {
+		return nil, err
+	}
+	if comment.Task.Project.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", comment.Task.Project.ID)
+	}
+
+	updated := *comment
+	updated.Body = body
+	updated.Edited = &edited
+
+	if err := p.journal.logComment(&updated); err != nil {
+		return nil, err
+	}
+	*comment = updated
+	p.compactIfNeeded()
+
+	return comment, nil
+}
+
+func (p *Inmem) DeleteComment(ctx context.Context, id string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	comment := p.commentByID(id)
+	if comment == nil {
+		return fmt.Errorf("comment %q not found", id)
+	}
+	if auth.RequireOwner(ctx, comment.Author.ID) != nil {
+		if err := p.requirePermission(
+			ctx, comment.Task.Project, permission.ModerateComments,
+		); err != nil {
+			return err
+		}
+	}
+	if comment.Task.Project.Archived != nil {
+		return fmt.Errorf("project %q is archived", comment.Task.Project.ID)
+	}
+
+	if err := p.journal.logCommentDeletion(id); err != nil {
+		return err
+	}
+	p.Comments = withoutThread(p.Comments, comment)
+	p.compactIfNeeded()
+
+	return nil
+}
+
+func (p *Inmem) GrantProjectRole(
+	ctx context.Context,
+	projectID string,
+	userID string,
+	role model.ProjectRole,
+) (*model.Project, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if !role.IsValid() {
+		return nil, fmt.Errorf("invalid project role %q", role)
+	}
+
+	project := p.projectByID(projectID)
+	if project == nil {
+		return nil, fmt.Errorf("project %q not found", projectID)
+	}
+	if err := p.requirePermission(
+		ctx, project, permission.ManageRoles,
+	); err != nil {
+		return nil, err
+	}
+	if project.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", projectID)
+	}
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+	if user.Deactivated != nil {
+		return nil, fmt.Errorf("user %q is deactivated", userID)
+	}
+
+	updated := *project
+	updated.Roles = nil
+	granted := false
+	for _, g := range project.Roles {
+		if g.User == user {
+			g = &model.ProjectRoleGrant{User: user, Role: role}
+			granted = true
+		}
+		updated.Roles = append(updated.Roles, g)
+	}
+	if !granted {
+		updated.Roles = append(updated.Roles, &model.ProjectRoleGrant{
+			User: user, Role: role,
+		})
+	}
+
+	if err := p.journal.logProject(&updated); err != nil {
+		return nil, err
+	}
+	*project = updated
+	p.compactIfNeeded()
+
+	return project, nil
+}
+
+func (p *Inmem) RevokeProjectRole(
+	ctx context.Context, projectID string, userID string,
+) (*model.Project, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	project := p.projectByID(projectID)
+	if project == nil {
+		return nil, fmt.Errorf("project %q not found", projectID)
+	}
+	if err := p.requirePermission(
+		ctx, project, permission.ManageRoles,
+	); err != nil {
+		return nil, err
+	}
+	if project.Archived != nil {
+		return nil, fmt.Errorf("project %q is archived", projectID)
+	}
+	if permission.RoleOf(project, userID) == "" {
+		return nil, fmt.Errorf(
+			"user %q has no role in project %q", userID, projectID,
+		)
+	}
+
+	updated := *project
+	updated.Roles = withoutGrant(project.Roles, userID)
+
+	if err := p.journal.logProject(&updated); err != nil {
+		return nil, err
+	}
+	*project = updated
+	p.compactIfNeeded()
+
+	return project, nil
+}
+
+func (p *Inmem) SetUserAdmin(
+	ctx context.Context, id string, admin bool,
+) (*model.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if !p.isAdmin(ctx) {
+		return nil, auth.ErrUnauthorized
+	}
+
+	user := p.userByID(id)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", id)
+	}
+	if !admin && id == reqctx.GetRequestContext(ctx).UserID {
+		return nil, errors.New("admins can't revoke their own admin role")
+	}
+
+	updated := *user
+	updated.Admin = admin
+
+	if err := p.journal.logUser(&updated); err != nil {
+		return nil, err
+	}
+	*user = updated
+	p.compactIfNeeded()
+
+	return user, nil
+}
+
+func (p *Inmem) CreateSession(
+	ctx context.Context,
+	creation time.Time,
+	userID string,
+	userAgent string,
+	refreshTokenHash string,
+	expires time.Time,
+) (*model.Session, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+	if user.Deactivated != nil {
+		return nil, fmt.Errorf("user %q is deactivated", userID)
+	}
+
+	newSession := &model.Session{
+		ID:               p.newSessionID(),
+		User:             user,
+		UserAgent:        userAgent,
+		Creation:         creation,
+		Refreshed:        creation,
+		Expires:          expires,
+		RefreshTokenHash: refreshTokenHash,
+	}
+	if err := p.journal.logSession(newSession); err != nil {
+		return nil, err
+	}
+	p.Sessions = append(p.Sessions, newSession)
+	p.compactIfNeeded()
+	return newSession, nil
+}
+
+func (p *Inmem) RotateRefreshToken(
+	ctx context.Context,
+	id string,
+	refreshTokenHash string,
+	newRefreshTokenHash string,
+	refreshed time.Time,
+	expires time.Time,
+) (*model.Session, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	session := p.sessionByID(id)
+	if session == nil ||
+		session.Revoked != nil ||
+		!refreshed.Before(session.Expires) ||
+		session.User.Deactivated != nil {
+		return nil, dataprovider.ErrRefreshTokenInvalid
+	}
+
+	updated := *session
+	switch refreshTokenHash {
+	case session.RefreshTokenHash:
+		updated.PreviousRefreshTokenHash = session.RefreshTokenHash
+		updated.RefreshTokenHash = newRefreshTokenHash
+		updated.Refreshed = refreshed
+		updated.Expires = expires
+	case session.PreviousRefreshTokenHash:
+		updated.Revoked = &refreshed
+	default:
+		return nil, dataprovider.ErrRefreshTokenInvalid
+	}
+
+	if err := p.journal.logSession(&updated); err != nil {
+		return nil, err
+	}
+	*session = updated
+	p.compactIfNeeded()
+
+	if session.Revoked != nil {
+		return nil, dataprovider.ErrRefreshTokenReused
+	}
+	return session, nil
+}
+
+func (p *Inmem) RevokeSession(
+	ctx context.Context, id string, revoked time.Time,
+) (*model.Session, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	session := p.sessionByID(id)
+	if session == nil {
+		return nil, fmt.Errorf("session %q %w", id, dataprovider.ErrNotFound)
+	}
+	if err := auth.RequireOwner(ctx, session.User.ID); err != nil {
+		return nil, err
+	}
+	if session.Revoked != nil {
+		return nil, fmt.Errorf("session %q is revoked", id)
+	}
+
+	updated := *session
+	updated.Revoked = &revoked
+
+	if err := p.journal.logSession(&updated); err != nil {
+		return nil, err
+	}
+	*session = updated
+	p.compactIfNeeded()
+
+	return session, nil
+}
+
+func (p *Inmem) RevokeUserSessions(
+	ctx context.Context, userID string, revoked time.Time,
+) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return 0, err
+	}
+	if p.userByID(userID) == nil {
+		return 0, fmt.Errorf("user %q not found", userID)
+	}
+
+	n := 0
+	for _, s := range p.Sessions {
+		if s.User.ID != userID || s.Revoked != nil {
+			continue
+		}
+		updated := *s
+		updated.Revoked = &revoked
+		if err := p.journal.logSession(&updated); err != nil {
+			return n, err
+		}
+		*s = updated
+		n++
+	}
+	p.compactIfNeeded()
+
+	return n, nil
+}
+
+func (p *Inmem) CreateAPIKey(
+	ctx context.Context,
+	creation time.Time,
+	userID string,
+	name string,
+	scopes []model.APIKeyScope,
+	secretHash string,
+) (*model.APIKey, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+	kind := model.APIKeyKindPersonal
+	if userID != reqctx.GetRequestContext(ctx).UserID {
+		if !p.isAdmin(ctx) {
+			return nil, auth.ErrUnauthorized
+		}
+		kind = model.APIKeyKindService
+	}
+	if user.Deactivated != nil {
+		return nil, fmt.Errorf("user %q is deactivated", userID)
+	}
+	scopes, err := apiKeyScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(scopes, model.APIKeyScopeAdmin) && !user.Admin {
+		return nil, fmt.Errorf(
+			"scope %s requires user %q to be admin",
+			model.APIKeyScopeAdmin, userID,
+		)
+	}
+
+	newKey := &model.APIKey{
+		ID:         p.newAPIKeyID(),
+		User:       user,
+		Name:       name,
+		Kind:       kind,
+		Scopes:     scopes,
+		Creation:   creation,
+		SecretHash: secretHash,
+	}
+	if err := p.journal.logAPIKey(newKey); err != nil {
+		return nil, err
+	}
+	p.APIKeys = append(p.APIKeys, newKey)
+	p.compactIfNeeded()
+	return newKey, nil
+}
+
+func (p *Inmem) RevokeAPIKey(
+	ctx context.Context, id string, revoked time.Time,
+) (*model.APIKey, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	k := p.apiKeyByID(id)
+	if k == nil {
+		return nil, fmt.Errorf("API key %q %w", id, dataprovider.ErrNotFound)
+	}
+	if !p.isAdmin(ctx) {
+		if err := auth.RequireOwner(ctx, k.User.ID); err != nil {
+			return nil, err
+		}
+	}
+	if k.Revoked != nil {
+		return nil, fmt.Errorf("API key %q is revoked", id)
+	}
+
+	updated := *k
+	updated.Revoked = &revoked
+
+	if err := p.journal.logAPIKey(&updated); err != nil {
+		return nil, err
+	}
+	*k = updated
+	p.compactIfNeeded()
+
+	return k, nil
+}
+
+func (p *Inmem) MarkAPIKeyUsed(
+	ctx context.Context, id string, used time.Time,
+) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	k := p.apiKeyByID(id)
+	if k == nil {
+		return fmt.Errorf("API key %q %w", id, dataprovider.ErrNotFound)
+	}
+
+	updated := *k
+	updated.LastUsed = &used
+
+	if err := p.journal.logAPIKey(&updated); err != nil {
+		return err
+	}
+	*k = updated
+	p.compactIfNeeded()
+
+	return nil
+}
+
+func (p *Inmem) SetTOTPSecret(
+	ctx context.Context, userID string, secret string,
+) (*model.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	updated := *user
+	updated.TOTPSecret = secret
+
+	return p.updateUser(user, &updated)
+}
+
+func (p *Inmem) EnableTwoFactor(
+	ctx context.Context,
+	userID string,
+	step int64,
+	recoveryCodeHashes []string,
+) (*model.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor authentication not enrolled")
+	}
+
+	updated := *user
+	updated.TwoFactorEnabled = true
+	updated.TOTPLastStep = step
+	updated.RecoveryCodeHashes = slices.Copy(recoveryCodeHashes)
+
+	return p.updateUser(user, &updated)
+}
+
+func (p *Inmem) DisableTwoFactor(
+	ctx context.Context, userID string,
+) (*model.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+	if !p.isAdmin(ctx) {
+		if err := auth.RequireOwner(ctx, userID); err != nil {
+			return nil, err
+		}
+		if p.OrganizationSettings.TwoFactorRequired {
+			return nil, errors.New(
+				"two-factor authentication is required by the organization",
+			)
+		}
+	}
+
+	updated := *user
+	updated.TwoFactorEnabled = false
+	updated.TOTPSecret = ""
+	updated.TOTPLastStep = 0
+	updated.RecoveryCodeHashes = nil
+
+	return p.updateUser(user, &updated)
+}
+
+func (p *Inmem) SetRecoveryCodes(
+	ctx context.Context, userID string, recoveryCodeHashes []string,
+) (*model.User, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+	if !user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication not enabled")
+	}
+
+	updated := *user
+	updated.RecoveryCodeHashes = slices.Copy(recoveryCodeHashes)
+
+	return p.updateUser(user, &updated)
+}
+
+func (p *Inmem) UseTOTPStep(
+	ctx context.Context, userID string, step int64,
+) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	user := p.userByID(userID)
+	if user == nil {
+		return fmt.Errorf("user %q not found", userID)
+	}
+	if !user.TwoFactorEnabled {
+		return errors.New("two-factor authentication not enabled")
+	}
+	if step <= user.TOTPLastStep {
+		return dataprovider.ErrTwoFactorCodeUsed
+	}
+
+	updated := *user
+	updated.TOTPLastStep = step
+
+	_, err := p.updateUser(user, &updated)
+	return err
+}
+
+func (p *Inmem) UseRecoveryCode(
+	ctx context.Context, userID string, recoveryCodeHash string,
+) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	user := p.userByID(userID)
+	if user == nil {
+		return fmt.Errorf("user %q not found", userID)
+	}
+	if !slices.Contains(user.RecoveryCodeHashes, recoveryCodeHash) {
+		return dataprovider.ErrTwoFactorCodeUsed
+	}
+
+	updated := *user
+	updated.RecoveryCodeHashes = without(
+		user.RecoveryCodeHashes, recoveryCodeHash,
+	)
+
+	_, err := p.updateUser(user, &updated)
+	return err
+}
+
+func (p *Inmem) SetTwoFactorRequired(
+	ctx context.Context, required bool,
+) (*model.Organization, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if !p.isAdmin(ctx) {
+		return nil, auth.ErrUnauthorized
+	}
+
+	updated := p.OrganizationSettings
+	updated.TwoFactorRequired = required
+
+	if err := p.journal.logOrganization(&updated); err != nil {
+		return nil, err
+	}
+	p.OrganizationSettings = updated
+	p.compactIfNeeded()
+
+	o := p.OrganizationSettings
+	return &o, nil
+}
+
+func (p *Inmem) GetProjectMembers(
+	ctx context.Context,
+	projectID string,
+) ([]*model.User, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	project := p.projectByID(projectID)
+	if project == nil {
+		return nil, fmt.Errorf("project %q not found", projectID)
+	}
+	if err := p.requirePermission(ctx, project, permission.View); err != nil {
+		return nil, err
+	}
+
+	m := []*model.User{}
+	for _, t := range p.Tasks {
+		if t.Project != project {
+			continue
+		}
+		for _, u := range t.Assignees {
+			m = slices.AppendUnique(m, u)
+		}
+		for _, u := range t.Reporters {
+			m = slices.AppendUnique(m, u)
+		}
+	}
+	return m, nil
+}
+
+func (p *Inmem) GetBlockingTasks(
+	ctx context.Context,
+	taskID string,
+) ([]*model.Task, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	task := p.taskByID(taskID)
+	if task == nil {
+		return nil, fmt.Errorf("task %q not found", taskID)
+	}
+	if err := p.requirePermission(
+		ctx, task.Project, permission.View,
+	); err != nil {
+		return nil, err
+	}
+
+	blockedBy := []*model.Task{}
+	for _, t := range p.Tasks {
+		if t == task {
+			continue
+		}
+		for _, tb := range t.Blocks {
+			if tb == task {
+				blockedBy = slices.AppendUnique(blockedBy, t)
+			}
+		}
+	}
+	return p.viewableTasks(ctx, blockedBy), nil
+}
+
+func (p *Inmem) GetRelatedTasks(
+	ctx context.Context,
+	taskID string,
+) ([]*model.Task, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	task := p.taskByID(taskID)
+	if task == nil {
+		return nil, fmt.Errorf("task %q not found", taskID)
+	}
+	if err := p.requirePermission(
+		ctx, task.Project, permission.View,
+	); err != nil {
+		return nil, err
+	}
+
+	relatesTo := []*model.Task{}
+	relatesTo = append(relatesTo, task.RelatesTo...)
+	for _, t := range p.Tasks {
+		if t == task {
+			continue
+		}
+		for _, tr := range t.RelatesTo {
+			if tr == task {
+				relatesTo = slices.AppendUnique(relatesTo, t)
+			}
+		}
+	}
+	return p.viewableTasks(ctx, relatesTo), nil
+}
+
+func (p *Inmem) GetTasksByProject(
+	ctx context.Context,
+	projectID string,
+	order *model.TasksOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.TaskConnection, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	project := p.projectByID(projectID)
+	if project == nil {
+		return nil, fmt.Errorf("project %q not found", projectID)
+	}
+	if err := p.requirePermission(ctx, project, permission.View); err != nil {
+		return nil, err
+	}
+
+	tasks := []*model.Task{}
+	for _, t := range p.Tasks {
+		if t.Project == project {
+			tasks = append(tasks, t)
+		}
+	}
+	return newTaskConnection(tasks, order, orderAsc, page), nil
+}
+
+func (p *Inmem) GetUserProjects(
+	ctx context.Context,
+	userID string,
+) ([]*model.Project, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	user := p.userByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+
+	projects := []*model.Project{}
+	for _, t := range p.Tasks {
+		for _, u := range t.Assignees {
+			if u == user {
+				projects = slices.AppendUnique(projects, t.Project)
+				break
+			}
+		}
+		for _, u := range t.Reporters {
+			if u == user {
 				projects = slices.AppendUnique(projects, t.Project)
 				break
 			}
 		}
 	}
-	return projects, nil
+	return slices.FilterInPlace(projects, func(x *model.Project) bool {
+		return p.canView(ctx, x)
+	}), nil
 }
 
 func (p *Inmem) GetTasksAssignedToUser(
 	ctx context.Context,
 	userID string,
-) ([]*model.Task, error) {
+	order *model.TasksOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.TaskConnection, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
 	user := p.userByID(userID)
 	if user == nil {
 		return nil, fmt.Errorf("user %q not found", userID)
@@ -735,13 +1847,20 @@ func (p *Inmem) GetTasksAssignedToUser(
 			}
 		}
 	}
-	return tasks, nil
+	tasks = p.viewableTasks(ctx, tasks)
+	return newTaskConnection(tasks, order, orderAsc, page), nil
 }
 
 func (p *Inmem) GetTasksReportedByUser(
 	ctx context.Context,
 	userID string,
 ) ([]*model.Task, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
 	user := p.userByID(userID)
 	if user == nil {
 		return nil, fmt.Errorf("user %q not found", userID)
@@ -755,7 +1874,218 @@ func (p *Inmem) GetTasksReportedByUser(
 			}
 		}
 	}
-	return tasks, nil
+	return p.viewableTasks(ctx, tasks), nil
+}
+
+func (p *Inmem) GetTaskComments(
+	ctx context.Context,
+	taskID string,
+) ([]*model.Comment, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	task := p.taskByID(taskID)
+	if task == nil {
+		return nil, fmt.Errorf("task %q not found", taskID)
+	}
+	if err := p.requirePermission(
+		ctx, task.Project, permission.View,
+	); err != nil {
+		return nil, err
+	}
+
+	comments := []*model.Comment{}
+	for _, c := range p.Comments {
+		if c.Task == task {
+			comments = append(comments, c)
+		}
+	}
+	sortByCreation(comments)
+	return comments, nil
+}
+
+func (p *Inmem) GetCommentReplies(
+	ctx context.Context,
+	commentID string,
+) ([]*model.Comment, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	comment := p.commentByID(commentID)
+	if comment == nil {
+		return nil, fmt.Errorf("comment %q not found", commentID)
+	}
+	if err := p.requirePermission(
+		ctx, comment.Task.Project, permission.View,
+	); err != nil {
+		return nil, err
+	}
+
+	replies := []*model.Comment{}
+	for _, c := range p.Comments {
+		if c.Parent == comment {
+			replies = append(replies, c)
+		}
+	}
+	sortByCreation(replies)
+	return replies, nil
+}
+
+func (p *Inmem) SessionByID(
+	ctx context.Context, id string,
+) (*model.Session, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	s := p.sessionByID(id)
+	if s == nil {
+		return nil, fmt.Errorf("session %q %w", id, dataprovider.ErrNotFound)
+	}
+	return s, nil
+}
+
+func (p *Inmem) GetUserSessions(
+	ctx context.Context,
+	userID string,
+) ([]*model.Session, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	if p.userByID(userID) == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+
+	sessions := []*model.Session{}
+	for _, s := range p.Sessions {
+		if s.User.ID == userID && s.Revoked == nil {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Creation.Before(sessions[j].Creation)
+	})
+	return sessions, nil
+}
+
+func (p *Inmem) Organization(
+	ctx context.Context,
+) (*model.Organization, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	o := p.OrganizationSettings
+	return &o, nil
+}
+
+func (p *Inmem) APIKeyByID(
+	ctx context.Context, id string,
+) (*model.APIKey, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	k := p.apiKeyByID(id)
+	if k == nil {
+		return nil, fmt.Errorf("API key %q %w", id, dataprovider.ErrNotFound)
+	}
+	return k, nil
+}
+
+func (p *Inmem) GetUserAPIKeys(
+	ctx context.Context,
+	userID string,
+) ([]*model.APIKey, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if !p.isAdmin(ctx) {
+		if err := auth.RequireOwner(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	if p.userByID(userID) == nil {
+		return nil, fmt.Errorf("user %q not found", userID)
+	}
+
+	keys := []*model.APIKey{}
+	for _, k := range p.APIKeys {
+		if k.User.ID == userID && k.Revoked == nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].Creation.Before(keys[j].Creation)
+	})
+	return keys, nil
+}
+
+// updateUser journals updated and applies it to user.
+// The caller is expected to hold the lock of p.
+func (p *Inmem) updateUser(user, updated *model.User) (*model.User, error) {
+	if err := p.journal.logUser(updated); err != nil {
+		return nil, err
+	}
+	*user = *updated
+	p.compactIfNeeded()
+	return user, nil
+}
+
+// isAdmin returns true if the client has the admin role and
+// isn't authenticated by an API key lacking the ADMIN scope.
+func (p *Inmem) isAdmin(ctx context.Context) bool {
+	u := p.userByID(reqctx.GetRequestContext(ctx).UserID)
+	return u != nil && u.Admin && auth.HasScope(ctx, model.APIKeyScopeAdmin)
+}
+
+// requirePermission returns nil if the client is allowed to perform
+// action a on project.
+func (p *Inmem) requirePermission(
+	ctx context.Context, project *model.Project, a permission.Action,
+) error {
+	userID := reqctx.GetRequestContext(ctx).UserID
+	return permission.Require(ctx, permission.Subject{
+		Admin: p.isAdmin(ctx),
+		Role:  permission.RoleOf(project, userID),
+	}, a)
+}
+
+// canView returns true if the client is allowed to view project.
+func (p *Inmem) canView(ctx context.Context, project *model.Project) bool {
+	return p.requirePermission(ctx, project, permission.View) == nil
+}
+
+// viewableTasks filters out the tasks of projects
+// the client isn't allowed to view.
+func (p *Inmem) viewableTasks(
+	ctx context.Context, tasks []*model.Task,
+) []*model.Task {
+	return slices.FilterInPlace(tasks, func(t *model.Task) bool {
+		return p.canView(ctx, t.Project)
+	})
+}
+
+// owners returns the active users referenced by ids.
+func (p *Inmem) owners(ids []string) ([]*model.User, error) {
+	var users []*model.User
+	for _, id := range ids {
+		u := p.userByID(id)
+		if u == nil {
+			return nil, fmt.Errorf("owner user %q not found", id)
+		}
+		if u.Deactivated != nil {
+			return nil, fmt.Errorf("owner user %q is deactivated", id)
+		}
+		users = slices.AppendUnique(users, u)
+	}
+	return users, nil
 }
 
 func (p *Inmem) userByID(id string) *model.User {
@@ -785,27 +2115,31 @@ func (p *Inmem) taskByID(id string) *model.Task {
 	return nil
 }
 
-// limitInt returns -1 if n == null, otherwise returns the value of n.
-func limitInt(n *int) int {
-	if n == nil {
-		return -1
+func (p *Inmem) commentByID(id string) *model.Comment {
+	for _, x := range p.Comments {
+		if x.ID == id {
+			return x
+		}
+	}
+	return nil
+}
+
+func (p *Inmem) sessionByID(id string) *model.Session {
+	for _, x := range p.Sessions {
+		if x.ID == id {
+			return x
+		}
 	}
-	return *n
+	return nil
 }
 
-// taskPriorityScalar returns the scalar value of a task priority enum value.
-func taskPriorityScalar(p model.TaskPriority) int {
-	switch p {
-	case model.TaskPriorityBlocker:
-		return 4
-	case model.TaskPriorityHigh:
-		return 3
-	case model.TaskPriorityMedium:
-		return 2
-	case model.TaskPriorityLow:
-		return 1
+func (p *Inmem) apiKeyByID(id string) *model.APIKey {
+	for _, x := range p.APIKeys {
+		if x.ID == id {
+			return x
+		}
 	}
-	return 0
+	return nil
 }
 
 // makeID trims spaces, replaces all whitespace sequences with underscores,
@@ -817,121 +2151,223 @@ func makeID(name string) string {
 	return strings.ToLower(s)
 }
 
-func getUserID(u *model.User) string { return u.ID }
+// newUserID returns a new unique user ID derived from displayName.
+func (p *Inmem) newUserID(displayName string) string {
+	return uniqueID("user_"+makeID(displayName), func(id string) bool {
+		return p.userByID(id) != nil
+	})
+}
 
-func sortFnUsers(order *model.UsersOrder, asc bool) func(a, b *model.User) bool {
-	if order == nil {
-		return nil
+// newProjectID returns a new unique project ID derived from name.
+func (p *Inmem) newProjectID(name string) string {
+	return uniqueID("project_"+makeID(name), func(id string) bool {
+		return p.projectByID(id) != nil
+	})
+}
+
+// newTaskID returns a new unique task ID derived from title.
+func (p *Inmem) newTaskID(title string) string {
+	return uniqueID("task_"+makeID(title), func(id string) bool {
+		return p.taskByID(id) != nil
+	})
+}
+
+// newCommentID returns a new unique comment ID.
+func (p *Inmem) newCommentID() string {
+	for i := len(p.Comments) + 1; ; i++ {
+		if id := fmt.Sprintf("comment_%d", i); p.commentByID(id) == nil {
+			return id
+		}
 	}
-	switch *order {
-	case model.UsersOrderNameAlpha:
-		return func(a, b *model.User) bool {
-			if asc {
-				return a.DisplayName < b.DisplayName
-			}
-			return a.DisplayName > b.DisplayName
+}
+
+// newSessionID returns a new unique session ID.
+func (p *Inmem) newSessionID() string {
+	for i := len(p.Sessions) + 1; ; i++ {
+		if id := fmt.Sprintf("session_%d", i); p.sessionByID(id) == nil {
+			return id
 		}
 	}
-	return nil
 }
 
-func (p *Inmem) sortFnProjects(
-	order *model.ProjectsOrder,
-	asc bool,
-) func(a, b *model.Project) bool {
-	if order == nil {
-		return nil
+// newAPIKeyID returns a new unique API key ID.
+func (p *Inmem) newAPIKeyID() string {
+	for i := len(p.APIKeys) + 1; ; i++ {
+		if id := fmt.Sprintf("apikey_%d", i); p.apiKeyByID(id) == nil {
+			return id
+		}
 	}
-	switch *order {
-	case model.ProjectsOrderNameAlpha:
-		return func(a, b *model.Project) bool {
-			if asc {
-				return a.Name < b.Name
-			}
-			return a.Name > b.Name
+}
+
+// uniqueID returns id if it's not taken, otherwise returns id with
+// the smallest numeric suffix that isn't taken.
+// IDs must remain unique since names can change.
+func uniqueID(id string, taken func(id string) bool) string {
+	if !taken(id) {
+		return id
+	}
+	for i := 2; ; i++ {
+		if s := fmt.Sprintf("%s_%d", id, i); !taken(s) {
+			return s
 		}
-	case model.ProjectsOrderNumMembers:
-		return func(a, b *model.Project) bool {
-			am, bm := []string{}, []string{}
-			for _, t := range p.Tasks {
-				switch t.Project {
-				case a:
-					for _, a := range t.Assignees {
-						am = slices.AppendUnique(am, a.ID)
-					}
-					for _, a := range t.Reporters {
-						am = slices.AppendUnique(am, a.ID)
-					}
-				case b:
-					for _, a := range t.Assignees {
-						bm = slices.AppendUnique(bm, a.ID)
-					}
-					for _, a := range t.Reporters {
-						bm = slices.AppendUnique(bm, a.ID)
-					}
-				}
-			}
-			if asc {
-				return len(am) < len(bm)
-			}
-			return len(am) > len(bm)
+	}
+}
+
+// dedupe returns s without duplicates preserving the order of first occurrence.
+func dedupe[T comparable](s []T) (r []T) {
+	for _, x := range s {
+		r = slices.AppendUnique(r, x)
+	}
+	return r
+}
+
+// without returns a copy of s without x.
+func without[T comparable](s []T, x T) (r []T) {
+	for _, y := range s {
+		if y != x {
+			r = append(r, y)
 		}
-	case model.ProjectsOrderNumTasks:
-		return func(a, b *model.Project) bool {
-			countA, countB := 0, 0
-			for _, t := range p.Tasks {
-				switch t.Project {
-				case a:
-					countA++
-				case b:
-					countB++
-				}
-			}
-			if asc {
-				return countA < countB
+	}
+	return r
+}
+
+// withOwners returns a copy of grants with the OWNER role granted to owners
+// and revoked from all other owners.
+func withOwners(
+	grants []*model.ProjectRoleGrant, owners []*model.User,
+) []*model.ProjectRoleGrant {
+	var r []*model.ProjectRoleGrant
+	for _, g := range grants {
+		switch {
+		case slices.Contains(owners, g.User):
+			r = append(r, &model.ProjectRoleGrant{
+				User: g.User, Role: model.ProjectRoleOwner,
+			})
+		case g.Role != model.ProjectRoleOwner:
+			r = append(r, g)
+		}
+	}
+NEXT_OWNER:
+	for _, u := range owners {
+		for _, g := range grants {
+			if g.User == u {
+				continue NEXT_OWNER
 			}
-			return countA > countB
 		}
+		r = append(r, &model.ProjectRoleGrant{
+			User: u, Role: model.ProjectRoleOwner,
+		})
 	}
-	return nil
+	return r
+}
+
+// withoutGrant returns a copy of grants without the grant of the user.
+func withoutGrant(
+	grants []*model.ProjectRoleGrant, userID string,
+) (r []*model.ProjectRoleGrant) {
+	for _, g := range grants {
+		if g.User.ID != userID {
+			r = append(r, g)
+		}
+	}
+	return r
+}
+
+// apiKeyScopes returns the unique scopes of s.
+// Returns an error if s is empty or contains invalid scopes.
+func apiKeyScopes(s []model.APIKeyScope) ([]model.APIKeyScope, error) {
+	if len(s) < 1 {
+		return nil, errors.New("API key requires at least one scope")
+	}
+	var r []model.APIKeyScope
+	for _, x := range s {
+		if !x.IsValid() {
+			return nil, fmt.Errorf("invalid API key scope %q", x)
+		}
+		r = slices.AppendUnique(r, x)
+	}
+	return r, nil
+}
+
+// withoutThread returns a copy of comments without c and all of
+// its direct and indirect replies. Replies are expected to
+// follow their parents in comments.
+func withoutThread(comments []*model.Comment, c *model.Comment) []*model.Comment {
+	removed := map[*model.Comment]bool{c: true}
+	r := make([]*model.Comment, 0, len(comments))
+	for _, x := range comments {
+		if removed[x] || (x.Parent != nil && removed[x.Parent]) {
+			removed[x] = true
+			continue
+		}
+		r = append(r, x)
+	}
+	return r
+}
+
+// sortByCreation sorts comments by creation time
+// preserving the order of comments created at the same time.
+func sortByCreation(comments []*model.Comment) {
+	sort.SliceStable(comments, func(i, j int) bool {
+		return comments[i].Creation.Before(comments[j].Creation)
+	})
 }
 
-func sortFnTasks(order *model.TasksOrder, asc bool) func(a, b *model.Task) bool {
+func getUserID(u *model.User) string { return u.ID }
+
+func getTaskID(t *model.Task) string { return t.ID }
+
+// projectCounts returns the number of members or tasks of all projects
+// depending on order.
+func (p *Inmem) projectCounts(
+	order *model.ProjectsOrder,
+) map[*model.Project]int {
+	counts := map[*model.Project]int{}
 	if order == nil {
-		return nil
+		return counts
 	}
 	switch *order {
-	case model.TasksOrderTitleAlpha:
-		return func(a, b *model.Task) bool {
-			if asc {
-				return a.Title < b.Title
+	case model.ProjectsOrderNumMembers:
+		members := map[*model.Project][]string{}
+		for _, t := range p.Tasks {
+			for _, u := range t.Assignees {
+				members[t.Project] = slices.AppendUnique(members[t.Project], u.ID)
 			}
-			return a.Title > b.Title
-		}
-	case model.TasksOrderPriority:
-		return func(a, b *model.Task) bool {
-			if asc {
-				return taskPriorityScalar(a.Priority) < taskPriorityScalar(b.Priority)
+			for _, u := range t.Reporters {
+				members[t.Project] = slices.AppendUnique(members[t.Project], u.ID)
 			}
-			return taskPriorityScalar(a.Priority) > taskPriorityScalar(b.Priority)
 		}
-	case model.TasksOrderDueTime:
-		return func(a, b *model.Task) bool {
-			if a.Due == nil || b.Due == nil {
-				return asc
-			}
-			if asc {
-				return a.Due.Unix() < b.Due.Unix()
-			}
-			return a.Due.Unix() > b.Due.Unix()
+		for x, m := range members {
+			counts[x] = len(m)
 		}
-	case model.TasksOrderCreationTime:
-		return func(a, b *model.Task) bool {
-			if asc {
-				return a.Creation.Unix() < b.Creation.Unix()
-			}
-			return a.Creation.Unix() > b.Creation.Unix()
+	case model.ProjectsOrderNumTasks:
+		for _, t := range p.Tasks {
+			counts[t.Project]++
 		}
 	}
-	return nil
+	return counts
+}
+
+func newTaskConnection(
+	tasks []*model.Task,
+	order *model.TasksOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) *model.TaskConnection {
+	nodes, cursors, info := dataprovider.Paginate(
+		tasks,
+		func(t *model.Task) dataprovider.Cursor {
+			return dataprovider.TaskCursor(order, t)
+		},
+		orderAsc, page,
+	)
+	c := &model.TaskConnection{
+		Edges:      make([]*model.TaskEdge, len(nodes)),
+		PageInfo:   info,
+		TotalCount: len(tasks),
+	}
+	for i, n := range nodes {
+		c.Edges[i] = &model.TaskEdge{Cursor: cursors[i], Node: n}
+	}
+	return c
 }
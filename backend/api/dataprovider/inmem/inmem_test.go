@@ -0,0 +1,35 @@
+package inmem_test
+
+import (
+	"testing"
+
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/dataprovider/inmem"
+	"github.com/romshark/taskhub/api/dataprovider/providertest"
+)
+
+func TestConformance(t *testing.T) {
+	for _, td := range []struct {
+		name        string
+		newProvider providertest.NewProvider
+	}{
+		{"Inmem", func(t *testing.T) dataprovider.DataProvider {
+			return new(inmem.Inmem)
+		}},
+		{"Journaled", func(t *testing.T) dataprovider.DataProvider {
+			p, err := inmem.OpenJournaled(inmem.JournalOptions{
+				DirPath:          t.TempDir(),
+				CompactThreshold: 8,
+			}, func() *inmem.Inmem { return new(inmem.Inmem) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { p.Close() })
+			return p
+		}},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			providertest.Run(t, td.newProvider)
+		})
+	}
+}
@@ -0,0 +1,930 @@
+package inmem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/romshark/taskhub/api/graph/model"
+)
+
+const (
+	journalFileSnapshot = "snapshot.json"
+	journalFileWAL      = "wal.jsonl"
+
+	// DefaultCompactThreshold is the default number of write-ahead log records
+	// after which the log is compacted into a new snapshot.
+	DefaultCompactThreshold = 1024
+)
+
+// JournalOptions configures the journal of an in-memory data provider.
+type JournalOptions struct {
+	// DirPath is the directory the snapshot and the write-ahead log are
+	// stored in. The directory is created if it doesn't exist.
+	DirPath string
+
+	// CompactThreshold is the number of write-ahead log records after which
+	// the log is compacted into a new snapshot.
+	// Uses DefaultCompactThreshold if 0.
+	CompactThreshold int
+
+	// OnCompaction is invoked after every automatic compaction
+	// with the error if the compaction failed. A failed compaction
+	// doesn't affect the mutation that triggered it and is retried
+	// on the next mutation.
+	OnCompaction func(err error)
+}
+
+// OpenJournaled restores the in-memory data provider from the journal in
+// opts.DirPath by loading the last snapshot and replaying the write-ahead log.
+// If there is no journal yet, the provider created by init is used as
+// initial state and is written to the first snapshot.
+// Every subsequent mutation is appended to the write-ahead log
+// before it's applied.
+//
+// The returned provider must be closed after use.
+func OpenJournaled(opts JournalOptions, init func() *Inmem) (*Inmem, error) {
+	if opts.CompactThreshold == 0 {
+		opts.CompactThreshold = DefaultCompactThreshold
+	}
+	if err := os.MkdirAll(opts.DirPath, 0o700); err != nil {
+		return nil, fmt.Errorf("creating journal directory: %w", err)
+	}
+
+	pathSnapshot := filepath.Join(opts.DirPath, journalFileSnapshot)
+	pathWAL := filepath.Join(opts.DirPath, journalFileWAL)
+
+	var (
+		snapshot   journalSnapshot
+		walRecords []journalRecord
+		walSize    int64
+		fresh      bool
+	)
+	snapshotFile, err := os.ReadFile(pathSnapshot)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		fresh = true
+	case err != nil:
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	default:
+		if err := json.Unmarshal(snapshotFile, &snapshot); err != nil {
+			return nil, fmt.Errorf("decoding snapshot: %w", err)
+		}
+		walRecords, walSize, err = readWAL(pathWAL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var p *Inmem
+	if fresh {
+		if init != nil {
+			p = init()
+		} else {
+			p = new(Inmem)
+		}
+	} else {
+		p, err = restore(snapshot, walRecords)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	j := &journal{
+		pathSnapshot:     pathSnapshot,
+		pathWAL:          pathWAL,
+		compactThreshold: opts.CompactThreshold,
+		onCompaction:     opts.OnCompaction,
+		records:          len(walRecords),
+	}
+	// Drop any partially written trailing record.
+	if err := j.openWAL(walSize); err != nil {
+		return nil, err
+	}
+	p.journal = j
+
+	if fresh {
+		if err := j.compact(p); err != nil {
+			_ = j.close()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Close closes the journal if any.
+func (p *Inmem) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.journal == nil {
+		return nil
+	}
+	return p.journal.close()
+}
+
+// Compact writes the current state to a new snapshot and
+// truncates the write-ahead log. Compact is a no-op if the
+// provider has no journal.
+func (p *Inmem) Compact() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.journal == nil {
+		return nil
+	}
+	return p.journal.compact(p)
+}
+
+// journal is a write-ahead log with periodic snapshots.
+// A nil journal is valid and ignores all writes.
+type journal struct {
+	pathSnapshot     string
+	pathWAL          string
+	compactThreshold int
+	onCompaction     func(error)
+
+	wal     *os.File
+	records int
+}
+
+// journalRecord is a single write-ahead log entry holding either
+// the complete state of exactly one created or updated entity
+// or the ID of a deleted task or comment.
+type journalRecord struct {
+	User           *journalUser    `json:"user,omitempty"`
+	Project        *journalProject `json:"project,omitempty"`
+	Task           *journalTask    `json:"task,omitempty"`
+	Comment        *journalComment `json:"comment,omitempty"`
+	Session        *journalSession `json:"session,omitempty"`
+	APIKey         *journalAPIKey  `json:"apiKey,omitempty"`
+	DeletedTask    string          `json:"deletedTask,omitempty"`
+	DeletedComment string          `json:"deletedComment,omitempty"`
+
+	Organization *journalOrganization `json:"organization,omitempty"`
+}
+
+type journalSnapshot struct {
+	Users    []*journalUser    `json:"users"`
+	Projects []*journalProject `json:"projects"`
+	Tasks    []*journalTask    `json:"tasks"`
+	Comments []*journalComment `json:"comments,omitempty"`
+	Sessions []*journalSession `json:"sessions,omitempty"`
+	APIKeys  []*journalAPIKey  `json:"apiKeys,omitempty"`
+
+	Organization *journalOrganization `json:"organization,omitempty"`
+}
+
+type journalUser struct {
+	ID             string     `json:"id"`
+	Email          string     `json:"email"`
+	DisplayName    string     `json:"displayName"`
+	Role           string     `json:"role"`
+	Location       string     `json:"location"`
+	PersonalStatus string     `json:"personalStatus"`
+	Manager        *string    `json:"manager,omitempty"`
+	Subordinates   []string   `json:"subordinates,omitempty"`
+	Deactivated    *time.Time `json:"deactivated,omitempty"`
+	Admin          bool       `json:"admin,omitempty"`
+	PasswordHash   string     `json:"passwordHash"`
+
+	TwoFactorEnabled   bool     `json:"twoFactorEnabled,omitempty"`
+	TOTPSecret         string   `json:"totpSecret,omitempty"`
+	TOTPLastStep       int64    `json:"totpLastStep,omitempty"`
+	RecoveryCodeHashes []string `json:"recoveryCodeHashes,omitempty"`
+}
+
+type journalOrganization struct {
+	TwoFactorRequired bool `json:"twoFactorRequired"`
+}
+
+type journalProject struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Slug        string             `json:"slug"`
+	Creation    time.Time          `json:"creation"`
+	Archived    *time.Time         `json:"archived,omitempty"`
+	Roles       []journalRoleGrant `json:"roles,omitempty"`
+
+	// Owners is only read from journals written before project roles
+	// were introduced, the owners are restored as OWNER grants.
+	Owners []string `json:"owners,omitempty"`
+}
+
+type journalRoleGrant struct {
+	User string            `json:"user"`
+	Role model.ProjectRole `json:"role"`
+}
+
+type journalTask struct {
+	ID          string             `json:"id"`
+	Title       string             `json:"title"`
+	Description *string            `json:"description,omitempty"`
+	Priority    model.TaskPriority `json:"priority"`
+	Status      model.TaskStatus   `json:"status"`
+	Creation    time.Time          `json:"creation"`
+	Due         *time.Time         `json:"due,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Project     string             `json:"project"`
+	Assignees   []string           `json:"assignees,omitempty"`
+	Reporters   []string           `json:"reporters,omitempty"`
+	Blocks      []string           `json:"blocks,omitempty"`
+	RelatesTo   []string           `json:"relatesTo,omitempty"`
+}
+
+type journalComment struct {
+	ID       string     `json:"id"`
+	Task     string     `json:"task"`
+	Author   string     `json:"author"`
+	Body     string     `json:"body"`
+	Creation time.Time  `json:"creation"`
+	Edited   *time.Time `json:"edited,omitempty"`
+	Parent   *string    `json:"parent,omitempty"`
+}
+
+type journalSession struct {
+	ID                       string     `json:"id"`
+	User                     string     `json:"user"`
+	UserAgent                string     `json:"userAgent"`
+	Creation                 time.Time  `json:"creation"`
+	Refreshed                time.Time  `json:"refreshed"`
+	Expires                  time.Time  `json:"expires"`
+	Revoked                  *time.Time `json:"revoked,omitempty"`
+	RefreshTokenHash         string     `json:"refreshTokenHash"`
+	PreviousRefreshTokenHash string     `json:"previousRefreshTokenHash,omitempty"`
+}
+
+type journalAPIKey struct {
+	ID         string              `json:"id"`
+	User       string              `json:"user"`
+	Name       string              `json:"name"`
+	Kind       model.APIKeyKind    `json:"kind"`
+	Scopes     []model.APIKeyScope `json:"scopes"`
+	Creation   time.Time           `json:"creation"`
+	LastUsed   *time.Time          `json:"lastUsed,omitempty"`
+	Revoked    *time.Time          `json:"revoked,omitempty"`
+	SecretHash string              `json:"secretHash"`
+}
+
+func (j *journal) logUser(u *model.User) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{User: makeJournalUser(u)})
+}
+
+func (j *journal) logProject(p *model.Project) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{Project: makeJournalProject(p)})
+}
+
+func (j *journal) logTask(t *model.Task) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{Task: makeJournalTask(t)})
+}
+
+func (j *journal) logTaskDeletion(id string) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{DeletedTask: id})
+}
+
+func (j *journal) logComment(c *model.Comment) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{Comment: makeJournalComment(c)})
+}
+
+func (j *journal) logSession(s *model.Session) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{Session: makeJournalSession(s)})
+}
+
+func (j *journal) logAPIKey(k *model.APIKey) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{APIKey: makeJournalAPIKey(k)})
+}
+
+func (j *journal) logOrganization(o *model.Organization) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{
+		Organization: makeJournalOrganization(o),
+	})
+}
+
+func (j *journal) logCommentDeletion(id string) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(journalRecord{DeletedComment: id})
+}
+
+// compactIfNeeded compacts the journal if the write-ahead log
+// reached the compaction threshold.
+// The caller is expected to hold the lock of p.
+func (p *Inmem) compactIfNeeded() {
+	j := p.journal
+	if j == nil || j.records < j.compactThreshold {
+		return
+	}
+	err := j.compact(p)
+	if j.onCompaction != nil {
+		j.onCompaction(err)
+	}
+}
+
+// append writes r to the write-ahead log and syncs it to disk.
+func (j *journal) append(r journalRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encoding journal record: %w", err)
+	}
+	b = append(b, '\n')
+	if _, err := j.wal.Write(b); err != nil {
+		return fmt.Errorf("writing journal record: %w", err)
+	}
+	if err := j.wal.Sync(); err != nil {
+		return fmt.Errorf("syncing write-ahead log: %w", err)
+	}
+	j.records++
+	return nil
+}
+
+// compact atomically replaces the snapshot with the state of p
+// and truncates the write-ahead log.
+// The caller is expected to hold the lock of p.
+func (j *journal) compact(p *Inmem) error {
+	s := journalSnapshot{
+		Users:    make([]*journalUser, len(p.Users)),
+		Projects: make([]*journalProject, len(p.Projects)),
+		Tasks:    make([]*journalTask, len(p.Tasks)),
+		Comments: make([]*journalComment, len(p.Comments)),
+		Sessions: make([]*journalSession, len(p.Sessions)),
+		APIKeys:  make([]*journalAPIKey, len(p.APIKeys)),
+
+		Organization: makeJournalOrganization(&p.OrganizationSettings),
+	}
+	for i, u := range p.Users {
+		s.Users[i] = makeJournalUser(u)
+	}
+	for i, x := range p.Projects {
+		s.Projects[i] = makeJournalProject(x)
+	}
+	for i, t := range p.Tasks {
+		s.Tasks[i] = makeJournalTask(t)
+	}
+	for i, c := range p.Comments {
+		s.Comments[i] = makeJournalComment(c)
+	}
+	for i, x := range p.Sessions {
+		s.Sessions[i] = makeJournalSession(x)
+	}
+	for i, x := range p.APIKeys {
+		s.APIKeys[i] = makeJournalAPIKey(x)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	tmpPath := j.pathSnapshot + ".tmp"
+	if err := writeFileSync(tmpPath, b); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.pathSnapshot); err != nil {
+		return fmt.Errorf("replacing snapshot: %w", err)
+	}
+	// Records are idempotent, replaying a write-ahead log that wasn't
+	// truncated due to a crash on top of the new snapshot is safe.
+	if err := j.wal.Truncate(0); err != nil {
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+	if _, err := j.wal.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+	j.records = 0
+	return nil
+}
+
+// openWAL opens the write-ahead log for appending
+// truncating it to size first.
+func (j *journal) openWAL(size int64) error {
+	f, err := os.OpenFile(j.pathWAL, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening write-ahead log: %w", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("truncating write-ahead log: %w", err)
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("seeking write-ahead log: %w", err)
+	}
+	j.wal = f
+	return nil
+}
+
+func (j *journal) close() error {
+	if err := j.wal.Close(); err != nil {
+		return fmt.Errorf("closing write-ahead log: %w", err)
+	}
+	return nil
+}
+
+// readWAL reads all complete records from the write-ahead log at path and
+// returns them together with the size of the valid part of the log.
+// A trailing record that was only partially written (e.g. due to a crash)
+// is ignored.
+func readWAL(path string) (records []journalRecord, size int64, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening write-ahead log: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Either the end of the log or a partially written record.
+			return records, size, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("reading write-ahead log: %w", err)
+		}
+		var rec journalRecord
+		if err := json.Unmarshal(bytes.TrimSpace(b), &rec); err != nil {
+			return nil, 0, fmt.Errorf(
+				"decoding write-ahead log record at line %d: %w", line, err,
+			)
+		}
+		records = append(records, rec)
+		size += int64(len(b))
+	}
+}
+
+// restore creates a new in-memory provider from snapshot
+// with records applied on top of it.
+func restore(snapshot journalSnapshot, records []journalRecord) (*Inmem, error) {
+	// Collect the latest state of every entity in order of first appearance.
+	var (
+		users    = newLatest[*journalUser]()
+		projects = newLatest[*journalProject]()
+		tasks    = newLatest[*journalTask]()
+		comments = newLatest[*journalComment]()
+		sessions = newLatest[*journalSession]()
+		apiKeys  = newLatest[*journalAPIKey]()
+
+		organization = snapshot.Organization
+	)
+	for _, u := range snapshot.Users {
+		users.put(u.ID, u)
+	}
+	for _, p := range snapshot.Projects {
+		projects.put(p.ID, p)
+	}
+	for _, t := range snapshot.Tasks {
+		tasks.put(t.ID, t)
+	}
+	for _, c := range snapshot.Comments {
+		comments.put(c.ID, c)
+	}
+	for _, x := range snapshot.Sessions {
+		sessions.put(x.ID, x)
+	}
+	for _, x := range snapshot.APIKeys {
+		apiKeys.put(x.ID, x)
+	}
+	for _, r := range records {
+		switch {
+		case r.User != nil:
+			users.put(r.User.ID, r.User)
+		case r.Project != nil:
+			projects.put(r.Project.ID, r.Project)
+		case r.Task != nil:
+			tasks.put(r.Task.ID, r.Task)
+		case r.Comment != nil:
+			comments.put(r.Comment.ID, r.Comment)
+		case r.Session != nil:
+			sessions.put(r.Session.ID, r.Session)
+		case r.APIKey != nil:
+			apiKeys.put(r.APIKey.ID, r.APIKey)
+		case r.Organization != nil:
+			organization = r.Organization
+		case r.DeletedTask != "":
+			tasks.remove(r.DeletedTask)
+		case r.DeletedComment != "":
+			comments.remove(r.DeletedComment)
+		}
+	}
+
+	p := &Inmem{
+		Users:    make([]*model.User, len(users.list)),
+		Projects: make([]*model.Project, len(projects.list)),
+		Tasks:    make([]*model.Task, len(tasks.list)),
+	}
+	for i, u := range users.list {
+		p.Users[i] = &model.User{
+			ID:             u.ID,
+			Email:          u.Email,
+			DisplayName:    u.DisplayName,
+			Role:           u.Role,
+			Location:       u.Location,
+			PersonalStatus: u.PersonalStatus,
+			Deactivated:    u.Deactivated,
+			Admin:          u.Admin,
+			PasswordHash:   u.PasswordHash,
+
+			TwoFactorEnabled:   u.TwoFactorEnabled,
+			TOTPSecret:         u.TOTPSecret,
+			TOTPLastStep:       u.TOTPLastStep,
+			RecoveryCodeHashes: u.RecoveryCodeHashes,
+		}
+	}
+	if organization != nil {
+		p.OrganizationSettings = model.Organization{
+			TwoFactorRequired: organization.TwoFactorRequired,
+		}
+	}
+	for i, x := range projects.list {
+		p.Projects[i] = &model.Project{
+			ID:          x.ID,
+			Name:        x.Name,
+			Description: x.Description,
+			Slug:        x.Slug,
+			Creation:    x.Creation,
+			Archived:    x.Archived,
+		}
+	}
+	for i, t := range tasks.list {
+		p.Tasks[i] = &model.Task{
+			ID:          t.ID,
+			Title:       t.Title,
+			Description: t.Description,
+			Priority:    t.Priority,
+			Status:      t.Status,
+			Creation:    t.Creation,
+			Due:         t.Due,
+			Tags:        t.Tags,
+		}
+	}
+
+	// Link relations once all entities exist.
+	var err error
+	for i, u := range users.list {
+		x := p.Users[i]
+		if u.Manager != nil {
+			if x.Manager = p.userByID(*u.Manager); x.Manager == nil {
+				return nil, fmt.Errorf(
+					"restoring user %q: manager %q not found", u.ID, *u.Manager,
+				)
+			}
+		}
+		if x.Subordinates, err = resolve(u.Subordinates, p.userByID); err != nil {
+			return nil, fmt.Errorf("restoring user %q subordinates: %w", u.ID, err)
+		}
+	}
+	for i, x := range projects.list {
+		grants := x.Roles
+		for _, id := range x.Owners {
+			grants = append(grants, journalRoleGrant{
+				User: id, Role: model.ProjectRoleOwner,
+			})
+		}
+		for _, g := range grants {
+			u := p.userByID(g.User)
+			if u == nil {
+				return nil, fmt.Errorf(
+					"restoring project %q roles: %q not found", x.ID, g.User,
+				)
+			}
+			// Deactivation records don't carry the revocation of the roles
+			// of the user and its removal from the assignees.
+			if u.Deactivated != nil {
+				continue
+			}
+			p.Projects[i].Roles = append(
+				p.Projects[i].Roles, &model.ProjectRoleGrant{User: u, Role: g.Role},
+			)
+		}
+	}
+	for i, t := range tasks.list {
+		x := p.Tasks[i]
+		if x.Project = p.projectByID(t.Project); x.Project == nil {
+			return nil, fmt.Errorf(
+				"restoring task %q: project %q not found", t.ID, t.Project,
+			)
+		}
+		if x.Assignees, err = resolve(t.Assignees, p.userByID); err != nil {
+			return nil, fmt.Errorf("restoring task %q assignees: %w", t.ID, err)
+		}
+		x.Assignees = activeUsers(x.Assignees)
+		if x.Reporters, err = resolve(t.Reporters, p.userByID); err != nil {
+			return nil, fmt.Errorf("restoring task %q reporters: %w", t.ID, err)
+		}
+		// Deletion records don't carry the removal of the deleted task
+		// from the blocks and relatesTo lists of other tasks.
+		if x.Blocks, err = resolve(
+			tasks.filter(t.Blocks), p.taskByID,
+		); err != nil {
+			return nil, fmt.Errorf("restoring task %q blocks: %w", t.ID, err)
+		}
+		if x.RelatesTo, err = resolve(
+			tasks.filter(t.RelatesTo), p.taskByID,
+		); err != nil {
+			return nil, fmt.Errorf("restoring task %q relatesTo: %w", t.ID, err)
+		}
+	}
+	// Deletion records don't carry the removal of the comments of
+	// deleted tasks and the replies to deleted comments.
+	// Replies always follow their parents.
+	for _, c := range comments.list {
+		x := &model.Comment{
+			ID:       c.ID,
+			Body:     c.Body,
+			Creation: c.Creation,
+			Edited:   c.Edited,
+		}
+		if x.Task = p.taskByID(c.Task); x.Task == nil {
+			continue
+		}
+		if c.Parent != nil {
+			if x.Parent = p.commentByID(*c.Parent); x.Parent == nil {
+				continue
+			}
+		}
+		if x.Author = p.userByID(c.Author); x.Author == nil {
+			return nil, fmt.Errorf(
+				"restoring comment %q: author %q not found", c.ID, c.Author,
+			)
+		}
+		p.Comments = append(p.Comments, x)
+	}
+	for _, x := range sessions.list {
+		s := &model.Session{
+			ID:                       x.ID,
+			UserAgent:                x.UserAgent,
+			Creation:                 x.Creation,
+			Refreshed:                x.Refreshed,
+			Expires:                  x.Expires,
+			Revoked:                  x.Revoked,
+			RefreshTokenHash:         x.RefreshTokenHash,
+			PreviousRefreshTokenHash: x.PreviousRefreshTokenHash,
+		}
+		if s.User = p.userByID(x.User); s.User == nil {
+			return nil, fmt.Errorf(
+				"restoring session %q: user %q not found", x.ID, x.User,
+			)
+		}
+		p.Sessions = append(p.Sessions, s)
+	}
+	for _, x := range apiKeys.list {
+		k := &model.APIKey{
+			ID:         x.ID,
+			Name:       x.Name,
+			Kind:       x.Kind,
+			Scopes:     x.Scopes,
+			Creation:   x.Creation,
+			LastUsed:   x.LastUsed,
+			Revoked:    x.Revoked,
+			SecretHash: x.SecretHash,
+		}
+		if k.User = p.userByID(x.User); k.User == nil {
+			return nil, fmt.Errorf(
+				"restoring API key %q: user %q not found", x.ID, x.User,
+			)
+		}
+		p.APIKeys = append(p.APIKeys, k)
+	}
+	return p, nil
+}
+
+// latest holds the latest version of entities in order of first appearance.
+type latest[T any] struct {
+	indexes map[string]int
+	list    []T
+}
+
+func newLatest[T any]() *latest[T] {
+	return &latest[T]{indexes: map[string]int{}}
+}
+
+// put overwrites the entity identified by id or appends it if it's new.
+func (l *latest[T]) put(id string, v T) {
+	if i, ok := l.indexes[id]; ok {
+		l.list[i] = v
+		return
+	}
+	l.indexes[id] = len(l.list)
+	l.list = append(l.list, v)
+}
+
+// remove removes the entity identified by id if any.
+func (l *latest[T]) remove(id string) {
+	i, ok := l.indexes[id]
+	if !ok {
+		return
+	}
+	l.list = append(l.list[:i], l.list[i+1:]...)
+	delete(l.indexes, id)
+	for id, j := range l.indexes {
+		if j > i {
+			l.indexes[id] = j - 1
+		}
+	}
+}
+
+// filter returns the ids of the entities that weren't removed.
+func (l *latest[T]) filter(ids []string) []string {
+	if ids == nil {
+		return nil
+	}
+	r := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := l.indexes[id]; ok {
+			r = append(r, id)
+		}
+	}
+	return r
+}
+
+// activeUsers returns s without deactivated users.
+func activeUsers(s []*model.User) []*model.User {
+	if s == nil {
+		return nil
+	}
+	r := make([]*model.User, 0, len(s))
+	for _, u := range s {
+		if u.Deactivated == nil {
+			r = append(r, u)
+		}
+	}
+	return r
+}
+
+// resolve returns the entities referenced by ids.
+func resolve[T any](ids []string, byID func(string) *T) ([]*T, error) {
+	if ids == nil {
+		return nil, nil
+	}
+	s := make([]*T, len(ids))
+	for i, id := range ids {
+		if s[i] = byID(id); s[i] == nil {
+			return nil, fmt.Errorf("%q not found", id)
+		}
+	}
+	return s, nil
+}
+
+func makeJournalUser(u *model.User) *journalUser {
+	j := &journalUser{
+		ID:             u.ID,
+		Email:          u.Email,
+		DisplayName:    u.DisplayName,
+		Role:           u.Role,
+		Location:       u.Location,
+		PersonalStatus: u.PersonalStatus,
+		Subordinates:   ids(u.Subordinates, getUserID),
+		Deactivated:    u.Deactivated,
+		Admin:          u.Admin,
+		PasswordHash:   u.PasswordHash,
+
+		TwoFactorEnabled:   u.TwoFactorEnabled,
+		TOTPSecret:         u.TOTPSecret,
+		TOTPLastStep:       u.TOTPLastStep,
+		RecoveryCodeHashes: u.RecoveryCodeHashes,
+	}
+	if u.Manager != nil {
+		j.Manager = &u.Manager.ID
+	}
+	return j
+}
+
+func makeJournalProject(p *model.Project) *journalProject {
+	j := &journalProject{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Slug:        p.Slug,
+		Creation:    p.Creation,
+		Archived:    p.Archived,
+	}
+	for _, g := range p.Roles {
+		j.Roles = append(j.Roles, journalRoleGrant{User: g.User.ID, Role: g.Role})
+	}
+	return j
+}
+
+func makeJournalTask(t *model.Task) *journalTask {
+	return &journalTask{
+		ID:          t.ID,
+		Title:       t.Title,
+		Description: t.Description,
+		Priority:    t.Priority,
+		Status:      t.Status,
+		Creation:    t.Creation,
+		Due:         t.Due,
+		Tags:        t.Tags,
+		Project:     t.Project.ID,
+		Assignees:   ids(t.Assignees, getUserID),
+		Reporters:   ids(t.Reporters, getUserID),
+		Blocks:      ids(t.Blocks, getTaskID),
+		RelatesTo:   ids(t.RelatesTo, getTaskID),
+	}
+}
+
+func makeJournalComment(c *model.Comment) *journalComment {
+	j := &journalComment{
+		ID:       c.ID,
+		Task:     c.Task.ID,
+		Author:   c.Author.ID,
+		Body:     c.Body,
+		Creation: c.Creation,
+		Edited:   c.Edited,
+	}
+	if c.Parent != nil {
+		j.Parent = &c.Parent.ID
+	}
+	return j
+}
+
+func makeJournalSession(s *model.Session) *journalSession {
+	return &journalSession{
+		ID:                       s.ID,
+		User:                     s.User.ID,
+		UserAgent:                s.UserAgent,
+		Creation:                 s.Creation,
+		Refreshed:                s.Refreshed,
+		Expires:                  s.Expires,
+		Revoked:                  s.Revoked,
+		RefreshTokenHash:         s.RefreshTokenHash,
+		PreviousRefreshTokenHash: s.PreviousRefreshTokenHash,
+	}
+}
+
+func makeJournalAPIKey(k *model.APIKey) *journalAPIKey {
+	return &journalAPIKey{
+		ID:         k.ID,
+		User:       k.User.ID,
+		Name:       k.Name,
+		Kind:       k.Kind,
+		Scopes:     k.Scopes,
+		Creation:   k.Creation,
+		LastUsed:   k.LastUsed,
+		Revoked:    k.Revoked,
+		SecretHash: k.SecretHash,
+	}
+}
+
+func makeJournalOrganization(o *model.Organization) *journalOrganization {
+	return &journalOrganization{TwoFactorRequired: o.TwoFactorRequired}
+}
+
+func ids[T any](s []T, getID func(T) string) []string {
+	if s == nil {
+		return nil
+	}
+	r := make([]string, len(s))
+	for i := range s {
+		r[i] = getID(s[i])
+	}
+	return r
+}
+
+// writeFileSync writes b to a new file at path and syncs it to disk.
+func writeFileSync(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
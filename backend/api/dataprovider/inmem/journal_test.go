@@ -0,0 +1,502 @@
+package inmem
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestJournalRestore(t *testing.T) {
+	dir := t.TempDir()
+	opts := JournalOptions{DirPath: dir}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	expect := mutate(t, p)
+	require.NoError(t, p.Close())
+
+	p, err = OpenJournaled(opts, func() *Inmem {
+		t.Fatal("init called on existing journal")
+		return nil
+	})
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, expect, encodeState(t, p))
+}
+
+func TestJournalCompaction(t *testing.T) {
+	dir := t.TempDir()
+	var compactions int
+	opts := JournalOptions{
+		DirPath:          dir,
+		CompactThreshold: 2,
+		OnCompaction: func(err error) {
+			require.NoError(t, err)
+			compactions++
+		},
+	}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	expect := mutate(t, p)
+	require.NoError(t, p.Close())
+
+	// 5 mutations with a threshold of 2 leave one record in the log.
+	require.Equal(t, 2, compactions)
+	require.Len(t, readLines(t, filepath.Join(dir, journalFileWAL)), 1)
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, expect, encodeState(t, p))
+}
+
+func TestJournalPartialRecord(t *testing.T) {
+	dir := t.TempDir()
+	opts := JournalOptions{DirPath: dir}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	expect := mutate(t, p)
+	require.NoError(t, p.Close())
+
+	// Simulate a crash during a write.
+	f, err := os.OpenFile(
+		filepath.Join(dir, journalFileWAL), os.O_APPEND|os.O_WRONLY, 0,
+	)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"user":{"id":"us`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	require.Equal(t, expect, encodeState(t, p))
+
+	// The partial record must be discarded before appending.
+	ctx := authenticated("user_ryan_lindsey")
+	_, err = p.CreateProject(ctx, time.Now(), "After crash", "", "AFTC", nil)
+	require.NoError(t, err)
+	expect = encodeState(t, p)
+	require.NoError(t, p.Close())
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, expect, encodeState(t, p))
+}
+
+func TestJournalCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	opts := JournalOptions{DirPath: dir}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	mutate(t, p)
+	require.NoError(t, p.Close())
+
+	f, err := os.OpenFile(
+		filepath.Join(dir, journalFileWAL), os.O_APPEND|os.O_WRONLY, 0,
+	)
+	require.NoError(t, err)
+	_, err = f.WriteString("corrupt\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = OpenJournaled(opts, nil)
+	require.ErrorContains(t, err, "decoding write-ahead log record at line 6")
+}
+
+func TestJournalRemoval(t *testing.T) {
+	dir := t.TempDir()
+	opts := JournalOptions{DirPath: dir}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	mutate(t, p)
+
+	// Both the deleted task and the deactivated user are
+	// referenced by the task created in mutate.
+	ctx := authenticated("user_ryan_lindsey")
+	addComment := func(task string, parent *string) *model.Comment {
+		c, err := p.AddComment(ctx, time.Now(), task, "comment", parent)
+		require.NoError(t, err)
+		return c
+	}
+	thread := addComment("task_new_task", nil)
+	reply := addComment("task_new_task", &thread.ID)
+	addComment("task_new_task", &reply.ID)
+	kept := addComment("task_new_task", nil)
+	_, err = p.EditComment(ctx, kept.ID, "edited", time.Now())
+	require.NoError(t, err)
+	addComment("task_corm_1", nil)
+	require.NoError(t, p.DeleteComment(ctx, thread.ID))
+	_, err = p.GrantProjectRole(
+		ctx, "project_new_project", "user_anne_williams",
+		model.ProjectRoleViewer,
+	)
+	require.NoError(t, err)
+	admin := authenticated("user_cedric_maude")
+	require.NoError(t, p.DeleteTask(admin, "task_corm_1"))
+	u, err := p.UserByEmail(ctx, "new@company.com")
+	require.NoError(t, err)
+	_, err = p.DeactivateUser(authenticated(u.ID), u.ID, time.Now())
+	require.NoError(t, err)
+	proj, err := p.ProjectByID(ctx, "project_new_project")
+	require.NoError(t, err)
+	_, err = p.ArchiveProject(ctx, proj.ID, time.Now())
+	require.NoError(t, err)
+	expect := encodeState(t, p)
+	require.NoError(t, p.Close())
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, expect, encodeState(t, p))
+	require.Nil(t, p.taskByID("task_corm_1"))
+	task := p.taskByID("task_new_task")
+	require.Empty(t, task.RelatesTo)
+	require.Empty(t, task.Assignees)
+	require.Equal(t, []string{u.ID}, ids(task.Reporters, getUserID))
+	require.NotNil(t, task.Project.Archived)
+	require.Equal(t, []*model.ProjectRoleGrant{{
+		User: p.userByID("user_ryan_lindsey"), Role: model.ProjectRoleOwner,
+	}, {
+		User: p.userByID("user_anne_williams"), Role: model.ProjectRoleViewer,
+	}}, task.Project.Roles, "expected the role of the deactivated user revoked")
+	require.Len(t, p.Comments, 1)
+	require.Equal(t, kept.ID, p.Comments[0].ID)
+	require.Equal(t, "edited", p.Comments[0].Body)
+}
+
+func TestJournalLegacyOwners(t *testing.T) {
+	dir := t.TempDir()
+	opts := JournalOptions{DirPath: dir}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+
+	// Records written before project roles were introduced
+	// carry the owners instead.
+	f, err := os.OpenFile(
+		filepath.Join(dir, journalFileWAL), os.O_APPEND|os.O_WRONLY, 0,
+	)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"project":{"id":"project_core_migration",` +
+		`"name":"Core Migration","description":"","slug":"CORM",` +
+		`"creation":"2023-01-01T00:00:00Z",` +
+		`"owners":["user_ryan_lindsey","user_anne_williams"]}}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, []*model.ProjectRoleGrant{{
+		User: p.userByID("user_ryan_lindsey"), Role: model.ProjectRoleOwner,
+	}, {
+		User: p.userByID("user_anne_williams"), Role: model.ProjectRoleOwner,
+	}}, p.projectByID("project_core_migration").Roles)
+}
+
+func TestJournalSessions(t *testing.T) {
+	dir := t.TempDir()
+	var compactions int
+	opts := JournalOptions{
+		DirPath:          dir,
+		CompactThreshold: 3,
+		OnCompaction: func(err error) {
+			require.NoError(t, err)
+			compactions++
+		},
+	}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	now := time.Now()
+	const userID = "user_ryan_lindsey"
+	s1, err := p.CreateSession(
+		context.Background(), now, userID, "Browser", "h1", now.Add(time.Hour),
+	)
+	require.NoError(t, err)
+	_, err = p.RotateRefreshToken(
+		context.Background(), s1.ID, "h1", "h2", now, now.Add(2*time.Hour),
+	)
+	require.NoError(t, err)
+	s2, err := p.CreateSession(
+		context.Background(), now, userID, "Phone", "h3", now.Add(time.Hour),
+	)
+	require.NoError(t, err)
+	_, err = p.RevokeSession(authenticated(userID), s2.ID, now)
+	require.NoError(t, err)
+	expect := encodeState(t, p)
+	require.NoError(t, p.Close())
+	require.Equal(t, 1, compactions)
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, expect, encodeState(t, p))
+
+	s, err := p.SessionByID(context.Background(), s1.ID)
+	require.NoError(t, err)
+	require.Equal(t, "h2", s.RefreshTokenHash)
+	require.Equal(t, "h1", s.PreviousRefreshTokenHash)
+	require.Equal(t, userID, s.User.ID)
+	s, err = p.SessionByID(context.Background(), s2.ID)
+	require.NoError(t, err)
+	require.NotNil(t, s.Revoked)
+
+	// New sessions must not reuse restored IDs.
+	s3, err := p.CreateSession(
+		context.Background(), now, userID, "", "h4", now.Add(time.Hour),
+	)
+	require.NoError(t, err)
+	require.NotEqual(t, s1.ID, s3.ID)
+	require.NotEqual(t, s2.ID, s3.ID)
+}
+
+func TestJournalAPIKeys(t *testing.T) {
+	dir := t.TempDir()
+	var compactions int
+	opts := JournalOptions{
+		DirPath:          dir,
+		CompactThreshold: 3,
+		OnCompaction: func(err error) {
+			require.NoError(t, err)
+			compactions++
+		},
+	}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	now := time.Now()
+	const userID = "user_ryan_lindsey"
+	ctx := authenticated(userID)
+	scopes := []model.APIKeyScope{model.APIKeyScopeReadTasks}
+	k1, err := p.CreateAPIKey(ctx, now, userID, "CI", scopes, "h1")
+	require.NoError(t, err)
+	require.NoError(t, p.MarkAPIKeyUsed(context.Background(), k1.ID, now))
+	k2, err := p.CreateAPIKey(ctx, now, userID, "Bot", scopes, "h2")
+	require.NoError(t, err)
+	_, err = p.RevokeAPIKey(ctx, k2.ID, now)
+	require.NoError(t, err)
+	expect := encodeState(t, p)
+	require.NoError(t, p.Close())
+	require.Equal(t, 1, compactions)
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, expect, encodeState(t, p))
+
+	k, err := p.APIKeyByID(context.Background(), k1.ID)
+	require.NoError(t, err)
+	require.Equal(t, "h1", k.SecretHash)
+	require.Equal(t, scopes, k.Scopes)
+	require.Equal(t, userID, k.User.ID)
+	require.NotNil(t, k.LastUsed)
+	k, err = p.APIKeyByID(context.Background(), k2.ID)
+	require.NoError(t, err)
+	require.NotNil(t, k.Revoked)
+
+	// New API keys must not reuse restored IDs.
+	k3, err := p.CreateAPIKey(ctx, now, userID, "New", scopes, "h3")
+	require.NoError(t, err)
+	require.NotEqual(t, k1.ID, k3.ID)
+	require.NotEqual(t, k2.ID, k3.ID)
+}
+
+func TestJournalTwoFactor(t *testing.T) {
+	dir := t.TempDir()
+	var compactions int
+	opts := JournalOptions{
+		DirPath:          dir,
+		CompactThreshold: 3,
+		OnCompaction: func(err error) {
+			require.NoError(t, err)
+			compactions++
+		},
+	}
+
+	p, err := OpenJournaled(opts, newFixture)
+	require.NoError(t, err)
+	const userID = "user_ryan_lindsey"
+	ctx := authenticated(userID)
+	_, err = p.SetTOTPSecret(ctx, userID, "SECRET")
+	require.NoError(t, err)
+	_, err = p.EnableTwoFactor(ctx, userID, 10, []string{"r1", "r2"})
+	require.NoError(t, err)
+	require.NoError(t, p.UseRecoveryCode(context.Background(), userID, "r1"))
+	require.NoError(t, p.UseTOTPStep(context.Background(), userID, 11))
+	_, err = p.SetTwoFactorRequired(
+		authenticated("user_cedric_maude"), true,
+	)
+	require.NoError(t, err)
+	expect := encodeState(t, p)
+	require.NoError(t, p.Close())
+	require.Equal(t, 1, compactions)
+
+	p, err = OpenJournaled(opts, nil)
+	require.NoError(t, err)
+	defer p.Close()
+	require.Equal(t, expect, encodeState(t, p))
+
+	u, err := p.UserByID(context.Background(), userID)
+	require.NoError(t, err)
+	require.True(t, u.TwoFactorEnabled)
+	require.Equal(t, "SECRET", u.TOTPSecret)
+	require.Equal(t, int64(11), u.TOTPLastStep)
+	require.Equal(t, []string{"r2"}, u.RecoveryCodeHashes)
+	o, err := p.Organization(context.Background())
+	require.NoError(t, err)
+	require.True(t, o.TwoFactorRequired)
+}
+
+// newFixture returns a provider with a minimal data set referenced
+// by the journal tests. Unlike NewFake it doesn't hash passwords,
+// which would dominate the duration of the tests.
+func newFixture() *Inmem {
+	ryan := &model.User{
+		ID: "user_ryan_lindsey", Email: "ryan_lindsey@company.com",
+		DisplayName: "Ryan Lindsey", Role: "Software Engineer",
+		Location: "Berlin", PasswordHash: "hash",
+	}
+	anne := &model.User{
+		ID: "user_anne_williams", Email: "anne_williams@company.com",
+		DisplayName: "Anne Williams", Role: "Project Manager",
+		Location: "Berlin", PasswordHash: "hash",
+		Subordinates: []*model.User{ryan},
+	}
+	cedric := &model.User{
+		ID: "user_cedric_maude", Email: "cedric_maude@company.com",
+		DisplayName: "Cedric Maude", Role: "CEO",
+		Location: "New York City", PasswordHash: "hash", Admin: true,
+	}
+	ryan.Manager = anne
+
+	project := &model.Project{
+		ID: "project_core_migration", Name: "Core Migration", Slug: "CORM",
+		Creation: time.Now().AddDate(0, -1, 0),
+		Roles: []*model.ProjectRoleGrant{
+			{User: anne, Role: model.ProjectRoleOwner},
+			{User: ryan, Role: model.ProjectRoleMember},
+		},
+	}
+	task := func(id, title string) *model.Task {
+		return &model.Task{
+			ID: id, Title: title, Project: project,
+			Status:    model.TaskStatusTodo,
+			Priority:  model.TaskPriorityMedium,
+			Creation:  time.Now().AddDate(0, 0, -1),
+			Assignees: []*model.User{ryan},
+			Reporters: []*model.User{anne},
+		}
+	}
+
+	return &Inmem{
+		Users:    []*model.User{ryan, anne, cedric},
+		Projects: []*model.Project{project},
+		Tasks: []*model.Task{
+			task("task_corm_0", "Task zero"),
+			task("task_corm_1", "Task one"),
+		},
+	}
+}
+
+// mutate applies a set of mutations to p and returns the encoded state.
+func mutate(t *testing.T, p *Inmem) string {
+	t.Helper()
+	ctx := authenticated("user_ryan_lindsey")
+
+	u, err := p.CreateUser(
+		ctx, "new@company.com", "hash", "New User",
+		"SWE", "Berlin", ptr("user_anne_williams"), nil,
+	)
+	require.NoError(t, err)
+
+	proj, err := p.CreateProject(
+		ctx, time.Now(), "New Project", "description", "NEWP",
+		[]string{"user_ryan_lindsey", u.ID},
+	)
+	require.NoError(t, err)
+
+	task, err := p.CreateTask(
+		ctx, time.Now(), "New task", proj.ID,
+		model.TaskStatusTodo, model.TaskPriorityHigh,
+		nil, ptr(time.Now().Add(time.Hour)), []string{"new"},
+		[]string{u.ID}, []string{"user_ryan_lindsey"},
+		[]string{"task_corm_0"}, nil,
+	)
+	require.NoError(t, err)
+
+	_, err = p.UpdateTask(
+		ctx, task.ID, "New task (updated)", ptr("description"),
+		model.TaskStatusDone, model.TaskPriorityLow, nil,
+		[]string{"updated"}, proj.ID,
+		[]string{u.ID}, []string{u.ID},
+		nil, []string{"task_corm_1"},
+	)
+	require.NoError(t, err)
+
+	_, err = p.UpdateProject(
+		ctx, proj.ID, "New Project (updated)", "", "NEWPU",
+		[]string{"user_ryan_lindsey", u.ID},
+	)
+	require.NoError(t, err)
+
+	return encodeState(t, p)
+}
+
+func authenticated(userID string) context.Context {
+	return reqctx.WithRequestContext(
+		context.Background(), slog.Default(), userID, "", time.Now(),
+	)
+}
+
+func encodeState(t *testing.T, p *Inmem) string {
+	t.Helper()
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	var s journalSnapshot
+	for _, u := range p.Users {
+		s.Users = append(s.Users, makeJournalUser(u))
+	}
+	for _, x := range p.Projects {
+		s.Projects = append(s.Projects, makeJournalProject(x))
+	}
+	for _, x := range p.Tasks {
+		s.Tasks = append(s.Tasks, makeJournalTask(x))
+	}
+	for _, x := range p.Comments {
+		s.Comments = append(s.Comments, makeJournalComment(x))
+	}
+	for _, x := range p.Sessions {
+		s.Sessions = append(s.Sessions, makeJournalSession(x))
+	}
+	for _, x := range p.APIKeys {
+		s.APIKeys = append(s.APIKeys, makeJournalAPIKey(x))
+	}
+	s.Organization = makeJournalOrganization(&p.OrganizationSettings)
+	b, err := json.MarshalIndent(s, "", " ")
+	require.NoError(t, err)
+	return string(b)
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
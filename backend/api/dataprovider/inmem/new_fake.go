@@ -6,6 +6,7 @@ import (
 
 	"github.com/romshark/taskhub/api/graph/model"
 	"github.com/romshark/taskhub/api/passhash"
+	"github.com/romshark/taskhub/api/permission"
 	"github.com/romshark/taskhub/slices"
 )
 
@@ -118,6 +119,7 @@ func NewFake() *Inmem {
 		DisplayName: "Cedric Maude",
 		Role:        "CEO",
 		Location:    "New York City",
+		Admin:       true,
 	}
 
 	r.Users = []*model.User{
@@ -198,20 +200,26 @@ func NewFake() *Inmem {
 	projectCoreMigration := &model.Project{
 		Name:     "Core Migration",
 		Slug:     "CORM",
-		Owners:   []*model.User{userPM_AnneWilliams},
 		Creation: time.Now().AddDate(0, -1, -2),
+		Roles: []*model.ProjectRoleGrant{
+			{User: userPM_AnneWilliams, Role: model.ProjectRoleOwner},
+		},
 	}
 	projectVendorPlatform := &model.Project{
 		Name:     "Vendor Platform",
 		Slug:     "VENP",
-		Owners:   []*model.User{userPM_JamesHunter},
 		Creation: time.Now().AddDate(0, 0, -22),
+		Roles: []*model.ProjectRoleGrant{
+			{User: userPM_JamesHunter, Role: model.ProjectRoleOwner},
+		},
 	}
 	projectPlatformUpgrade := &model.Project{
 		Name:     "Platform Upgrade",
 		Slug:     "PLUG",
-		Owners:   []*model.User{userPM_JamesHunter},
 		Creation: time.Now().AddDate(0, 0, -21).Add(4 * time.Second),
+		Roles: []*model.ProjectRoleGrant{
+			{User: userPM_JamesHunter, Role: model.ProjectRoleOwner},
+		},
 	}
 
 	r.Projects = []*model.Project{
@@ -439,6 +447,20 @@ func NewFake() *Inmem {
 		t.ID = fmt.Sprintf("task_%s_%d", makeID(t.Project.Slug), i)
 	}
 
+	// Make all assignees and reporters members of the projects
+	for _, t := range r.Tasks {
+		for _, users := range [][]*model.User{t.Assignees, t.Reporters} {
+			for _, u := range users {
+				if permission.RoleOf(t.Project, u.ID) != "" {
+					continue
+				}
+				t.Project.Roles = append(t.Project.Roles, &model.ProjectRoleGrant{
+					User: u, Role: model.ProjectRoleMember,
+				})
+			}
+		}
+	}
+
 	return r
 }
 
@@ -0,0 +1,221 @@
+package dataprovider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/romshark/taskhub/api/graph/model"
+
+	"golang.org/x/exp/slices"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects a window of an ordered list as defined by the
+// Relay cursor connections specification.
+// After and Before exclude all elements up to and from the given cursor,
+// First and Last then limit the result to the first or last N elements.
+// The zero value selects all elements.
+type Page struct {
+	First  *int
+	After  *Cursor
+	Last   *int
+	Before *Cursor
+}
+
+// Cursor is a stable position in an ordered list derived from
+// the sort key of the element at that position.
+// Cursors remain valid when elements are added or removed.
+//
+// Lists are ordered by Int (nil last regardless of the direction),
+// then String in the direction of the order. Ties are broken
+// by Creation and ID in ascending order.
+type Cursor struct {
+	// Order is the name of the order the cursor was derived from.
+	Order string `json:"o,omitempty"`
+	// Desc is true if the cursor was derived from a descending order.
+	Desc     bool   `json:"d,omitempty"`
+	Int      *int64 `json:"i,omitempty"`
+	String   string `json:"s,omitempty"`
+	Creation int64  `json:"c,omitempty"`
+	ID       string `json:"id"`
+}
+
+// ParseCursor parses a cursor encoded by Cursor.Encode.
+func ParseCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := new(Cursor)
+	d := json.NewDecoder(strings.NewReader(string(b)))
+	d.DisallowUnknownFields()
+	if err := d.Decode(c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Encode returns the opaque string representation of c.
+func (c Cursor) Encode() string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Compare returns -1 if c precedes d, 1 if c follows d and 0 if they're
+// equal in an ascending (asc=true) or descending order.
+func (c Cursor) Compare(d Cursor, asc bool) int {
+	if r := c.compareKey(d, asc); r != 0 {
+		return r
+	}
+	switch {
+	case c.Creation < d.Creation:
+		return -1
+	case c.Creation > d.Creation:
+		return 1
+	}
+	return strings.Compare(c.ID, d.ID)
+}
+
+func (c Cursor) compareKey(d Cursor, asc bool) (r int) {
+	switch {
+	case c.Int == nil && d.Int != nil:
+		return 1
+	case c.Int != nil && d.Int == nil:
+		return -1
+	case c.Int != nil && *c.Int < *d.Int:
+		r = -1
+	case c.Int != nil && *c.Int > *d.Int:
+		r = 1
+	default:
+		r = strings.Compare(c.String, d.String)
+	}
+	if !asc {
+		return -r
+	}
+	return r
+}
+
+// Paginate orders s by the cursors returned by key and
+// returns the window selected by page.
+func Paginate[T any](
+	s []T, key func(T) Cursor, asc bool, page Page,
+) (nodes []T, cursors []string, info *model.PageInfo) {
+	type entry struct {
+		node T
+		key  Cursor
+	}
+	entries := make([]entry, len(s))
+	for i, x := range s {
+		entries[i] = entry{node: x, key: key(x)}
+		entries[i].key.Desc = !asc
+	}
+	slices.SortFunc(entries, func(a, b entry) bool {
+		return a.key.Compare(b.key, asc) < 0
+	})
+
+	start, end := 0, len(entries)
+	if page.After != nil {
+		for start < end && entries[start].key.Compare(*page.After, asc) <= 0 {
+			start++
+		}
+	}
+	if page.Before != nil {
+		for end > start && entries[end-1].key.Compare(*page.Before, asc) >= 0 {
+			end--
+		}
+	}
+	if page.First != nil && *page.First < end-start {
+		end = start + *page.First
+	}
+	if page.Last != nil && *page.Last < end-start {
+		start = end - *page.Last
+	}
+
+	info = &model.PageInfo{
+		HasPreviousPage: start > 0,
+		HasNextPage:     end < len(entries),
+	}
+	for _, e := range entries[start:end] {
+		nodes = append(nodes, e.node)
+		cursors = append(cursors, e.key.Encode())
+	}
+	if len(cursors) > 0 {
+		info.StartCursor = &cursors[0]
+		info.EndCursor = &cursors[len(cursors)-1]
+	}
+	return nodes, cursors, info
+}
+
+// UserCursor returns the cursor of u in a list of users ordered by order.
+func UserCursor(order *model.UsersOrder, u *model.User) Cursor {
+	c := Cursor{String: u.DisplayName, ID: u.ID}
+	if order != nil {
+		c.Order = string(*order)
+	}
+	return c
+}
+
+// ProjectCursor returns the cursor of p in a list of projects ordered
+// by order. n is the number of members for ProjectsOrderNumMembers and
+// the number of tasks for ProjectsOrderNumTasks.
+func ProjectCursor(order *model.ProjectsOrder, p *model.Project, n int) Cursor {
+	c := Cursor{Creation: p.Creation.UnixNano(), ID: p.ID}
+	if order == nil {
+		c.Int = ptr(c.Creation)
+		return c
+	}
+	c.Order = string(*order)
+	switch *order {
+	case model.ProjectsOrderNameAlpha:
+		c.String = p.Name
+	case model.ProjectsOrderNumMembers, model.ProjectsOrderNumTasks:
+		c.Int = ptr(int64(n))
+	}
+	return c
+}
+
+// TaskCursor returns the cursor of t in a list of tasks ordered by order.
+func TaskCursor(order *model.TasksOrder, t *model.Task) Cursor {
+	c := Cursor{Creation: t.Creation.UnixNano(), ID: t.ID}
+	if order == nil {
+		c.Int = ptr(c.Creation)
+		return c
+	}
+	c.Order = string(*order)
+	switch *order {
+	case model.TasksOrderTitleAlpha:
+		c.String = t.Title
+	case model.TasksOrderPriority:
+		c.Int = ptr(int64(TaskPriorityScalar(t.Priority)))
+	case model.TasksOrderDueTime:
+		if t.Due != nil {
+			c.Int = ptr(t.Due.UnixNano())
+		}
+	case model.TasksOrderCreationTime:
+		c.Int = ptr(t.Creation.UnixNano())
+	}
+	return c
+}
+
+// TaskPriorityScalar returns the scalar value of a task priority enum value.
+func TaskPriorityScalar(p model.TaskPriority) int {
+	switch p {
+	case model.TaskPriorityBlocker:
+		return 4
+	case model.TaskPriorityHigh:
+		return 3
+	case model.TaskPriorityMedium:
+		return 2
+	case model.TaskPriorityLow:
+		return 1
+	}
+	return 0
+}
+
+func ptr[T any](v T) *T { return &v }
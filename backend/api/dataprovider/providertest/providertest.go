@@ -0,0 +1,3144 @@
+// Package providertest provides a reusable contract test suite
+// for dataprovider.DataProvider implementations.
+package providertest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/permission"
+	"github.com/romshark/taskhub/api/reqctx"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+// NewProvider creates a new empty data provider for a single test.
+// Any cleanup should be registered with t.Cleanup.
+type NewProvider func(t *testing.T) dataprovider.DataProvider
+
+// Run runs the data provider contract test suite.
+// newProvider is invoked for every test and must return a new empty provider.
+//
+// The suite only compares the IDs of related models since providers
+// may return relations as shallow references.
+func Run(t *testing.T, newProvider NewProvider) {
+	t.Run("UserByEmail", func(t *testing.T) { testUserByEmail(t, newProvider) })
+	t.Run("ByID", func(t *testing.T) { testByID(t, newProvider) })
+	t.Run("CreateUser", func(t *testing.T) { testCreateUser(t, newProvider) })
+	t.Run("UpdateUser", func(t *testing.T) { testUpdateUser(t, newProvider) })
+	t.Run("CreateProject", func(t *testing.T) { testCreateProject(t, newProvider) })
+	t.Run("UpdateProject", func(t *testing.T) { testUpdateProject(t, newProvider) })
+	t.Run("CreateTask", func(t *testing.T) { testCreateTask(t, newProvider) })
+	t.Run("UpdateTask", func(t *testing.T) { testUpdateTask(t, newProvider) })
+	t.Run("DeleteTask", func(t *testing.T) { testDeleteTask(t, newProvider) })
+	t.Run("ArchiveProject", func(t *testing.T) { testArchiveProject(t, newProvider) })
+	t.Run("DeactivateUser", func(t *testing.T) { testDeactivateUser(t, newProvider) })
+	t.Run("Comments", func(t *testing.T) { testComments(t, newProvider) })
+	t.Run("Permissions", func(t *testing.T) { testPermissions(t, newProvider) })
+	t.Run("ProjectRoles", func(t *testing.T) { testProjectRoles(t, newProvider) })
+	t.Run("SetUserAdmin", func(t *testing.T) { testSetUserAdmin(t, newProvider) })
+	t.Run("Sessions", func(t *testing.T) { testSessions(t, newProvider) })
+	t.Run("APIKeys", func(t *testing.T) { testAPIKeys(t, newProvider) })
+	t.Run("TwoFactor", func(t *testing.T) { testTwoFactor(t, newProvider) })
+	t.Run("UniqueIDs", func(t *testing.T) { testUniqueIDs(t, newProvider) })
+	t.Run("GetUsers", func(t *testing.T) { testGetUsers(t, newProvider) })
+	t.Run("GetProjects", func(t *testing.T) { testGetProjects(t, newProvider) })
+	t.Run("GetTasks", func(t *testing.T) { testGetTasks(t, newProvider) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, newProvider) })
+	t.Run("Relations", func(t *testing.T) { testRelations(t, newProvider) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newProvider) })
+}
+
+// base is the reference time all fixture times are relative to.
+var base = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// fixture is the data set most tests operate on:
+//
+//	Alice (admin), Bob, Carol, Dave
+//	Alpha   (ALPH; owners: Alice; creation: base)
+//	Bravo   (BRAV; owners: Bob;   creation: base+1h)
+//	Charlie (CHAR; owners: Alice; creation: base+2h)
+//	T1 "Task one"   Alpha TODO        LOW     due:+48h tags:[backend]
+//	   assignees:[Alice]      reporters:[Bob]
+//	T2 "Task two"   Alpha IN_PROGRESS BLOCKER due:-   tags:[backend frontend]
+//	   assignees:[Alice Bob]  reporters:[Carol] blocks:[T1]
+//	T3 "Task three" Bravo DONE        HIGH    due:+24h
+//	   assignees:[Carol]      reporters:[Carol] relatesTo:[T1]
+//	T4 "Task four"  Bravo TODO        MEDIUM  due:+72h tags:[frontend]
+//	   reporters:[Alice] blocks:[T1] relatesTo:[T2]
+//
+// Task Tn is created at base+n hours.
+type fixture struct {
+	Alice, Bob, Carol, Dave *model.User
+	Alpha, Bravo, Charlie   *model.Project
+	T1, T2, T3, T4          *model.Task
+}
+
+func seed(t *testing.T, p dataprovider.DataProvider) *fixture {
+	t.Helper()
+	f := new(fixture)
+	ctx := background()
+
+	f.Alice = mustCreateUser(t, p, "alice@test.com", "Alice")
+	f.Bob = mustCreateUser(t, p, "bob@test.com", "Bob")
+	f.Carol = mustCreateUser(t, p, "carol@test.com", "Carol")
+	f.Dave = mustCreateUser(t, p, "dave@test.com", "Dave")
+
+	ctx = authenticated(f.Alice.ID)
+	var err error
+	f.Alpha, err = p.CreateProject(
+		ctx, base, "Alpha", "First project", "ALPH", []string{f.Alice.ID},
+	)
+	require.NoError(t, err)
+	f.Bravo, err = p.CreateProject(
+		ctx, base.Add(time.Hour), "Bravo", "", "BRAV", []string{f.Bob.ID},
+	)
+	require.NoError(t, err)
+	f.Charlie, err = p.CreateProject(
+		ctx, base.Add(2*time.Hour), "Charlie", "", "CHAR", nil,
+	)
+	require.NoError(t, err)
+
+	f.T1, err = p.CreateTask(
+		ctx, base.Add(1*time.Hour), "Task one", f.Alpha.ID,
+		model.TaskStatusTodo, model.TaskPriorityLow,
+		ptr("Description one"), ptr(base.Add(48*time.Hour)),
+		[]string{"backend"},
+		[]string{f.Alice.ID}, []string{f.Bob.ID}, nil, nil,
+	)
+	require.NoError(t, err)
+	f.T2, err = p.CreateTask(
+		ctx, base.Add(2*time.Hour), "Task two", f.Alpha.ID,
+		model.TaskStatusInProgress, model.TaskPriorityBlocker,
+		nil, nil, []string{"backend", "frontend"},
+		[]string{f.Alice.ID, f.Bob.ID}, []string{f.Carol.ID},
+		[]string{f.T1.ID}, nil,
+	)
+	require.NoError(t, err)
+	f.T3, err = p.CreateTask(
+		ctx, base.Add(3*time.Hour), "Task three", f.Bravo.ID,
+		model.TaskStatusDone, model.TaskPriorityHigh,
+		nil, ptr(base.Add(24*time.Hour)), nil,
+		[]string{f.Carol.ID}, []string{f.Carol.ID},
+		nil, []string{f.T1.ID},
+	)
+	require.NoError(t, err)
+	f.T4, err = p.CreateTask(
+		ctx, base.Add(4*time.Hour), "Task four", f.Bravo.ID,
+		model.TaskStatusTodo, model.TaskPriorityMedium,
+		nil, ptr(base.Add(72*time.Hour)), []string{"frontend"},
+		nil, []string{f.Alice.ID},
+		[]string{f.T1.ID}, []string{f.T2.ID},
+	)
+	require.NoError(t, err)
+	return f
+}
+
+func testUserByEmail(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := background()
+
+	u, err := p.UserByEmail(ctx, "bob@test.com")
+	require.NoError(t, err)
+	require.Equal(t, f.Bob.ID, u.ID)
+	require.Equal(t, "hash_Bob", u.PasswordHash)
+
+	_, err = p.UserByEmail(ctx, "unknown@test.com")
+	require.Error(t, err)
+}
+
+func testByID(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	u, err := p.UserByID(ctx, f.Carol.ID)
+	require.NoError(t, err)
+	require.Equal(t, "carol@test.com", u.Email)
+
+	project, err := p.ProjectByID(ctx, f.Alpha.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Alpha", project.Name)
+	require.Equal(t, "First project", project.Description)
+	require.Equal(t, "ALPH", project.Slug)
+	require.True(t, base.Equal(project.Creation))
+	requireUserIDs(t, []string{f.Alice.ID}, project.Owners())
+
+	task, err := p.TaskByID(ctx, f.T1.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Task one", task.Title)
+	require.Equal(t, ptr("Description one"), task.Description)
+	require.Equal(t, model.TaskStatusTodo, task.Status)
+	require.Equal(t, model.TaskPriorityLow, task.Priority)
+	require.True(t, base.Add(time.Hour).Equal(task.Creation))
+	require.NotNil(t, task.Due)
+	require.True(t, base.Add(48*time.Hour).Equal(*task.Due))
+	require.Equal(t, []string{"backend"}, task.Tags)
+	require.Equal(t, f.Alpha.ID, task.Project.ID)
+	requireUserIDs(t, []string{f.Alice.ID}, task.Assignees)
+	requireUserIDs(t, []string{f.Bob.ID}, task.Reporters)
+
+	task, err = p.TaskByID(ctx, f.T4.ID)
+	require.NoError(t, err)
+	require.Nil(t, task.Description)
+	requireTaskIDs(t, []string{f.T1.ID}, task.Blocks)
+	requireTaskIDs(t, []string{f.T2.ID}, task.RelatesTo)
+
+	for _, td := range []struct {
+		name string
+		get  func(ctx context.Context, id string) error
+	}{
+		{"user_not_found", func(ctx context.Context, id string) error {
+			_, err := p.UserByID(ctx, id)
+			return err
+		}},
+		{"project_not_found", func(ctx context.Context, id string) error {
+			_, err := p.ProjectByID(ctx, id)
+			return err
+		}},
+		{"task_not_found", func(ctx context.Context, id string) error {
+			_, err := p.TaskByID(ctx, id)
+			return err
+		}},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			require.Error(t, td.get(ctx, "unknown"))
+		})
+	}
+}
+
+func testCreateUser(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := background()
+
+	u, err := p.CreateUser(
+		ctx, "eve@test.com", "hash_Eve", "Eve", "SWE", "Berlin",
+		&f.Alice.ID, []string{f.Bob.ID, f.Carol.ID, f.Bob.ID},
+	)
+	require.NoError(t, err)
+	require.NotZero(t, u.ID)
+	require.Equal(t, "eve@test.com", u.Email)
+	require.Equal(t, "Eve", u.DisplayName)
+	require.Equal(t, "SWE", u.Role)
+	require.Equal(t, "Berlin", u.Location)
+	require.Equal(t, "hash_Eve", u.PasswordHash)
+	require.NotNil(t, u.Manager)
+	require.Equal(t, f.Alice.ID, u.Manager.ID)
+	requireUserIDs(t, []string{f.Bob.ID, f.Carol.ID}, u.Subordinates)
+
+	stored, err := p.UserByID(ctx, u.ID)
+	require.NoError(t, err)
+	require.Equal(t, u.Email, stored.Email)
+	requireUserIDs(t, []string{f.Bob.ID, f.Carol.ID}, stored.Subordinates)
+
+	for _, td := range []struct {
+		name         string
+		email        string
+		displayName  string
+		manager      *string
+		subordinates []string
+	}{
+		{name: "non_unique_email", email: "alice@test.com", displayName: "X1"},
+		{name: "non_unique_display_name", email: "x@test.com", displayName: "Alice"},
+		{
+			name: "manager_not_found", email: "x@test.com", displayName: "X1",
+			manager: ptr("unknown"),
+		},
+		{
+			name: "subordinate_not_found", email: "x@test.com", displayName: "X1",
+			subordinates: []string{f.Bob.ID, "unknown"},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			_, err := p.CreateUser(
+				ctx, td.email, "hash", td.displayName, "SWE", "",
+				td.manager, td.subordinates,
+			)
+			require.Error(t, err)
+			_, err = p.UserByEmail(ctx, "x@test.com")
+			require.Error(t, err, "expected no user to be created")
+		})
+	}
+}
+
+func testUpdateUser(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	update := func(
+		ctx context.Context, id, email, displayName string,
+		manager *string, subordinates []string,
+	) (*model.User, error) {
+		return p.UpdateUser(
+			ctx, id, email, displayName, "Lead", "Paris",
+			ptr("busy"), manager, subordinates,
+		)
+	}
+
+	for _, td := range []struct {
+		name         string
+		ctx          context.Context // authenticated as Alice if nil
+		id           string          // Alice if empty
+		email        string
+		displayName  string
+		manager      *string
+		subordinates []string
+		expectErr    error // any error if nil
+	}{
+		{
+			name: "unauthenticated", ctx: background(),
+			email: "alice@test.com", displayName: "Alice",
+			expectErr: auth.ErrUnauthenticated,
+		},
+		{
+			name: "not_owner", ctx: authenticated(f.Bob.ID), id: f.Carol.ID,
+			email: "carol@test.com", displayName: "Carol",
+			expectErr: auth.ErrUnauthorized,
+		},
+		{name: "not_found", id: "unknown", email: "x@test.com", displayName: "X1"},
+		{name: "non_unique_email", email: "bob@test.com", displayName: "Alice"},
+		{name: "non_unique_display_name", email: "alice@test.com", displayName: "Bob"},
+		{
+			name: "manager_not_found", email: "alice@test.com", displayName: "Alice",
+			manager: ptr("unknown"),
+		},
+		{
+			name: "manager_self", email: "alice@test.com", displayName: "Alice",
+			manager: &f.Alice.ID,
+		},
+		{
+			name: "subordinate_not_found", email: "alice@test.com", displayName: "Alice",
+			subordinates: []string{"unknown"},
+		},
+		{
+			name: "subordinate_self", email: "alice@test.com", displayName: "Alice",
+			subordinates: []string{f.Bob.ID, f.Alice.ID},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			c, id := td.ctx, td.id
+			if c == nil {
+				c = ctx
+			}
+			if id == "" {
+				id = f.Alice.ID
+			}
+			_, err := update(
+				c, id, td.email, td.displayName, td.manager, td.subordinates,
+			)
+			if td.expectErr != nil {
+				require.ErrorIs(t, err, td.expectErr)
+			} else {
+				require.Error(t, err)
+			}
+			for _, u := range []*model.User{f.Alice, f.Carol} {
+				u, err := p.UserByID(ctx, u.ID)
+				require.NoError(t, err)
+				require.Equal(t, "SWE", u.Role, "expected user to remain unchanged")
+			}
+		})
+	}
+
+	t.Run("keep_email_and_display_name", func(t *testing.T) {
+		u, err := update(
+			ctx, f.Alice.ID, "alice@test.com", "Alice",
+			&f.Dave.ID, []string{f.Bob.ID, f.Bob.ID},
+		)
+		require.NoError(t, err)
+		require.Equal(t, f.Alice.ID, u.ID)
+		require.Equal(t, "Lead", u.Role)
+		require.Equal(t, "Paris", u.Location)
+		require.Equal(t, "busy", u.PersonalStatus)
+		require.Equal(t, f.Dave.ID, u.Manager.ID)
+		requireUserIDs(t, []string{f.Bob.ID}, u.Subordinates)
+	})
+
+	t.Run("change_email_and_display_name", func(t *testing.T) {
+		u, err := update(ctx, f.Alice.ID, "alice2@test.com", "Alice 2", nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, "alice2@test.com", u.Email)
+		require.Equal(t, "Alice 2", u.DisplayName)
+		require.Nil(t, u.Manager)
+		require.Empty(t, u.Subordinates)
+
+		_, err = p.UserByEmail(ctx, "alice@test.com")
+		require.Error(t, err)
+		u, err = p.UserByEmail(ctx, "alice2@test.com")
+		require.NoError(t, err)
+		require.Equal(t, f.Alice.ID, u.ID)
+		require.Equal(t, "hash_Alice", u.PasswordHash)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		u, err := update(ctx, f.Bob.ID, "bob@test.com", "Bob", nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, "Lead", u.Role)
+	})
+}
+
+func testCreateProject(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	for _, td := range []struct {
+		name      string
+		ctx       context.Context // authenticated as Alice if nil
+		pName     string
+		slug      string
+		owners    []string
+		expectErr error // any error if nil
+	}{
+		{
+			name: "unauthenticated", ctx: background(),
+			pName: "Delta", slug: "DELT", expectErr: auth.ErrUnauthenticated,
+		},
+		{name: "non_unique_name", pName: "Alpha", slug: "DELT"},
+		{name: "non_unique_slug", pName: "Delta", slug: "ALPH"},
+		{
+			name: "owner_not_found", pName: "Delta", slug: "DELT",
+			owners: []string{f.Alice.ID, "unknown"},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			c := td.ctx
+			if c == nil {
+				c = ctx
+			}
+			_, err := p.CreateProject(c, base, td.pName, "", td.slug, td.owners)
+			if td.expectErr != nil {
+				require.ErrorIs(t, err, td.expectErr)
+			} else {
+				require.Error(t, err)
+			}
+			requireProjectCount(t, p, f, 3)
+		})
+	}
+
+	project, err := p.CreateProject(
+		ctx, base.Add(time.Minute), "Delta", "Fourth", "DELT",
+		[]string{f.Bob.ID, f.Alice.ID, f.Bob.ID},
+	)
+	require.NoError(t, err)
+	require.NotZero(t, project.ID)
+	require.Equal(t, "Delta", project.Name)
+	require.Equal(t, "Fourth", project.Description)
+	require.Equal(t, "DELT", project.Slug)
+	require.True(t, base.Add(time.Minute).Equal(project.Creation))
+	requireUserIDs(t, []string{f.Bob.ID, f.Alice.ID}, project.Owners())
+
+	stored, err := p.ProjectByID(ctx, project.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Delta", stored.Name)
+	requireProjectCount(t, p, f, 4)
+}
+
+func testUpdateProject(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	for _, td := range []struct {
+		name      string
+		ctx       context.Context // authenticated as Alice if nil
+		id        string          // Alpha if empty
+		pName     string
+		slug      string
+		owners    []string
+		expectErr error // any error if nil
+	}{
+		{
+			name: "unauthenticated", ctx: background(),
+			pName: "Alpha", slug: "ALPH", expectErr: auth.ErrUnauthenticated,
+		},
+		{name: "not_found", id: "unknown", pName: "Delta", slug: "DELT"},
+		{name: "non_unique_name", pName: "Bravo", slug: "ALPH"},
+		{name: "non_unique_slug", pName: "Alpha", slug: "BRAV"},
+		{
+			name: "owner_not_found", pName: "Alpha", slug: "ALPH",
+			owners: []string{"unknown"},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			c, id := td.ctx, td.id
+			if c == nil {
+				c = ctx
+			}
+			if id == "" {
+				id = f.Alpha.ID
+			}
+			_, err := p.UpdateProject(
+				c, id, td.pName, "changed", td.slug, td.owners,
+			)
+			if td.expectErr != nil {
+				require.ErrorIs(t, err, td.expectErr)
+			} else {
+				require.Error(t, err)
+			}
+			x, err := p.ProjectByID(ctx, f.Alpha.ID)
+			require.NoError(t, err)
+			require.Equal(t, "First project", x.Description,
+				"expected project to remain unchanged")
+		})
+	}
+
+	t.Run("keep_name_and_slug", func(t *testing.T) {
+		x, err := p.UpdateProject(
+			ctx, f.Alpha.ID, "Alpha", "changed", "ALPH", []string{f.Carol.ID},
+		)
+		require.NoError(t, err)
+		require.Equal(t, "changed", x.Description)
+		requireUserIDs(t, []string{f.Carol.ID}, x.Owners())
+		require.True(t, base.Equal(x.Creation), "expected creation unchanged")
+	})
+
+	t.Run("change_name_and_slug", func(t *testing.T) {
+		x, err := p.UpdateProject(ctx, f.Alpha.ID, "Alpha 2", "", "ALP2", nil)
+		require.NoError(t, err)
+		require.Equal(t, f.Alpha.ID, x.ID)
+		require.Equal(t, "Alpha 2", x.Name)
+		require.Equal(t, "ALP2", x.Slug)
+		require.Empty(t, x.Owners())
+
+		tasks, err := p.GetTasksByProject(
+			ctx, f.Alpha.ID, nil, true, dataprovider.Page{},
+		)
+		require.NoError(t, err)
+		requireTaskIDs(t, []string{f.T1.ID, f.T2.ID}, taskNodes(tasks))
+	})
+}
+
+func testCreateTask(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	create := func(
+		ctx context.Context, title, project string,
+		assignees, reporters, blocks, relatesTo []string,
+	) (*model.Task, error) {
+		return p.CreateTask(
+			ctx, base.Add(5*time.Hour), title, project,
+			model.TaskStatusInProgress, model.TaskPriorityHigh,
+			ptr("description"), ptr(base.Add(96*time.Hour)),
+			[]string{"a", "b", "a"},
+			assignees, reporters, blocks, relatesTo,
+		)
+	}
+
+	for _, td := range []struct {
+		name      string
+		ctx       context.Context // authenticated as Alice if nil
+		title     string
+		project   string
+		assignees []string
+		reporters []string
+		blocks    []string
+		relatesTo []string
+		expectErr error // any error if nil
+	}{
+		{
+			name: "unauthenticated", ctx: background(),
+			title: "Task five", project: f.Alpha.ID,
+			expectErr: auth.ErrUnauthenticated,
+		},
+		{name: "non_unique_title", title: "Task one", project: f.Alpha.ID},
+		{name: "project_not_found", title: "Task five", project: "unknown"},
+		{
+			name: "assignee_not_found", title: "Task five", project: f.Alpha.ID,
+			assignees: []string{"unknown"},
+		},
+		{
+			name: "reporter_not_found", title: "Task five", project: f.Alpha.ID,
+			reporters: []string{"unknown"},
+		},
+		{
+			name: "blocked_task_not_found", title: "Task five", project: f.Alpha.ID,
+			blocks: []string{"unknown"},
+		},
+		{
+			name: "related_task_not_found", title: "Task five", project: f.Alpha.ID,
+			relatesTo: []string{"unknown"},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			c := td.ctx
+			if c == nil {
+				c = ctx
+			}
+			_, err := create(
+				c, td.title, td.project,
+				td.assignees, td.reporters, td.blocks, td.relatesTo,
+			)
+			if td.expectErr != nil {
+				require.ErrorIs(t, err, td.expectErr)
+			} else {
+				require.Error(t, err)
+			}
+			requireTaskCount(t, p, f, 4)
+		})
+	}
+
+	task, err := create(
+		ctx, "Task five", f.Charlie.ID,
+		[]string{f.Dave.ID, f.Dave.ID},
+		[]string{f.Carol.ID, f.Bob.ID},
+		[]string{f.T2.ID},
+		[]string{f.T3.ID, f.T4.ID},
+	)
+	require.NoError(t, err)
+	require.NotZero(t, task.ID)
+	require.Equal(t, "Task five", task.Title)
+	require.Equal(t, ptr("description"), task.Description)
+	require.Equal(t, model.TaskStatusInProgress, task.Status)
+	require.Equal(t, model.TaskPriorityHigh, task.Priority)
+	require.True(t, base.Add(5*time.Hour).Equal(task.Creation))
+	require.True(t, base.Add(96*time.Hour).Equal(*task.Due))
+	require.Equal(t, []string{"a", "b"}, task.Tags)
+	require.Equal(t, f.Charlie.ID, task.Project.ID)
+	requireUserIDs(t, []string{f.Dave.ID}, task.Assignees)
+	requireUserIDs(t, []string{f.Carol.ID, f.Bob.ID}, task.Reporters)
+	requireTaskIDs(t, []string{f.T2.ID}, task.Blocks)
+	requireTaskIDs(t, []string{f.T3.ID, f.T4.ID}, task.RelatesTo)
+
+	stored, err := p.TaskByID(ctx, task.ID)
+	require.NoError(t, err)
+	requireUserIDs(t, []string{f.Carol.ID, f.Bob.ID}, stored.Reporters)
+	requireTaskCount(t, p, f, 5)
+}
+
+func testUpdateTask(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	update := func(
+		ctx context.Context, id, title, project string,
+		assignees, reporters, blocks, relatesTo []string,
+	) (*model.Task, error) {
+		return p.UpdateTask(
+			ctx, id, title, nil,
+			model.TaskStatusDone, model.TaskPriorityBlocker, nil,
+			[]string{"updated"}, project,
+			assignees, reporters, blocks, relatesTo,
+		)
+	}
+
+	for _, td := range []struct {
+		name      string
+		ctx       context.Context // authenticated as Alice if nil
+		id        string          // T1 if empty
+		title     string
+		project   string
+		assignees []string
+		reporters []string
+		blocks    []string
+		relatesTo []string
+		expectErr error // any error if nil
+	}{
+		{
+			name: "unauthenticated", ctx: background(),
+			title: "Task one", project: f.Alpha.ID,
+			expectErr: auth.ErrUnauthenticated,
+		},
+		{
+			name: "not_found", id: "unknown",
+			title: "Task X", project: f.Alpha.ID,
+		},
+		{name: "non_unique_title", title: "Task two", project: f.Alpha.ID},
+		{name: "project_not_found", title: "Task one", project: "unknown"},
+		{
+			name: "assignee_not_found", title: "Task one", project: f.Alpha.ID,
+			assignees: []string{"unknown"},
+		},
+		{
+			name: "reporter_not_found", title: "Task one", project: f.Alpha.ID,
+			reporters: []string{"unknown"},
+		},
+		{
+			name: "blocked_task_not_found", title: "Task one", project: f.Alpha.ID,
+			blocks: []string{"unknown"},
+		},
+		{
+			name: "blocks_self", title: "Task one", project: f.Alpha.ID,
+			blocks: []string{f.T2.ID, f.T1.ID},
+		},
+		{
+			name: "related_task_not_found", title: "Task one", project: f.Alpha.ID,
+			relatesTo: []string{"unknown"},
+		},
+		{
+			name: "relates_to_self", title: "Task one", project: f.Alpha.ID,
+			relatesTo: []string{f.T1.ID},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			c, id := td.ctx, td.id
+			if c == nil {
+				c = ctx
+			}
+			if id == "" {
+				id = f.T1.ID
+			}
+			_, err := update(
+				c, id, td.title, td.project,
+				td.assignees, td.reporters, td.blocks, td.relatesTo,
+			)
+			if td.expectErr != nil {
+				require.ErrorIs(t, err, td.expectErr)
+			} else {
+				require.Error(t, err)
+			}
+			x, err := p.TaskByID(ctx, f.T1.ID)
+			require.NoError(t, err)
+			require.Equal(t, model.TaskStatusTodo, x.Status,
+				"expected task to remain unchanged")
+		})
+	}
+
+	t.Run("keep_title", func(t *testing.T) {
+		x, err := update(
+			ctx, f.T1.ID, "Task one", f.Bravo.ID,
+			[]string{f.Dave.ID}, []string{f.Carol.ID, f.Dave.ID},
+			[]string{f.T3.ID}, []string{f.T4.ID},
+		)
+		require.NoError(t, err)
+		require.Equal(t, f.T1.ID, x.ID)
+		require.Equal(t, "Task one", x.Title)
+		require.Nil(t, x.Description)
+		require.Nil(t, x.Due)
+		require.Equal(t, model.TaskStatusDone, x.Status)
+		require.Equal(t, model.TaskPriorityBlocker, x.Priority)
+		require.True(t, base.Add(time.Hour).Equal(x.Creation),
+			"expected creation unchanged")
+		require.Equal(t, []string{"updated"}, x.Tags)
+		require.Equal(t, f.Bravo.ID, x.Project.ID)
+		requireUserIDs(t, []string{f.Dave.ID}, x.Assignees)
+		requireUserIDs(t, []string{f.Carol.ID, f.Dave.ID}, x.Reporters)
+		requireTaskIDs(t, []string{f.T3.ID}, x.Blocks)
+		requireTaskIDs(t, []string{f.T4.ID}, x.RelatesTo)
+	})
+
+	t.Run("change_title", func(t *testing.T) {
+		x, err := update(
+			ctx, f.T1.ID, "Task one (renamed)", f.Alpha.ID, nil, nil, nil, nil,
+		)
+		require.NoError(t, err)
+		require.Equal(t, "Task one (renamed)", x.Title)
+		require.Empty(t, x.Assignees)
+		require.Empty(t, x.Reporters)
+		require.Empty(t, x.Blocks)
+		require.Empty(t, x.RelatesTo)
+	})
+}
+
+func testDeleteTask(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		err := p.DeleteTask(background(), f.T1.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		requireTaskCount(t, p, f, 4)
+	})
+	t.Run("not_found", func(t *testing.T) {
+		require.Error(t, p.DeleteTask(ctx, "unknown"))
+		requireTaskCount(t, p, f, 4)
+	})
+
+	// T1 is blocked by T2 and T4 and related to T3.
+	require.NoError(t, p.DeleteTask(ctx, f.T1.ID))
+	requireTaskCount(t, p, f, 3)
+	_, err := p.TaskByID(ctx, f.T1.ID)
+	require.Error(t, err)
+	require.Error(t, p.DeleteTask(ctx, f.T1.ID), "expected already deleted")
+
+	t2, err := p.TaskByID(ctx, f.T2.ID)
+	require.NoError(t, err)
+	require.Empty(t, t2.Blocks)
+	t3, err := p.TaskByID(ctx, f.T3.ID)
+	require.NoError(t, err)
+	require.Empty(t, t3.RelatesTo)
+	t4, err := p.TaskByID(ctx, f.T4.ID)
+	require.NoError(t, err)
+	require.Empty(t, t4.Blocks)
+	requireTaskIDs(t, []string{f.T2.ID}, t4.RelatesTo)
+
+	// T2 is related to T4.
+	require.NoError(t, p.DeleteTask(ctx, f.T2.ID))
+	related, err := p.GetRelatedTasks(ctx, f.T4.ID)
+	require.NoError(t, err)
+	require.Empty(t, related)
+	assigned, err := p.GetTasksAssignedToUser(
+		ctx, f.Bob.ID, nil, true, dataprovider.Page{},
+	)
+	require.NoError(t, err)
+	require.Empty(t, taskNodes(assigned))
+	members, err := p.GetProjectMembers(ctx, f.Alpha.ID)
+	require.NoError(t, err)
+	require.Empty(t, members)
+
+	// The title of a deleted task can be reused.
+	_, err = p.CreateTask(
+		ctx, base, "Task one", f.Alpha.ID,
+		model.TaskStatusTodo, model.TaskPriorityLow,
+		nil, nil, nil, nil, nil, nil, nil,
+	)
+	require.NoError(t, err)
+}
+
+func testArchiveProject(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+	archived := base.Add(time.Hour)
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		_, err := p.ArchiveProject(background(), f.Bravo.ID, archived)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.UnarchiveProject(background(), f.Bravo.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	})
+	t.Run("not_found", func(t *testing.T) {
+		_, err := p.ArchiveProject(ctx, "unknown", archived)
+		require.Error(t, err)
+		_, err = p.UnarchiveProject(ctx, "unknown")
+		require.Error(t, err)
+	})
+	t.Run("unarchive_active", func(t *testing.T) {
+		_, err := p.UnarchiveProject(ctx, f.Bravo.ID)
+		require.Error(t, err)
+	})
+
+	x, err := p.ArchiveProject(ctx, f.Bravo.ID, archived)
+	require.NoError(t, err)
+	require.Equal(t, f.Bravo.ID, x.ID)
+	require.NotNil(t, x.Archived)
+	require.True(t, archived.Equal(*x.Archived))
+	requireUserIDs(t, []string{f.Bob.ID}, x.Owners())
+
+	stored, err := p.ProjectByID(ctx, f.Bravo.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.Archived)
+	require.True(t, archived.Equal(*stored.Archived))
+
+	_, err = p.ArchiveProject(ctx, f.Bravo.ID, archived)
+	require.Error(t, err, "expected already archived")
+
+	t.Run("filters", func(t *testing.T) {
+		for _, td := range []struct {
+			archived *bool
+			projects []*model.Project
+			tasks    []*model.Task
+		}{
+			{
+				archived: nil,
+				projects: []*model.Project{f.Alpha, f.Bravo, f.Charlie},
+				tasks:    []*model.Task{f.T1, f.T2, f.T3, f.T4},
+			},
+			{
+				archived: ptr(true),
+				projects: []*model.Project{f.Bravo},
+				tasks:    []*model.Task{f.T3, f.T4},
+			},
+			{
+				archived: ptr(false),
+				projects: []*model.Project{f.Alpha, f.Charlie},
+				tasks:    []*model.Task{f.T1, f.T2},
+			},
+		} {
+			projects, err := p.GetProjects(
+				ctx, &model.ProjectsFilters{Archived: td.archived},
+				nil, true, dataprovider.Page{},
+			)
+			require.NoError(t, err)
+			require.Equal(t,
+				projectIDs(td.projects), projectIDs(projectNodes(projects)),
+			)
+			tasks, err := p.GetTasks(
+				ctx, &model.TasksFilters{Archived: td.archived},
+				nil, true, dataprovider.Page{},
+			)
+			require.NoError(t, err)
+			require.Equal(t, taskIDs(td.tasks), taskIDs(taskNodes(tasks)))
+		}
+	})
+
+	t.Run("read_only", func(t *testing.T) {
+		_, err := p.UpdateProject(ctx, f.Bravo.ID, "Bravo", "", "BRAV", nil)
+		require.Error(t, err)
+		_, err = p.CreateTask(
+			ctx, base, "Task five", f.Bravo.ID,
+			model.TaskStatusTodo, model.TaskPriorityLow,
+			nil, nil, nil, nil, nil, nil, nil,
+		)
+		require.Error(t, err)
+		_, err = p.UpdateTask(
+			ctx, f.T3.ID, "Task three", nil,
+			model.TaskStatusTodo, model.TaskPriorityLow, nil,
+			nil, f.Alpha.ID, nil, nil, nil, nil,
+		)
+		require.Error(t, err, "expected tasks of archived projects read-only")
+		_, err = p.UpdateTask(
+			ctx, f.T1.ID, "Task one", nil,
+			model.TaskStatusTodo, model.TaskPriorityLow, nil,
+			nil, f.Bravo.ID, nil, nil, nil, nil,
+		)
+		require.Error(t, err, "expected moving to archived project rejected")
+		require.Error(t, p.DeleteTask(ctx, f.T3.ID))
+		requireTaskCount(t, p, f, 4)
+
+		x, err := p.TaskByID(ctx, f.T3.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.TaskStatusDone, x.Status)
+		x, err = p.TaskByID(ctx, f.T1.ID)
+		require.NoError(t, err)
+		require.Equal(t, f.Alpha.ID, x.Project.ID)
+	})
+
+	x, err = p.UnarchiveProject(ctx, f.Bravo.ID)
+	require.NoError(t, err)
+	require.Nil(t, x.Archived)
+	stored, err = p.ProjectByID(ctx, f.Bravo.ID)
+	require.NoError(t, err)
+	require.Nil(t, stored.Archived)
+
+	_, err = p.UpdateProject(ctx, f.Bravo.ID, "Bravo", "", "BRAV", nil)
+	require.NoError(t, err)
+	require.NoError(t, p.DeleteTask(ctx, f.T3.ID))
+}
+
+func testDeactivateUser(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	deactivated := base.Add(time.Hour)
+
+	// Alice is the manager of Bob.
+	_, err := p.UpdateUser(
+		authenticated(f.Bob.ID), f.Bob.ID, "bob@test.com", "Bob",
+		"SWE", "", nil, &f.Alice.ID, nil,
+	)
+	require.NoError(t, err)
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		_, err := p.DeactivateUser(background(), f.Bob.ID, deactivated)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	})
+	t.Run("not_found", func(t *testing.T) {
+		_, err := p.DeactivateUser(
+			authenticated(f.Alice.ID), "unknown", deactivated,
+		)
+		require.Error(t, err)
+	})
+	t.Run("not_owner_nor_manager", func(t *testing.T) {
+		_, err := p.DeactivateUser(
+			authenticated(f.Carol.ID), f.Bob.ID, deactivated,
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.DeactivateUser(
+			authenticated(f.Bob.ID), f.Alice.ID, deactivated,
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+	})
+
+	// Bob is assigned to T2, owns Bravo and reported T1.
+	u, err := p.DeactivateUser(authenticated(f.Alice.ID), f.Bob.ID, deactivated)
+	require.NoError(t, err)
+	require.Equal(t, f.Bob.ID, u.ID)
+	require.NotNil(t, u.Deactivated)
+	require.True(t, deactivated.Equal(*u.Deactivated))
+
+	ctx := authenticated(f.Alice.ID)
+	stored, err := p.UserByID(ctx, f.Bob.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.Deactivated)
+
+	t2, err := p.TaskByID(ctx, f.T2.ID)
+	require.NoError(t, err)
+	requireUserIDs(t, []string{f.Alice.ID}, t2.Assignees)
+	bravo, err := p.ProjectByID(ctx, f.Bravo.ID)
+	require.NoError(t, err)
+	require.Empty(t, bravo.Roles)
+	t1, err := p.TaskByID(ctx, f.T1.ID)
+	require.NoError(t, err)
+	requireUserIDs(t, []string{f.Bob.ID}, t1.Reporters)
+
+	_, err = p.DeactivateUser(ctx, f.Bob.ID, deactivated)
+	require.Error(t, err, "expected already deactivated")
+
+	t.Run("filters", func(t *testing.T) {
+		for _, td := range []struct {
+			deactivated *bool
+			expect      []*model.User
+		}{
+			{nil, []*model.User{f.Alice, f.Bob, f.Carol, f.Dave}},
+			{ptr(true), []*model.User{f.Bob}},
+			{ptr(false), []*model.User{f.Alice, f.Carol, f.Dave}},
+		} {
+			a, err := p.GetUsers(
+				ctx, &model.UsersFilters{Deactivated: td.deactivated},
+				nil, true, dataprovider.Page{},
+			)
+			require.NoError(t, err)
+			require.Equal(t, userIDs(td.expect), userIDs(userNodes(a)))
+		}
+	})
+
+	t.Run("no_assignments", func(t *testing.T) {
+		_, err := p.CreateTask(
+			ctx, base, "Task five", f.Alpha.ID,
+			model.TaskStatusTodo, model.TaskPriorityLow,
+			nil, nil, nil, []string{f.Bob.ID}, nil, nil, nil,
+		)
+		require.Error(t, err)
+		_, err = p.UpdateTask(
+			ctx, f.T1.ID, "Task one", nil,
+			model.TaskStatusTodo, model.TaskPriorityLow, nil,
+			nil, f.Alpha.ID, []string{f.Bob.ID}, nil, nil, nil,
+		)
+		require.Error(t, err)
+		_, err = p.CreateProject(
+			ctx, base, "Delta", "", "DELT", []string{f.Bob.ID},
+		)
+		require.Error(t, err)
+		_, err = p.UpdateProject(
+			ctx, f.Alpha.ID, "Alpha", "", "ALPH", []string{f.Bob.ID},
+		)
+		require.Error(t, err)
+		requireTaskCount(t, p, f, 4)
+		requireProjectCount(t, p, f, 3)
+	})
+
+	// Deactivated users remain reporters.
+	_, err = p.UpdateTask(
+		ctx, f.T1.ID, "Task one", nil,
+		model.TaskStatusTodo, model.TaskPriorityLow, nil,
+		nil, f.Alpha.ID, nil, []string{f.Bob.ID}, nil, nil,
+	)
+	require.NoError(t, err)
+
+	// Users can deactivate themselves.
+	_, err = p.DeactivateUser(authenticated(f.Dave.ID), f.Dave.ID, deactivated)
+	require.NoError(t, err)
+
+	// Admins can deactivate anyone.
+	_, err = p.DeactivateUser(ctx, f.Carol.ID, deactivated)
+	require.NoError(t, err)
+}
+
+func testComments(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	alice, bob := authenticated(f.Alice.ID), authenticated(f.Bob.ID)
+
+	add := func(
+		ctx context.Context, creation time.Duration, task, body string,
+		parent *model.Comment,
+	) *model.Comment {
+		t.Helper()
+		var parentID *string
+		if parent != nil {
+			parentID = &parent.ID
+		}
+		c, err := p.AddComment(ctx, base.Add(creation), task, body, parentID)
+		require.NoError(t, err)
+		return c
+	}
+	requireComments := func(
+		t *testing.T, expect []*model.Comment, actual []*model.Comment,
+	) {
+		t.Helper()
+		require.Equal(t, commentIDs(expect), commentIDs(actual))
+	}
+
+	_, err := p.GrantProjectRole(
+		alice, f.Alpha.ID, f.Bob.ID, model.ProjectRoleViewer,
+	)
+	require.NoError(t, err)
+
+	// C1
+	// ├─ C2
+	// │  └─ C4
+	// └─ C5
+	// C3
+	c1 := add(alice, time.Hour, f.T1.ID, "First", nil)
+	c2 := add(bob, 2*time.Hour, f.T1.ID, "Reply to first", c1)
+	c3 := add(bob, 3*time.Hour, f.T1.ID, "Second", nil)
+	c4 := add(alice, 4*time.Hour, f.T1.ID, "Reply to reply", c2)
+	c5 := add(alice, 5*time.Hour, f.T1.ID, "Another reply to first", c1)
+	other := add(alice, time.Hour, f.T2.ID, "Other task", nil)
+
+	require.Equal(t, f.T1.ID, c1.Task.ID)
+	require.Equal(t, f.Alice.ID, c1.Author.ID)
+	require.Equal(t, "First", c1.Body)
+	require.True(t, base.Add(time.Hour).Equal(c1.Creation))
+	require.Nil(t, c1.Edited)
+	require.Nil(t, c1.Parent)
+	require.Equal(t, f.Bob.ID, c2.Author.ID)
+	require.Equal(t, c1.ID, c2.Parent.ID)
+
+	stored, err := p.CommentByID(alice, c2.ID)
+	require.NoError(t, err)
+	require.Equal(t, c2.ID, stored.ID)
+	require.Equal(t, "Reply to first", stored.Body)
+	require.Equal(t, c1.ID, stored.Parent.ID)
+
+	comments, err := p.GetTaskComments(alice, f.T1.ID)
+	require.NoError(t, err)
+	requireComments(t, []*model.Comment{c1, c2, c3, c4, c5}, comments)
+	comments, err = p.GetTaskComments(alice, f.T3.ID)
+	require.NoError(t, err)
+	require.Empty(t, comments)
+	replies, err := p.GetCommentReplies(alice, c1.ID)
+	require.NoError(t, err)
+	requireComments(t, []*model.Comment{c2, c5}, replies)
+
+	t.Run("add_errors", func(t *testing.T) {
+		_, err := p.AddComment(background(), base, f.T1.ID, "x", nil)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.AddComment(alice, base, "unknown", "x", nil)
+		require.Error(t, err)
+		_, err = p.AddComment(alice, base, f.T1.ID, "x", ptr("unknown"))
+		require.Error(t, err)
+		_, err = p.AddComment(alice, base, f.T1.ID, "x", &other.ID)
+		require.Error(t, err, "expected parent of another task rejected")
+		comments, err := p.GetTaskComments(alice, f.T1.ID)
+		require.NoError(t, err)
+		require.Len(t, comments, 5)
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		_, err := p.EditComment(background(), c3.ID, "x", base)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.EditComment(alice, c3.ID, "x", base)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.EditComment(alice, "unknown", "x", base)
+		require.Error(t, err)
+
+		edited := base.Add(10 * time.Hour)
+		c, err := p.EditComment(bob, c3.ID, "Second (edited)", edited)
+		require.NoError(t, err)
+		require.Equal(t, "Second (edited)", c.Body)
+		require.NotNil(t, c.Edited)
+		require.True(t, edited.Equal(*c.Edited))
+		require.True(t, base.Add(3*time.Hour).Equal(c.Creation))
+
+		stored, err := p.CommentByID(alice, c3.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Second (edited)", stored.Body)
+		require.NotNil(t, stored.Edited)
+	})
+
+	t.Run("archived", func(t *testing.T) {
+		_, err := p.ArchiveProject(alice, f.Alpha.ID, base)
+		require.NoError(t, err)
+		_, err = p.AddComment(alice, base, f.T1.ID, "x", nil)
+		require.Error(t, err)
+		_, err = p.EditComment(alice, c1.ID, "x", base)
+		require.Error(t, err)
+		require.Error(t, p.DeleteComment(alice, c1.ID))
+		_, err = p.UnarchiveProject(alice, f.Alpha.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.ErrorIs(t,
+			p.DeleteComment(background(), c1.ID), auth.ErrUnauthenticated,
+		)
+		require.ErrorIs(t, p.DeleteComment(bob, c1.ID), auth.ErrUnauthorized)
+		require.Error(t, p.DeleteComment(alice, "unknown"))
+
+		// Deleting C1 deletes the entire thread including
+		// replies by other authors.
+		require.NoError(t, p.DeleteComment(alice, c1.ID))
+		for _, c := range []*model.Comment{c1, c2, c4, c5} {
+			_, err := p.CommentByID(alice, c.ID)
+			require.Error(t, err)
+		}
+		comments, err := p.GetTaskComments(alice, f.T1.ID)
+		require.NoError(t, err)
+		requireComments(t, []*model.Comment{c3}, comments)
+	})
+
+	t.Run("delete_task", func(t *testing.T) {
+		require.NoError(t, p.DeleteTask(alice, f.T1.ID))
+		_, err := p.CommentByID(alice, c3.ID)
+		require.Error(t, err)
+		comments, err := p.GetTaskComments(alice, f.T2.ID)
+		require.NoError(t, err)
+		requireComments(t, []*model.Comment{other}, comments)
+	})
+}
+
+func testPermissions(t *testing.T, newProvider NewProvider) {
+	const (
+		Y = true
+		N = false
+	)
+	// Erin and Frank are created after the fixture, roles are in Bravo.
+	// The columns follow the order of permission.Actions.
+	for _, td := range []struct {
+		name   string
+		user   func(f *fixture, erin, frank *model.User) *model.User
+		expect [9]bool
+	}{
+		{"admin", func(f *fixture, _, _ *model.User) *model.User { return f.Alice },
+			[9]bool{Y, Y, Y, Y, Y, Y, Y, Y, Y}},
+		{"owner", func(f *fixture, _, _ *model.User) *model.User { return f.Bob },
+			[9]bool{Y, Y, Y, Y, Y, Y, Y, Y, Y}},
+		{"maintainer", func(f *fixture, _, _ *model.User) *model.User { return f.Carol },
+			[9]bool{Y, Y, Y, Y, Y, Y, Y, Y, N}},
+		{"member", func(f *fixture, _, _ *model.User) *model.User { return f.Dave },
+			[9]bool{Y, Y, Y, Y, N, N, N, N, N}},
+		{"viewer", func(_ *fixture, erin, _ *model.User) *model.User { return erin },
+			[9]bool{Y, Y, N, N, N, N, N, N, N}},
+		{"none", func(_ *fixture, _, frank *model.User) *model.User { return frank },
+			[9]bool{N, N, N, N, N, N, N, N, N}},
+	} {
+		require.Len(t, permission.Actions, len(td.expect))
+		for i, action := range permission.Actions {
+			t.Run(td.name+"/"+action.String(), func(t *testing.T) {
+				p := newProvider(t)
+				f := seed(t, p)
+				erin := mustCreateUser(t, p, "erin@test.com", "Erin")
+				frank := mustCreateUser(t, p, "frank@test.com", "Frank")
+				bob := authenticated(f.Bob.ID)
+				for _, g := range []struct {
+					user *model.User
+					role model.ProjectRole
+				}{
+					{f.Carol, model.ProjectRoleMaintainer},
+					{f.Dave, model.ProjectRoleMember},
+					{erin, model.ProjectRoleViewer},
+				} {
+					_, err := p.GrantProjectRole(bob, f.Bravo.ID, g.user.ID, g.role)
+					require.NoError(t, err)
+				}
+				bobsComment, err := p.AddComment(bob, base, f.T3.ID, "Bob's", nil)
+				require.NoError(t, err)
+
+				ctx := authenticated(td.user(f, erin, frank).ID)
+				err = performAction(ctx, p, f, action, frank.ID, bobsComment.ID)
+				if td.expect[i] {
+					require.NoError(t, err)
+				} else {
+					require.ErrorIs(t, err, auth.ErrUnauthorized)
+				}
+			})
+		}
+	}
+
+	t.Run("read", func(t *testing.T) {
+		p := newProvider(t)
+		f := seed(t, p)
+		erin := mustCreateUser(t, p, "erin@test.com", "Erin")
+		frank := mustCreateUser(t, p, "frank@test.com", "Frank")
+		bob := authenticated(f.Bob.ID)
+		_, err := p.GrantProjectRole(
+			bob, f.Bravo.ID, erin.ID, model.ProjectRoleViewer,
+		)
+		require.NoError(t, err)
+		c, err := p.AddComment(bob, base, f.T3.ID, "Bob's", nil)
+		require.NoError(t, err)
+
+		// Frank has no role in Bravo.
+		ctx := authenticated(frank.ID)
+		_, err = p.TaskByID(ctx, f.T3.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.CommentByID(ctx, c.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetTaskComments(ctx, f.T3.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetCommentReplies(ctx, c.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetProjectMembers(ctx, f.Bravo.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetTasksByProject(
+			ctx, f.Bravo.ID, nil, true, dataprovider.Page{},
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetBlockingTasks(ctx, f.T1.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetRelatedTasks(ctx, f.T3.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		projects, err := p.GetProjects(ctx, nil, nil, true, dataprovider.Page{})
+		require.NoError(t, err)
+		require.Zero(t, projects.TotalCount)
+		tasks, err := p.GetTasks(ctx, nil, nil, true, dataprovider.Page{})
+		require.NoError(t, err)
+		require.Zero(t, tasks.TotalCount)
+
+		// Erin only views Bravo, relations to tasks of Alpha are hidden.
+		ctx = authenticated(erin.ID)
+		task, err := p.TaskByID(ctx, f.T3.ID)
+		require.NoError(t, err)
+		require.Equal(t, f.T3.ID, task.ID)
+		_, err = p.TaskByID(ctx, f.T1.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.CommentByID(ctx, c.ID)
+		require.NoError(t, err)
+		projects, err = p.GetProjects(ctx, nil, nil, true, dataprovider.Page{})
+		require.NoError(t, err)
+		require.Equal(t, projectIDs([]*model.Project{f.Bravo}),
+			projectIDs(projectNodes(projects)))
+		require.Equal(t, 1, projects.TotalCount)
+		tasks, err = p.GetTasks(ctx, nil, nil, true, dataprovider.Page{})
+		require.NoError(t, err)
+		requireTaskIDs(t, []string{f.T3.ID, f.T4.ID}, taskNodes(tasks))
+		require.Equal(t, 2, tasks.TotalCount)
+		related, err := p.GetRelatedTasks(ctx, f.T3.ID)
+		require.NoError(t, err)
+		requireTaskIDs(t, nil, related)
+		userProjects, err := p.GetUserProjects(ctx, f.Carol.ID)
+		require.NoError(t, err)
+		require.Equal(t, projectIDs([]*model.Project{f.Bravo}),
+			projectIDs(userProjects))
+		assigned, err := p.GetTasksAssignedToUser(
+			ctx, f.Alice.ID, nil, true, dataprovider.Page{},
+		)
+		require.NoError(t, err)
+		require.Zero(t, assigned.TotalCount)
+		reported, err := p.GetTasksReportedByUser(ctx, f.Carol.ID)
+		require.NoError(t, err)
+		requireTaskIDs(t, []string{f.T3.ID}, reported)
+
+		_, err = p.TaskByID(background(), f.T3.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.GetTasks(background(), nil, nil, true, dataprovider.Page{})
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	})
+
+	t.Run("move_task", func(t *testing.T) {
+		p := newProvider(t)
+		f := seed(t, p)
+		alice, dave := authenticated(f.Alice.ID), authenticated(f.Dave.ID)
+		_, err := p.GrantProjectRole(
+			alice, f.Bravo.ID, f.Dave.ID, model.ProjectRoleMember,
+		)
+		require.NoError(t, err)
+		move := func() error {
+			_, err := p.UpdateTask(
+				dave, f.T3.ID, "Task three", nil,
+				model.TaskStatusDone, model.TaskPriorityHigh, nil,
+				nil, f.Alpha.ID, nil, nil, nil, nil,
+			)
+			return err
+		}
+
+		// Moving a task requires the MEMBER role in the target project.
+		require.ErrorIs(t, move(), auth.ErrUnauthorized)
+		_, err = p.GrantProjectRole(
+			alice, f.Alpha.ID, f.Dave.ID, model.ProjectRoleViewer,
+		)
+		require.NoError(t, err)
+		require.ErrorIs(t, move(), auth.ErrUnauthorized)
+		_, err = p.GrantProjectRole(
+			alice, f.Alpha.ID, f.Dave.ID, model.ProjectRoleMember,
+		)
+		require.NoError(t, err)
+		require.NoError(t, move())
+	})
+
+	t.Run("change_owners", func(t *testing.T) {
+		p := newProvider(t)
+		f := seed(t, p)
+		_, err := p.GrantProjectRole(
+			authenticated(f.Bob.ID), f.Bravo.ID, f.Carol.ID,
+			model.ProjectRoleMaintainer,
+		)
+		require.NoError(t, err)
+		carol := authenticated(f.Carol.ID)
+
+		// Changing the owners requires the OWNER role.
+		_, err = p.UpdateProject(
+			carol, f.Bravo.ID, "Bravo", "", "BRAV", []string{f.Carol.ID},
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.UpdateProject(carol, f.Bravo.ID, "Bravo", "", "BRAV", nil)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		x, err := p.UpdateProject(
+			carol, f.Bravo.ID, "Bravo", "changed", "BRAV", []string{f.Bob.ID},
+		)
+		require.NoError(t, err)
+		require.Equal(t, "changed", x.Description)
+	})
+
+	t.Run("own_comments", func(t *testing.T) {
+		p := newProvider(t)
+		f := seed(t, p)
+		_, err := p.GrantProjectRole(
+			authenticated(f.Bob.ID), f.Bravo.ID, f.Carol.ID,
+			model.ProjectRoleViewer,
+		)
+		require.NoError(t, err)
+		carol := authenticated(f.Carol.ID)
+		c, err := p.AddComment(carol, base, f.T3.ID, "Carol's", nil)
+		require.NoError(t, err)
+
+		// Comments can be edited and deleted by their authors
+		// regardless of their role.
+		_, err = p.EditComment(carol, c.ID, "edited", base)
+		require.NoError(t, err)
+		_, err = p.RevokeProjectRole(authenticated(f.Bob.ID), f.Bravo.ID, f.Carol.ID)
+		require.NoError(t, err)
+		require.NoError(t, p.DeleteComment(carol, c.ID))
+	})
+}
+
+// performAction performs action on project Bravo of f.
+// userID is a user without role and comment is a comment of another author.
+func performAction(
+	ctx context.Context,
+	p dataprovider.DataProvider,
+	f *fixture,
+	action permission.Action,
+	userID string,
+	comment string,
+) error {
+	var err error
+	switch action {
+	case permission.View:
+		_, err = p.ProjectByID(ctx, f.Bravo.ID)
+	case permission.Comment:
+		_, err = p.AddComment(ctx, base, f.T3.ID, "x", nil)
+	case permission.CreateTask:
+		_, err = p.CreateTask(
+			ctx, base, "Task five", f.Bravo.ID,
+			model.TaskStatusTodo, model.TaskPriorityLow,
+			nil, nil, nil, nil, nil, nil, nil,
+		)
+	case permission.UpdateTask:
+		_, err = p.UpdateTask(
+			ctx, f.T3.ID, "Task three (updated)", nil,
+			model.TaskStatusDone, model.TaskPriorityHigh, nil,
+			nil, f.Bravo.ID, nil, nil, nil, nil,
+		)
+	case permission.DeleteTask:
+		err = p.DeleteTask(ctx, f.T4.ID)
+	case permission.ModerateComments:
+		err = p.DeleteComment(ctx, comment)
+	case permission.UpdateProject:
+		_, err = p.UpdateProject(
+			ctx, f.Bravo.ID, "Bravo", "changed", "BRAV", []string{f.Bob.ID},
+		)
+	case permission.ArchiveProject:
+		_, err = p.ArchiveProject(ctx, f.Bravo.ID, base)
+	case permission.ManageRoles:
+		_, err = p.GrantProjectRole(
+			ctx, f.Bravo.ID, userID, model.ProjectRoleViewer,
+		)
+	default:
+		panic(fmt.Errorf("unsupported action: %s", action))
+	}
+	return err
+}
+
+func testProjectRoles(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	bob := authenticated(f.Bob.ID)
+
+	requireRoles := func(
+		t *testing.T, expect []*model.ProjectRoleGrant, projectID string,
+	) {
+		t.Helper()
+		x, err := p.ProjectByID(bob, projectID)
+		require.NoError(t, err)
+		require.Len(t, x.Roles, len(expect))
+		for i, g := range expect {
+			require.Equal(t, g.User.ID, x.Roles[i].User.ID)
+			require.Equal(t, g.Role, x.Roles[i].Role)
+		}
+	}
+	grant := func(u *model.User, r model.ProjectRole) *model.ProjectRoleGrant {
+		return &model.ProjectRoleGrant{User: u, Role: r}
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := p.GrantProjectRole(
+			background(), f.Bravo.ID, f.Carol.ID, model.ProjectRoleMember,
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.GrantProjectRole(
+			bob, "unknown", f.Carol.ID, model.ProjectRoleMember,
+		)
+		require.Error(t, err)
+		_, err = p.GrantProjectRole(
+			bob, f.Bravo.ID, "unknown", model.ProjectRoleMember,
+		)
+		require.Error(t, err)
+		_, err = p.GrantProjectRole(bob, f.Bravo.ID, f.Carol.ID, "ROOT")
+		require.Error(t, err)
+		_, err = p.GrantProjectRole(
+			bob, f.Alpha.ID, f.Carol.ID, model.ProjectRoleMember,
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.RevokeProjectRole(bob, f.Bravo.ID, f.Carol.ID)
+		require.Error(t, err, "expected no role to revoke")
+		_, err = p.RevokeProjectRole(bob, f.Alpha.ID, f.Alice.ID)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		requireRoles(t, []*model.ProjectRoleGrant{
+			grant(f.Bob, model.ProjectRoleOwner),
+		}, f.Bravo.ID)
+	})
+
+	x, err := p.GrantProjectRole(bob, f.Bravo.ID, f.Carol.ID, model.ProjectRoleMember)
+	require.NoError(t, err)
+	require.Equal(t, model.ProjectRoleMember, permission.RoleOf(x, f.Carol.ID))
+	_, err = p.GrantProjectRole(bob, f.Bravo.ID, f.Dave.ID, model.ProjectRoleViewer)
+	require.NoError(t, err)
+
+	// Granting replaces the current role.
+	_, err = p.GrantProjectRole(bob, f.Bravo.ID, f.Carol.ID, model.ProjectRoleOwner)
+	require.NoError(t, err)
+	requireRoles(t, []*model.ProjectRoleGrant{
+		grant(f.Bob, model.ProjectRoleOwner),
+		grant(f.Carol, model.ProjectRoleOwner),
+		grant(f.Dave, model.ProjectRoleViewer),
+	}, f.Bravo.ID)
+
+	// Removing an owner revokes its role, other roles are kept.
+	_, err = p.UpdateProject(bob, f.Bravo.ID, "Bravo", "", "BRAV", []string{
+		f.Bob.ID, f.Dave.ID,
+	})
+	require.NoError(t, err)
+	requireRoles(t, []*model.ProjectRoleGrant{
+		grant(f.Bob, model.ProjectRoleOwner),
+		grant(f.Dave, model.ProjectRoleOwner),
+	}, f.Bravo.ID)
+
+	x, err = p.RevokeProjectRole(bob, f.Bravo.ID, f.Dave.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.ProjectRole(""), permission.RoleOf(x, f.Dave.ID))
+	requireRoles(t, []*model.ProjectRoleGrant{
+		grant(f.Bob, model.ProjectRoleOwner),
+	}, f.Bravo.ID)
+
+	t.Run("create_project", func(t *testing.T) {
+		// The client becomes the owner if no owners are specified.
+		x, err := p.CreateProject(bob, base, "Delta", "", "DELT", nil)
+		require.NoError(t, err)
+		requireRoles(t, []*model.ProjectRoleGrant{
+			grant(f.Bob, model.ProjectRoleOwner),
+		}, x.ID)
+	})
+
+	t.Run("deactivated", func(t *testing.T) {
+		_, err := p.GrantProjectRole(
+			bob, f.Bravo.ID, f.Carol.ID, model.ProjectRoleMember,
+		)
+		require.NoError(t, err)
+		_, err = p.DeactivateUser(authenticated(f.Carol.ID), f.Carol.ID, base)
+		require.NoError(t, err)
+		requireRoles(t, []*model.ProjectRoleGrant{
+			grant(f.Bob, model.ProjectRoleOwner),
+		}, f.Bravo.ID)
+		_, err = p.GrantProjectRole(
+			bob, f.Bravo.ID, f.Carol.ID, model.ProjectRoleMember,
+		)
+		require.Error(t, err)
+	})
+
+	t.Run("archived", func(t *testing.T) {
+		_, err := p.ArchiveProject(bob, f.Bravo.ID, base)
+		require.NoError(t, err)
+		_, err = p.GrantProjectRole(
+			bob, f.Bravo.ID, f.Dave.ID, model.ProjectRoleMember,
+		)
+		require.Error(t, err)
+		_, err = p.RevokeProjectRole(bob, f.Bravo.ID, f.Bob.ID)
+		require.Error(t, err)
+		_, err = p.UnarchiveProject(bob, f.Bravo.ID)
+		require.NoError(t, err)
+	})
+}
+
+func testSetUserAdmin(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	alice, bob := authenticated(f.Alice.ID), authenticated(f.Bob.ID)
+
+	// The first user is granted the admin role.
+	require.True(t, f.Alice.Admin)
+	require.False(t, f.Bob.Admin)
+
+	_, err := p.SetUserAdmin(background(), f.Bob.ID, true)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	_, err = p.SetUserAdmin(bob, f.Bob.ID, true)
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+	_, err = p.SetUserAdmin(alice, "unknown", true)
+	require.Error(t, err)
+	_, err = p.SetUserAdmin(alice, f.Alice.ID, false)
+	require.Error(t, err, "expected admins unable to revoke their own role")
+
+	u, err := p.SetUserAdmin(alice, f.Bob.ID, true)
+	require.NoError(t, err)
+	require.True(t, u.Admin)
+
+	// Bob is allowed to manage Alpha now.
+	_, err = p.ArchiveProject(bob, f.Alpha.ID, base)
+	require.NoError(t, err)
+
+	u, err = p.SetUserAdmin(bob, f.Alice.ID, false)
+	require.NoError(t, err)
+	require.False(t, u.Admin)
+	stored, err := p.UserByID(bob, f.Alice.ID)
+	require.NoError(t, err)
+	require.False(t, stored.Admin)
+
+	// Alice remains owner of Alpha.
+	_, err = p.UnarchiveProject(alice, f.Alpha.ID)
+	require.NoError(t, err)
+	_, err = p.SetUserAdmin(alice, f.Carol.ID, true)
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+}
+
+func testSessions(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	alice, bob := authenticated(f.Alice.ID), authenticated(f.Bob.ID)
+	expires := base.Add(24 * time.Hour)
+
+	s1, err := p.CreateSession(background(), base, f.Alice.ID, "Browser", "h1", expires)
+	require.NoError(t, err)
+	require.Equal(t, f.Alice.ID, s1.User.ID)
+	require.Equal(t, "Browser", s1.UserAgent)
+	require.True(t, base.Equal(s1.Creation))
+	require.True(t, base.Equal(s1.Refreshed))
+	require.True(t, expires.Equal(s1.Expires))
+	require.Nil(t, s1.Revoked)
+	require.Equal(t, "h1", s1.RefreshTokenHash)
+	require.Empty(t, s1.PreviousRefreshTokenHash)
+
+	s2, err := p.CreateSession(
+		background(), base.Add(time.Hour), f.Alice.ID, "Phone", "h2", expires,
+	)
+	require.NoError(t, err)
+	require.NotEqual(t, s1.ID, s2.ID)
+	sBob, err := p.CreateSession(background(), base, f.Bob.ID, "", "h3", expires)
+	require.NoError(t, err)
+
+	stored, err := p.SessionByID(background(), s1.ID)
+	require.NoError(t, err)
+	require.Equal(t, s1.ID, stored.ID)
+	require.Equal(t, f.Alice.ID, stored.User.ID)
+	_, err = p.SessionByID(background(), "unknown")
+	require.ErrorIs(t, err, dataprovider.ErrNotFound)
+
+	sessions, err := p.GetUserSessions(alice, f.Alice.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{s1.ID, s2.ID}, sessionIDs(sessions))
+	_, err = p.GetUserSessions(background(), f.Alice.ID)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	_, err = p.GetUserSessions(bob, f.Alice.ID)
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+
+	t.Run("create_errors", func(t *testing.T) {
+		_, err := p.CreateSession(background(), base, "unknown", "", "h", expires)
+		require.Error(t, err)
+		_, err = p.DeactivateUser(authenticated(f.Dave.ID), f.Dave.ID, base)
+		require.NoError(t, err)
+		_, err = p.CreateSession(background(), base, f.Dave.ID, "", "h", expires)
+		require.Error(t, err, "expected deactivated user rejected")
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		refreshed := base.Add(2 * time.Hour)
+		newExpires := refreshed.Add(24 * time.Hour)
+		_, err := p.RotateRefreshToken(
+			background(), s1.ID, "wrong", "h1b", refreshed, newExpires,
+		)
+		require.ErrorIs(t, err, dataprovider.ErrRefreshTokenInvalid)
+		_, err = p.RotateRefreshToken(
+			background(), "unknown", "h1", "h1b", refreshed, newExpires,
+		)
+		require.ErrorIs(t, err, dataprovider.ErrRefreshTokenInvalid)
+		_, err = p.RotateRefreshToken(
+			background(), s1.ID, "h1", "h1b", expires, newExpires,
+		)
+		require.ErrorIs(t, err, dataprovider.ErrRefreshTokenInvalid,
+			"expected expired session rejected")
+
+		s, err := p.RotateRefreshToken(
+			background(), s1.ID, "h1", "h1b", refreshed, newExpires,
+		)
+		require.NoError(t, err)
+		require.Equal(t, "h1b", s.RefreshTokenHash)
+		require.Equal(t, "h1", s.PreviousRefreshTokenHash)
+		require.True(t, refreshed.Equal(s.Refreshed))
+		require.True(t, newExpires.Equal(s.Expires))
+		require.True(t, base.Equal(s.Creation))
+
+		s, err = p.RotateRefreshToken(
+			background(), s1.ID, "h1b", "h1c", refreshed, newExpires,
+		)
+		require.NoError(t, err)
+		require.Equal(t, "h1c", s.RefreshTokenHash)
+
+		// Reusing the previous refresh token revokes the session.
+		_, err = p.RotateRefreshToken(
+			background(), s1.ID, "h1b", "h1d", refreshed, newExpires,
+		)
+		require.ErrorIs(t, err, dataprovider.ErrRefreshTokenReused)
+		stored, err := p.SessionByID(background(), s1.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.Revoked)
+		require.True(t, refreshed.Equal(*stored.Revoked))
+		_, err = p.RotateRefreshToken(
+			background(), s1.ID, "h1c", "h1d", refreshed, newExpires,
+		)
+		require.ErrorIs(t, err, dataprovider.ErrRefreshTokenInvalid)
+
+		sessions, err := p.GetUserSessions(alice, f.Alice.ID)
+		require.NoError(t, err)
+		require.Equal(t, []string{s2.ID}, sessionIDs(sessions))
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		_, err := p.RevokeSession(background(), s2.ID, base)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.RevokeSession(bob, s2.ID, base)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.RevokeSession(alice, "unknown", base)
+		require.ErrorIs(t, err, dataprovider.ErrNotFound)
+
+		revoked := base.Add(3 * time.Hour)
+		s, err := p.RevokeSession(alice, s2.ID, revoked)
+		require.NoError(t, err)
+		require.NotNil(t, s.Revoked)
+		require.True(t, revoked.Equal(*s.Revoked))
+		_, err = p.RevokeSession(alice, s2.ID, revoked)
+		require.Error(t, err, "expected revoked session rejected")
+		_, err = p.RotateRefreshToken(
+			background(), s2.ID, "h2", "h2b", revoked, expires,
+		)
+		require.ErrorIs(t, err, dataprovider.ErrRefreshTokenInvalid)
+	})
+
+	t.Run("revoke_all", func(t *testing.T) {
+		_, err := p.CreateSession(background(), base, f.Bob.ID, "", "h4", expires)
+		require.NoError(t, err)
+		_, err = p.RevokeUserSessions(background(), f.Bob.ID, base)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.RevokeUserSessions(alice, f.Bob.ID, base)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+
+		n, err := p.RevokeUserSessions(bob, f.Bob.ID, base)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+		n, err = p.RevokeUserSessions(bob, f.Bob.ID, base)
+		require.NoError(t, err)
+		require.Zero(t, n)
+
+		sessions, err := p.GetUserSessions(bob, f.Bob.ID)
+		require.NoError(t, err)
+		require.Empty(t, sessions)
+		stored, err := p.SessionByID(background(), sBob.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.Revoked)
+	})
+}
+
+func testAPIKeys(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	alice, bob := authenticated(f.Alice.ID), authenticated(f.Bob.ID)
+	read := []model.APIKeyScope{model.APIKeyScopeReadTasks}
+
+	k1, err := p.CreateAPIKey(bob, base, f.Bob.ID, "CI", []model.APIKeyScope{
+		model.APIKeyScopeReadTasks,
+		model.APIKeyScopeWriteTasks,
+		model.APIKeyScopeReadTasks,
+	}, "h1")
+	require.NoError(t, err)
+	require.Equal(t, f.Bob.ID, k1.User.ID)
+	require.Equal(t, "CI", k1.Name)
+	require.Equal(t, model.APIKeyKindPersonal, k1.Kind)
+	require.Equal(t, []model.APIKeyScope{
+		model.APIKeyScopeReadTasks, model.APIKeyScopeWriteTasks,
+	}, k1.Scopes)
+	require.True(t, base.Equal(k1.Creation))
+	require.Nil(t, k1.LastUsed)
+	require.Nil(t, k1.Revoked)
+	require.Equal(t, "h1", k1.SecretHash)
+
+	// Admins create service keys for other users.
+	k2, err := p.CreateAPIKey(
+		alice, base.Add(time.Hour), f.Bob.ID, "Bot", read, "h2",
+	)
+	require.NoError(t, err)
+	require.NotEqual(t, k1.ID, k2.ID)
+	require.Equal(t, model.APIKeyKindService, k2.Kind)
+	kAlice, err := p.CreateAPIKey(alice, base, f.Alice.ID, "Admin", []model.APIKeyScope{
+		model.APIKeyScopeAdmin,
+	}, "h3")
+	require.NoError(t, err)
+
+	stored, err := p.APIKeyByID(background(), k1.ID)
+	require.NoError(t, err)
+	require.Equal(t, k1.ID, stored.ID)
+	require.Equal(t, f.Bob.ID, stored.User.ID)
+	require.Equal(t, k1.Scopes, stored.Scopes)
+	_, err = p.APIKeyByID(background(), "unknown")
+	require.ErrorIs(t, err, dataprovider.ErrNotFound)
+
+	keys, err := p.GetUserAPIKeys(bob, f.Bob.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{k1.ID, k2.ID}, apiKeyIDs(keys))
+	keys, err = p.GetUserAPIKeys(alice, f.Bob.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{k1.ID, k2.ID}, apiKeyIDs(keys))
+	_, err = p.GetUserAPIKeys(background(), f.Bob.ID)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	_, err = p.GetUserAPIKeys(bob, f.Alice.ID)
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+
+	t.Run("create_errors", func(t *testing.T) {
+		_, err := p.CreateAPIKey(background(), base, f.Bob.ID, "X", read, "h")
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.CreateAPIKey(bob, base, "unknown", "X", read, "h")
+		require.Error(t, err)
+		_, err = p.CreateAPIKey(bob, base, f.Carol.ID, "X", read, "h")
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.CreateAPIKey(bob, base, f.Bob.ID, "X", nil, "h")
+		require.Error(t, err, "expected at least one scope required")
+		_, err = p.CreateAPIKey(bob, base, f.Bob.ID, "X", []model.APIKeyScope{
+			"DELETE_EVERYTHING",
+		}, "h")
+		require.Error(t, err)
+		_, err = p.CreateAPIKey(bob, base, f.Bob.ID, "X", []model.APIKeyScope{
+			model.APIKeyScopeAdmin,
+		}, "h")
+		require.Error(t, err, "expected ADMIN scope to require admin")
+		_, err = p.CreateAPIKey(alice, base, f.Bob.ID, "X", []model.APIKeyScope{
+			model.APIKeyScopeAdmin,
+		}, "h")
+		require.Error(t, err, "expected ADMIN scope to require admin")
+
+		// The admin role requires the ADMIN scope when using an API key.
+		_, err = p.CreateAPIKey(
+			authenticatedByAPIKey(f.Alice.ID, model.APIKeyScopeWriteTasks),
+			base, f.Bob.ID, "X", read, "h",
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetUserAPIKeys(
+			authenticatedByAPIKey(f.Alice.ID, model.APIKeyScopeReadTasks),
+			f.Bob.ID,
+		)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.GetUserAPIKeys(
+			authenticatedByAPIKey(f.Alice.ID, model.APIKeyScopeAdmin),
+			f.Bob.ID,
+		)
+		require.NoError(t, err)
+	})
+
+	t.Run("mark_used", func(t *testing.T) {
+		used := base.Add(2 * time.Hour)
+		require.NoError(t, p.MarkAPIKeyUsed(background(), k1.ID, used))
+		stored, err := p.APIKeyByID(background(), k1.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.LastUsed)
+		require.True(t, used.Equal(*stored.LastUsed))
+		err = p.MarkAPIKeyUsed(background(), "unknown", used)
+		require.ErrorIs(t, err, dataprovider.ErrNotFound)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		revoked := base.Add(3 * time.Hour)
+		_, err := p.RevokeAPIKey(background(), k1.ID, revoked)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+		_, err = p.RevokeAPIKey(bob, kAlice.ID, revoked)
+		require.ErrorIs(t, err, auth.ErrUnauthorized)
+		_, err = p.RevokeAPIKey(bob, "unknown", revoked)
+		require.ErrorIs(t, err, dataprovider.ErrNotFound)
+
+		x, err := p.RevokeAPIKey(bob, k1.ID, revoked)
+		require.NoError(t, err)
+		require.NotNil(t, x.Revoked)
+		require.True(t, revoked.Equal(*x.Revoked))
+		_, err = p.RevokeAPIKey(bob, k1.ID, revoked)
+		require.Error(t, err, "expected already revoked key rejected")
+
+		// Admins revoke keys of other users.
+		_, err = p.RevokeAPIKey(alice, k2.ID, revoked)
+		require.NoError(t, err)
+
+		keys, err := p.GetUserAPIKeys(bob, f.Bob.ID)
+		require.NoError(t, err)
+		require.Empty(t, keys)
+		stored, err := p.APIKeyByID(background(), k1.ID)
+		require.NoError(t, err)
+		require.NotNil(t, stored.Revoked)
+	})
+}
+
+func testTwoFactor(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	alice, bob := authenticated(f.Alice.ID), authenticated(f.Bob.ID)
+
+	o, err := p.Organization(bob)
+	require.NoError(t, err)
+	require.False(t, o.TwoFactorRequired)
+	require.False(t, f.Bob.TwoFactorEnabled)
+
+	_, err = p.SetTOTPSecret(background(), f.Bob.ID, "S1")
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	_, err = p.SetTOTPSecret(alice, f.Bob.ID, "S1")
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+	_, err = p.EnableTwoFactor(bob, f.Bob.ID, 1, []string{"r1"})
+	require.Error(t, err, "expected enabling to require a secret")
+	_, err = p.SetRecoveryCodes(bob, f.Bob.ID, []string{"r1"})
+	require.Error(t, err, "expected two-factor authentication not enabled")
+
+	u, err := p.SetTOTPSecret(bob, f.Bob.ID, "S1")
+	require.NoError(t, err)
+	require.Equal(t, "S1", u.TOTPSecret)
+	require.False(t, u.TwoFactorEnabled)
+	// Enrolling again replaces the pending secret.
+	_, err = p.SetTOTPSecret(bob, f.Bob.ID, "S2")
+	require.NoError(t, err)
+
+	_, err = p.EnableTwoFactor(alice, f.Bob.ID, 10, []string{"r1", "r2"})
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+	u, err = p.EnableTwoFactor(bob, f.Bob.ID, 10, []string{"r1", "r2"})
+	require.NoError(t, err)
+	require.True(t, u.TwoFactorEnabled)
+	require.Equal(t, "S2", u.TOTPSecret)
+	require.Equal(t, int64(10), u.TOTPLastStep)
+	require.Equal(t, []string{"r1", "r2"}, u.RecoveryCodeHashes)
+
+	_, err = p.SetTOTPSecret(bob, f.Bob.ID, "S3")
+	require.Error(t, err, "expected secret immutable once enabled")
+	_, err = p.EnableTwoFactor(bob, f.Bob.ID, 11, nil)
+	require.Error(t, err, "expected already enabled")
+
+	// Time steps are accepted only once and in order.
+	require.ErrorIs(t,
+		p.UseTOTPStep(background(), f.Bob.ID, 10),
+		dataprovider.ErrTwoFactorCodeUsed,
+	)
+	require.ErrorIs(t,
+		p.UseTOTPStep(background(), f.Bob.ID, 9),
+		dataprovider.ErrTwoFactorCodeUsed,
+	)
+	require.NoError(t, p.UseTOTPStep(background(), f.Bob.ID, 11))
+	require.Error(t, p.UseTOTPStep(background(), f.Carol.ID, 11),
+		"expected two-factor authentication not enabled")
+
+	// Recovery codes are used up.
+	require.NoError(t, p.UseRecoveryCode(background(), f.Bob.ID, "r1"))
+	require.ErrorIs(t,
+		p.UseRecoveryCode(background(), f.Bob.ID, "r1"),
+		dataprovider.ErrTwoFactorCodeUsed,
+	)
+	stored, err := p.UserByID(bob, f.Bob.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), stored.TOTPLastStep)
+	require.Equal(t, []string{"r2"}, stored.RecoveryCodeHashes)
+
+	_, err = p.SetRecoveryCodes(alice, f.Bob.ID, []string{"r3"})
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+	u, err = p.SetRecoveryCodes(bob, f.Bob.ID, []string{"r3", "r4"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"r3", "r4"}, u.RecoveryCodeHashes)
+
+	_, err = p.SetTwoFactorRequired(background(), true)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	_, err = p.SetTwoFactorRequired(bob, true)
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+	_, err = p.SetTwoFactorRequired(
+		authenticatedByAPIKey(f.Alice.ID, model.APIKeyScopeWriteTasks), true,
+	)
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+	o, err = p.SetTwoFactorRequired(alice, true)
+	require.NoError(t, err)
+	require.True(t, o.TwoFactorRequired)
+	o, err = p.Organization(bob)
+	require.NoError(t, err)
+	require.True(t, o.TwoFactorRequired)
+
+	// Only admins disable two-factor authentication while it's required.
+	_, err = p.DisableTwoFactor(background(), f.Bob.ID)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	_, err = p.DisableTwoFactor(bob, f.Bob.ID)
+	require.Error(t, err, "expected two-factor authentication required")
+	_, err = p.DisableTwoFactor(authenticated(f.Carol.ID), f.Bob.ID)
+	require.ErrorIs(t, err, auth.ErrUnauthorized)
+	u, err = p.DisableTwoFactor(alice, f.Bob.ID)
+	require.NoError(t, err)
+	require.False(t, u.TwoFactorEnabled)
+	require.Empty(t, u.TOTPSecret)
+	require.Zero(t, u.TOTPLastStep)
+	require.Empty(t, u.RecoveryCodeHashes)
+
+	_, err = p.SetTwoFactorRequired(alice, false)
+	require.NoError(t, err)
+	_, err = p.SetTOTPSecret(bob, f.Bob.ID, "S4")
+	require.NoError(t, err)
+	_, err = p.EnableTwoFactor(bob, f.Bob.ID, 1, []string{"r5"})
+	require.NoError(t, err)
+	u, err = p.DisableTwoFactor(bob, f.Bob.ID)
+	require.NoError(t, err)
+	require.False(t, u.TwoFactorEnabled)
+}
+
+func testUniqueIDs(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	// Names can change, IDs must remain unique nonetheless.
+	_, err := p.UpdateUser(
+		ctx, f.Alice.ID, "alice@test.com", "Alice (renamed)",
+		"SWE", "", nil, nil, nil,
+	)
+	require.NoError(t, err)
+	u := mustCreateUser(t, p, "alice2@test.com", "Alice")
+	require.NotEqual(t, f.Alice.ID, u.ID)
+
+	_, err = p.UpdateProject(ctx, f.Alpha.ID, "Alpha (renamed)", "", "ALP2", nil)
+	require.NoError(t, err)
+	project, err := p.CreateProject(ctx, base, "Alpha", "", "ALPH", nil)
+	require.NoError(t, err)
+	require.NotEqual(t, f.Alpha.ID, project.ID)
+
+	_, err = p.UpdateTask(
+		ctx, f.T1.ID, "Task one (renamed)", nil,
+		model.TaskStatusTodo, model.TaskPriorityLow, nil,
+		nil, f.Alpha.ID, nil, nil, nil, nil,
+	)
+	require.NoError(t, err)
+	task, err := p.CreateTask(
+		ctx, base, "Task one", f.Alpha.ID,
+		model.TaskStatusTodo, model.TaskPriorityLow,
+		nil, nil, nil, nil, nil, nil, nil,
+	)
+	require.NoError(t, err)
+	require.NotEqual(t, f.T1.ID, task.ID)
+}
+
+func testGetUsers(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := background()
+	nameAlpha := model.UsersOrderNameAlpha
+
+	for _, td := range []struct {
+		name      string
+		filters   *model.UsersFilters
+		order     *model.UsersOrder
+		orderDesc bool
+		first     *int
+		expect    []*model.User
+	}{
+		{
+			name:   "all",
+			expect: []*model.User{f.Alice, f.Bob, f.Carol, f.Dave},
+		},
+		{
+			name:    "name_empty",
+			filters: &model.UsersFilters{},
+			expect:  []*model.User{f.Alice, f.Bob, f.Carol, f.Dave},
+		},
+		{
+			name:    "name_case_insensitive",
+			filters: &model.UsersFilters{Name: "AL"},
+			expect:  []*model.User{f.Alice},
+		},
+		{
+			name:    "name_no_match",
+			filters: &model.UsersFilters{Name: "zz"},
+			expect:  nil,
+		},
+		{
+			name:    "projects_one",
+			filters: &model.UsersFilters{Projects: []string{f.Alpha.ID}},
+			expect:  []*model.User{f.Alice, f.Bob, f.Carol},
+		},
+		{
+			name: "projects_all_of",
+			filters: &model.UsersFilters{
+				Projects: []string{f.Alpha.ID, f.Bravo.ID},
+			},
+			expect: []*model.User{f.Alice, f.Carol},
+		},
+		{
+			name:    "projects_no_members",
+			filters: &model.UsersFilters{Projects: []string{f.Charlie.ID}},
+			expect:  nil,
+		},
+		{
+			name:   "order_name_asc",
+			order:  &nameAlpha,
+			expect: []*model.User{f.Alice, f.Bob, f.Carol, f.Dave},
+		},
+		{
+			name:      "order_name_desc",
+			order:     &nameAlpha,
+			orderDesc: true,
+			expect:    []*model.User{f.Dave, f.Carol, f.Bob, f.Alice},
+		},
+		{
+			name:      "order_name_desc_limit",
+			order:     &nameAlpha,
+			orderDesc: true,
+			first:     ptr(2),
+			expect:    []*model.User{f.Dave, f.Carol},
+		},
+		{
+			name:   "limit_zero",
+			first:  ptr(0),
+			expect: nil,
+		},
+		{
+			name:   "limit_exceeding",
+			first:  ptr(10),
+			expect: []*model.User{f.Alice, f.Bob, f.Carol, f.Dave},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			a, err := p.GetUsers(
+				ctx, td.filters, td.order, !td.orderDesc,
+				dataprovider.Page{First: td.first},
+			)
+			require.NoError(t, err)
+			require.Equal(t, userIDs(td.expect), userIDs(userNodes(a)))
+		})
+	}
+}
+
+func testGetProjects(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+	var (
+		nameAlpha  = model.ProjectsOrderNameAlpha
+		numMembers = model.ProjectsOrderNumMembers
+		numTasks   = model.ProjectsOrderNumTasks
+	)
+
+	for _, td := range []struct {
+		name      string
+		filters   *model.ProjectsFilters
+		order     *model.ProjectsOrder
+		orderDesc bool
+		first     *int
+		expect    []*model.Project
+	}{
+		{
+			name:   "all",
+			expect: []*model.Project{f.Alpha, f.Bravo, f.Charlie},
+		},
+		{
+			name:    "members_one",
+			filters: &model.ProjectsFilters{Members: []string{f.Carol.ID}},
+			expect:  []*model.Project{f.Alpha, f.Bravo},
+		},
+		{
+			name: "members_all_of",
+			filters: &model.ProjectsFilters{
+				Members: []string{f.Bob.ID, f.Carol.ID},
+			},
+			expect: []*model.Project{f.Alpha},
+		},
+		{
+			name:    "members_none",
+			filters: &model.ProjectsFilters{Members: []string{f.Dave.ID}},
+			expect:  nil,
+		},
+		{
+			name:    "created_after_exclusive",
+			filters: &model.ProjectsFilters{CreatedAfter: ptr(base)},
+			expect:  []*model.Project{f.Bravo, f.Charlie},
+		},
+		{
+			name: "created_before_exclusive",
+			filters: &model.ProjectsFilters{
+				CreatedBefore: ptr(base.Add(2 * time.Hour)),
+			},
+			expect: []*model.Project{f.Alpha, f.Bravo},
+		},
+		{
+			name: "created_between",
+			filters: &model.ProjectsFilters{
+				CreatedAfter:  ptr(base),
+				CreatedBefore: ptr(base.Add(2 * time.Hour)),
+			},
+			expect: []*model.Project{f.Bravo},
+		},
+		{
+			name:   "order_name_asc",
+			order:  &nameAlpha,
+			expect: []*model.Project{f.Alpha, f.Bravo, f.Charlie},
+		},
+		{
+			name:      "order_name_desc",
+			order:     &nameAlpha,
+			orderDesc: true,
+			expect:    []*model.Project{f.Charlie, f.Bravo, f.Alpha},
+		},
+		{
+			name:      "order_num_members_desc",
+			order:     &numMembers,
+			orderDesc: true,
+			expect:    []*model.Project{f.Alpha, f.Bravo, f.Charlie},
+		},
+		{
+			name:   "order_num_members_asc",
+			order:  &numMembers,
+			expect: []*model.Project{f.Charlie, f.Bravo, f.Alpha},
+		},
+		{
+			// Equal keys retain the order of creation.
+			name:   "order_num_tasks_asc",
+			order:  &numTasks,
+			expect: []*model.Project{f.Charlie, f.Alpha, f.Bravo},
+		},
+		{
+			name:      "order_num_tasks_desc_limit",
+			order:     &numTasks,
+			orderDesc: true,
+			first:     ptr(1),
+			expect:    []*model.Project{f.Alpha},
+		},
+		{
+			name:   "limit_zero",
+			first:  ptr(0),
+			expect: nil,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			a, err := p.GetProjects(
+				ctx, td.filters, td.order, !td.orderDesc,
+				dataprovider.Page{First: td.first},
+			)
+			require.NoError(t, err)
+			require.Equal(t, projectIDs(td.expect), projectIDs(projectNodes(a)))
+		})
+	}
+}
+
+func testGetTasks(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+	var (
+		priority     = model.TasksOrderPriority
+		creationTime = model.TasksOrderCreationTime
+		dueTime      = model.TasksOrderDueTime
+		titleAlpha   = model.TasksOrderTitleAlpha
+	)
+
+	for _, td := range []struct {
+		name      string
+		filters   *model.TasksFilters
+		order     *model.TasksOrder
+		orderDesc bool
+		first     *int
+		expect    []*model.Task
+	}{
+		{
+			name:   "all",
+			expect: []*model.Task{f.T1, f.T2, f.T3, f.T4},
+		},
+		{
+			name:    "empty_filters",
+			filters: &model.TasksFilters{},
+			expect:  []*model.Task{f.T1, f.T2, f.T3, f.T4},
+		},
+		{
+			name:    "assignees_one",
+			filters: &model.TasksFilters{Assignees: []string{f.Alice.ID}},
+			expect:  []*model.Task{f.T1, f.T2},
+		},
+		{
+			name: "assignees_all_of",
+			filters: &model.TasksFilters{
+				Assignees: []string{f.Alice.ID, f.Bob.ID},
+			},
+			expect: []*model.Task{f.T2},
+		},
+		{
+			name:    "assignees_unknown",
+			filters: &model.TasksFilters{Assignees: []string{"unknown"}},
+			expect:  nil,
+		},
+		{
+			name:    "reporters",
+			filters: &model.TasksFilters{Reporters: []string{f.Carol.ID}},
+			expect:  []*model.Task{f.T2, f.T3},
+		},
+		{
+			name:    "projects_one",
+			filters: &model.TasksFilters{Projects: []string{f.Bravo.ID}},
+			expect:  []*model.Task{f.T3, f.T4},
+		},
+		{
+			name: "projects_any_of",
+			filters: &model.TasksFilters{
+				Projects: []string{f.Alpha.ID, f.Bravo.ID},
+			},
+			expect: []*model.Task{f.T1, f.T2, f.T3, f.T4},
+		},
+		{
+			name:    "projects_empty",
+			filters: &model.TasksFilters{Projects: []string{}},
+			expect:  nil,
+		},
+		{
+			name: "status_one",
+			filters: &model.TasksFilters{
+				Status: []model.TaskStatus{model.TaskStatusTodo},
+			},
+			expect: []*model.Task{f.T1, f.T4},
+		},
+		{
+			name: "status_any_of",
+			filters: &model.TasksFilters{Status: []model.TaskStatus{
+				model.TaskStatusTodo, model.TaskStatusDone,
+			}},
+			expect: []*model.Task{f.T1, f.T3, f.T4},
+		},
+		{
+			name:    "tags_one",
+			filters: &model.TasksFilters{Tags: []string{"backend"}},
+			expect:  []*model.Task{f.T1, f.T2},
+		},
+		{
+			name:    "tags_all_of",
+			filters: &model.TasksFilters{Tags: []string{"backend", "frontend"}},
+			expect:  []*model.Task{f.T2},
+		},
+		{
+			name: "created_after_exclusive",
+			filters: &model.TasksFilters{
+				CreatedAfter: ptr(base.Add(2 * time.Hour)),
+			},
+			expect: []*model.Task{f.T3, f.T4},
+		},
+		{
+			name: "created_before_exclusive",
+			filters: &model.TasksFilters{
+				CreatedBefore: ptr(base.Add(2 * time.Hour)),
+			},
+			expect: []*model.Task{f.T1},
+		},
+		{
+			name: "combined",
+			filters: &model.TasksFilters{
+				Projects: []string{f.Alpha.ID},
+				Status:   []model.TaskStatus{model.TaskStatusInProgress},
+				Tags:     []string{"frontend"},
+			},
+			expect: []*model.Task{f.T2},
+		},
+		{
+			name:   "order_priority_asc",
+			order:  &priority,
+			expect: []*model.Task{f.T1, f.T4, f.T3, f.T2},
+		},
+		{
+			name:      "order_priority_desc",
+			order:     &priority,
+			orderDesc: true,
+			expect:    []*model.Task{f.T2, f.T3, f.T4, f.T1},
+		},
+		{
+			name:   "order_creation_time_asc",
+			order:  &creationTime,
+			expect: []*model.Task{f.T1, f.T2, f.T3, f.T4},
+		},
+		{
+			name:      "order_creation_time_desc",
+			order:     &creationTime,
+			orderDesc: true,
+			expect:    []*model.Task{f.T4, f.T3, f.T2, f.T1},
+		},
+		{
+			// Tasks without due time are ordered last.
+			name:   "order_due_time_asc",
+			order:  &dueTime,
+			expect: []*model.Task{f.T3, f.T1, f.T4, f.T2},
+		},
+		{
+			// Tasks without due time are ordered last.
+			name:      "order_due_time_desc",
+			order:     &dueTime,
+			orderDesc: true,
+			expect:    []*model.Task{f.T4, f.T1, f.T3, f.T2},
+		},
+		{
+			name:   "order_title_asc",
+			order:  &titleAlpha,
+			expect: []*model.Task{f.T4, f.T1, f.T3, f.T2},
+		},
+		{
+			name:      "order_title_desc_limit",
+			order:     &titleAlpha,
+			orderDesc: true,
+			first:     ptr(2),
+			expect:    []*model.Task{f.T2, f.T3},
+		},
+		{
+			name: "filter_order_limit",
+			filters: &model.TasksFilters{
+				Tags: []string{"frontend"},
+			},
+			order:     &creationTime,
+			orderDesc: true,
+			first:     ptr(1),
+			expect:    []*model.Task{f.T4},
+		},
+		{
+			name:   "limit_zero",
+			first:  ptr(0),
+			expect: nil,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			a, err := p.GetTasks(
+				ctx, td.filters, td.order, !td.orderDesc,
+				dataprovider.Page{First: td.first},
+			)
+			require.NoError(t, err)
+			require.Equal(t, taskIDs(td.expect), taskIDs(taskNodes(a)))
+		})
+	}
+}
+
+func testPagination(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	// T5 ties with T1 in priority and with T2 in due time.
+	t5, err := p.CreateTask(
+		ctx, base.Add(5*time.Hour), "Task five", f.Charlie.ID,
+		model.TaskStatusTodo, model.TaskPriorityLow,
+		nil, nil, nil, []string{f.Dave.ID}, nil, nil, nil,
+	)
+	require.NoError(t, err)
+
+	var (
+		priority     = model.TasksOrderPriority
+		creationTime = model.TasksOrderCreationTime
+		dueTime      = model.TasksOrderDueTime
+		titleAlpha   = model.TasksOrderTitleAlpha
+	)
+	for _, td := range []struct {
+		name      string
+		order     *model.TasksOrder
+		orderDesc bool
+		expect    []*model.Task
+	}{
+		{
+			name:   "default",
+			expect: []*model.Task{f.T1, f.T2, f.T3, f.T4, t5},
+		},
+		{
+			name:      "default_desc",
+			orderDesc: true,
+			expect:    []*model.Task{t5, f.T4, f.T3, f.T2, f.T1},
+		},
+		{
+			// Ties are broken by creation time in ascending order.
+			name:   "priority_asc",
+			order:  &priority,
+			expect: []*model.Task{f.T1, t5, f.T4, f.T3, f.T2},
+		},
+		{
+			name:      "priority_desc",
+			order:     &priority,
+			orderDesc: true,
+			expect:    []*model.Task{f.T2, f.T3, f.T4, f.T1, t5},
+		},
+		{
+			name:   "due_time_asc",
+			order:  &dueTime,
+			expect: []*model.Task{f.T3, f.T1, f.T4, f.T2, t5},
+		},
+		{
+			name:      "due_time_desc",
+			order:     &dueTime,
+			orderDesc: true,
+			expect:    []*model.Task{f.T4, f.T1, f.T3, f.T2, t5},
+		},
+		{
+			name:      "creation_time_desc",
+			order:     &creationTime,
+			orderDesc: true,
+			expect:    []*model.Task{t5, f.T4, f.T3, f.T2, f.T1},
+		},
+		{
+			name:   "title_asc",
+			order:  &titleAlpha,
+			expect: []*model.Task{t5, f.T4, f.T1, f.T3, f.T2},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			get := func(page dataprovider.Page) connection {
+				c, err := p.GetTasks(ctx, nil, td.order, !td.orderDesc, page)
+				require.NoError(t, err)
+				return taskConnection(c)
+			}
+			expect := taskIDs(td.expect)
+			for n := 1; n <= 3; n++ {
+				require.Equal(t, expect, walk(t, n, true, get), "forward %d", n)
+				require.Equal(t, expect, walk(t, n, false, get), "backward %d", n)
+			}
+		})
+	}
+
+	t.Run("window", func(t *testing.T) {
+		all, err := p.GetTasks(ctx, nil, &priority, true, dataprovider.Page{})
+		require.NoError(t, err)
+		after := mustParseCursor(t, all.Edges[0].Cursor)
+		before := mustParseCursor(t, all.Edges[4].Cursor)
+
+		c, err := p.GetTasks(ctx, nil, &priority, true, dataprovider.Page{
+			After: after, Before: before,
+		})
+		require.NoError(t, err)
+		require.Equal(t, taskIDs([]*model.Task{t5, f.T4, f.T3}),
+			taskIDs(taskNodes(c)))
+		require.True(t, c.PageInfo.HasPreviousPage)
+		require.True(t, c.PageInfo.HasNextPage)
+		require.Equal(t, 5, c.TotalCount)
+
+		c, err = p.GetTasks(ctx, nil, &priority, true, dataprovider.Page{
+			After: after, Before: before, Last: ptr(1),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{f.T3.ID}, taskIDs(taskNodes(c)))
+		require.True(t, c.PageInfo.HasPreviousPage)
+		require.True(t, c.PageInfo.HasNextPage)
+	})
+
+	t.Run("empty_page", func(t *testing.T) {
+		all, err := p.GetTasks(ctx, nil, nil, true, dataprovider.Page{})
+		require.NoError(t, err)
+		last := mustParseCursor(t, all.Edges[4].Cursor)
+
+		c, err := p.GetTasks(ctx, nil, nil, true, dataprovider.Page{After: last})
+		require.NoError(t, err)
+		require.Empty(t, c.Edges)
+		require.True(t, c.PageInfo.HasPreviousPage)
+		require.False(t, c.PageInfo.HasNextPage)
+		require.Nil(t, c.PageInfo.StartCursor)
+		require.Nil(t, c.PageInfo.EndCursor)
+		require.Equal(t, 5, c.TotalCount)
+
+		c, err = p.GetTasks(ctx, nil, nil, true, dataprovider.Page{First: ptr(0)})
+		require.NoError(t, err)
+		require.Empty(t, c.Edges)
+		require.False(t, c.PageInfo.HasPreviousPage)
+		require.True(t, c.PageInfo.HasNextPage)
+
+		c, err = p.GetTasks(ctx, nil, nil, true, dataprovider.Page{Last: ptr(0)})
+		require.NoError(t, err)
+		require.Empty(t, c.Edges)
+		require.True(t, c.PageInfo.HasPreviousPage)
+		require.False(t, c.PageInfo.HasNextPage)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		filters := &model.TasksFilters{Tags: []string{"frontend"}}
+		c, err := p.GetTasks(ctx, filters, nil, true, dataprovider.Page{
+			First: ptr(1),
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{f.T2.ID}, taskIDs(taskNodes(c)))
+		require.False(t, c.PageInfo.HasPreviousPage)
+		require.True(t, c.PageInfo.HasNextPage)
+		require.Equal(t, 2, c.TotalCount)
+	})
+
+	t.Run("users", func(t *testing.T) {
+		nameAlpha := model.UsersOrderNameAlpha
+		get := func(page dataprovider.Page) connection {
+			c, err := p.GetUsers(ctx, nil, &nameAlpha, false, page)
+			require.NoError(t, err)
+			return userConnection(c)
+		}
+		expect := userIDs([]*model.User{f.Dave, f.Carol, f.Bob, f.Alice})
+		require.Equal(t, expect, walk(t, 3, true, get))
+		require.Equal(t, expect, walk(t, 3, false, get))
+	})
+
+	t.Run("projects", func(t *testing.T) {
+		numTasks := model.ProjectsOrderNumTasks
+		get := func(page dataprovider.Page) connection {
+			c, err := p.GetProjects(ctx, nil, &numTasks, false, page)
+			require.NoError(t, err)
+			return projectConnection(c)
+		}
+		expect := projectIDs([]*model.Project{f.Alpha, f.Bravo, f.Charlie})
+		require.Equal(t, expect, walk(t, 1, true, get))
+		require.Equal(t, expect, walk(t, 2, false, get))
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		get := func(page dataprovider.Page) connection {
+			c, err := p.GetTasksByProject(ctx, f.Alpha.ID, &priority, false, page)
+			require.NoError(t, err)
+			return taskConnection(c)
+		}
+		expect := taskIDs([]*model.Task{f.T2, f.T1})
+		require.Equal(t, expect, walk(t, 1, true, get))
+		require.Equal(t, expect, walk(t, 1, false, get))
+
+		get = func(page dataprovider.Page) connection {
+			c, err := p.GetTasksAssignedToUser(
+				ctx, f.Alice.ID, &titleAlpha, true, page,
+			)
+			require.NoError(t, err)
+			return taskConnection(c)
+		}
+		expect = taskIDs([]*model.Task{f.T1, f.T2})
+		require.Equal(t, expect, walk(t, 1, true, get))
+		require.Equal(t, expect, walk(t, 1, false, get))
+	})
+
+	// Cursors must record the direction they were obtained with.
+	t.Run("cursor_direction", func(t *testing.T) {
+		for _, asc := range []bool{true, false} {
+			c, err := p.GetTasks(ctx, nil, &priority, asc, dataprovider.Page{
+				First: ptr(1),
+			})
+			require.NoError(t, err)
+			cursor := mustParseCursor(t, *c.PageInfo.EndCursor)
+			require.Equal(t, string(priority), cursor.Order)
+			require.Equal(t, !asc, cursor.Desc)
+		}
+	})
+
+	// Cursors must remain valid when the list changes.
+	t.Run("stable_cursor", func(t *testing.T) {
+		c, err := p.GetTasks(ctx, nil, &creationTime, true, dataprovider.Page{
+			First: ptr(2),
+		})
+		require.NoError(t, err)
+		require.Equal(t, taskIDs([]*model.Task{f.T1, f.T2}), taskIDs(taskNodes(c)))
+		after := mustParseCursor(t, *c.PageInfo.EndCursor)
+
+		t0, err := p.CreateTask(
+			ctx, base, "Task zero", f.Charlie.ID,
+			model.TaskStatusTodo, model.TaskPriorityLow,
+			nil, nil, nil, nil, nil, nil, nil,
+		)
+		require.NoError(t, err)
+		_, err = p.UpdateTask(
+			ctx, f.T2.ID, "Task two (renamed)", nil,
+			model.TaskStatusInProgress, model.TaskPriorityBlocker, nil,
+			nil, f.Alpha.ID, nil, nil, nil, nil,
+		)
+		require.NoError(t, err)
+
+		c, err = p.GetTasks(ctx, nil, &creationTime, true, dataprovider.Page{
+			First: ptr(2), After: after,
+		})
+		require.NoError(t, err)
+		require.Equal(t, taskIDs([]*model.Task{f.T3, f.T4}), taskIDs(taskNodes(c)))
+		require.True(t, c.PageInfo.HasPreviousPage)
+		require.True(t, c.PageInfo.HasNextPage)
+		require.Equal(t, 6, c.TotalCount)
+
+		c, err = p.GetTasks(ctx, nil, &creationTime, true, dataprovider.Page{
+			Before: after,
+		})
+		require.NoError(t, err)
+		require.Equal(t, taskIDs([]*model.Task{t0, f.T1}), taskIDs(taskNodes(c)))
+	})
+}
+
+func testRelations(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	var (
+		projectMembers = func(id string) ([]string, error) {
+			a, err := p.GetProjectMembers(ctx, id)
+			return userIDs(a), err
+		}
+		blockingTasks = func(id string) ([]string, error) {
+			a, err := p.GetBlockingTasks(ctx, id)
+			return taskIDs(a), err
+		}
+		relatedTasks = func(id string) ([]string, error) {
+			a, err := p.GetRelatedTasks(ctx, id)
+			return taskIDs(a), err
+		}
+		tasksByProject = func(id string) ([]string, error) {
+			a, err := p.GetTasksByProject(ctx, id, nil, true, dataprovider.Page{})
+			if err != nil {
+				return nil, err
+			}
+			return taskIDs(taskNodes(a)), nil
+		}
+		userProjects = func(id string) ([]string, error) {
+			a, err := p.GetUserProjects(ctx, id)
+			return projectIDs(a), err
+		}
+		tasksAssigned = func(id string) ([]string, error) {
+			a, err := p.GetTasksAssignedToUser(
+				ctx, id, nil, true, dataprovider.Page{},
+			)
+			if err != nil {
+				return nil, err
+			}
+			return taskIDs(taskNodes(a)), nil
+		}
+		tasksReported = func(id string) ([]string, error) {
+			a, err := p.GetTasksReportedByUser(ctx, id)
+			return taskIDs(a), err
+		}
+	)
+
+	for _, td := range []struct {
+		name      string
+		list      func(id string) ([]string, error)
+		id        string
+		ordered   bool
+		expect    []string
+		expectErr bool
+	}{
+		{
+			name: "project_members_alpha", list: projectMembers, id: f.Alpha.ID,
+			expect: userIDs([]*model.User{f.Alice, f.Bob, f.Carol}),
+		},
+		{
+			name: "project_members_bravo", list: projectMembers, id: f.Bravo.ID,
+			expect: userIDs([]*model.User{f.Alice, f.Carol}),
+		},
+		{
+			name: "project_members_charlie", list: projectMembers, id: f.Charlie.ID,
+		},
+		{
+			name: "blocking_tasks_t1", list: blockingTasks, id: f.T1.ID,
+			expect: taskIDs([]*model.Task{f.T2, f.T4}),
+		},
+		{name: "blocking_tasks_t2", list: blockingTasks, id: f.T2.ID},
+		{name: "blocking_tasks_t4", list: blockingTasks, id: f.T4.ID},
+		{
+			name: "blocking_tasks_unknown", list: blockingTasks, id: "unknown",
+			expectErr: true,
+		},
+		// Relations are symmetric.
+		{
+			name: "related_tasks_t1", list: relatedTasks, id: f.T1.ID,
+			expect: []string{f.T3.ID},
+		},
+		{
+			name: "related_tasks_t2", list: relatedTasks, id: f.T2.ID,
+			expect: []string{f.T4.ID},
+		},
+		{
+			name: "related_tasks_t3", list: relatedTasks, id: f.T3.ID,
+			expect: []string{f.T1.ID},
+		},
+		{
+			name: "related_tasks_t4", list: relatedTasks, id: f.T4.ID,
+			expect: []string{f.T2.ID},
+		},
+		{
+			name: "related_tasks_unknown", list: relatedTasks, id: "unknown",
+			expectErr: true,
+		},
+		{
+			name: "tasks_by_project_alpha", list: tasksByProject, id: f.Alpha.ID,
+			ordered: true, expect: []string{f.T1.ID, f.T2.ID},
+		},
+		{
+			name: "tasks_by_project_bravo", list: tasksByProject, id: f.Bravo.ID,
+			ordered: true, expect: []string{f.T3.ID, f.T4.ID},
+		},
+		{
+			name: "tasks_by_project_charlie", list: tasksByProject,
+			id: f.Charlie.ID, ordered: true,
+		},
+		{
+			name: "tasks_by_project_unknown", list: tasksByProject, id: "unknown",
+			expectErr: true,
+		},
+		{
+			name: "user_projects_alice", list: userProjects, id: f.Alice.ID,
+			expect: []string{f.Alpha.ID, f.Bravo.ID},
+		},
+		{
+			name: "user_projects_bob", list: userProjects, id: f.Bob.ID,
+			expect: []string{f.Alpha.ID},
+		},
+		{name: "user_projects_dave", list: userProjects, id: f.Dave.ID},
+		{
+			name: "user_projects_unknown", list: userProjects, id: "unknown",
+			expectErr: true,
+		},
+		{
+			name: "tasks_assigned_alice", list: tasksAssigned, id: f.Alice.ID,
+			ordered: true, expect: []string{f.T1.ID, f.T2.ID},
+		},
+		{
+			name: "tasks_assigned_carol", list: tasksAssigned, id: f.Carol.ID,
+			ordered: true, expect: []string{f.T3.ID},
+		},
+		{
+			name: "tasks_assigned_dave", list: tasksAssigned, id: f.Dave.ID,
+			ordered: true,
+		},
+		{
+			name: "tasks_assigned_unknown", list: tasksAssigned, id: "unknown",
+			expectErr: true,
+		},
+		{
+			name: "tasks_reported_alice", list: tasksReported, id: f.Alice.ID,
+			expect: []string{f.T4.ID},
+		},
+		{
+			name: "tasks_reported_bob", list: tasksReported, id: f.Bob.ID,
+			expect: []string{f.T1.ID},
+		},
+		{
+			name: "tasks_reported_carol", list: tasksReported, id: f.Carol.ID,
+			expect: []string{f.T2.ID, f.T3.ID},
+		},
+		{name: "tasks_reported_dave", list: tasksReported, id: f.Dave.ID},
+		{
+			name: "tasks_reported_unknown", list: tasksReported, id: "unknown",
+			expectErr: true,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			a, err := td.list(td.id)
+			if td.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if td.ordered {
+				require.Equal(t, orEmpty(td.expect), orEmpty(a))
+				return
+			}
+			require.ElementsMatch(t, td.expect, a)
+		})
+	}
+}
+
+func testConcurrency(t *testing.T, newProvider NewProvider) {
+	p := newProvider(t)
+	f := seed(t, p)
+	ctx := authenticated(f.Alice.ID)
+
+	const writers, tasksPerWriter = 4, 8
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		w := w
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < tasksPerWriter; i++ {
+				task, err := p.CreateTask(
+					ctx, base, fmt.Sprintf("Concurrent %d-%d", w, i), f.Charlie.ID,
+					model.TaskStatusTodo, model.TaskPriorityLow,
+					nil, nil, nil,
+					[]string{f.Bob.ID}, []string{f.Carol.ID}, []string{f.T1.ID}, nil,
+				)
+				if !assertNoError(t, err) {
+					return
+				}
+				_, err = p.UpdateTask(
+					ctx, task.ID, task.Title+" (updated)", nil,
+					model.TaskStatusDone, model.TaskPriorityLow, nil, nil,
+					f.Charlie.ID, nil, nil, nil, nil,
+				)
+				if !assertNoError(t, err) {
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < tasksPerWriter; i++ {
+				_, err := p.GetTasks(ctx, nil, nil, true, dataprovider.Page{})
+				assertNoError(t, err)
+				_, err = p.GetProjectMembers(ctx, f.Charlie.ID)
+				assertNoError(t, err)
+				_, err = p.GetBlockingTasks(ctx, f.T1.ID)
+				assertNoError(t, err)
+				_, err = p.GetProjects(ctx, nil, nil, true, dataprovider.Page{})
+				assertNoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	tasks, err := p.GetTasksByProject(
+		ctx, f.Charlie.ID, nil, true, dataprovider.Page{},
+	)
+	require.NoError(t, err)
+	require.Equal(t, writers*tasksPerWriter, tasks.TotalCount)
+	for _, x := range taskNodes(tasks) {
+		require.Equal(t, model.TaskStatusDone, x.Status)
+	}
+}
+
+// connection is a page of any connection type.
+type connection struct {
+	IDs        []string
+	Cursors    []string
+	PageInfo   *model.PageInfo
+	TotalCount int
+}
+
+func userConnection(c *model.UserConnection) (r connection) {
+	for _, e := range c.Edges {
+		r.IDs = append(r.IDs, e.Node.ID)
+		r.Cursors = append(r.Cursors, e.Cursor)
+	}
+	r.PageInfo, r.TotalCount = c.PageInfo, c.TotalCount
+	return r
+}
+
+func projectConnection(c *model.ProjectConnection) (r connection) {
+	for _, e := range c.Edges {
+		r.IDs = append(r.IDs, e.Node.ID)
+		r.Cursors = append(r.Cursors, e.Cursor)
+	}
+	r.PageInfo, r.TotalCount = c.PageInfo, c.TotalCount
+	return r
+}
+
+func taskConnection(c *model.TaskConnection) (r connection) {
+	for _, e := range c.Edges {
+		r.IDs = append(r.IDs, e.Node.ID)
+		r.Cursors = append(r.Cursors, e.Cursor)
+	}
+	r.PageInfo, r.TotalCount = c.PageInfo, c.TotalCount
+	return r
+}
+
+// walk reads all pages of size n forward (first/after) or
+// backward (last/before), verifies the page info of every page
+// and returns the IDs of all elements in list order.
+func walk(
+	t *testing.T, n int, forward bool,
+	get func(dataprovider.Page) connection,
+) []string {
+	t.Helper()
+	var ids []string
+	var cursor *dataprovider.Cursor
+	var total int
+	for i := 0; ; i++ {
+		page := dataprovider.Page{First: &n, After: cursor}
+		if !forward {
+			page = dataprovider.Page{Last: &n, Before: cursor}
+		}
+		c := get(page)
+		require.LessOrEqual(t, len(c.IDs), n)
+		if i == 0 {
+			total = c.TotalCount
+		}
+		require.Equal(t, total, c.TotalCount)
+		require.NotEmpty(t, c.IDs)
+		require.Equal(t, c.Cursors[0], *c.PageInfo.StartCursor)
+		require.Equal(t, c.Cursors[len(c.Cursors)-1], *c.PageInfo.EndCursor)
+
+		more, beyond := c.PageInfo.HasNextPage, c.PageInfo.HasPreviousPage
+		if !forward {
+			more, beyond = beyond, more
+		}
+		require.Equal(t, i > 0, beyond, "page %d", i)
+		if forward {
+			ids = append(ids, c.IDs...)
+			cursor = mustParseCursor(t, *c.PageInfo.EndCursor)
+		} else {
+			ids = append(append([]string{}, c.IDs...), ids...)
+			cursor = mustParseCursor(t, *c.PageInfo.StartCursor)
+		}
+		if !more {
+			break
+		}
+	}
+	require.Len(t, ids, total)
+	return ids
+}
+
+func mustParseCursor(t *testing.T, s string) *dataprovider.Cursor {
+	t.Helper()
+	c, err := dataprovider.ParseCursor(s)
+	require.NoError(t, err)
+	return c
+}
+
+func mustCreateUser(
+	t *testing.T, p dataprovider.DataProvider, email, displayName string,
+) *model.User {
+	t.Helper()
+	u, err := p.CreateUser(
+		background(), email, "hash_"+displayName, displayName, "SWE", "", nil, nil,
+	)
+	require.NoError(t, err)
+	return u
+}
+
+// requireProjectCount requires n projects to exist.
+// Projects are counted by the admin Alice of f.
+func requireProjectCount(
+	t *testing.T, p dataprovider.DataProvider, f *fixture, n int,
+) {
+	t.Helper()
+	projects, err := p.GetProjects(
+		authenticated(f.Alice.ID), nil, nil, true, dataprovider.Page{},
+	)
+	require.NoError(t, err)
+	require.Len(t, projects.Edges, n)
+}
+
+// requireTaskCount requires n tasks to exist.
+// Tasks are counted by the admin Alice of f.
+func requireTaskCount(
+	t *testing.T, p dataprovider.DataProvider, f *fixture, n int,
+) {
+	t.Helper()
+	tasks, err := p.GetTasks(
+		authenticated(f.Alice.ID), nil, nil, true, dataprovider.Page{},
+	)
+	require.NoError(t, err)
+	require.Len(t, tasks.Edges, n)
+}
+
+func requireUserIDs(t *testing.T, expect []string, actual []*model.User) {
+	t.Helper()
+	require.Equal(t, orEmpty(expect), userIDs(actual))
+}
+
+func requireTaskIDs(t *testing.T, expect []string, actual []*model.Task) {
+	t.Helper()
+	require.Equal(t, orEmpty(expect), taskIDs(actual))
+}
+
+// assertNoError is safe to use in goroutines other than the test goroutine.
+func assertNoError(t *testing.T, err error) bool {
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+		return false
+	}
+	return true
+}
+
+// background returns an unauthenticated request context.
+func background() context.Context {
+	return authenticated("")
+}
+
+func authenticated(userID string) context.Context {
+	return reqctx.WithRequestContext(
+		context.Background(), slog.Default(), userID, "", time.Now(),
+	)
+}
+
+// authenticatedByAPIKey returns the context of a client
+// authenticated by an API key of the user with the given scopes.
+func authenticatedByAPIKey(
+	userID string, scopes ...model.APIKeyScope,
+) context.Context {
+	ctx := authenticated(userID)
+	c := reqctx.GetRequestContext(ctx)
+	c.APIKeyID = "apikey_test"
+	c.APIKeyScopes = scopes
+	return ctx
+}
+
+func userNodes(c *model.UserConnection) []*model.User {
+	var s []*model.User
+	for _, e := range c.Edges {
+		s = append(s, e.Node)
+	}
+	return s
+}
+
+func projectNodes(c *model.ProjectConnection) []*model.Project {
+	var s []*model.Project
+	for _, e := range c.Edges {
+		s = append(s, e.Node)
+	}
+	return s
+}
+
+func taskNodes(c *model.TaskConnection) []*model.Task {
+	var s []*model.Task
+	for _, e := range c.Edges {
+		s = append(s, e.Node)
+	}
+	return s
+}
+
+func userIDs(s []*model.User) []string {
+	ids := []string{}
+	for _, x := range s {
+		ids = append(ids, x.ID)
+	}
+	return ids
+}
+
+func projectIDs(s []*model.Project) []string {
+	ids := []string{}
+	for _, x := range s {
+		ids = append(ids, x.ID)
+	}
+	return ids
+}
+
+func taskIDs(s []*model.Task) []string {
+	ids := []string{}
+	for _, x := range s {
+		ids = append(ids, x.ID)
+	}
+	return ids
+}
+
+func sessionIDs(s []*model.Session) []string {
+	ids := []string{}
+	for _, x := range s {
+		ids = append(ids, x.ID)
+	}
+	return ids
+}
+
+func apiKeyIDs(s []*model.APIKey) []string {
+	ids := []string{}
+	for _, x := range s {
+		ids = append(ids, x.ID)
+	}
+	return ids
+}
+
+func commentIDs(s []*model.Comment) []string {
+	ids := []string{}
+	for _, x := range s {
+		ids = append(ids, x.ID)
+	}
+	return ids
+}
+
+func orEmpty(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func ptr[T any](v T) *T { return &v }
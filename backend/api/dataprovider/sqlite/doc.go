@@ -0,0 +1,3 @@
+// Package sqlite provides a persistent data provider
+// backed by an embedded SQLite database.
+package sqlite
@@ -0,0 +1,91 @@
+CREATE TABLE users (
+	id              TEXT PRIMARY KEY,
+	email           TEXT NOT NULL UNIQUE,
+	display_name    TEXT NOT NULL UNIQUE,
+	role            TEXT NOT NULL,
+	location        TEXT NOT NULL,
+	personal_status TEXT NOT NULL DEFAULT '',
+	manager_id      TEXT REFERENCES users (id),
+	password_hash   TEXT NOT NULL
+);
+
+CREATE TABLE user_subordinates (
+	user_id        TEXT NOT NULL REFERENCES users (id),
+	subordinate_id TEXT NOT NULL REFERENCES users (id),
+	position       INTEGER NOT NULL,
+	PRIMARY KEY (user_id, subordinate_id)
+);
+
+CREATE TABLE projects (
+	id          TEXT PRIMARY KEY,
+	name        TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL,
+	slug        TEXT NOT NULL UNIQUE,
+	-- creation is stored as Unix time in nanoseconds.
+	creation    INTEGER NOT NULL
+);
+
+CREATE TABLE project_owners (
+	project_id TEXT NOT NULL REFERENCES projects (id),
+	user_id    TEXT NOT NULL REFERENCES users (id),
+	position   INTEGER NOT NULL,
+	PRIMARY KEY (project_id, user_id)
+);
+
+CREATE TABLE tasks (
+	id          TEXT PRIMARY KEY,
+	title       TEXT NOT NULL UNIQUE,
+	description TEXT,
+	priority    TEXT NOT NULL,
+	status      TEXT NOT NULL,
+	-- creation and due are stored as Unix time in nanoseconds.
+	creation    INTEGER NOT NULL,
+	due         INTEGER,
+	project_id  TEXT NOT NULL REFERENCES projects (id)
+);
+
+CREATE INDEX tasks_project_id ON tasks (project_id);
+
+CREATE TABLE task_tags (
+	task_id  TEXT NOT NULL REFERENCES tasks (id),
+	tag      TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	PRIMARY KEY (task_id, tag)
+);
+
+CREATE TABLE task_assignees (
+	task_id  TEXT NOT NULL REFERENCES tasks (id),
+	user_id  TEXT NOT NULL REFERENCES users (id),
+	position INTEGER NOT NULL,
+	PRIMARY KEY (task_id, user_id)
+);
+
+CREATE INDEX task_assignees_user_id ON task_assignees (user_id);
+
+CREATE TABLE task_reporters (
+	task_id  TEXT NOT NULL REFERENCES tasks (id),
+	user_id  TEXT NOT NULL REFERENCES users (id),
+	position INTEGER NOT NULL,
+	PRIMARY KEY (task_id, user_id)
+);
+
+CREATE INDEX task_reporters_user_id ON task_reporters (user_id);
+
+-- task_blocks links task_id to the tasks it blocks.
+CREATE TABLE task_blocks (
+	task_id         TEXT NOT NULL REFERENCES tasks (id),
+	blocked_task_id TEXT NOT NULL REFERENCES tasks (id),
+	position        INTEGER NOT NULL,
+	PRIMARY KEY (task_id, blocked_task_id)
+);
+
+CREATE INDEX task_blocks_blocked_task_id ON task_blocks (blocked_task_id);
+
+CREATE TABLE task_relations (
+	task_id         TEXT NOT NULL REFERENCES tasks (id),
+	related_task_id TEXT NOT NULL REFERENCES tasks (id),
+	position        INTEGER NOT NULL,
+	PRIMARY KEY (task_id, related_task_id)
+);
+
+CREATE INDEX task_relations_related_task_id ON task_relations (related_task_id);
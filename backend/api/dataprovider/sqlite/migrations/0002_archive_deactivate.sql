@@ -0,0 +1,5 @@
+-- archived and deactivated are stored as Unix time in nanoseconds
+-- and are NULL for active projects and users.
+ALTER TABLE projects ADD COLUMN archived INTEGER;
+
+ALTER TABLE users ADD COLUMN deactivated INTEGER;
@@ -0,0 +1,15 @@
+CREATE TABLE comments (
+	id        TEXT PRIMARY KEY,
+	task_id   TEXT NOT NULL REFERENCES tasks (id),
+	author_id TEXT NOT NULL REFERENCES users (id),
+	-- parent_id is NULL for comments that aren't replies.
+	parent_id TEXT REFERENCES comments (id),
+	body      TEXT NOT NULL,
+	-- creation and edited are stored as Unix time in nanoseconds.
+	creation  INTEGER NOT NULL,
+	edited    INTEGER
+);
+
+CREATE INDEX comments_task_id ON comments (task_id);
+
+CREATE INDEX comments_parent_id ON comments (parent_id);
@@ -0,0 +1,17 @@
+CREATE TABLE sessions (
+	id         TEXT PRIMARY KEY,
+	user_id    TEXT NOT NULL REFERENCES users (id),
+	user_agent TEXT NOT NULL,
+	-- refresh_token_hash is the hash of the current refresh token,
+	-- previous_refresh_token_hash is the hash of the one it replaced.
+	refresh_token_hash          TEXT NOT NULL,
+	previous_refresh_token_hash TEXT NOT NULL DEFAULT '',
+	-- creation, refreshed, expires and revoked are stored
+	-- as Unix time in nanoseconds.
+	creation   INTEGER NOT NULL,
+	refreshed  INTEGER NOT NULL,
+	expires    INTEGER NOT NULL,
+	revoked    INTEGER
+);
+
+CREATE INDEX sessions_user_id ON sessions (user_id);
@@ -0,0 +1,22 @@
+ALTER TABLE users ADD COLUMN admin INTEGER NOT NULL DEFAULT 0;
+
+-- The first user of existing databases is granted the admin role
+-- just like the first user created in new ones.
+UPDATE users SET admin = 1
+WHERE rowid = (SELECT MIN(rowid) FROM users);
+
+-- role is any of OWNER, MAINTAINER, MEMBER and VIEWER.
+CREATE TABLE project_roles (
+	project_id TEXT NOT NULL REFERENCES projects (id),
+	user_id    TEXT NOT NULL REFERENCES users (id),
+	role       TEXT NOT NULL,
+	position   INTEGER NOT NULL,
+	PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX project_roles_user_id ON project_roles (user_id);
+
+INSERT INTO project_roles (project_id, user_id, role, position)
+SELECT project_id, user_id, 'OWNER', position FROM project_owners;
+
+DROP TABLE project_owners;
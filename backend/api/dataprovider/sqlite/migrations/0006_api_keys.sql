@@ -0,0 +1,16 @@
+CREATE TABLE api_keys (
+	id          TEXT PRIMARY KEY,
+	user_id     TEXT NOT NULL REFERENCES users (id),
+	name        TEXT NOT NULL,
+	kind        TEXT NOT NULL,
+	-- scopes is a comma-separated list of scopes.
+	scopes      TEXT NOT NULL,
+	secret_hash TEXT NOT NULL,
+	-- creation, last_used and revoked are stored
+	-- as Unix time in nanoseconds.
+	creation    INTEGER NOT NULL,
+	last_used   INTEGER,
+	revoked     INTEGER
+);
+
+CREATE INDEX api_keys_user_id ON api_keys (user_id);
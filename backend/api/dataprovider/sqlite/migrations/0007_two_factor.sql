@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN two_factor_enabled INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+-- recovery_code_hashes is a comma-separated list of recovery code hashes.
+ALTER TABLE users ADD COLUMN recovery_code_hashes TEXT NOT NULL DEFAULT '';
+
+-- organization holds exactly one row of settings shared by all users.
+CREATE TABLE organization (
+	id                  INTEGER PRIMARY KEY CHECK (id = 1),
+	two_factor_required INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO organization (id) VALUES (1);
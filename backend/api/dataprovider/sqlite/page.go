@@ -0,0 +1,300 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph/model"
+)
+
+// cursorField is the field of dataprovider.Cursor a sort key maps to.
+type cursorField int
+
+const (
+	cursorInt cursorField = iota
+	cursorString
+	cursorCreation
+	cursorID
+)
+
+// sortKey is a column of the total order of a paginated query.
+type sortKey struct {
+	expr  string
+	field cursorField
+	desc  bool
+
+	// nullsLast orders NULL values last regardless of the direction.
+	nullsLast bool
+}
+
+// pageQuery is a query for a window of a list of rows
+// ordered by keys, where the last key must be unique.
+type pageQuery struct {
+	order string
+	desc  bool
+	from  string
+	where []string
+	args  []any
+	keys  []sortKey
+}
+
+// sortKeys returns the keys ordering by expr in the given direction
+// with ties broken by creation and id in ascending order.
+// expr is ignored if empty.
+func sortKeys(
+	expr string, field cursorField, asc, nullsLast bool, creation, id string,
+) []sortKey {
+	var keys []sortKey
+	if expr != "" {
+		keys = append(keys, sortKey{
+			expr: expr, field: field, desc: !asc, nullsLast: nullsLast,
+		})
+	}
+	if creation != "" {
+		keys = append(keys, sortKey{expr: creation, field: cursorCreation})
+	}
+	return append(keys, sortKey{expr: id, field: cursorID})
+}
+
+// paginate returns the IDs and cursors of the rows selected by page
+// in order, the page info and the total number of rows.
+func paginate(
+	ctx context.Context, q queryer, pq pageQuery, page dataprovider.Page,
+) (ids, cursors []string, info *model.PageInfo, total int, err error) {
+	err = q.QueryRowContext(ctx,
+		`SELECT count(*) `+pq.from+whereClause(pq.where), pq.args...,
+	).Scan(&total)
+	if err != nil {
+		return nil, nil, nil, 0, fmt.Errorf("counting: %w", err)
+	}
+
+	where := append([]string{}, pq.where...)
+	args := append([]any{}, pq.args...)
+	if page.After != nil {
+		c, a := pq.condition(*page.After, true, false)
+		where, args = append(where, c), append(args, a...)
+	}
+	if page.Before != nil {
+		c, a := pq.condition(*page.Before, false, false)
+		where, args = append(where, c), append(args, a...)
+	}
+
+	// Backward pagination reads the rows in reverse.
+	reverse := page.Last != nil && page.First == nil
+	limit := -1
+	switch {
+	case page.First != nil:
+		limit = *page.First
+	case page.Last != nil:
+		limit = *page.Last
+	}
+
+	keys, err := pq.query(ctx, q,
+		`SELECT `+pq.columns()+` `+pq.from+whereClause(where)+
+			` ORDER BY `+pq.orderBy(reverse)+` LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, nil, nil, 0, err
+	}
+	if reverse {
+		for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+	if page.First != nil && page.Last != nil && *page.Last < len(keys) {
+		keys = keys[len(keys)-*page.Last:]
+	}
+
+	info = new(model.PageInfo)
+	switch {
+	case len(keys) > 0:
+		if info.HasPreviousPage, err = pq.exists(
+			ctx, q, keys[0], false, false,
+		); err != nil {
+			return nil, nil, nil, 0, err
+		}
+		if info.HasNextPage, err = pq.exists(
+			ctx, q, keys[len(keys)-1], true, false,
+		); err != nil {
+			return nil, nil, nil, 0, err
+		}
+	default:
+		// The page is empty, check for rows around its position,
+		// which is after the after cursor or before the before cursor
+		// when paginating backward.
+		pos, end := page.After, false
+		if reverse {
+			pos, end = page.Before, true
+		}
+		switch {
+		case pos != nil:
+			if info.HasPreviousPage, err = pq.exists(
+				ctx, q, *pos, false, !end,
+			); err != nil {
+				return nil, nil, nil, 0, err
+			}
+			if info.HasNextPage, err = pq.exists(
+				ctx, q, *pos, true, end,
+			); err != nil {
+				return nil, nil, nil, 0, err
+			}
+		case end:
+			info.HasPreviousPage = total > 0
+		default:
+			info.HasNextPage = total > 0
+		}
+	}
+
+	for _, k := range keys {
+		ids = append(ids, k.ID)
+		cursors = append(cursors, k.Encode())
+	}
+	if len(cursors) > 0 {
+		info.StartCursor = &cursors[0]
+		info.EndCursor = &cursors[len(cursors)-1]
+	}
+	return ids, cursors, info, total, nil
+}
+
+// exists returns true if there are rows ordered after (after=true)
+// or before the given cursor.
+func (pq pageQuery) exists(
+	ctx context.Context, q queryer, c dataprovider.Cursor, after, inclusive bool,
+) (bool, error) {
+	cond, args := pq.condition(c, after, inclusive)
+	return exists(ctx, q,
+		`SELECT 1 `+pq.from+
+			whereClause(append(append([]string{}, pq.where...), cond)),
+		append(append([]any{}, pq.args...), args...)...,
+	)
+}
+
+// query executes query selecting pq.columns and returns the cursors.
+func (pq pageQuery) query(
+	ctx context.Context, q queryer, query string, args ...any,
+) ([]dataprovider.Cursor, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying page: %w", err)
+	}
+	defer rows.Close()
+
+	var cursors []dataprovider.Cursor
+	for rows.Next() {
+		c := dataprovider.Cursor{Order: pq.order, Desc: pq.desc}
+		var i sql.NullInt64
+		dest := make([]any, len(pq.keys))
+		for k, key := range pq.keys {
+			switch key.field {
+			case cursorInt:
+				dest[k] = &i
+			case cursorString:
+				dest[k] = &c.String
+			case cursorCreation:
+				dest[k] = &c.Creation
+			case cursorID:
+				dest[k] = &c.ID
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scanning page: %w", err)
+		}
+		if i.Valid {
+			c.Int = &i.Int64
+		}
+		cursors = append(cursors, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading page: %w", err)
+	}
+	return cursors, nil
+}
+
+func (pq pageQuery) columns() string {
+	exprs := make([]string, len(pq.keys))
+	for i, k := range pq.keys {
+		exprs[i] = k.expr
+	}
+	return strings.Join(exprs, ", ")
+}
+
+func (pq pageQuery) orderBy(reverse bool) string {
+	var b strings.Builder
+	for i, k := range pq.keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if k.nullsLast {
+			b.WriteString(k.expr + " IS NULL")
+			b.WriteString(direction(!reverse) + ", ")
+		}
+		b.WriteString(k.expr)
+		b.WriteString(direction(k.desc == reverse))
+	}
+	return b.String()
+}
+
+// condition returns the condition selecting all rows ordered after
+// (after=true) or before the given cursor.
+// inclusive includes the row of the cursor itself.
+func (pq pageQuery) condition(
+	c dataprovider.Cursor, after, inclusive bool,
+) (string, []any) {
+	return pq.conditionFrom(0, c, after, inclusive)
+}
+
+func (pq pageQuery) conditionFrom(
+	i int, c dataprovider.Cursor, after, inclusive bool,
+) (string, []any) {
+	k := pq.keys[i]
+	op := "<"
+	if after != k.desc {
+		op = ">"
+	}
+	v := cursorValue(c, k.field)
+	if i == len(pq.keys)-1 {
+		if inclusive {
+			op += "="
+		}
+		return k.expr + " " + op + " ?", []any{v}
+	}
+
+	var beyond, equal string
+	var args []any
+	switch {
+	case k.nullsLast && v == nil && after:
+		beyond, equal = "0", k.expr+" IS NULL"
+	case k.nullsLast && v == nil:
+		beyond, equal = k.expr+" IS NOT NULL", k.expr+" IS NULL"
+	case k.nullsLast && after:
+		beyond = "(" + k.expr + " IS NULL OR " + k.expr + " " + op + " ?)"
+		equal = k.expr + " = ?"
+		args = []any{v, v}
+	default:
+		beyond, equal = k.expr+" "+op+" ?", k.expr+" = ?"
+		args = []any{v, v}
+	}
+	rest, restArgs := pq.conditionFrom(i+1, c, after, inclusive)
+	return "(" + beyond + " OR (" + equal + " AND " + rest + "))",
+		append(args, restArgs...)
+}
+
+// cursorValue returns the value of field f of c, nil for a NULL value.
+func cursorValue(c dataprovider.Cursor, f cursorField) any {
+	switch f {
+	case cursorInt:
+		if c.Int == nil {
+			return nil
+		}
+		return *c.Int
+	case cursorString:
+		return c.String
+	case cursorCreation:
+		return c.Creation
+	}
+	return c.ID
+}
@@ -0,0 +1,1236 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/permission"
+	"github.com/romshark/taskhub/api/reqctx"
+)
+
+const (
+	columnsUser = `u.id, u.email, u.display_name, u.role, u.location,
+		u.personal_status, u.manager_id, u.deactivated, u.admin,
+		u.password_hash, u.two_factor_enabled, u.totp_secret,
+		u.totp_last_step, u.recovery_code_hashes`
+	columnsProject = `p.id, p.name, p.description, p.slug, p.creation,
+		p.archived`
+	columnsTask = `t.id, t.title, t.description, t.priority, t.status,
+		t.creation, t.due, t.project_id`
+	columnsComment = `c.id, c.task_id, c.author_id, c.parent_id, c.body,
+		c.creation, c.edited`
+	columnsSession = `s.id, s.user_id, s.user_agent, s.creation, s.refreshed,
+		s.expires, s.revoked, s.refresh_token_hash,
+		s.previous_refresh_token_hash`
+	columnsAPIKey = `k.id, k.user_id, k.name, k.kind, k.scopes, k.creation,
+		k.last_used, k.revoked, k.secret_hash`
+)
+
+func (p *SQLite) UserByEmail(
+	ctx context.Context, email string,
+) (*model.User, error) {
+	users, err := queryUsers(ctx, p.db,
+		`SELECT `+columnsUser+` FROM users u WHERE u.email = ?`, email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) < 1 {
+		return nil, fmt.Errorf("user %q not found", email)
+	}
+	return users[0], nil
+}
+
+func (p *SQLite) UserByID(
+	ctx context.Context, id string,
+) (*model.User, error) {
+	return userByID(ctx, p.db, id)
+}
+
+func (p *SQLite) ProjectByID(
+	ctx context.Context, id string,
+) (*model.Project, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	project, err := projectByID(ctx, p.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := requirePermission(ctx, p.db, id, permission.View); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (p *SQLite) TaskByID(
+	ctx context.Context, id string,
+) (*model.Task, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	task, err := taskByID(ctx, p.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := requirePermission(
+		ctx, p.db, task.Project.ID, permission.View,
+	); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (p *SQLite) CommentByID(
+	ctx context.Context, id string,
+) (*model.Comment, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	c, err := commentByID(ctx, p.db, id)
+	if err != nil {
+		return nil, err
+	}
+	project, err := taskProjectID(ctx, p.db, c.Task.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requirePermission(
+		ctx, p.db, project, permission.View,
+	); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (p *SQLite) GetUsers(
+	ctx context.Context,
+	filters *model.UsersFilters,
+	order *model.UsersOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.UserConnection, error) {
+	var where []string
+	var args []any
+	if filters != nil {
+		if filters.Name != "" {
+			where = append(where, `instr(lower(u.display_name), lower(?)) > 0`)
+			args = append(args, filters.Name)
+		}
+		for _, id := range filters.Projects {
+			where = append(where, `EXISTS (
+				SELECT 1 FROM tasks t
+				WHERE t.project_id = ? AND (
+					EXISTS (SELECT 1 FROM task_assignees a
+						WHERE a.task_id = t.id AND a.user_id = u.id) OR
+					EXISTS (SELECT 1 FROM task_reporters r
+						WHERE r.task_id = t.id AND r.user_id = u.id)
+				)
+			)`)
+			args = append(args, id)
+		}
+		if filters.Deactivated != nil {
+			where = append(where, nullCondition(
+				"u.deactivated", *filters.Deactivated,
+			))
+		}
+	}
+
+	// Users are ordered by display name by default.
+	ids, cursors, info, total, err := paginate(ctx, p.db, pageQuery{
+		order: orderName(order),
+		desc:  !orderAsc,
+		from:  `FROM users u`,
+		where: where,
+		args:  args,
+		keys: sortKeys(
+			"u.display_name", cursorString, orderAsc, false, "", "u.id",
+		),
+	}, page)
+	if err != nil {
+		return nil, err
+	}
+	users, err := queryUsers(ctx, p.db,
+		`SELECT `+columnsUser+` FROM users u
+		WHERE `+inClause("u.id", len(ids)),
+		anys(ids)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &model.UserConnection{
+		Edges:      make([]*model.UserEdge, len(ids)),
+		PageInfo:   info,
+		TotalCount: total,
+	}
+	for i, u := range inOrder(users, ids, func(u *model.User) string {
+		return u.ID
+	}) {
+		c.Edges[i] = &model.UserEdge{Cursor: cursors[i], Node: u}
+	}
+	return c, nil
+}
+
+func (p *SQLite) GetProjects(
+	ctx context.Context,
+	filters *model.ProjectsFilters,
+	order *model.ProjectsOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.ProjectConnection, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	cond, args, err := viewable(ctx, p.db, "p.id")
+	if err != nil {
+		return nil, err
+	}
+	where := []string{cond}
+	if filters != nil {
+		if filters.CreatedAfter != nil {
+			where = append(where, `p.creation / 1000000000 > ?`)
+			args = append(args, filters.CreatedAfter.Unix())
+		}
+		if filters.CreatedBefore != nil {
+			where = append(where, `p.creation / 1000000000 < ?`)
+			args = append(args, filters.CreatedBefore.Unix())
+		}
+		for _, id := range filters.Members {
+			where = append(where, `EXISTS (
+				SELECT 1 FROM tasks t
+				WHERE t.project_id = p.id AND (
+					EXISTS (SELECT 1 FROM task_assignees a
+						WHERE a.task_id = t.id AND a.user_id = ?) OR
+					EXISTS (SELECT 1 FROM task_reporters r
+						WHERE r.task_id = t.id AND r.user_id = ?)
+				)
+			)`)
+			args = append(args, id, id)
+		}
+		if filters.Archived != nil {
+			where = append(where, nullCondition("p.archived", *filters.Archived))
+		}
+	}
+
+	key, field := "p.creation", cursorInt
+	if order != nil {
+		switch *order {
+		case model.ProjectsOrderNameAlpha:
+			key, field = "p.name", cursorString
+		case model.ProjectsOrderNumMembers:
+			key = `(
+				SELECT count(DISTINCT m.user_id) FROM (
+					SELECT a.user_id FROM task_assignees a
+					JOIN tasks t ON t.id = a.task_id WHERE t.project_id = p.id
+					UNION ALL
+					SELECT r.user_id FROM task_reporters r
+					JOIN tasks t ON t.id = r.task_id WHERE t.project_id = p.id
+				) m
+			)`
+		case model.ProjectsOrderNumTasks:
+			key = `(
+				SELECT count(*) FROM tasks t WHERE t.project_id = p.id
+			)`
+		}
+	}
+
+	ids, cursors, info, total, err := paginate(ctx, p.db, pageQuery{
+		order: orderName(order),
+		desc:  !orderAsc,
+		from:  `FROM projects p`,
+		where: where,
+		args:  args,
+		keys:  sortKeys(key, field, orderAsc, false, "p.creation", "p.id"),
+	}, page)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := queryProjects(ctx, p.db,
+		`SELECT `+columnsProject+` FROM projects p
+		WHERE `+inClause("p.id", len(ids)),
+		anys(ids)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &model.ProjectConnection{
+		Edges:      make([]*model.ProjectEdge, len(ids)),
+		PageInfo:   info,
+		TotalCount: total,
+	}
+	for i, x := range inOrder(projects, ids, func(x *model.Project) string {
+		return x.ID
+	}) {
+		c.Edges[i] = &model.ProjectEdge{Cursor: cursors[i], Node: x}
+	}
+	return c, nil
+}
+
+func (p *SQLite) GetTasks(
+	ctx context.Context,
+	filters *model.TasksFilters,
+	order *model.TasksOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.TaskConnection, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	cond, args, err := viewable(ctx, p.db, "t.project_id")
+	if err != nil {
+		return nil, err
+	}
+	where := []string{cond}
+	if filters != nil {
+		if filters.CreatedAfter != nil {
+			where = append(where, `t.creation / 1000000000 > ?`)
+			args = append(args, filters.CreatedAfter.Unix())
+		}
+		if filters.CreatedBefore != nil {
+			where = append(where, `t.creation / 1000000000 < ?`)
+			args = append(args, filters.CreatedBefore.Unix())
+		}
+		for _, id := range filters.Assignees {
+			where = append(where, `EXISTS (SELECT 1 FROM task_assignees a
+				WHERE a.task_id = t.id AND a.user_id = ?)`)
+			args = append(args, id)
+		}
+		for _, id := range filters.Reporters {
+			where = append(where, `EXISTS (SELECT 1 FROM task_reporters r
+				WHERE r.task_id = t.id AND r.user_id = ?)`)
+			args = append(args, id)
+		}
+		for _, tag := range filters.Tags {
+			where = append(where, `EXISTS (SELECT 1 FROM task_tags g
+				WHERE g.task_id = t.id AND g.tag = ?)`)
+			args = append(args, tag)
+		}
+		if filters.Status != nil {
+			where = append(where, inClause("t.status", len(filters.Status)))
+			args = append(args, anys(filters.Status)...)
+		}
+		if filters.Projects != nil {
+			where = append(where, inClause("t.project_id", len(filters.Projects)))
+			args = append(args, anys(filters.Projects)...)
+		}
+		if filters.Archived != nil {
+			where = append(where, `EXISTS (SELECT 1 FROM projects p
+				WHERE p.id = t.project_id AND `+
+				nullCondition("p.archived", *filters.Archived)+`)`)
+		}
+	}
+
+	return p.getTasks(ctx, where, args, order, orderAsc, page)
+}
+
+func (p *SQLite) GetProjectMembers(
+	ctx context.Context,
+	projectID string,
+) ([]*model.User, error) {
+	if err := requireViewProject(ctx, p.db, projectID); err != nil {
+		return nil, err
+	}
+	return queryUsers(ctx, p.db,
+		`SELECT `+columnsUser+` FROM users u
+		WHERE EXISTS (
+			SELECT 1 FROM tasks t
+			WHERE t.project_id = ? AND (
+				EXISTS (SELECT 1 FROM task_assignees a
+					WHERE a.task_id = t.id AND a.user_id = u.id) OR
+				EXISTS (SELECT 1 FROM task_reporters r
+					WHERE r.task_id = t.id AND r.user_id = u.id)
+			)
+		)
+		ORDER BY u.rowid`,
+		projectID,
+	)
+}
+
+func (p *SQLite) GetBlockingTasks(
+	ctx context.Context,
+	taskID string,
+) ([]*model.Task, error) {
+	if err := requireViewTask(ctx, p.db, taskID); err != nil {
+		return nil, err
+	}
+	cond, args, err := viewable(ctx, p.db, "t.project_id")
+	if err != nil {
+		return nil, err
+	}
+	return queryTasks(ctx, p.db,
+		`SELECT `+columnsTask+` FROM tasks t
+		JOIN task_blocks b ON b.task_id = t.id
+		WHERE b.blocked_task_id = ? AND t.id != b.blocked_task_id
+		AND `+cond+` ORDER BY t.rowid`,
+		append([]any{taskID}, args...)...,
+	)
+}
+
+func (p *SQLite) GetRelatedTasks(
+	ctx context.Context,
+	taskID string,
+) ([]*model.Task, error) {
+	if err := requireViewTask(ctx, p.db, taskID); err != nil {
+		return nil, err
+	}
+	cond, args, err := viewable(ctx, p.db, "t.project_id")
+	if err != nil {
+		return nil, err
+	}
+	// Relations are symmetric: a task is related to both
+	// the tasks it references and the tasks referencing it.
+	return queryTasks(ctx, p.db,
+		`SELECT `+columnsTask+` FROM tasks t
+		JOIN (
+			SELECT related_task_id AS id, 0 AS src, position AS pos
+			FROM task_relations WHERE task_id = ?1
+			UNION
+			SELECT r.task_id AS id, 1 AS src, x.rowid AS pos
+			FROM task_relations r
+			JOIN tasks x ON x.id = r.task_id
+			WHERE r.related_task_id = ?1 AND r.task_id NOT IN (
+				SELECT related_task_id FROM task_relations WHERE task_id = ?1
+			)
+		) rel ON rel.id = t.id
+		WHERE `+cond+`
+		ORDER BY rel.src, rel.pos`,
+		append([]any{taskID}, args...)...,
+	)
+}
+
+func (p *SQLite) GetTasksByProject(
+	ctx context.Context,
+	projectID string,
+	order *model.TasksOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.TaskConnection, error) {
+	if err := requireViewProject(ctx, p.db, projectID); err != nil {
+		return nil, err
+	}
+	return p.getTasks(ctx,
+		[]string{`t.project_id = ?`}, []any{projectID},
+		order, orderAsc, page,
+	)
+}
+
+func (p *SQLite) GetUserProjects(
+	ctx context.Context,
+	userID string,
+) ([]*model.Project, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireExists(ctx, p.db, "users", "user", userID); err != nil {
+		return nil, err
+	}
+	cond, args, err := viewable(ctx, p.db, "p.id")
+	if err != nil {
+		return nil, err
+	}
+	return queryProjects(ctx, p.db,
+		`SELECT `+columnsProject+` FROM projects p
+		WHERE EXISTS (
+			SELECT 1 FROM tasks t
+			WHERE t.project_id = p.id AND (
+				EXISTS (SELECT 1 FROM task_assignees a
+					WHERE a.task_id = t.id AND a.user_id = ?1) OR
+				EXISTS (SELECT 1 FROM task_reporters r
+					WHERE r.task_id = t.id AND r.user_id = ?1)
+			)
+		) AND `+cond+`
+		ORDER BY p.rowid`,
+		append([]any{userID}, args...)...,
+	)
+}
+
+func (p *SQLite) GetTasksAssignedToUser(
+	ctx context.Context,
+	userID string,
+	order *model.TasksOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.TaskConnection, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireExists(ctx, p.db, "users", "user", userID); err != nil {
+		return nil, err
+	}
+	cond, args, err := viewable(ctx, p.db, "t.project_id")
+	if err != nil {
+		return nil, err
+	}
+	return p.getTasks(ctx,
+		[]string{`EXISTS (SELECT 1 FROM task_assignees a
+			WHERE a.task_id = t.id AND a.user_id = ?)`, cond},
+		append([]any{userID}, args...),
+		order, orderAsc, page,
+	)
+}
+
+func (p *SQLite) GetTasksReportedByUser(
+	ctx context.Context,
+	userID string,
+) ([]*model.Task, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireExists(ctx, p.db, "users", "user", userID); err != nil {
+		return nil, err
+	}
+	cond, args, err := viewable(ctx, p.db, "t.project_id")
+	if err != nil {
+		return nil, err
+	}
+	return queryTasks(ctx, p.db,
+		`SELECT `+columnsTask+` FROM tasks t
+		JOIN task_reporters r ON r.task_id = t.id
+		WHERE r.user_id = ? AND `+cond+` ORDER BY t.rowid`,
+		append([]any{userID}, args...)...,
+	)
+}
+
+func (p *SQLite) GetTaskComments(
+	ctx context.Context,
+	taskID string,
+) ([]*model.Comment, error) {
+	if err := requireViewTask(ctx, p.db, taskID); err != nil {
+		return nil, err
+	}
+	return queryComments(ctx, p.db,
+		`SELECT `+columnsComment+` FROM comments c
+		WHERE c.task_id = ? ORDER BY c.creation, c.rowid`,
+		taskID,
+	)
+}
+
+func (p *SQLite) GetCommentReplies(
+	ctx context.Context,
+	commentID string,
+) ([]*model.Comment, error) {
+	if _, err := p.CommentByID(ctx, commentID); err != nil {
+		return nil, err
+	}
+	return queryComments(ctx, p.db,
+		`SELECT `+columnsComment+` FROM comments c
+		WHERE c.parent_id = ? ORDER BY c.creation, c.rowid`,
+		commentID,
+	)
+}
+
+func (p *SQLite) SessionByID(
+	ctx context.Context, id string,
+) (*model.Session, error) {
+	return sessionByID(ctx, p.db, id)
+}
+
+func (p *SQLite) GetUserSessions(
+	ctx context.Context,
+	userID string,
+) ([]*model.Session, error) {
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+	if err := requireExists(ctx, p.db, "users", "user", userID); err != nil {
+		return nil, err
+	}
+	return querySessions(ctx, p.db,
+		`SELECT `+columnsSession+` FROM sessions s
+		WHERE s.user_id = ? AND s.revoked IS NULL
+		ORDER BY s.creation, s.rowid`,
+		userID,
+	)
+}
+
+func (p *SQLite) Organization(
+	ctx context.Context,
+) (*model.Organization, error) {
+	return organization(ctx, p.db)
+}
+
+func (p *SQLite) APIKeyByID(
+	ctx context.Context, id string,
+) (*model.APIKey, error) {
+	return apiKeyByID(ctx, p.db, id)
+}
+
+func (p *SQLite) GetUserAPIKeys(
+	ctx context.Context,
+	userID string,
+) ([]*model.APIKey, error) {
+	admin, err := isAdmin(ctx, p.db)
+	if err != nil {
+		return nil, err
+	}
+	if !admin {
+		if err := auth.RequireOwner(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	if err := requireExists(ctx, p.db, "users", "user", userID); err != nil {
+		return nil, err
+	}
+	return queryAPIKeys(ctx, p.db,
+		`SELECT `+columnsAPIKey+` FROM api_keys k
+		WHERE k.user_id = ? AND k.revoked IS NULL
+		ORDER BY k.creation, k.rowid`,
+		userID,
+	)
+}
+
+// getTasks returns the page of tasks matching all conditions in where.
+func (p *SQLite) getTasks(
+	ctx context.Context,
+	where []string,
+	args []any,
+	order *model.TasksOrder,
+	orderAsc bool,
+	page dataprovider.Page,
+) (*model.TaskConnection, error) {
+	key, field, nullsLast := "t.creation", cursorInt, false
+	if order != nil {
+		switch *order {
+		case model.TasksOrderTitleAlpha:
+			key, field = "t.title", cursorString
+		case model.TasksOrderPriority:
+			key = `CASE t.priority
+				WHEN 'BLOCKER' THEN 4
+				WHEN 'HIGH' THEN 3
+				WHEN 'MEDIUM' THEN 2
+				WHEN 'LOW' THEN 1
+				ELSE 0 END`
+		case model.TasksOrderDueTime:
+			// Tasks without a due time are always ordered last.
+			key, nullsLast = "t.due", true
+		}
+	}
+
+	ids, cursors, info, total, err := paginate(ctx, p.db, pageQuery{
+		order: orderName(order),
+		desc:  !orderAsc,
+		from:  `FROM tasks t`,
+		where: where,
+		args:  args,
+		keys:  sortKeys(key, field, orderAsc, nullsLast, "t.creation", "t.id"),
+	}, page)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := queryTasks(ctx, p.db,
+		`SELECT `+columnsTask+` FROM tasks t
+		WHERE `+inClause("t.id", len(ids)),
+		anys(ids)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := &model.TaskConnection{
+		Edges:      make([]*model.TaskEdge, len(ids)),
+		PageInfo:   info,
+		TotalCount: total,
+	}
+	for i, t := range inOrder(tasks, ids, func(t *model.Task) string {
+		return t.ID
+	}) {
+		c.Edges[i] = &model.TaskEdge{Cursor: cursors[i], Node: t}
+	}
+	return c, nil
+}
+
+func userByID(ctx context.Context, q queryer, id string) (*model.User, error) {
+	users, err := queryUsers(ctx, q,
+		`SELECT `+columnsUser+` FROM users u WHERE u.id = ?`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) < 1 {
+		return nil, fmt.Errorf("user %q not found", id)
+	}
+	return users[0], nil
+}
+
+func projectByID(
+	ctx context.Context, q queryer, id string,
+) (*model.Project, error) {
+	projects, err := queryProjects(ctx, q,
+		`SELECT `+columnsProject+` FROM projects p WHERE p.id = ?`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) < 1 {
+		return nil, fmt.Errorf("project %q not found", id)
+	}
+	return projects[0], nil
+}
+
+func taskByID(ctx context.Context, q queryer, id string) (*model.Task, error) {
+	tasks, err := queryTasks(ctx, q,
+		`SELECT `+columnsTask+` FROM tasks t WHERE t.id = ?`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) < 1 {
+		return nil, fmt.Errorf("task %q not found", id)
+	}
+	return tasks[0], nil
+}
+
+func commentByID(
+	ctx context.Context, q queryer, id string,
+) (*model.Comment, error) {
+	comments, err := queryComments(ctx, q,
+		`SELECT `+columnsComment+` FROM comments c WHERE c.id = ?`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(comments) < 1 {
+		return nil, fmt.Errorf("comment %q not found", id)
+	}
+	return comments[0], nil
+}
+
+func sessionByID(
+	ctx context.Context, q queryer, id string,
+) (*model.Session, error) {
+	sessions, err := querySessions(ctx, q,
+		`SELECT `+columnsSession+` FROM sessions s WHERE s.id = ?`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) < 1 {
+		return nil, fmt.Errorf("session %q %w", id, dataprovider.ErrNotFound)
+	}
+	return sessions[0], nil
+}
+
+func apiKeyByID(
+	ctx context.Context, q queryer, id string,
+) (*model.APIKey, error) {
+	keys, err := queryAPIKeys(ctx, q,
+		`SELECT `+columnsAPIKey+` FROM api_keys k WHERE k.id = ?`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) < 1 {
+		return nil, fmt.Errorf("API key %q %w", id, dataprovider.ErrNotFound)
+	}
+	return keys[0], nil
+}
+
+func organization(
+	ctx context.Context, q queryer,
+) (*model.Organization, error) {
+	o := new(model.Organization)
+	if err := q.QueryRowContext(ctx,
+		`SELECT two_factor_required FROM organization WHERE id = 1`,
+	).Scan(&o.TwoFactorRequired); err != nil {
+		return nil, fmt.Errorf("querying organization: %w", err)
+	}
+	return o, nil
+}
+
+// queryUsers executes query selecting columnsUser and
+// loads the subordinate references of all returned users.
+func queryUsers(
+	ctx context.Context, q queryer, query string, args ...any,
+) ([]*model.User, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*model.User{}
+	ids := []string{}
+	for rows.Next() {
+		u := new(model.User)
+		var (
+			managerID     sql.NullString
+			deactivated   sql.NullInt64
+			recoveryCodes string
+		)
+		if err := rows.Scan(
+			&u.ID, &u.Email, &u.DisplayName, &u.Role, &u.Location,
+			&u.PersonalStatus, &managerID, &deactivated, &u.Admin,
+			&u.PasswordHash, &u.TwoFactorEnabled, &u.TOTPSecret,
+			&u.TOTPLastStep, &recoveryCodes,
+		); err != nil {
+			return nil, fmt.Errorf("scanning user: %w", err)
+		}
+		if recoveryCodes != "" {
+			u.RecoveryCodeHashes = strings.Split(recoveryCodes, ",")
+		}
+		if managerID.Valid {
+			u.Manager = &model.User{ID: managerID.String}
+		}
+		u.Deactivated = nullTime(deactivated)
+		users = append(users, u)
+		ids = append(ids, u.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading users: %w", err)
+	}
+
+	subordinates, err := queryRefs(ctx, q,
+		"user_subordinates", "user_id", "subordinate_id", ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		for _, id := range subordinates[u.ID] {
+			u.Subordinates = append(u.Subordinates, &model.User{ID: id})
+		}
+	}
+	return users, nil
+}
+
+// queryProjects executes query selecting columnsProject and
+// loads the role grants of all returned projects.
+func queryProjects(
+	ctx context.Context, q queryer, query string, args ...any,
+) ([]*model.Project, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := []*model.Project{}
+	ids := []string{}
+	for rows.Next() {
+		p := new(model.Project)
+		var (
+			creation int64
+			archived sql.NullInt64
+		)
+		if err := rows.Scan(
+			&p.ID, &p.Name, &p.Description, &p.Slug, &creation, &archived,
+		); err != nil {
+			return nil, fmt.Errorf("scanning project: %w", err)
+		}
+		p.Creation = timeFromInt(creation)
+		p.Archived = nullTime(archived)
+		projects = append(projects, p)
+		ids = append(ids, p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading projects: %w", err)
+	}
+
+	roles, err := queryRoles(ctx, q, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		p.Roles = roles[p.ID]
+	}
+	return projects, nil
+}
+
+// queryTasks executes query selecting columnsTask and loads the tags,
+// assignee, reporter, blocked and related task references of all
+// returned tasks.
+func queryTasks(
+	ctx context.Context, q queryer, query string, args ...any,
+) ([]*model.Task, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []*model.Task{}
+	ids := []string{}
+	for rows.Next() {
+		t := new(model.Task)
+		var (
+			description sql.NullString
+			creation    int64
+			due         sql.NullInt64
+			projectID   string
+		)
+		if err := rows.Scan(
+			&t.ID, &t.Title, &description, &t.Priority, &t.Status,
+			&creation, &due, &projectID,
+		); err != nil {
+			return nil, fmt.Errorf("scanning task: %w", err)
+		}
+		if description.Valid {
+			t.Description = &description.String
+		}
+		t.Creation = timeFromInt(creation)
+		t.Due = nullTime(due)
+		t.Project = &model.Project{ID: projectID}
+		tasks = append(tasks, t)
+		ids = append(ids, t.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading tasks: %w", err)
+	}
+
+	tags, err := queryRefs(ctx, q, "task_tags", "task_id", "tag", ids)
+	if err != nil {
+		return nil, err
+	}
+	assignees, err := queryRefs(ctx, q,
+		"task_assignees", "task_id", "user_id", ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	reporters, err := queryRefs(ctx, q,
+		"task_reporters", "task_id", "user_id", ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := queryRefs(ctx, q,
+		"task_blocks", "task_id", "blocked_task_id", ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	relatesTo, err := queryRefs(ctx, q,
+		"task_relations", "task_id", "related_task_id", ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tasks {
+		t.Tags = tags[t.ID]
+		for _, id := range assignees[t.ID] {
+			t.Assignees = append(t.Assignees, &model.User{ID: id})
+		}
+		for _, id := range reporters[t.ID] {
+			t.Reporters = append(t.Reporters, &model.User{ID: id})
+		}
+		for _, id := range blocks[t.ID] {
+			t.Blocks = append(t.Blocks, &model.Task{ID: id})
+		}
+		for _, id := range relatesTo[t.ID] {
+			t.RelatesTo = append(t.RelatesTo, &model.Task{ID: id})
+		}
+	}
+	return tasks, nil
+}
+
+// queryComments executes query selecting columnsComment.
+func queryComments(
+	ctx context.Context, q queryer, query string, args ...any,
+) ([]*model.Comment, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []*model.Comment{}
+	for rows.Next() {
+		c := new(model.Comment)
+		var (
+			taskID, authorID string
+			parentID         sql.NullString
+			creation         int64
+			edited           sql.NullInt64
+		)
+		if err := rows.Scan(
+			&c.ID, &taskID, &authorID, &parentID, &c.Body, &creation, &edited,
+		); err != nil {
+			return nil, fmt.Errorf("scanning comment: %w", err)
+		}
+		c.Task = &model.Task{ID: taskID}
+		c.Author = &model.User{ID: authorID}
+		if parentID.Valid {
+			c.Parent = &model.Comment{ID: parentID.String}
+		}
+		c.Creation = timeFromInt(creation)
+		c.Edited = nullTime(edited)
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading comments: %w", err)
+	}
+	return comments, nil
+}
+
+func querySessions(
+	ctx context.Context, q queryer, query string, args ...any,
+) ([]*model.Session, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []*model.Session{}
+	for rows.Next() {
+		s := new(model.Session)
+		var (
+			userID                       string
+			creation, refreshed, expires int64
+			revoked                      sql.NullInt64
+		)
+		if err := rows.Scan(
+			&s.ID, &userID, &s.UserAgent, &creation, &refreshed, &expires,
+			&revoked, &s.RefreshTokenHash, &s.PreviousRefreshTokenHash,
+		); err != nil {
+			return nil, fmt.Errorf("scanning session: %w", err)
+		}
+		s.User = &model.User{ID: userID}
+		s.Creation = timeFromInt(creation)
+		s.Refreshed = timeFromInt(refreshed)
+		s.Expires = timeFromInt(expires)
+		s.Revoked = nullTime(revoked)
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func queryAPIKeys(
+	ctx context.Context, q queryer, query string, args ...any,
+) ([]*model.APIKey, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*model.APIKey{}
+	for rows.Next() {
+		k := new(model.APIKey)
+		var (
+			userID, scopes    string
+			creation          int64
+			lastUsed, revoked sql.NullInt64
+		)
+		if err := rows.Scan(
+			&k.ID, &userID, &k.Name, &k.Kind, &scopes, &creation,
+			&lastUsed, &revoked, &k.SecretHash,
+		); err != nil {
+			return nil, fmt.Errorf("scanning API key: %w", err)
+		}
+		k.User = &model.User{ID: userID}
+		for _, s := range strings.Split(scopes, ",") {
+			k.Scopes = append(k.Scopes, model.APIKeyScope(s))
+		}
+		k.Creation = timeFromInt(creation)
+		k.LastUsed = nullTime(lastUsed)
+		k.Revoked = nullTime(revoked)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// queryRefs reads column ref of all rows in table where
+// column key is any of keys and returns them grouped by key
+// in the order of their position.
+func queryRefs(
+	ctx context.Context, q queryer, table, key, ref string, keys []string,
+) (map[string][]string, error) {
+	if len(keys) < 1 {
+		return nil, nil
+	}
+	rows, err := q.QueryContext(ctx,
+		`SELECT `+key+`, `+ref+` FROM `+table+
+			` WHERE `+key+` IN (`+placeholders(len(keys))+`)`+
+			` ORDER BY `+key+`, position`,
+		anys(keys)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	m := make(map[string][]string, len(keys))
+	for rows.Next() {
+		var k, r string
+		if err := rows.Scan(&k, &r); err != nil {
+			return nil, fmt.Errorf("scanning %s: %w", table, err)
+		}
+		m[k] = append(m[k], r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", table, err)
+	}
+	return m, nil
+}
+
+// queryRoles returns the role grants of projects
+// with shallow user references by project ID.
+func queryRoles(
+	ctx context.Context, q queryer, projects []string,
+) (map[string][]*model.ProjectRoleGrant, error) {
+	if len(projects) < 1 {
+		return nil, nil
+	}
+	rows, err := q.QueryContext(ctx,
+		`SELECT project_id, user_id, role FROM project_roles
+		WHERE project_id IN (`+placeholders(len(projects))+`)
+		ORDER BY project_id, position`,
+		anys(projects)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying project_roles: %w", err)
+	}
+	defer rows.Close()
+
+	m := make(map[string][]*model.ProjectRoleGrant, len(projects))
+	for rows.Next() {
+		var project, user string
+		var role model.ProjectRole
+		if err := rows.Scan(&project, &user, &role); err != nil {
+			return nil, fmt.Errorf("scanning project_roles: %w", err)
+		}
+		m[project] = append(m[project], &model.ProjectRoleGrant{
+			User: &model.User{ID: user}, Role: role,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading project_roles: %w", err)
+	}
+	return m, nil
+}
+
+// requireViewProject returns nil if project id exists and the client
+// is allowed to view it.
+func requireViewProject(ctx context.Context, q queryer, id string) error {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return err
+	}
+	if err := requireExists(ctx, q, "projects", "project", id); err != nil {
+		return err
+	}
+	return requirePermission(ctx, q, id, permission.View)
+}
+
+// requireViewTask returns nil if task id exists and the client
+// is allowed to view its project.
+func requireViewTask(ctx context.Context, q queryer, id string) error {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return err
+	}
+	project, err := taskProjectID(ctx, q, id)
+	if err != nil {
+		return err
+	}
+	return requirePermission(ctx, q, project, permission.View)
+}
+
+// viewable returns the condition restricting column, which must refer to
+// project IDs, to the projects the client is allowed to view.
+// Any project role includes permission.View.
+func viewable(
+	ctx context.Context, q queryer, column string,
+) (cond string, args []any, err error) {
+	admin, err := isAdmin(ctx, q)
+	if err != nil {
+		return "", nil, err
+	}
+	return `(? OR EXISTS (SELECT 1 FROM project_roles v
+		WHERE v.project_id = ` + column + ` AND v.user_id = ?))`,
+		[]any{admin, reqctx.GetRequestContext(ctx).UserID}, nil
+}
+
+// requireExists returns an error if there's no row with the given id in table.
+// name is the human-readable name of the entity used in the error message.
+func requireExists(
+	ctx context.Context, q queryer, table, name, id string,
+) error {
+	ok, err := exists(ctx, q, `SELECT 1 FROM `+table+` WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s %q not found", name, id)
+	}
+	return nil
+}
+
+// exists returns true if query returns at least one row.
+func exists(
+	ctx context.Context, q queryer, query string, args ...any,
+) (bool, error) {
+	var x int
+	err := q.QueryRowContext(ctx, query+` LIMIT 1`, args...).Scan(&x)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("querying: %w", err)
+	}
+	return true, nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) < 1 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `)
+}
+
+// nullCondition returns the condition selecting rows where
+// column is NOT NULL if notNull is true and NULL otherwise.
+func nullCondition(column string, notNull bool) string {
+	if notNull {
+		return column + " IS NOT NULL"
+	}
+	return column + " IS NULL"
+}
+
+// inClause returns an IN condition for column with n placeholders.
+// An empty set matches nothing.
+func inClause(column string, n int) string {
+	if n < 1 {
+		return "0"
+	}
+	return column + " IN (" + placeholders(n) + ")"
+}
+
+// inOrder returns s ordered by ids.
+func inOrder[T any](s []T, ids []string, id func(T) string) []T {
+	byID := make(map[string]T, len(s))
+	for _, x := range s {
+		byID[id(x)] = x
+	}
+	r := make([]T, len(ids))
+	for i, x := range ids {
+		r[i] = byID[x]
+	}
+	return r
+}
+
+// orderName returns the name of order, or an empty string if order is nil.
+func orderName[T ~string](order *T) string {
+	if order == nil {
+		return ""
+	}
+	return string(*order)
+}
+
+func direction(asc bool) string {
+	if asc {
+		return " ASC"
+	}
+	return " DESC"
+}
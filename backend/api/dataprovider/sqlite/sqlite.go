@@ -0,0 +1,164 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/romshark/taskhub/api/dataprovider"
+
+	// Register the pure-Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+var _ dataprovider.DataProvider = &SQLite{}
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// SQLite is a persistent data provider backed by an embedded SQLite database.
+//
+// Relations of returned models (such as model.Task.Project or
+// model.User.Manager) are shallow references that only carry the ID.
+type SQLite struct {
+	db *sql.DB
+}
+
+// Open opens the SQLite database file at path creating it if it
+// doesn't exist yet and applies all pending schema migrations.
+// Use ":memory:" as path for a non-persistent in-memory database.
+func Open(ctx context.Context, path string) (*SQLite, error) {
+	dsn := "file:" + path +
+		"?_pragma=foreign_keys(1)" +
+		"&_pragma=busy_timeout(5000)" +
+		"&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	// SQLite only supports a single writer at a time and in-memory
+	// databases are private to their connection.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (p *SQLite) Close() error { return p.db.Close() }
+
+// migrate applies all embedded migrations that weren't applied yet.
+// The index of the last applied migration is tracked in PRAGMA user_version.
+func migrate(ctx context.Context, db *sql.DB) error {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("listing migrations: %w", err)
+	}
+	sort.Strings(files)
+
+	var version int
+	if err := db.QueryRowContext(
+		ctx, "PRAGMA user_version",
+	).Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if version > len(files) {
+		return fmt.Errorf(
+			"database schema version (%d) is newer than supported (%d)",
+			version, len(files),
+		)
+	}
+
+	for i := version; i < len(files); i++ {
+		src, err := migrations.ReadFile(files[i])
+		if err != nil {
+			return fmt.Errorf("reading migration %q: %w", files[i], err)
+		}
+		err = transaction(ctx, db, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, string(src)); err != nil {
+				return err
+			}
+			// PRAGMA doesn't support parameters.
+			_, err := tx.ExecContext(
+				ctx, "PRAGMA user_version = "+strconv.Itoa(i+1),
+			)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("applying migration %q: %w", files[i], err)
+		}
+	}
+	return nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// transaction executes fn in a transaction and commits it if fn returns nil,
+// otherwise the transaction is rolled back.
+func transaction(
+	ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// makeID generates a new unique identifier with the given prefix.
+func makeID(prefix string) string {
+	return prefix + "_" + ulid.MustNew(ulid.Now(), rand.Reader).String()
+}
+
+// placeholders returns n comma-separated query parameter placeholders.
+func placeholders(n int) string {
+	if n < 1 {
+		return ""
+	}
+	return strings.Repeat("?,", n-1) + "?"
+}
+
+// anys converts s to a slice of query arguments.
+func anys[T any](s []T) []any {
+	a := make([]any, len(s))
+	for i := range s {
+		a[i] = s[i]
+	}
+	return a
+}
+
+func timeToInt(t time.Time) int64 { return t.UnixNano() }
+
+func timeFromInt(n int64) time.Time { return time.Unix(0, n) }
+
+// nullTime returns nil if n is NULL.
+func nullTime(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := timeFromInt(n.Int64)
+	return &t
+}
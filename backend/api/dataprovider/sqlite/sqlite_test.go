@@ -0,0 +1,172 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/dataprovider/providertest"
+	"github.com/romshark/taskhub/api/dataprovider/sqlite"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestConformance(t *testing.T) {
+	providertest.Run(t, func(t *testing.T) dataprovider.DataProvider {
+		db, err := sqlite.Open(context.Background(), ":memory:")
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "taskhub.db")
+	ctx := context.Background()
+
+	db, err := sqlite.Open(ctx, path)
+	require.NoError(t, err)
+	u, err := db.CreateUser(
+		ctx, "a@test.com", "hash", "Alice", "SWE", "Berlin", nil, nil,
+	)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Reopening must not reapply migrations and must preserve the data.
+	db, err = sqlite.Open(ctx, path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	a, err := db.UserByEmail(ctx, "a@test.com")
+	require.NoError(t, err)
+	require.Equal(t, u, a)
+}
+
+func TestTasks(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.Open(ctx, ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	alice, err := db.CreateUser(
+		ctx, "a@test.com", "hash", "Alice", "SWE", "Berlin", nil, nil,
+	)
+	require.NoError(t, err)
+	bob, err := db.CreateUser(
+		ctx, "b@test.com", "hash", "Bob", "SWE", "Paris", &alice.ID, nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, &model.User{ID: alice.ID}, bob.Manager)
+
+	_, err = db.CreateUser(
+		ctx, "a@test.com", "hash", "Alice 2", "SWE", "Berlin", nil, nil,
+	)
+	require.EqualError(t, err, "non-unique email")
+
+	_, err = db.CreateProject(
+		reqctx.WithRequestContext(ctx, slog.Default(), "", "", time.Now()),
+		time.Now(), "Project", "", "PROJ", nil,
+	)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+
+	ctx = reqctx.WithRequestContext(
+		ctx, slog.Default(), alice.ID, "", time.Now(),
+	)
+	creation := time.Unix(1000, 0)
+
+	p, err := db.CreateProject(
+		ctx, creation, "Project", "", "PROJ", []string{alice.ID},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []*model.User{{ID: alice.ID}}, p.Owners())
+	require.True(t, creation.Equal(p.Creation))
+
+	t1, err := db.CreateTask(
+		ctx, creation, "First", p.ID,
+		model.TaskStatusTodo, model.TaskPriorityLow,
+		nil, nil, []string{"a", "b"},
+		[]string{alice.ID}, []string{bob.ID}, nil, nil,
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, t1.Tags)
+	require.Equal(t, []*model.User{{ID: alice.ID}}, t1.Assignees)
+	require.Equal(t, []*model.User{{ID: bob.ID}}, t1.Reporters)
+
+	t2, err := db.CreateTask(
+		ctx, creation.Add(time.Hour), "Second", p.ID,
+		model.TaskStatusDone, model.TaskPriorityBlocker,
+		nil, nil, nil,
+		[]string{bob.ID}, []string{bob.ID},
+		[]string{t1.ID}, []string{t1.ID},
+	)
+	require.NoError(t, err)
+
+	_, err = db.UpdateTask(
+		ctx, t1.ID, "Second", nil,
+		model.TaskStatusTodo, model.TaskPriorityLow, nil,
+		nil, p.ID, nil, nil, nil, nil,
+	)
+	require.EqualError(t, err, "non-unique title")
+
+	_, err = db.UpdateTask(
+		ctx, t1.ID, "First", nil,
+		model.TaskStatusTodo, model.TaskPriorityLow, nil,
+		nil, p.ID, nil, nil, []string{t1.ID}, nil,
+	)
+	require.EqualError(t, err, "task references itself as blocker")
+
+	blocking, err := db.GetBlockingTasks(ctx, t1.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{t2.ID}, taskIDs(blocking))
+
+	related, err := db.GetRelatedTasks(ctx, t1.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{t2.ID}, taskIDs(related))
+
+	members, err := db.GetProjectMembers(ctx, p.ID)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+
+	order := model.TasksOrderPriority
+	tasks, err := db.GetTasks(ctx, nil, &order, false, dataprovider.Page{})
+	require.NoError(t, err)
+	require.Equal(t, []string{t2.ID, t1.ID}, connectionIDs(tasks))
+
+	tasks, err = db.GetTasks(ctx, &model.TasksFilters{
+		Reporters: []string{bob.ID},
+		Status:    []model.TaskStatus{model.TaskStatusTodo},
+	}, nil, true, dataprovider.Page{})
+	require.NoError(t, err)
+	require.Equal(t, []string{t1.ID}, connectionIDs(tasks))
+
+	limit := 1
+	tasks, err = db.GetTasks(
+		ctx, nil, nil, true, dataprovider.Page{First: &limit},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{t1.ID}, connectionIDs(tasks))
+	require.Equal(t, 2, tasks.TotalCount)
+	require.True(t, tasks.PageInfo.HasNextPage)
+}
+
+func connectionIDs(c *model.TaskConnection) []string {
+	ids := make([]string, len(c.Edges))
+	for i, e := range c.Edges {
+		ids[i] = e.Node.ID
+	}
+	return ids
+}
+
+func taskIDs(tasks []*model.Task) []string {
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	return ids
+}
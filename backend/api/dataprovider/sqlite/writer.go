@@ -0,0 +1,1704 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/permission"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/slices"
+)
+
+func (p *SQLite) CreateUser(
+	ctx context.Context,
+	email string,
+	passwordHash string,
+	displayName string,
+	role string,
+	location string,
+	manager *string,
+	subordinates []string,
+) (newUser *model.User, err error) {
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		if err := checkUserUnique(ctx, tx, "", email, displayName); err != nil {
+			return err
+		}
+		if manager != nil {
+			ok, err := exists(ctx, tx, `SELECT 1 FROM users WHERE id = ?`, *manager)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("manager user %q not found", *manager)
+			}
+		}
+		subordinates, err := checkRefs(ctx, tx,
+			"users", "subordinate user", subordinates, "",
+			"user references itself as subordinate",
+		)
+		if err != nil {
+			return err
+		}
+
+		// The first user is granted the admin role.
+		hasUsers, err := exists(ctx, tx, `SELECT 1 FROM users`)
+		if err != nil {
+			return err
+		}
+
+		id := makeID("user")
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO users (
+				id, email, display_name, role, location,
+				personal_status, manager_id, admin, password_hash
+			) VALUES (?, ?, ?, ?, ?, '', ?, ?, ?)`,
+			id, email, displayName, role, location, manager,
+			!hasUsers, passwordHash,
+		); err != nil {
+			return fmt.Errorf("inserting user: %w", err)
+		}
+		err = insertRefs(ctx, tx,
+			"user_subordinates", "user_id", "subordinate_id", id, subordinates,
+		)
+		if err != nil {
+			return err
+		}
+
+		newUser, err = userByID(ctx, tx, id)
+		return err
+	})
+	return newUser, err
+}
+
+func (p *SQLite) UpdateUser(
+	ctx context.Context,
+	id string,
+	email string,
+	displayName string,
+	role string,
+	location string,
+	personalStatus *string,
+	manager *string,
+	subordinates []string,
+) (updated *model.User, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		if err := requireExists(ctx, tx, "users", "user", id); err != nil {
+			return err
+		}
+		admin, err := isAdmin(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			if err := auth.RequireOwner(ctx, id); err != nil {
+				return err
+			}
+		}
+		if err := checkUserUnique(ctx, tx, id, email, displayName); err != nil {
+			return err
+		}
+
+		var personalStatusText string
+		if personalStatus != nil {
+			personalStatusText = *personalStatus
+		}
+
+		if manager != nil {
+			ok, err := exists(ctx, tx, `SELECT 1 FROM users WHERE id = ?`, *manager)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("manager user %q not found", *manager)
+			}
+			if *manager == id {
+				return errors.New("user references itself as manager")
+			}
+		}
+		subordinates, err := checkRefs(ctx, tx,
+			"users", "subordinate user", subordinates, id,
+			"user references itself as subordinate",
+		)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET
+				email = ?, display_name = ?, role = ?, location = ?,
+				personal_status = ?, manager_id = ?
+			WHERE id = ?`,
+			email, displayName, role, location,
+			personalStatusText, manager, id,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+		err = replaceRefs(ctx, tx,
+			"user_subordinates", "user_id", "subordinate_id", id, subordinates,
+		)
+		if err != nil {
+			return err
+		}
+
+		updated, err = userByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) CreateProject(
+	ctx context.Context,
+	creation time.Time,
+	name string,
+	description string,
+	slug string,
+	owners []string,
+) (newProject *model.Project, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		if err := checkProjectUnique(ctx, tx, "", name, slug); err != nil {
+			return err
+		}
+		if len(owners) == 0 {
+			owners = []string{reqctx.GetRequestContext(ctx).UserID}
+		}
+		owners, err := checkRefs(ctx, tx, "users", "owner user", owners, "", "")
+		if err != nil {
+			return err
+		}
+		if err := requireActiveUsers(ctx, tx, "owner user", owners); err != nil {
+			return err
+		}
+
+		id := makeID("project")
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO projects (id, name, description, slug, creation)
+			VALUES (?, ?, ?, ?, ?)`,
+			id, name, description, slug, timeToInt(creation),
+		); err != nil {
+			return fmt.Errorf("inserting project: %w", err)
+		}
+		if err := replaceRoles(ctx, tx, id, withOwners(nil, owners)); err != nil {
+			return err
+		}
+
+		newProject, err = projectByID(ctx, tx, id)
+		return err
+	})
+	return newProject, err
+}
+
+func (p *SQLite) UpdateProject(
+	ctx context.Context,
+	id string,
+	name string,
+	description string,
+	slug string,
+	owners []string,
+) (updated *model.Project, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		current, err := projectByID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, id, permission.UpdateProject)
+		if err != nil {
+			return err
+		}
+		if err := requireProjectActive(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := checkProjectUnique(ctx, tx, id, name, slug); err != nil {
+			return err
+		}
+		owners, err := checkRefs(ctx, tx, "users", "owner user", owners, "", "")
+		if err != nil {
+			return err
+		}
+		if err := requireActiveUsers(ctx, tx, "owner user", owners); err != nil {
+			return err
+		}
+		currentOwners := ownerIDs(current.Roles)
+		if !slices.IsSubset(owners, currentOwners) ||
+			!slices.IsSubset(currentOwners, owners) {
+			err := requirePermission(ctx, tx, id, permission.ManageRoles)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE projects SET name = ?, description = ?, slug = ?
+			WHERE id = ?`,
+			name, description, slug, id,
+		); err != nil {
+			return fmt.Errorf("updating project: %w", err)
+		}
+		err = replaceRoles(ctx, tx, id, withOwners(current.Roles, owners))
+		if err != nil {
+			return err
+		}
+
+		updated, err = projectByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) CreateTask(
+	ctx context.Context,
+	creation time.Time,
+	title string,
+	project string,
+	status model.TaskStatus,
+	priority model.TaskPriority,
+	description *string,
+	due *time.Time,
+	tags []string,
+	assignees []string,
+	reporters []string,
+	blocks []string,
+	relatesTo []string,
+) (newTask *model.Task, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		err := requireExists(ctx, tx, "projects", "project", project)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, project, permission.CreateTask)
+		if err != nil {
+			return err
+		}
+
+		id := makeID("task")
+		r, err := checkTask(
+			ctx, tx, id, title, project,
+			assignees, reporters, blocks, relatesTo,
+		)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO tasks (
+				id, title, description, priority, status,
+				creation, due, project_id
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, title, description, priority, status,
+			timeToInt(creation), dueToInt(due), project,
+		); err != nil {
+			return fmt.Errorf("inserting task: %w", err)
+		}
+		if err := insertTaskRefs(ctx, tx, id, tags, r); err != nil {
+			return err
+		}
+
+		newTask, err = taskByID(ctx, tx, id)
+		return err
+	})
+	return newTask, err
+}
+
+func (p *SQLite) UpdateTask(
+	ctx context.Context,
+	id string,
+	title string,
+	description *string,
+	status model.TaskStatus,
+	priority model.TaskPriority,
+	due *time.Time,
+	tags []string,
+	project string,
+	assignees []string,
+	reporters []string,
+	blocks []string,
+	relatesTo []string,
+) (updated *model.Task, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		current, err := taskProjectID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, current, permission.UpdateTask)
+		if err != nil {
+			return err
+		}
+		if err := requireProjectActive(ctx, tx, current); err != nil {
+			return err
+		}
+		if project != current {
+			err := requireExists(ctx, tx, "projects", "project", project)
+			if err != nil {
+				return err
+			}
+			err = requirePermission(ctx, tx, project, permission.CreateTask)
+			if err != nil {
+				return err
+			}
+		}
+		r, err := checkTask(
+			ctx, tx, id, title, project,
+			assignees, reporters, blocks, relatesTo,
+		)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE tasks SET
+				title = ?, description = ?, priority = ?, status = ?,
+				due = ?, project_id = ?
+			WHERE id = ?`,
+			title, description, priority, status,
+			dueToInt(due), project, id,
+		); err != nil {
+			return fmt.Errorf("updating task: %w", err)
+		}
+		for _, table := range []string{
+			"task_tags", "task_assignees", "task_reporters",
+			"task_blocks", "task_relations",
+		} {
+			if _, err := tx.ExecContext(ctx,
+				`DELETE FROM `+table+` WHERE task_id = ?`, id,
+			); err != nil {
+				return fmt.Errorf("deleting %s: %w", table, err)
+			}
+		}
+		if err := insertTaskRefs(ctx, tx, id, tags, r); err != nil {
+			return err
+		}
+
+		updated, err = taskByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) DeleteTask(ctx context.Context, id string) error {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	return transaction(ctx, p.db, func(tx *sql.Tx) error {
+		project, err := taskProjectID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, project, permission.DeleteTask)
+		if err != nil {
+			return err
+		}
+		if err := requireProjectActive(ctx, tx, project); err != nil {
+			return err
+		}
+
+		// Remove the task from the blocks and relatesTo lists
+		// of other tasks along with all of its own references.
+		for _, x := range []struct{ table, ref string }{
+			{"comments", ""},
+			{"task_tags", ""},
+			{"task_assignees", ""},
+			{"task_reporters", ""},
+			{"task_blocks", "blocked_task_id"},
+			{"task_relations", "related_task_id"},
+		} {
+			query := `DELETE FROM ` + x.table + ` WHERE task_id = ?1`
+			if x.ref != "" {
+				query += ` OR ` + x.ref + ` = ?1`
+			}
+			if _, err := tx.ExecContext(ctx, query, id); err != nil {
+				return fmt.Errorf("deleting %s: %w", x.table, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM tasks WHERE id = ?`, id,
+		); err != nil {
+			return fmt.Errorf("deleting task: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *SQLite) ArchiveProject(
+	ctx context.Context, id string, archived time.Time,
+) (updated *model.Project, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		if err := requireExists(ctx, tx, "projects", "project", id); err != nil {
+			return err
+		}
+		err := requirePermission(ctx, tx, id, permission.ArchiveProject)
+		if err != nil {
+			return err
+		}
+		if err := requireProjectActive(ctx, tx, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE projects SET archived = ? WHERE id = ?`,
+			timeToInt(archived), id,
+		); err != nil {
+			return fmt.Errorf("archiving project: %w", err)
+		}
+
+		updated, err = projectByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) UnarchiveProject(
+	ctx context.Context, id string,
+) (updated *model.Project, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		project, err := projectByID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, id, permission.ArchiveProject)
+		if err != nil {
+			return err
+		}
+		if project.Archived == nil {
+			return fmt.Errorf("project %q is not archived", id)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE projects SET archived = NULL WHERE id = ?`, id,
+		); err != nil {
+			return fmt.Errorf("unarchiving project: %w", err)
+		}
+
+		updated, err = projectByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) DeactivateUser(
+	ctx context.Context, id string, deactivated time.Time,
+) (updated *model.User, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		user, err := userByID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		owners := []string{id}
+		if user.Manager != nil {
+			owners = append(owners, user.Manager.ID)
+		}
+		admin, err := isAdmin(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			if err := auth.RequireAnyOwner(ctx, owners...); err != nil {
+				return err
+			}
+		}
+		if user.Deactivated != nil {
+			return fmt.Errorf("user %q is deactivated", id)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET deactivated = ? WHERE id = ?`,
+			timeToInt(deactivated), id,
+		); err != nil {
+			return fmt.Errorf("deactivating user: %w", err)
+		}
+		for _, table := range []string{"task_assignees", "project_roles"} {
+			if _, err := tx.ExecContext(ctx,
+				`DELETE FROM `+table+` WHERE user_id = ?`, id,
+			); err != nil {
+				return fmt.Errorf("deleting %s: %w", table, err)
+			}
+		}
+
+		updated, err = userByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) AddComment(
+	ctx context.Context,
+	creation time.Time,
+	task string,
+	body string,
+	parent *string,
+) (newComment *model.Comment, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	authorID := reqctx.GetRequestContext(ctx).UserID
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		err := requireExists(ctx, tx, "users", "author user", authorID)
+		if err != nil {
+			return err
+		}
+		project, err := taskProjectID(ctx, tx, task)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, project, permission.Comment)
+		if err != nil {
+			return err
+		}
+		if err := requireProjectActive(ctx, tx, project); err != nil {
+			return err
+		}
+		if parent != nil {
+			c, err := commentByID(ctx, tx, *parent)
+			if err != nil {
+				return fmt.Errorf("parent comment %q not found", *parent)
+			}
+			if c.Task.ID != task {
+				return errors.New("parent comment belongs to another task")
+			}
+		}
+
+		id := makeID("comment")
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO comments (
+				id, task_id, author_id, parent_id, body, creation
+			) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, task, authorID, parent, body, timeToInt(creation),
+		); err != nil {
+			return fmt.Errorf("inserting comment: %w", err)
+		}
+
+		newComment, err = commentByID(ctx, tx, id)
+		return err
+	})
+	return newComment, err
+}
+
+func (p *SQLite) EditComment(
+	ctx context.Context, id string, body string, edited time.Time,
+) (updated *model.Comment, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		if err := requireCommentEditable(ctx, tx, id, false); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE comments SET body = ?, edited = ? WHERE id = ?`,
+			body, timeToInt(edited), id,
+		); err != nil {
+			return fmt.Errorf("updating comment: %w", err)
+		}
+
+		updated, err = commentByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) DeleteComment(ctx context.Context, id string) error {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	return transaction(ctx, p.db, func(tx *sql.Tx) error {
+		if err := requireCommentEditable(ctx, tx, id, true); err != nil {
+			return err
+		}
+		// Foreign keys are checked at the end of the statement,
+		// which deletes the comment and all of its replies at once.
+		if _, err := tx.ExecContext(ctx,
+			`WITH RECURSIVE thread (id) AS (
+				SELECT ?
+				UNION ALL
+				SELECT c.id FROM comments c JOIN thread t ON c.parent_id = t.id
+			)
+			DELETE FROM comments WHERE id IN thread`,
+			id,
+		); err != nil {
+			return fmt.Errorf("deleting comment: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *SQLite) GrantProjectRole(
+	ctx context.Context,
+	projectID string,
+	userID string,
+	role model.ProjectRole,
+) (updated *model.Project, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if !role.IsValid() {
+		return nil, fmt.Errorf("invalid project role %q", role)
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		project, err := projectByID(ctx, tx, projectID)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, projectID, permission.ManageRoles)
+		if err != nil {
+			return err
+		}
+		if err := requireProjectActive(ctx, tx, projectID); err != nil {
+			return err
+		}
+		if err := requireExists(ctx, tx, "users", "user", userID); err != nil {
+			return err
+		}
+		if err := requireActiveUsers(
+			ctx, tx, "user", []string{userID},
+		); err != nil {
+			return err
+		}
+
+		grants := make([]*model.ProjectRoleGrant, 0, len(project.Roles)+1)
+		granted := false
+		for _, g := range project.Roles {
+			if g.User.ID == userID {
+				g = &model.ProjectRoleGrant{User: g.User, Role: role}
+				granted = true
+			}
+			grants = append(grants, g)
+		}
+		if !granted {
+			grants = append(grants, &model.ProjectRoleGrant{
+				User: &model.User{ID: userID}, Role: role,
+			})
+		}
+		if err := replaceRoles(ctx, tx, projectID, grants); err != nil {
+			return err
+		}
+
+		updated, err = projectByID(ctx, tx, projectID)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) RevokeProjectRole(
+	ctx context.Context, projectID string, userID string,
+) (updated *model.Project, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		err := requireExists(ctx, tx, "projects", "project", projectID)
+		if err != nil {
+			return err
+		}
+		err = requirePermission(ctx, tx, projectID, permission.ManageRoles)
+		if err != nil {
+			return err
+		}
+		if err := requireProjectActive(ctx, tx, projectID); err != nil {
+			return err
+		}
+		r, err := tx.ExecContext(ctx,
+			`DELETE FROM project_roles WHERE project_id = ? AND user_id = ?`,
+			projectID, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("deleting project_roles: %w", err)
+		}
+		if n, err := r.RowsAffected(); err != nil {
+			return fmt.Errorf("deleting project_roles: %w", err)
+		} else if n < 1 {
+			return fmt.Errorf(
+				"user %q has no role in project %q", userID, projectID,
+			)
+		}
+
+		updated, err = projectByID(ctx, tx, projectID)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) SetUserAdmin(
+	ctx context.Context, id string, admin bool,
+) (updated *model.User, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		clientAdmin, err := isAdmin(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if !clientAdmin {
+			return auth.ErrUnauthorized
+		}
+		if err := requireExists(ctx, tx, "users", "user", id); err != nil {
+			return err
+		}
+		if !admin && id == reqctx.GetRequestContext(ctx).UserID {
+			return errors.New("admins can't revoke their own admin role")
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET admin = ? WHERE id = ?`, admin, id,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+
+		updated, err = userByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) CreateSession(
+	ctx context.Context,
+	creation time.Time,
+	userID string,
+	userAgent string,
+	refreshTokenHash string,
+	expires time.Time,
+) (newSession *model.Session, err error) {
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		err := requireExists(ctx, tx, "users", "user", userID)
+		if err != nil {
+			return err
+		}
+		if err := requireActiveUsers(ctx, tx, "user", []string{userID}); err != nil {
+			return err
+		}
+
+		id := makeID("session")
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO sessions (
+				id, user_id, user_agent, refresh_token_hash,
+				creation, refreshed, expires
+			) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, userID, userAgent, refreshTokenHash,
+			timeToInt(creation), timeToInt(creation), timeToInt(expires),
+		); err != nil {
+			return fmt.Errorf("inserting session: %w", err)
+		}
+
+		newSession, err = sessionByID(ctx, tx, id)
+		return err
+	})
+	return newSession, err
+}
+
+func (p *SQLite) RotateRefreshToken(
+	ctx context.Context,
+	id string,
+	refreshTokenHash string,
+	newRefreshTokenHash string,
+	refreshed time.Time,
+	expires time.Time,
+) (updated *model.Session, err error) {
+	var reused bool
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		s, err := sessionByID(ctx, tx, id)
+		if errors.Is(err, dataprovider.ErrNotFound) {
+			return dataprovider.ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		if s.Revoked != nil || !refreshed.Before(s.Expires) {
+			return dataprovider.ErrRefreshTokenInvalid
+		}
+		if err := requireActiveUsers(
+			ctx, tx, "user", []string{s.User.ID},
+		); err != nil {
+			return dataprovider.ErrRefreshTokenInvalid
+		}
+
+		switch refreshTokenHash {
+		case s.RefreshTokenHash:
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE sessions SET
+					refresh_token_hash = ?, previous_refresh_token_hash = ?,
+					refreshed = ?, expires = ?
+				WHERE id = ?`,
+				newRefreshTokenHash, s.RefreshTokenHash,
+				timeToInt(refreshed), timeToInt(expires), id,
+			); err != nil {
+				return fmt.Errorf("updating session: %w", err)
+			}
+		case s.PreviousRefreshTokenHash:
+			// Revoke the session, but commit the transaction
+			// before returning ErrRefreshTokenReused.
+			reused = true
+			if _, err := tx.ExecContext(ctx,
+				`UPDATE sessions SET revoked = ? WHERE id = ?`,
+				timeToInt(refreshed), id,
+			); err != nil {
+				return fmt.Errorf("revoking session: %w", err)
+			}
+			return nil
+		default:
+			return dataprovider.ErrRefreshTokenInvalid
+		}
+
+		updated, err = sessionByID(ctx, tx, id)
+		return err
+	})
+	if err == nil && reused {
+		return nil, dataprovider.ErrRefreshTokenReused
+	}
+	return updated, err
+}
+
+func (p *SQLite) RevokeSession(
+	ctx context.Context, id string, revoked time.Time,
+) (updated *model.Session, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		s, err := sessionByID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if err := auth.RequireOwner(ctx, s.User.ID); err != nil {
+			return err
+		}
+		if s.Revoked != nil {
+			return fmt.Errorf("session %q is revoked", id)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE sessions SET revoked = ? WHERE id = ?`,
+			timeToInt(revoked), id,
+		); err != nil {
+			return fmt.Errorf("revoking session: %w", err)
+		}
+
+		updated, err = sessionByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) RevokeUserSessions(
+	ctx context.Context, userID string, revoked time.Time,
+) (n int, err error) {
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return 0, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		err := requireExists(ctx, tx, "users", "user", userID)
+		if err != nil {
+			return err
+		}
+		r, err := tx.ExecContext(ctx,
+			`UPDATE sessions SET revoked = ?
+			WHERE user_id = ? AND revoked IS NULL`,
+			timeToInt(revoked), userID,
+		)
+		if err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+		affected, err := r.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("revoking sessions: %w", err)
+		}
+		n = int(affected)
+		return nil
+	})
+	return n, err
+}
+
+func (p *SQLite) CreateAPIKey(
+	ctx context.Context,
+	creation time.Time,
+	userID string,
+	name string,
+	scopes []model.APIKeyScope,
+	secretHash string,
+) (newKey *model.APIKey, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		user, err := userByID(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		kind := model.APIKeyKindPersonal
+		if userID != reqctx.GetRequestContext(ctx).UserID {
+			admin, err := isAdmin(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if !admin {
+				return auth.ErrUnauthorized
+			}
+			kind = model.APIKeyKindService
+		}
+		if user.Deactivated != nil {
+			return fmt.Errorf("user %q is deactivated", userID)
+		}
+		scopes, err := apiKeyScopes(scopes)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(scopes, model.APIKeyScopeAdmin) && !user.Admin {
+			return fmt.Errorf(
+				"scope %s requires user %q to be admin",
+				model.APIKeyScopeAdmin, userID,
+			)
+		}
+
+		id := makeID("apikey")
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO api_keys (
+				id, user_id, name, kind, scopes, secret_hash, creation
+			) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, userID, name, kind, joinScopes(scopes), secretHash,
+			timeToInt(creation),
+		); err != nil {
+			return fmt.Errorf("inserting API key: %w", err)
+		}
+
+		newKey, err = apiKeyByID(ctx, tx, id)
+		return err
+	})
+	return newKey, err
+}
+
+func (p *SQLite) RevokeAPIKey(
+	ctx context.Context, id string, revoked time.Time,
+) (updated *model.APIKey, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		k, err := apiKeyByID(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		admin, err := isAdmin(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			if err := auth.RequireOwner(ctx, k.User.ID); err != nil {
+				return err
+			}
+		}
+		if k.Revoked != nil {
+			return fmt.Errorf("API key %q is revoked", id)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE api_keys SET revoked = ? WHERE id = ?`,
+			timeToInt(revoked), id,
+		); err != nil {
+			return fmt.Errorf("revoking API key: %w", err)
+		}
+
+		updated, err = apiKeyByID(ctx, tx, id)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) MarkAPIKeyUsed(
+	ctx context.Context, id string, used time.Time,
+) error {
+	r, err := p.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used = ? WHERE id = ?`,
+		timeToInt(used), id,
+	)
+	if err != nil {
+		return fmt.Errorf("updating API key: %w", err)
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("updating API key: %w", err)
+	}
+	if n < 1 {
+		return fmt.Errorf("API key %q %w", id, dataprovider.ErrNotFound)
+	}
+	return nil
+}
+
+func (p *SQLite) SetTOTPSecret(
+	ctx context.Context, userID string, secret string,
+) (updated *model.User, err error) {
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		user, err := userByID(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TwoFactorEnabled {
+			return errors.New("two-factor authentication already enabled")
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET totp_secret = ? WHERE id = ?`, secret, userID,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+
+		updated, err = userByID(ctx, tx, userID)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) EnableTwoFactor(
+	ctx context.Context,
+	userID string,
+	step int64,
+	recoveryCodeHashes []string,
+) (updated *model.User, err error) {
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		user, err := userByID(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.TwoFactorEnabled {
+			return errors.New("two-factor authentication already enabled")
+		}
+		if user.TOTPSecret == "" {
+			return errors.New("two-factor authentication not enrolled")
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET
+				two_factor_enabled = 1,
+				totp_last_step = ?,
+				recovery_code_hashes = ?
+			WHERE id = ?`,
+			step, strings.Join(recoveryCodeHashes, ","), userID,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+
+		updated, err = userByID(ctx, tx, userID)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) DisableTwoFactor(
+	ctx context.Context, userID string,
+) (updated *model.User, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		if _, err := userByID(ctx, tx, userID); err != nil {
+			return err
+		}
+		admin, err := isAdmin(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			if err := auth.RequireOwner(ctx, userID); err != nil {
+				return err
+			}
+			o, err := organization(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if o.TwoFactorRequired {
+				return errors.New(
+					"two-factor authentication is required by the organization",
+				)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET
+				two_factor_enabled = 0,
+				totp_secret = '',
+				totp_last_step = 0,
+				recovery_code_hashes = ''
+			WHERE id = ?`, userID,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+
+		updated, err = userByID(ctx, tx, userID)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) SetRecoveryCodes(
+	ctx context.Context, userID string, recoveryCodeHashes []string,
+) (updated *model.User, err error) {
+	if err := auth.RequireOwner(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		user, err := userByID(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled {
+			return errors.New("two-factor authentication not enabled")
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET recovery_code_hashes = ? WHERE id = ?`,
+			strings.Join(recoveryCodeHashes, ","), userID,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+
+		updated, err = userByID(ctx, tx, userID)
+		return err
+	})
+	return updated, err
+}
+
+func (p *SQLite) UseTOTPStep(
+	ctx context.Context, userID string, step int64,
+) error {
+	return transaction(ctx, p.db, func(tx *sql.Tx) error {
+		user, err := userByID(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.TwoFactorEnabled {
+			return errors.New("two-factor authentication not enabled")
+		}
+		if step <= user.TOTPLastStep {
+			return dataprovider.ErrTwoFactorCodeUsed
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET totp_last_step = ? WHERE id = ?`, step, userID,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *SQLite) UseRecoveryCode(
+	ctx context.Context, userID string, recoveryCodeHash string,
+) error {
+	return transaction(ctx, p.db, func(tx *sql.Tx) error {
+		user, err := userByID(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(user.RecoveryCodeHashes, recoveryCodeHash) {
+			return dataprovider.ErrTwoFactorCodeUsed
+		}
+
+		remaining := make([]string, 0, len(user.RecoveryCodeHashes)-1)
+		for _, h := range user.RecoveryCodeHashes {
+			if h != recoveryCodeHash {
+				remaining = append(remaining, h)
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET recovery_code_hashes = ? WHERE id = ?`,
+			strings.Join(remaining, ","), userID,
+		); err != nil {
+			return fmt.Errorf("updating user: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *SQLite) SetTwoFactorRequired(
+	ctx context.Context, required bool,
+) (updated *model.Organization, err error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	err = transaction(ctx, p.db, func(tx *sql.Tx) error {
+		admin, err := isAdmin(ctx, tx)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return auth.ErrUnauthorized
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE organization SET two_factor_required = ? WHERE id = 1`,
+			required,
+		); err != nil {
+			return fmt.Errorf("updating organization: %w", err)
+		}
+
+		updated, err = organization(ctx, tx)
+		return err
+	})
+	return updated, err
+}
+
+// requireCommentEditable returns an error if comment id doesn't exist,
+// wasn't authored by the client or belongs to a task of an archived project.
+// If moderate is true then comments of other authors are editable
+// with permission.ModerateComments.
+func requireCommentEditable(
+	ctx context.Context, tx *sql.Tx, id string, moderate bool,
+) error {
+	c, err := commentByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	project, err := taskProjectID(ctx, tx, c.Task.ID)
+	if err != nil {
+		return err
+	}
+	if err := auth.RequireOwner(ctx, c.Author.ID); err != nil {
+		if !moderate {
+			return err
+		}
+		err := requirePermission(ctx, tx, project, permission.ModerateComments)
+		if err != nil {
+			return err
+		}
+	}
+	return requireProjectActive(ctx, tx, project)
+}
+
+// isAdmin returns true if the client has the admin role and
+// isn't authenticated by an API key lacking the ADMIN scope.
+func isAdmin(ctx context.Context, q queryer) (bool, error) {
+	if !auth.HasScope(ctx, model.APIKeyScopeAdmin) {
+		return false, nil
+	}
+	return exists(ctx, q,
+		`SELECT 1 FROM users WHERE id = ? AND admin`,
+		reqctx.GetRequestContext(ctx).UserID,
+	)
+}
+
+// requirePermission returns nil if the client is allowed
+// to perform action a on project id.
+func requirePermission(
+	ctx context.Context, q queryer, id string, a permission.Action,
+) error {
+	var s permission.Subject
+	var err error
+	if s.Admin, err = isAdmin(ctx, q); err != nil {
+		return err
+	}
+	err = q.QueryRowContext(ctx,
+		`SELECT role FROM project_roles WHERE project_id = ? AND user_id = ?`,
+		id, reqctx.GetRequestContext(ctx).UserID,
+	).Scan(&s.Role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("querying project role: %w", err)
+	}
+	return permission.Require(ctx, s, a)
+}
+
+// replaceRoles replaces all role grants of project id with grants.
+func replaceRoles(
+	ctx context.Context,
+	tx *sql.Tx,
+	id string,
+	grants []*model.ProjectRoleGrant,
+) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM project_roles WHERE project_id = ?`, id,
+	); err != nil {
+		return fmt.Errorf("deleting project_roles: %w", err)
+	}
+	for i, g := range grants {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO project_roles (project_id, user_id, role, position)
+			VALUES (?, ?, ?, ?)`,
+			id, g.User.ID, g.Role, i,
+		); err != nil {
+			return fmt.Errorf("inserting project_roles: %w", err)
+		}
+	}
+	return nil
+}
+
+// withOwners returns a copy of grants with the OWNER role granted to owners
+// and revoked from all other owners.
+func withOwners(
+	grants []*model.ProjectRoleGrant, owners []string,
+) []*model.ProjectRoleGrant {
+	var r []*model.ProjectRoleGrant
+	for _, g := range grants {
+		switch {
+		case slices.Contains(owners, g.User.ID):
+			r = append(r, &model.ProjectRoleGrant{
+				User: g.User, Role: model.ProjectRoleOwner,
+			})
+		case g.Role != model.ProjectRoleOwner:
+			r = append(r, g)
+		}
+	}
+	granted := ownerIDs(r)
+	for _, id := range owners {
+		if !slices.Contains(granted, id) {
+			r = append(r, &model.ProjectRoleGrant{
+				User: &model.User{ID: id}, Role: model.ProjectRoleOwner,
+			})
+		}
+	}
+	return r
+}
+
+// ownerIDs returns the IDs of the users with the OWNER role in grants.
+func ownerIDs(grants []*model.ProjectRoleGrant) []string {
+	var ids []string
+	for _, g := range grants {
+		if g.Role == model.ProjectRoleOwner {
+			ids = append(ids, g.User.ID)
+		}
+	}
+	return ids
+}
+
+// taskProjectID returns the ID of the project of task id.
+func taskProjectID(ctx context.Context, q queryer, id string) (string, error) {
+	var project string
+	err := q.QueryRowContext(ctx,
+		`SELECT project_id FROM tasks WHERE id = ?`, id,
+	).Scan(&project)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("task %q not found", id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("querying task project: %w", err)
+	}
+	return project, nil
+}
+
+// requireProjectActive returns an error if project id is archived.
+func requireProjectActive(ctx context.Context, tx *sql.Tx, id string) error {
+	ok, err := exists(ctx, tx,
+		`SELECT 1 FROM projects WHERE id = ? AND archived IS NOT NULL`, id,
+	)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return fmt.Errorf("project %q is archived", id)
+	}
+	return nil
+}
+
+// requireActiveUsers returns an error if any of the users ids is deactivated.
+// name is the human-readable name of the reference used in the error message.
+func requireActiveUsers(
+	ctx context.Context, tx *sql.Tx, name string, ids []string,
+) error {
+	for _, id := range ids {
+		ok, err := exists(ctx, tx,
+			`SELECT 1 FROM users WHERE id = ? AND deactivated IS NOT NULL`, id,
+		)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return fmt.Errorf("%s %q is deactivated", name, id)
+		}
+	}
+	return nil
+}
+
+// taskRefs holds the deduplicated and verified references of a task.
+type taskRefs struct {
+	assignees []string
+	reporters []string
+	blocks    []string
+	relatesTo []string
+}
+
+// checkTask verifies the uniqueness of the title and the
+// existence of all referenced entities of task id.
+func checkTask(
+	ctx context.Context,
+	tx *sql.Tx,
+	id string,
+	title string,
+	project string,
+	assignees []string,
+	reporters []string,
+	blocks []string,
+	relatesTo []string,
+) (r taskRefs, err error) {
+	ok, err := exists(ctx, tx,
+		`SELECT 1 FROM tasks WHERE title = ? AND id != ?`, title, id,
+	)
+	if err != nil {
+		return r, err
+	}
+	if ok {
+		return r, errors.New("non-unique title")
+	}
+	if err := requireExists(ctx, tx, "projects", "project", project); err != nil {
+		return r, err
+	}
+	if err := requireProjectActive(ctx, tx, project); err != nil {
+		return r, err
+	}
+	if r.assignees, err = checkRefs(
+		ctx, tx, "users", "assignee user", assignees, "", "",
+	); err != nil {
+		return r, err
+	}
+	if err := requireActiveUsers(ctx, tx, "assignee user", r.assignees); err != nil {
+		return r, err
+	}
+	if r.reporters, err = checkRefs(
+		ctx, tx, "users", "reporter user", reporters, "", "",
+	); err != nil {
+		return r, err
+	}
+	if r.blocks, err = checkRefs(
+		ctx, tx, "tasks", "blocked task", blocks,
+		id, "task references itself as blocker",
+	); err != nil {
+		return r, err
+	}
+	if r.relatesTo, err = checkRefs(
+		ctx, tx, "tasks", "related task", relatesTo,
+		id, "task references itself as related",
+	); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func insertTaskRefs(
+	ctx context.Context, tx *sql.Tx, id string, tags []string, r taskRefs,
+) error {
+	tags = dedupe(tags)
+	for _, x := range []struct {
+		table, ref string
+		ids        []string
+	}{
+		{"task_tags", "tag", tags},
+		{"task_assignees", "user_id", r.assignees},
+		{"task_reporters", "user_id", r.reporters},
+		{"task_blocks", "blocked_task_id", r.blocks},
+		{"task_relations", "related_task_id", r.relatesTo},
+	} {
+		if err := insertRefs(ctx, tx, x.table, "task_id", x.ref, id, x.ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUserUnique returns an error if any user other than id
+// has the same email or display name.
+func checkUserUnique(
+	ctx context.Context, tx *sql.Tx, id, email, displayName string,
+) error {
+	ok, err := exists(ctx, tx,
+		`SELECT 1 FROM users WHERE display_name = ? AND id != ?`,
+		displayName, id,
+	)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return errors.New("non-unique displayName")
+	}
+	ok, err = exists(ctx, tx,
+		`SELECT 1 FROM users WHERE email = ? AND id != ?`, email, id,
+	)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return errors.New("non-unique email")
+	}
+	return nil
+}
+
+// checkProjectUnique returns an error if any project other than id
+// has the same name or slug.
+func checkProjectUnique(
+	ctx context.Context, tx *sql.Tx, id, name, slug string,
+) error {
+	ok, err := exists(ctx, tx,
+		`SELECT 1 FROM projects WHERE name = ? AND id != ?`, name, id,
+	)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return errors.New("non-unique project name")
+	}
+	ok, err = exists(ctx, tx,
+		`SELECT 1 FROM projects WHERE slug = ? AND id != ?`, slug, id,
+	)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return errors.New("non-unique project slug")
+	}
+	return nil
+}
+
+// checkRefs verifies that all ids exist in table and returns them deduplicated.
+// If self != "" then referencing self is rejected with errSelfRef.
+func checkRefs(
+	ctx context.Context,
+	tx *sql.Tx,
+	table string,
+	name string,
+	ids []string,
+	self string,
+	errSelfRef string,
+) ([]string, error) {
+	ids = dedupe(ids)
+	for _, id := range ids {
+		ok, err := exists(ctx, tx, `SELECT 1 FROM `+table+` WHERE id = ?`, id)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s %q not found", name, id)
+		}
+		if self != "" && id == self {
+			return nil, errors.New(errSelfRef)
+		}
+	}
+	return ids, nil
+}
+
+// insertRefs inserts a row into table for every ref
+// preserving the order of refs.
+func insertRefs(
+	ctx context.Context,
+	tx *sql.Tx,
+	table, key, ref string,
+	id string,
+	refs []string,
+) error {
+	for i, r := range refs {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO `+table+` (`+key+`, `+ref+`, position)
+			VALUES (?, ?, ?)`,
+			id, r, i,
+		); err != nil {
+			return fmt.Errorf("inserting %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// replaceRefs replaces all rows of id in table with refs.
+func replaceRefs(
+	ctx context.Context,
+	tx *sql.Tx,
+	table, key, ref string,
+	id string,
+	refs []string,
+) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM `+table+` WHERE `+key+` = ?`, id,
+	); err != nil {
+		return fmt.Errorf("deleting %s: %w", table, err)
+	}
+	return insertRefs(ctx, tx, table, key, ref, id, refs)
+}
+
+// dedupe returns s without duplicates preserving the order of first occurrence.
+func dedupe(s []string) (r []string) {
+	for _, x := range s {
+		r = slices.AppendUnique(r, x)
+	}
+	return r
+}
+
+func dueToInt(due *time.Time) *int64 {
+	if due == nil {
+		return nil
+	}
+	n := timeToInt(*due)
+	return &n
+}
+
+// apiKeyScopes returns the unique scopes of s.
+// Returns an error if s is empty or contains invalid scopes.
+func apiKeyScopes(s []model.APIKeyScope) ([]model.APIKeyScope, error) {
+	if len(s) < 1 {
+		return nil, errors.New("API key requires at least one scope")
+	}
+	var r []model.APIKeyScope
+	for _, x := range s {
+		if !x.IsValid() {
+			return nil, fmt.Errorf("invalid API key scope %q", x)
+		}
+		r = slices.AppendUnique(r, x)
+	}
+	return r, nil
+}
+
+// joinScopes returns s as a comma-separated list.
+func joinScopes(s []model.APIKeyScope) string {
+	b := make([]string, len(s))
+	for i, x := range s {
+		b[i] = string(x)
+	}
+	return strings.Join(b, ",")
+}
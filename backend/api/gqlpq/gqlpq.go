@@ -4,11 +4,16 @@ package gqlpq
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -27,11 +32,72 @@ type PersistedQueries struct {
 	schema *ast.Schema
 
 	list atomic.Value
-	// list map[string]string
+	// list *list
+
+	status atomic.Value
+	// status Status
+}
+
+type list struct {
+	queries map[string]*Query
+
+	// hashes maps the lowercase hex encoded
+	// SHA-256 hashes of the queries to keys.
+	hashes map[string]string
+}
+
+// Query is a persisted query.
+type Query struct {
+	// Key is the name of the query file without the extension.
+	Key string
+
+	// Encoded is the query encoded as JSON string.
+	Encoded string
+
+	// Hash is the lowercase hex encoded SHA-256 hash of the query.
+	Hash string
+
+	// Operation is the type of the operation.
+	Operation ast.Operation
+
+	// Document is the query validated against the schema.
+	Document *ast.QueryDocument
+
+	Metadata Metadata
+}
+
+// Hash returns the lowercase hex encoded SHA-256 hash of query as used by
+// the Automatic Persisted Queries protocol (extensions.persistedQuery.sha256Hash).
+func Hash(query string) string {
+	h := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(h[:])
 }
 
 // New reads the schema and creates a new GraphQL persisted queries list instance.
 func New(schemaDirPath string) (*PersistedQueries, error) {
+	schema, err := LoadSchema(schemaDirPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithSchema(schema), nil
+}
+
+// NewWithSchema creates a new GraphQL persisted queries list instance
+// validating queries against schema.
+func NewWithSchema(schema *ast.Schema) *PersistedQueries {
+	l := &PersistedQueries{schema: schema}
+	l.list.Store(&list{
+		queries: map[string]*Query{},
+		hashes:  map[string]string{},
+	})
+	return l
+}
+
+// Schema returns the schema queries are validated against.
+func (l *PersistedQueries) Schema() *ast.Schema { return l.schema }
+
+// LoadSchema reads and parses all schema files in schemaDirPath.
+func LoadSchema(schemaDirPath string) (*ast.Schema, error) {
 	dir, err := os.ReadDir(schemaDirPath)
 	if err != nil {
 		return nil, fmt.Errorf("reading schema directory path: %w", err)
@@ -60,104 +126,319 @@ func New(schemaDirPath string) (*PersistedQueries, error) {
 	if err != nil {
 		return nil, fmt.Errorf("loading schema: %w", err)
 	}
-	var list atomic.Value
-	list.Store(map[string]string{})
-	return &PersistedQueries{
-		schema: schema,
-		list:   list,
-	}, nil
+	return schema, nil
 }
 
 // GetQuery returns the query by key, or "" if no query is found.
 // GetQuery is safe for concurrent use.
 func (l *PersistedQueries) GetQuery(key string) string {
-	m := l.list.Load().(map[string]string)
-	return m[key]
+	if q := l.Get(key); q != nil {
+		return q.Encoded
+	}
+	return ""
+}
+
+// Get returns the query by key, or nil if no query is found.
+// Get is safe for concurrent use.
+func (l *PersistedQueries) Get(key string) *Query {
+	m := l.list.Load().(*list)
+	return m.queries[key]
+}
+
+// GetByHash returns the query by the hex encoded SHA-256 hash
+// of the query, or nil if no query is found.
+// GetByHash is safe for concurrent use.
+func (l *PersistedQueries) GetByHash(sha256Hash string) *Query {
+	m := l.list.Load().(*list)
+	return m.queries[m.hashes[strings.ToLower(sha256Hash)]]
 }
 
 // Len returns the length of the list.
 // Len is safe for concurrent use.
 func (l *PersistedQueries) Len() int {
-	m := l.list.Load().(map[string]string)
-	return len(m)
+	m := l.list.Load().(*list)
+	return len(m.queries)
 }
 
 // ForEach calls fn for every key-query pair stored.
 // ForEach is safe for concurrent use.
 func (l *PersistedQueries) ForEach(fn func(key, query string)) {
-	m := l.list.Load().(map[string]string)
-	for k, v := range m {
-		fn(k, v)
+	m := l.list.Load().(*list)
+	for k, v := range m.queries {
+		fn(k, v.Encoded)
+	}
+}
+
+// Queries returns all queries sorted by key.
+// Queries is safe for concurrent use.
+func (l *PersistedQueries) Queries() []*Query {
+	m := l.list.Load().(*list)
+	s := make([]*Query, 0, len(m.queries))
+	for _, q := range m.queries {
+		s = append(s, q)
 	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Key < s[j].Key })
+	return s
 }
 
-// Load loads the persisted queries from dirPath.
+// FileError is a problem with a single persisted query file.
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("query file %q: %v", e.File, e.Err)
+}
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// LoadError is returned by Load when any of the query files is invalid.
+type LoadError struct {
+	Files []*FileError
+}
+
+func (e *LoadError) Error() string {
+	if len(e.Files) == 1 {
+		return e.Files[0].Error()
+	}
+	return fmt.Sprintf(
+		"%d invalid query files, first: %v", len(e.Files), e.Files[0],
+	)
+}
+
+// Status is the state of the last reload.
+type Status struct {
+	// Queries is the number of queries currently served.
+	Queries int `json:"queries"`
+
+	// LastAttempt is the time of the last call to Load.
+	LastAttempt time.Time `json:"lastAttempt"`
+
+	// LastSuccess is the time the served queries were last loaded.
+	LastSuccess time.Time `json:"lastSuccess"`
+
+	// LastError is the error of the last call to Load or ""
+	// if it succeeded.
+	LastError string `json:"lastError,omitempty"`
+
+	// FailingFiles maps the invalid query files
+	// to their errors as of the last call to Load.
+	FailingFiles map[string]string `json:"failingFiles,omitempty"`
+}
+
+// Status returns the status of the last reload.
+// Status is safe for concurrent use.
+func (l *PersistedQueries) Status() Status {
+	s, _ := l.status.Load().(Status)
+	s.Queries = l.Len()
+	return s
+}
+
+// Load loads the persisted queries from dirPath and its subdirectories.
+// The key of a query is its path relative to dirPath without
+// the file extension, such as mobile/v2/qry_task.
 // Load swaps the list atomically and is therefore safe for concurrent use.
+// If any of the query files is invalid, the currently served queries
+// are kept and a *LoadError listing all invalid files is returned.
 func (l *PersistedQueries) Load(dirPath string) error {
-	dir, err := os.ReadDir(dirPath)
+	status, _ := l.status.Load().(Status)
+	status.LastAttempt = time.Now()
+	status.LastError, status.FailingFiles = "", nil
+
+	err := l.load(dirPath)
 	if err != nil {
-		return fmt.Errorf("reading query directory path: %w", err)
+		status.LastError = err.Error()
+		var errLoad *LoadError
+		if errors.As(err, &errLoad) {
+			status.FailingFiles = make(map[string]string, len(errLoad.Files))
+			for _, f := range errLoad.Files {
+				status.FailingFiles[f.File] = f.Err.Error()
+			}
+		}
+	} else {
+		status.LastSuccess = status.LastAttempt
 	}
-	newMap := make(map[string]string)
-	for _, o := range dir {
-		if o.IsDir() {
+	l.status.Store(status)
+	return err
+}
+
+func (l *PersistedQueries) load(dirPath string) error {
+	files, err := findQueryFiles(dirPath)
+	if err != nil {
+		return err
+	}
+	newList := &list{
+		queries: make(map[string]*Query),
+		hashes:  make(map[string]string),
+	}
+	var errLoad LoadError
+	for _, f := range files {
+		q, err := l.loadFile(f.Path, f.Key)
+		if err != nil {
+			errLoad.Files = append(errLoad.Files, &FileError{File: f.Path, Err: err})
 			continue
 		}
-		n := o.Name()
-		if !strings.HasSuffix(n, gqlFileExtension) {
+		if k, ok := newList.hashes[q.Hash]; ok {
+			errLoad.Files = append(errLoad.Files, &FileError{
+				File: f.Path,
+				Err:  fmt.Errorf("identical to query %q", k),
+			})
 			continue
 		}
-		if n != url.PathEscape(n) {
-			return fmt.Errorf("invalid file name (not URL safe): %q", n)
+		newList.queries[q.Key] = q
+		newList.hashes[q.Hash] = q.Key
+	}
+	if errLoad.Files != nil {
+		return &errLoad
+	}
+
+	l.list.Swap(newList)
+	return nil
+}
+
+// loadFile reads and validates the query file at path p with the given key.
+func (l *PersistedQueries) loadFile(p, key string) (*Query, error) {
+	if !isValidKey(key) {
+		return nil, errors.New("invalid path (not URL safe)")
+	}
+	query, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("reading: %w", err)
+	}
+
+	queryStr := string(query)
+	doc, errs := gqlparse.LoadQuery(l.schema, queryStr)
+	if errs != nil {
+		return nil, errors.New(strings.TrimSpace(errs.Error()))
+	}
+	if len(doc.Operations) != 1 {
+		return nil, fmt.Errorf(
+			"must contain exactly one operation, found %d", len(doc.Operations),
+		)
+	}
+
+	metadata, err := parseMetadata(queryStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := metadata.checkOperation(doc.Operations[0].Operation); err != nil {
+		return nil, err
+	}
+
+	encodedQuery, err := json.Marshal(queryStr)
+	if err != nil {
+		return nil, fmt.Errorf("encoding query to JSON string: %w", err)
+	}
+	return &Query{
+		Key:       key,
+		Encoded:   string(encodedQuery),
+		Hash:      Hash(queryStr),
+		Operation: doc.Operations[0].Operation,
+		Document:  doc,
+		Metadata:  metadata,
+	}, nil
+}
+
+// queryFile is a persisted query file.
+type queryFile struct {
+	Path string
+
+	// Key is the path relative to the query directory without
+	// the file extension using / as separator, such as mobile/v2/qry_task.
+	Key string
+}
+
+// findQueryFiles returns all query files in dirPath and its
+// subdirectories in lexical order. Hidden directories are skipped.
+func findQueryFiles(dirPath string) ([]queryFile, error) {
+	var files []queryFile
+	err := filepath.WalkDir(dirPath, func(
+		p string, d fs.DirEntry, err error,
+	) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir():
+			if p != dirPath && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		case !strings.HasSuffix(d.Name(), gqlFileExtension):
+			return nil
 		}
-		p := filepath.Join(dirPath, n)
-		query, err := os.ReadFile(p)
+		rel, err := filepath.Rel(dirPath, p)
 		if err != nil {
-			return fmt.Errorf("reading query file %q: %w", p, err)
-		}
-		n, _ = strings.CutSuffix(n, gqlFileExtension)
-
-		queryStr := string(query)
-		_, errs := gqlparse.LoadQuery(l.schema, queryStr)
-		if errs != nil {
-			return fmt.Errorf("reading query file %q: %v", p, errs)
+			return err
 		}
+		files = append(files, queryFile{
+			Path: p,
+			Key:  strings.TrimSuffix(filepath.ToSlash(rel), gqlFileExtension),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading query directory path: %w", err)
+	}
+	return files, nil
+}
 
-		encodedQuery, err := json.Marshal(queryStr)
-		if err != nil {
-			return fmt.Errorf("encoding query to JSON string: %w", err)
+// isValidKey returns true if all path segments of key are URL safe.
+func isValidKey(key string) bool {
+	for _, s := range strings.Split(key, "/") {
+		if s == "" || s != url.PathEscape(s) {
+			return false
 		}
-		newMap[n] = string(encodedQuery)
 	}
+	return true
+}
 
-	l.list.Swap(newMap)
-	return nil
+// watchDirs adds dirPath and all of its subdirectories except
+// hidden ones to w.
+func watchDirs(w *fsnotify.Watcher, dirPath string) error {
+	return filepath.WalkDir(dirPath, func(
+		p string, d fs.DirEntry, err error,
+	) error {
+		switch {
+		case err != nil:
+			return err
+		case !d.IsDir():
+			return nil
+		case p != dirPath && strings.HasPrefix(d.Name(), "."):
+			return filepath.SkipDir
+		}
+		if err := w.Add(p); err != nil {
+			return fmt.Errorf("adding watcher dir path %q: %w", p, err)
+		}
+		return nil
+	})
 }
 
-// Watch starts listening to changes on dirPath
+// Watch starts listening to changes on dirPath and its subdirectories
 // and automatically reloads the persisted queries.
 // Watch is safe for concurrent use.
-// onReloaded is invoked after a reload.
+// onReload is invoked after every reload attempt with the error
+// returned by Load. A failed reload keeps the previous queries
+// and is retried on the next change. Watch only returns when ctx
+// is canceled or the watcher fails.
 func (l *PersistedQueries) Watch(
 	ctx context.Context,
 	schemaDirPath string,
 	dirPath string,
 	debounce time.Duration,
-	onReloaded func(),
+	onReload func(error),
 ) (err error) {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
 		return fmt.Errorf("initializing watcher: %w", err)
 	}
 	defer func() {
-		err = w.Close()
-		if err != nil {
-			err = fmt.Errorf("closing watcher %w", err)
+		if errClose := w.Close(); errClose != nil && err == nil {
+			err = fmt.Errorf("closing watcher %w", errClose)
 		}
 	}()
-	err = w.Add(dirPath)
-	if err != nil {
-		return fmt.Errorf("adding watcher dir path %q: %w", dirPath, err)
+	if err = watchDirs(w, dirPath); err != nil {
+		return err
 	}
 	timer := time.NewTimer(0)
 	if !timer.Stop() {
@@ -169,12 +450,12 @@ func (l *PersistedQueries) Watch(
 		case <-ctx.Done(): // Context canceled
 			return ctx.Err()
 		case <-timer.C: // Debounce triggered
+			// Watch subdirectories created since the last reload,
+			// Load reports the directory if it became unreadable.
+			_ = watchDirs(w, dirPath)
 			err := l.Load(dirPath)
-			if err != nil {
-				return fmt.Errorf("parsing: %w", err)
-			}
-			if onReloaded != nil {
-				onReloaded()
+			if onReload != nil {
+				onReload(err)
 			}
 		case _, ok := <-w.Events: // Allowlist changed
 			if !ok {
@@ -0,0 +1,126 @@
+package gqlpq_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const testSchema = `type Query { foo: String! bar: Int! }`
+
+func newTestPersistedQueries(t *testing.T) (*gqlpq.PersistedQueries, string) {
+	t.Helper()
+	schemaDir, queryDir := t.TempDir(), t.TempDir()
+	writeFile(t, schemaDir, "schema.graphqls", testSchema)
+	pq, err := gqlpq.New(schemaDir)
+	require.NoError(t, err)
+	return pq, queryDir
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+	require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+}
+
+func TestLoad(t *testing.T) {
+	pq, dir := newTestPersistedQueries(t)
+	writeFile(t, dir, "foo.graphql", "query { foo }")
+	writeFile(t, dir, "bar.graphql", "# @auth\nquery { bar }")
+	writeFile(t, dir, "ignored.txt", "not a query")
+
+	require.NoError(t, pq.Load(dir))
+	require.Equal(t, 2, pq.Len())
+	require.Equal(t, `"query { foo }"`, pq.GetQuery("foo"))
+
+	bar := pq.Get("bar")
+	require.NotNil(t, bar)
+	require.True(t, bar.Metadata.AuthRequired)
+	require.Equal(t, ast.Query, bar.Operation)
+	require.Equal(t, gqlpq.Hash("# @auth\nquery { bar }"), bar.Hash)
+	require.Equal(t, bar, pq.GetByHash(bar.Hash))
+	require.Nil(t, pq.GetByHash(gqlpq.Hash("query { baz }")))
+
+	qs := pq.Queries()
+	require.Len(t, qs, 2)
+	require.Equal(t, bar, qs[0])
+	require.Equal(t, "foo", qs[1].Key)
+
+	s := pq.Status()
+	require.Equal(t, 2, s.Queries)
+	require.Equal(t, s.LastAttempt, s.LastSuccess)
+	require.Zero(t, s.LastError)
+	require.Nil(t, s.FailingFiles)
+}
+
+func TestLoadNamespaces(t *testing.T) {
+	pq, dir := newTestPersistedQueries(t)
+	writeFile(t, dir, "foo.graphql", "query { foo }")
+	writeFile(t, dir, "mobile/v2/foo.graphql", "query { foo bar }")
+	writeFile(t, dir, ".git/bar.graphql", "query { bar }")
+
+	require.NoError(t, pq.Load(dir))
+	require.Equal(t, 2, pq.Len())
+	require.Equal(t, `"query { foo }"`, pq.GetQuery("foo"))
+	require.Equal(t, `"query { foo bar }"`, pq.GetQuery("mobile/v2/foo"))
+	require.Nil(t, pq.Get(".git/bar"), "hidden directories are skipped")
+
+	writeFile(t, dir, "mobile/v 3/foo.graphql", "query { bar }")
+	err := pq.Load(dir)
+	var errLoad *gqlpq.LoadError
+	require.True(t, errors.As(err, &errLoad))
+	require.Len(t, errLoad.Files, 1)
+	require.Equal(t, filepath.Join(dir, "mobile", "v 3", "foo.graphql"),
+		errLoad.Files[0].File)
+	require.ErrorContains(t, errLoad.Files[0].Err, "invalid path (not URL safe)")
+}
+
+func TestLoadKeepsLastGoodSet(t *testing.T) {
+	pq, dir := newTestPersistedQueries(t)
+	writeFile(t, dir, "foo.graphql", "query { foo }")
+	require.NoError(t, pq.Load(dir))
+	lastSuccess := pq.Status().LastSuccess
+
+	writeFile(t, dir, "bar.graphql", "query { bar }")
+	writeFile(t, dir, "invalid.graphql", "query { baz }")
+	writeFile(t, dir, "duplicate.graphql", "query { foo }")
+	writeFile(t, dir, "metadata.graphql", "# @unknown\nquery { bar foo }")
+
+	err := pq.Load(dir)
+	var errLoad *gqlpq.LoadError
+	require.True(t, errors.As(err, &errLoad))
+	require.Len(t, errLoad.Files, 3)
+
+	// The previous set is still served.
+	require.Equal(t, 1, pq.Len())
+	require.NotNil(t, pq.Get("foo"))
+	require.Nil(t, pq.Get("bar"))
+
+	s := pq.Status()
+	require.Equal(t, 1, s.Queries)
+	require.Equal(t, lastSuccess, s.LastSuccess)
+	require.True(t, s.LastAttempt.After(s.LastSuccess))
+	require.Equal(t, err.Error(), s.LastError)
+	require.Len(t, s.FailingFiles, 3)
+	require.Contains(t, s.FailingFiles, filepath.Join(dir, "invalid.graphql"))
+	require.Contains(t, s.FailingFiles, filepath.Join(dir, "metadata.graphql"))
+	require.Contains(t, s.FailingFiles, filepath.Join(dir, "foo.graphql"))
+
+	// Fixing the files recovers.
+	require.NoError(t, os.Remove(filepath.Join(dir, "invalid.graphql")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "duplicate.graphql")))
+	require.NoError(t, os.Remove(filepath.Join(dir, "metadata.graphql")))
+	require.NoError(t, pq.Load(dir))
+	require.Equal(t, 2, pq.Len())
+
+	s = pq.Status()
+	require.Equal(t, s.LastAttempt, s.LastSuccess)
+	require.Zero(t, s.LastError)
+	require.Nil(t, s.FailingFiles)
+}
@@ -0,0 +1,237 @@
+package gqlpq
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity string
+
+const (
+	// SeverityError is a problem that prevents the query from being loaded.
+	SeverityError Severity = "error"
+
+	// SeverityWarning is a problem that doesn't prevent
+	// the query from being loaded.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found by Lint.
+type Diagnostic struct {
+	File string `json:"file"`
+
+	// Line and Column are 0 if the problem isn't bound to a location.
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	return fmt.Sprintf(
+		"%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message,
+	)
+}
+
+// ManifestEntry describes a valid persisted query.
+type ManifestEntry struct {
+	Name          string             `json:"name"`
+	Hash          string             `json:"hash"`
+	OperationType string             `json:"operationType"`
+	Variables     []ManifestVariable `json:"variables"`
+}
+
+// ManifestVariable describes a variable of a persisted query.
+type ManifestVariable struct {
+	Name string `json:"name"`
+
+	// Type is the GraphQL type, such as [ID!]!.
+	Type string `json:"type"`
+
+	// Default is the default value as GraphQL literal
+	// or "" if there's no default value.
+	Default string `json:"default,omitempty"`
+}
+
+// Lint validates all persisted query files in dirPath
+// and its subdirectories against schema
+// without loading them. Besides all errors that would make Load fail,
+// Lint reports warnings for usages of deprecated fields and for queries
+// with the same operation shape (the same document ignoring formatting,
+// comments and operation names).
+// The returned manifest contains all valid queries in file name order.
+func Lint(
+	schema *ast.Schema, dirPath string,
+) (manifest []ManifestEntry, diagnostics []Diagnostic, err error) {
+	files, err := findQueryFiles(dirPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	shapes := map[string]string{} // shape -> file path
+	for _, f := range files {
+		p := f.Path
+		report := func(pos *ast.Position, severity Severity, msg string) {
+			d := Diagnostic{File: p, Severity: severity, Message: msg}
+			if pos != nil {
+				d.Line, d.Column = pos.Line, pos.Column
+			}
+			diagnostics = append(diagnostics, d)
+		}
+
+		if !isValidKey(f.Key) {
+			report(nil, SeverityError, "invalid path (not URL safe)")
+			continue
+		}
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading query file %q: %w", p, err)
+		}
+		query := string(src)
+
+		metadata, errMeta := parseMetadata(query)
+		if errMeta != nil {
+			report(nil, SeverityError, errMeta.Error())
+		}
+
+		doc, errs := parseQuery(schema, query)
+		for _, e := range errs {
+			var pos *ast.Position
+			if len(e.Locations) > 0 {
+				pos = &ast.Position{
+					Line:   e.Locations[0].Line,
+					Column: e.Locations[0].Column,
+				}
+			}
+			report(pos, SeverityError, e.Message)
+		}
+		if errs != nil {
+			continue
+		}
+		if len(doc.Operations) != 1 {
+			report(nil, SeverityError, fmt.Sprintf(
+				"must contain exactly one operation, found %d",
+				len(doc.Operations),
+			))
+			continue
+		}
+		if errMeta == nil {
+			errMeta = metadata.checkOperation(doc.Operations[0].Operation)
+			if errMeta != nil {
+				report(nil, SeverityError, errMeta.Error())
+			}
+		}
+
+		forEachField(doc, func(f *ast.Field) {
+			if f.Definition == nil {
+				return
+			}
+			d := f.Definition.Directives.ForName("deprecated")
+			if d == nil {
+				return
+			}
+			msg := fmt.Sprintf(
+				"field %s.%s is deprecated", f.ObjectDefinition.Name, f.Name,
+			)
+			if r := d.Arguments.ForName("reason"); r != nil && r.Value != nil {
+				msg += ": " + r.Value.Raw
+			}
+			report(f.Position, SeverityWarning, msg)
+		})
+
+		if errMeta != nil {
+			continue
+		}
+
+		shape := operationShape(doc)
+		if first, ok := shapes[shape]; ok {
+			report(nil, SeverityWarning, fmt.Sprintf(
+				"same operation shape as %q", first,
+			))
+		} else {
+			shapes[shape] = p
+		}
+
+		op := doc.Operations[0]
+		e := ManifestEntry{
+			Name:          f.Key,
+			Hash:          Hash(query),
+			OperationType: string(op.Operation),
+			Variables:     []ManifestVariable{},
+		}
+		for _, v := range op.VariableDefinitions {
+			mv := ManifestVariable{Name: v.Variable, Type: v.Type.String()}
+			if v.DefaultValue != nil {
+				mv.Default = v.DefaultValue.String()
+			}
+			e.Variables = append(e.Variables, mv)
+		}
+		manifest = append(manifest, e)
+	}
+	return manifest, diagnostics, nil
+}
+
+// parseQuery parses and validates query against schema
+// returning all validation errors.
+func parseQuery(
+	schema *ast.Schema, query string,
+) (*ast.QueryDocument, gqlerror.List) {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		var gqlErr *gqlerror.Error
+		if errors.As(err, &gqlErr) {
+			return nil, gqlerror.List{gqlErr}
+		}
+		return nil, gqlerror.List{gqlerror.Wrap(err)}
+	}
+	if errs := validator.Validate(schema, doc); len(errs) > 0 {
+		return nil, errs
+	}
+	return doc, nil
+}
+
+// forEachField calls fn for every field selected
+// in the operations and fragments of doc.
+func forEachField(doc *ast.QueryDocument, fn func(*ast.Field)) {
+	var walk func(ast.SelectionSet)
+	walk = func(s ast.SelectionSet) {
+		for _, sel := range s {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				fn(sel)
+				walk(sel.SelectionSet)
+			case *ast.InlineFragment:
+				walk(sel.SelectionSet)
+			}
+		}
+	}
+	for _, o := range doc.Operations {
+		walk(o.SelectionSet)
+	}
+	for _, f := range doc.Fragments {
+		walk(f.SelectionSet)
+	}
+}
+
+// operationShape returns doc formatted without operation names.
+// doc is mutated.
+func operationShape(doc *ast.QueryDocument) string {
+	for _, o := range doc.Operations {
+		o.Name = ""
+	}
+	var b bytes.Buffer
+	formatter.NewFormatter(&b).FormatQueryDocument(doc)
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package gqlpq_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	schemaDir, dir := t.TempDir(), t.TempDir()
+	writeFile(t, schemaDir, "schema.graphqls", `
+		type Query {
+			foo(x: Int): String!
+			bar: Int! @deprecated(reason: "use foo")
+		}
+		type Mutation { setFoo(foo: String!): String! }
+	`)
+	schema, err := gqlpq.LoadSchema(schemaDir)
+	require.NoError(t, err)
+
+	writeFile(t, dir, "a_query.graphql", "# @auth\nquery A($x: Int = 42) { foo(x: $x) }")
+	writeFile(t, dir, "b_same_shape.graphql", "query B($x: Int = 42) {\n  foo(x: $x)\n}")
+	writeFile(t, dir, "c_deprecated.graphql", "query {\n  foo\n  bar\n}")
+	writeFile(t, dir, "d_unused_fragment.graphql", "fragment F on Query { foo }\nquery { foo }")
+	writeFile(t, dir, "e_invalid.graphql", "query { baz }")
+	writeFile(t, dir, "f_two_operations.graphql", "query X { foo }\nquery Y { bar }")
+	writeFile(t, dir, "g_metadata.graphql", "# @cache 10s\nmutation ($f: String!) { setFoo(foo: $f) }")
+	writeFile(t, dir, "h_mutation.graphql", "mutation ($f: String!) { setFoo(foo: $f) }")
+
+	manifest, diagnostics, err := gqlpq.Lint(schema, dir)
+	require.NoError(t, err)
+
+	p := func(n string) string { return filepath.Join(dir, n) }
+	require.Equal(t, []gqlpq.Diagnostic{
+		{
+			File:     p("b_same_shape.graphql"),
+			Severity: gqlpq.SeverityWarning,
+			Message:  `same operation shape as "` + p("a_query.graphql") + `"`,
+		},
+		{
+			File: p("c_deprecated.graphql"), Line: 3, Column: 3,
+			Severity: gqlpq.SeverityWarning,
+			Message:  "field Query.bar is deprecated: use foo",
+		},
+		{
+			File: p("d_unused_fragment.graphql"), Line: 1, Column: 1,
+			Severity: gqlpq.SeverityError,
+			Message:  `Fragment "F" is never used.`,
+		},
+		{
+			File: p("e_invalid.graphql"), Line: 1, Column: 9,
+			Severity: gqlpq.SeverityError,
+			Message:  `Cannot query field "baz" on type "Query". Did you mean "bar"?`,
+		},
+		{
+			File:     p("f_two_operations.graphql"),
+			Severity: gqlpq.SeverityError,
+			Message:  "must contain exactly one operation, found 2",
+		},
+		{
+			File:     p("g_metadata.graphql"),
+			Severity: gqlpq.SeverityError,
+			Message:  "@cache is only allowed for queries",
+		},
+	}, diagnostics)
+
+	require.Equal(t, []gqlpq.ManifestEntry{
+		{
+			Name:          "a_query",
+			Hash:          gqlpq.Hash("# @auth\nquery A($x: Int = 42) { foo(x: $x) }"),
+			OperationType: "query",
+			Variables: []gqlpq.ManifestVariable{
+				{Name: "x", Type: "Int", Default: "42"},
+			},
+		},
+		{
+			Name:          "b_same_shape",
+			Hash:          gqlpq.Hash("query B($x: Int = 42) {\n  foo(x: $x)\n}"),
+			OperationType: "query",
+			Variables: []gqlpq.ManifestVariable{
+				{Name: "x", Type: "Int", Default: "42"},
+			},
+		},
+		{
+			Name:          "c_deprecated",
+			Hash:          gqlpq.Hash("query {\n  foo\n  bar\n}"),
+			OperationType: "query",
+			Variables:     []gqlpq.ManifestVariable{},
+		},
+		{
+			Name:          "h_mutation",
+			Hash:          gqlpq.Hash("mutation ($f: String!) { setFoo(foo: $f) }"),
+			OperationType: "mutation",
+			Variables: []gqlpq.ManifestVariable{
+				{Name: "f", Type: "String!"},
+			},
+		},
+	}, manifest)
+}
@@ -0,0 +1,238 @@
+package gqlpq
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Metadata is the per-query metadata declared by directive comments
+// in the leading comment block of a persisted query file:
+//
+//	# @auth
+//	# @roles admin
+//	# @rateLimit 10/m
+//	# @maxBodySize 4KiB
+//	# @maxAge 30s
+//	# @cache 10s
+//	# @deprecated 2024-01-31 Use mobile/v3/qry_task instead.
+//	# @sunset 2024-06-30
+//	query { ... }
+//
+// Comments without the @ prefix are ignored.
+type Metadata struct {
+	// AuthRequired is true if the query must not be
+	// executed by unauthenticated clients (@auth).
+	// Implied by Roles.
+	AuthRequired bool
+
+	// Roles is the list of global roles allowed to execute the query (@roles).
+	// Only roles granted by the server are supported (see RoleAdmin)
+	// since users can change their job title freely.
+	// Any user is allowed if empty.
+	Roles []string
+
+	// RateLimit is the maximum number of executions per client (@rateLimit).
+	// Unlimited if RateLimit.Requests is 0.
+	RateLimit RateLimit
+
+	// MaxBodySize is the maximum size of the request body
+	// in bytes (@maxBodySize). Unlimited if 0.
+	MaxBodySize int64
+
+	// MaxAge is the duration responses to GET requests
+	// may be cached for without revalidation (@maxAge).
+	// Responses must always be revalidated if 0.
+	MaxAge time.Duration
+
+	// CacheTTL is the duration responses are cached
+	// by the server (@cache). Not cached if 0.
+	CacheTTL time.Duration
+
+	// Deprecated is the date the query was deprecated at (@deprecated).
+	// Not deprecated if zero.
+	Deprecated time.Time
+
+	// DeprecationReason is the optional reason following
+	// the date of @deprecated.
+	DeprecationReason string
+
+	// Sunset is the date the query is no longer served
+	// from (@sunset). Served indefinitely if zero.
+	Sunset time.Time
+}
+
+// RoleAdmin is the global admin role of users (see model.User.Admin).
+const RoleAdmin = "admin"
+
+// RateLimit is a number of requests per period.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (r RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", r.Requests, r.Period)
+}
+
+// parseMetadata parses the directive comments of the
+// leading comment block of query.
+func parseMetadata(query string) (m Metadata, err error) {
+	declared := map[string]bool{}
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		comment, ok := strings.CutPrefix(line, "#")
+		if !ok {
+			break // End of the leading comment block
+		}
+		comment = strings.TrimSpace(comment)
+		directive, ok := strings.CutPrefix(comment, "@")
+		if !ok {
+			continue // Regular comment
+		}
+		name, arg, _ := strings.Cut(directive, " ")
+		arg = strings.TrimSpace(arg)
+		if declared[name] {
+			return Metadata{}, fmt.Errorf("duplicate directive @%s", name)
+		}
+		declared[name] = true
+
+		switch name {
+		case "auth":
+			if arg != "" {
+				return Metadata{}, errors.New("@auth takes no argument")
+			}
+			m.AuthRequired = true
+		case "roles":
+			for _, r := range strings.Split(arg, ",") {
+				if r = strings.TrimSpace(r); r == "" {
+					return Metadata{}, errors.New("@roles: empty role")
+				}
+				if r != RoleAdmin {
+					return Metadata{}, fmt.Errorf("@roles: unknown role %q", r)
+				}
+				m.Roles = append(m.Roles, r)
+			}
+			m.AuthRequired = true
+		case "rateLimit":
+			if m.RateLimit, err = parseRateLimit(arg); err != nil {
+				return Metadata{}, fmt.Errorf("@rateLimit: %w", err)
+			}
+		case "maxBodySize":
+			if m.MaxBodySize, err = parseByteSize(arg); err != nil {
+				return Metadata{}, fmt.Errorf("@maxBodySize: %w", err)
+			}
+		case "maxAge":
+			if m.MaxAge, err = time.ParseDuration(arg); err != nil ||
+				m.MaxAge < time.Second {
+				return Metadata{}, fmt.Errorf(
+					"@maxAge: invalid duration %q; must be at least 1s", arg,
+				)
+			}
+		case "cache":
+			if m.CacheTTL, err = time.ParseDuration(arg); err != nil ||
+				m.CacheTTL < time.Second {
+				return Metadata{}, fmt.Errorf(
+					"@cache: invalid duration %q; must be at least 1s", arg,
+				)
+			}
+		case "deprecated":
+			date, reason, _ := strings.Cut(arg, " ")
+			if m.Deprecated, err = parseDate(date); err != nil {
+				return Metadata{}, fmt.Errorf("@deprecated: %w", err)
+			}
+			m.DeprecationReason = strings.TrimSpace(reason)
+		case "sunset":
+			if m.Sunset, err = parseDate(arg); err != nil {
+				return Metadata{}, fmt.Errorf("@sunset: %w", err)
+			}
+		default:
+			return Metadata{}, fmt.Errorf("unknown directive @%s", name)
+		}
+	}
+	if !m.Deprecated.IsZero() && !m.Sunset.IsZero() &&
+		m.Sunset.Before(m.Deprecated) {
+		return Metadata{}, errors.New("@sunset: before @deprecated")
+	}
+	return m, nil
+}
+
+// parseDate parses a YYYY-MM-DD date in UTC.
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q; use YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// checkOperation returns an error if m declares directives
+// that don't apply to operations of type op.
+func (m Metadata) checkOperation(op ast.Operation) error {
+	if op == ast.Query {
+		return nil
+	}
+	switch {
+	case m.MaxAge > 0:
+		return fmt.Errorf("@maxAge is only allowed for queries")
+	case m.CacheTTL > 0:
+		return fmt.Errorf("@cache is only allowed for queries")
+	}
+	return nil
+}
+
+// parseRateLimit parses "<requests>/<period>" where period is
+// either s, m, h or a duration such as 10s.
+func parseRateLimit(s string) (r RateLimit, err error) {
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return r, fmt.Errorf("invalid rate limit %q; use <requests>/<period>", s)
+	}
+	if r.Requests, err = strconv.Atoi(requests); err != nil || r.Requests < 1 {
+		return r, fmt.Errorf("invalid number of requests %q", requests)
+	}
+	switch period {
+	case "s":
+		r.Period = time.Second
+	case "m":
+		r.Period = time.Minute
+	case "h":
+		r.Period = time.Hour
+	default:
+		if r.Period, err = time.ParseDuration(period); err != nil || r.Period <= 0 {
+			return r, fmt.Errorf("invalid period %q", period)
+		}
+	}
+	return r, nil
+}
+
+// parseByteSize parses a positive number of bytes
+// with an optional B, KiB or MiB unit suffix.
+func parseByteSize(s string) (int64, error) {
+	n, multiplier := s, int64(1)
+	for _, u := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KiB", 1 << 10},
+		{"MiB", 1 << 20},
+		{"B", 1},
+	} {
+		if v, ok := strings.CutSuffix(s, u.suffix); ok {
+			n, multiplier = strings.TrimSpace(v), u.multiplier
+			break
+		}
+	}
+	i, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || i < 1 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return i * multiplier, nil
+}
@@ -0,0 +1,178 @@
+package gqlpq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadata(t *testing.T) {
+	for _, td := range []struct {
+		name   string
+		input  string
+		expect Metadata
+	}{
+		{
+			name:  "none",
+			input: "query { users { id } }",
+		},
+		{
+			name: "regular_comments",
+			input: "# Lists all users.\n" +
+				"# @auth\n" +
+				"query { users { id } }",
+			expect: Metadata{AuthRequired: true},
+		},
+		{
+			name: "all",
+			input: "\n# @roles admin \n" +
+				"#@rateLimit 10/m\n" +
+				"  # @maxBodySize 4KiB\n" +
+				"# @maxAge 1m30s\n" +
+				"# @cache 10s\n" +
+				"\n" +
+				"query { users { id } }",
+			expect: Metadata{
+				AuthRequired: true,
+				Roles:        []string{"admin"},
+				RateLimit:    RateLimit{Requests: 10, Period: time.Minute},
+				MaxBodySize:  4 << 10,
+				MaxAge:       90 * time.Second,
+				CacheTTL:     10 * time.Second,
+			},
+		},
+		{
+			name: "leading_block_only",
+			input: "query { users { id } }\n" +
+				"# @auth\n",
+		},
+		{
+			name:  "rate_limit_duration",
+			input: "# @rateLimit 5/30s\nquery { users { id } }",
+			expect: Metadata{
+				RateLimit: RateLimit{Requests: 5, Period: 30 * time.Second},
+			},
+		},
+		{
+			name:   "body_size_bytes",
+			input:  "# @maxBodySize 512\nquery { users { id } }",
+			expect: Metadata{MaxBodySize: 512},
+		},
+		{
+			name: "deprecated",
+			input: "# @deprecated 2024-01-31  Use v3/qry_users instead.\n" +
+				"# @sunset 2024-06-30\n" +
+				"query { users { id } }",
+			expect: Metadata{
+				Deprecated:        time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+				DeprecationReason: "Use v3/qry_users instead.",
+				Sunset:            time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "deprecated_without_reason",
+			input: "# @deprecated 2024-01-31\nquery { users { id } }",
+			expect: Metadata{
+				Deprecated: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:   "body_size_mib",
+			input:  "# @maxBodySize 2 MiB\nquery { users { id } }",
+			expect: Metadata{MaxBodySize: 2 << 20},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			m, err := parseMetadata(td.input)
+			require.NoError(t, err)
+			require.Equal(t, td.expect, m)
+		})
+	}
+}
+
+func TestParseMetadataErr(t *testing.T) {
+	for _, td := range []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{
+			name:   "unknown_directive",
+			input:  "# @cacheControl 10s\nquery { users { id } }",
+			expect: "unknown directive @cacheControl",
+		},
+		{
+			name:   "duplicate_directive",
+			input:  "# @auth\n# @auth\nquery { users { id } }",
+			expect: "duplicate directive @auth",
+		},
+		{
+			name:   "auth_argument",
+			input:  "# @auth yes\nquery { users { id } }",
+			expect: "@auth takes no argument",
+		},
+		{
+			name:   "empty_role",
+			input:  "# @roles admin,\nquery { users { id } }",
+			expect: "@roles: empty role",
+		},
+		{
+			name:   "unknown_role",
+			input:  "# @roles SWE Backend\nquery { users { id } }",
+			expect: `@roles: unknown role "SWE Backend"`,
+		},
+		{
+			name:  "rate_limit_syntax",
+			input: "# @rateLimit 10\nquery { users { id } }",
+			expect: `@rateLimit: invalid rate limit "10"; ` +
+				`use <requests>/<period>`,
+		},
+		{
+			name:   "rate_limit_requests",
+			input:  "# @rateLimit 0/s\nquery { users { id } }",
+			expect: `@rateLimit: invalid number of requests "0"`,
+		},
+		{
+			name:   "rate_limit_period",
+			input:  "# @rateLimit 1/d\nquery { users { id } }",
+			expect: `@rateLimit: invalid period "d"`,
+		},
+		{
+			name:   "body_size",
+			input:  "# @maxBodySize 1GiB\nquery { users { id } }",
+			expect: `@maxBodySize: invalid size "1GiB"`,
+		},
+		{
+			name:   "max_age",
+			input:  "# @maxAge 500ms\nquery { users { id } }",
+			expect: `@maxAge: invalid duration "500ms"; must be at least 1s`,
+		},
+		{
+			name:   "deprecated_date",
+			input:  "# @deprecated Use v3 instead.\nquery { users { id } }",
+			expect: `@deprecated: invalid date "Use"; use YYYY-MM-DD`,
+		},
+		{
+			name:   "sunset_date",
+			input:  "# @sunset 30.06.2024\nquery { users { id } }",
+			expect: `@sunset: invalid date "30.06.2024"; use YYYY-MM-DD`,
+		},
+		{
+			name: "sunset_before_deprecated",
+			input: "# @deprecated 2024-06-30\n# @sunset 2024-01-31\n" +
+				"query { users { id } }",
+			expect: "@sunset: before @deprecated",
+		},
+		{
+			name:   "cache",
+			input:  "# @cache forever\nquery { users { id } }",
+			expect: `@cache: invalid duration "forever"; must be at least 1s`,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			_, err := parseMetadata(td.input)
+			require.EqualError(t, err, td.expect)
+		})
+	}
+}
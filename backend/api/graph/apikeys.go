@@ -0,0 +1,76 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
+)
+
+const (
+	// APIKeyPrefix prefixes API keys to tell them apart from access tokens.
+	APIKeyPrefix = "thk_"
+
+	// APIKeyLastUsedResolution is the minimum time between
+	// two updates of the last use time of an API key.
+	APIKeyLastUsedResolution = time.Minute
+)
+
+// createAPIKey creates an API key of the given user, or of the client's
+// user if userID is nil, and returns it together with the key.
+func (r *Resolver) createAPIKey(
+	ctx context.Context, name string, scopes []model.APIKeyScope,
+	userID *string,
+) (*model.CreatedAPIKey, error) {
+	id := reqctx.GetRequestContext(ctx).UserID
+	if userID != nil {
+		id = *userID
+	}
+
+	secret, err := newAPIKeySecret()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := r.PasswordHasher.HashPassword([]byte(secret))
+	if err != nil {
+		return nil, fmt.Errorf("hashing API key: %w", err)
+	}
+	k, err := r.DataProvider.CreateAPIKey(
+		ctx, r.TimeProvider.Now(), id, name, scopes, hash,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreatedAPIKey{
+		Key:    APIKeyPrefix + k.ID + "." + secret,
+		APIKey: k,
+	}, nil
+}
+
+// ParseAPIKey returns the ID and the secret of key.
+// Returns ok=false if key isn't an API key.
+func ParseAPIKey(key string) (id, secret string, ok bool) {
+	s, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", "", false
+	}
+	id, secret, ok = strings.Cut(s, ".")
+	if !ok || id == "" || secret == "" {
+		return "", "", false
+	}
+	return id, secret, true
+}
+
+// newAPIKeySecret returns a new random API key secret.
+func newAPIKeySecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating API key: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
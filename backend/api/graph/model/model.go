@@ -2,6 +2,10 @@ package model
 
 import "time"
 
+// Relations between models (such as Task.Project or User.Manager)
+// may be shallow references only carrying the ID of the referenced model
+// depending on the data provider.
+
 type User struct {
 	ID             string  `json:"id"`
 	Email          string  `json:"email"`
@@ -11,8 +15,24 @@ type User struct {
 	PersonalStatus string  `json:"personalStatus"`
 	Manager        *User   `json:"manager,omitempty"`
 	Subordinates   []*User `json:"subordinates,omitempty"`
+	// Deactivated is nil if the user is active.
+	Deactivated *time.Time `json:"deactivated,omitempty"`
+	// Admin is true if the user has the global admin role.
+	Admin bool `json:"admin,omitempty"`
+	// TwoFactorEnabled is true once the enrollment of TOTPSecret
+	// was confirmed.
+	TwoFactorEnabled bool `json:"twoFactorEnabled,omitempty"`
 
 	PasswordHash string
+	// TOTPSecret is the base32 encoded TOTP secret, empty if the user
+	// never enrolled. The secret is pending until TwoFactorEnabled.
+	TOTPSecret string
+	// TOTPLastStep is the time step of the last accepted code.
+	// Codes of this or earlier steps are rejected to prevent replays.
+	TOTPLastStep int64
+	// RecoveryCodeHashes are the password hashes
+	// of the unused recovery codes.
+	RecoveryCodeHashes []string
 }
 
 type Project struct {
@@ -21,7 +41,26 @@ type Project struct {
 	Description string    `json:"description"`
 	Slug        string    `json:"slug"`
 	Creation    time.Time `json:"creation"`
-	Owners      []*User   `json:"owners,omitempty"`
+	// Archived is nil if the project is active.
+	Archived *time.Time `json:"archived,omitempty"`
+	// Roles are the project roles granted to users in order of granting.
+	Roles []*ProjectRoleGrant `json:"roles,omitempty"`
+}
+
+// Owners returns the users with the owner role.
+func (p *Project) Owners() []*User {
+	var owners []*User
+	for _, g := range p.Roles {
+		if g.Role == ProjectRoleOwner {
+			owners = append(owners, g.User)
+		}
+	}
+	return owners
+}
+
+type ProjectRoleGrant struct {
+	User *User       `json:"user"`
+	Role ProjectRole `json:"role"`
 }
 
 type Task struct {
@@ -39,3 +78,59 @@ type Task struct {
 	Blocks      []*Task      `json:"blocks"`
 	RelatesTo   []*Task      `json:"relatesTo"`
 }
+
+type Comment struct {
+	ID       string    `json:"id"`
+	Task     *Task     `json:"task"`
+	Author   *User     `json:"author"`
+	Body     string    `json:"body"`
+	Creation time.Time `json:"creation"`
+	// Edited is nil if the comment was never edited.
+	Edited *time.Time `json:"edited,omitempty"`
+	// Parent is nil for comments that aren't replies.
+	Parent *Comment `json:"parent,omitempty"`
+}
+
+// Organization holds the settings shared by all users.
+type Organization struct {
+	// TwoFactorRequired is true if all users are required
+	// to enable two-factor authentication.
+	TwoFactorRequired bool `json:"twoFactorRequired"`
+}
+
+// APIKey is a long-lived credential of a user for automation.
+type APIKey struct {
+	ID       string        `json:"id"`
+	User     *User         `json:"user"`
+	Name     string        `json:"name"`
+	Kind     APIKeyKind    `json:"kind"`
+	Scopes   []APIKeyScope `json:"scopes"`
+	Creation time.Time     `json:"creation"`
+	// LastUsed is nil if the key was never used.
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+	// Revoked is nil if the key wasn't revoked.
+	Revoked *time.Time `json:"revoked,omitempty"`
+
+	// SecretHash is the password hash of the secret of the key.
+	SecretHash string
+}
+
+// Session is a signed-in device of a user identified by a refresh token.
+type Session struct {
+	ID        string    `json:"id"`
+	User      *User     `json:"user"`
+	UserAgent string    `json:"userAgent"`
+	Creation  time.Time `json:"creation"`
+	// Refreshed is the time the refresh token was last rotated at.
+	Refreshed time.Time `json:"refreshed"`
+	// Expires is the time the refresh token expires at.
+	Expires time.Time `json:"expires"`
+	// Revoked is nil if the session wasn't revoked.
+	Revoked *time.Time `json:"revoked,omitempty"`
+
+	// RefreshTokenHash is the hash of the current refresh token.
+	RefreshTokenHash string
+	// PreviousRefreshTokenHash is the hash of the refresh token
+	// that was replaced by the current one, if any.
+	PreviousRefreshTokenHash string
+}
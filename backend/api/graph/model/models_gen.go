@@ -9,10 +9,62 @@ import (
 	"time"
 )
 
+type AuthTokens struct {
+	AccessToken        string    `json:"accessToken"`
+	AccessTokenExpires time.Time `json:"accessTokenExpires"`
+	RefreshToken       string    `json:"refreshToken"`
+	Session            *Session  `json:"session"`
+}
+
+type CreatedAPIKey struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"apiKey"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
+type ProjectConnection struct {
+	Edges      []*ProjectEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+type ProjectEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Project `json:"node"`
+}
+
 type ProjectsFilters struct {
 	Members       []string   `json:"members,omitempty"`
 	CreatedBefore *time.Time `json:"createdBefore,omitempty"`
 	CreatedAfter  *time.Time `json:"createdAfter,omitempty"`
+	Archived      *bool      `json:"archived,omitempty"`
+}
+
+type SignInChallenge struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+type SignInResult struct {
+	Tokens    *AuthTokens      `json:"tokens,omitempty"`
+	Challenge *SignInChallenge `json:"challenge,omitempty"`
+}
+
+type TaskConnection struct {
+	Edges      []*TaskEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type TaskEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Task  `json:"node"`
 }
 
 type TasksFilters struct {
@@ -23,11 +75,158 @@ type TasksFilters struct {
 	Tags          []string     `json:"tags,omitempty"`
 	CreatedBefore *time.Time   `json:"createdBefore,omitempty"`
 	CreatedAfter  *time.Time   `json:"createdAfter,omitempty"`
+	Archived      *bool        `json:"archived,omitempty"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type UserConnection struct {
+	Edges      []*UserEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
 }
 
 type UsersFilters struct {
-	Name     string   `json:"name"`
-	Projects []string `json:"projects,omitempty"`
+	Name        string   `json:"name"`
+	Projects    []string `json:"projects,omitempty"`
+	Deactivated *bool    `json:"deactivated,omitempty"`
+}
+
+type APIKeyKind string
+
+const (
+	APIKeyKindPersonal APIKeyKind = "PERSONAL"
+	APIKeyKindService  APIKeyKind = "SERVICE"
+)
+
+var AllAPIKeyKind = []APIKeyKind{
+	APIKeyKindPersonal,
+	APIKeyKindService,
+}
+
+func (e APIKeyKind) IsValid() bool {
+	switch e {
+	case APIKeyKindPersonal, APIKeyKindService:
+		return true
+	}
+	return false
+}
+
+func (e APIKeyKind) String() string {
+	return string(e)
+}
+
+func (e *APIKeyKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = APIKeyKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid APIKeyKind", str)
+	}
+	return nil
+}
+
+func (e APIKeyKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type APIKeyScope string
+
+const (
+	APIKeyScopeReadTasks  APIKeyScope = "READ_TASKS"
+	APIKeyScopeWriteTasks APIKeyScope = "WRITE_TASKS"
+	APIKeyScopeAdmin      APIKeyScope = "ADMIN"
+)
+
+var AllAPIKeyScope = []APIKeyScope{
+	APIKeyScopeReadTasks,
+	APIKeyScopeWriteTasks,
+	APIKeyScopeAdmin,
+}
+
+func (e APIKeyScope) IsValid() bool {
+	switch e {
+	case APIKeyScopeReadTasks, APIKeyScopeWriteTasks, APIKeyScopeAdmin:
+		return true
+	}
+	return false
+}
+
+func (e APIKeyScope) String() string {
+	return string(e)
+}
+
+func (e *APIKeyScope) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = APIKeyScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid APIKeyScope", str)
+	}
+	return nil
+}
+
+func (e APIKeyScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ProjectRole string
+
+const (
+	ProjectRoleOwner      ProjectRole = "OWNER"
+	ProjectRoleMaintainer ProjectRole = "MAINTAINER"
+	ProjectRoleMember     ProjectRole = "MEMBER"
+	ProjectRoleViewer     ProjectRole = "VIEWER"
+)
+
+var AllProjectRole = []ProjectRole{
+	ProjectRoleOwner,
+	ProjectRoleMaintainer,
+	ProjectRoleMember,
+	ProjectRoleViewer,
+}
+
+func (e ProjectRole) IsValid() bool {
+	switch e {
+	case ProjectRoleOwner, ProjectRoleMaintainer, ProjectRoleMember, ProjectRoleViewer:
+		return true
+	}
+	return false
+}
+
+func (e ProjectRole) String() string {
+	return string(e)
+}
+
+func (e *ProjectRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ProjectRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ProjectRole", str)
+	}
+	return nil
+}
+
+func (e ProjectRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
 type ProjectsOrder string
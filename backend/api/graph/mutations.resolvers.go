@@ -6,14 +6,248 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/romshark/taskhub/api/auth"
 	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/api/totp"
 	"github.com/romshark/taskhub/api/validate"
 )
 
+// SignIn is the resolver for the signIn field.
+func (r *mutationResolver) SignIn(ctx context.Context, email string, password string) (*model.AuthTokens, error) {
+	user, err := r.signIn(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorRequired
+	}
+	return r.createSession(ctx, user.ID, RefreshTokenLifetime)
+}
+
+// BeginSignIn is the resolver for the beginSignIn field.
+func (r *mutationResolver) BeginSignIn(ctx context.Context, email string, password string) (*model.SignInResult, error) {
+	user, err := r.signIn(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		tokens, err := r.createSession(ctx, user.ID, RefreshTokenLifetime)
+		if err != nil {
+			return nil, err
+		}
+		return &model.SignInResult{Tokens: tokens}, nil
+	}
+	now := r.TimeProvider.Now()
+	if r.twoFactorFailures.Locked(user.ID, now) {
+		return nil, ErrTwoFactorLocked
+	}
+	challenge, err := r.signInChallenges.Create(user.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	return &model.SignInResult{Challenge: challenge}, nil
+}
+
+// CompleteSignIn is the resolver for the completeSignIn field.
+func (r *mutationResolver) CompleteSignIn(ctx context.Context, challengeToken string, code string) (*model.AuthTokens, error) {
+	return r.completeSignIn(ctx, challengeToken, code)
+}
+
+// RefreshAccessToken is the resolver for the refreshAccessToken field.
+func (r *mutationResolver) RefreshAccessToken(ctx context.Context, refreshToken string) (*model.AuthTokens, error) {
+	return r.refreshSession(ctx, refreshToken)
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (string, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return "", err
+	}
+	id := reqctx.GetRequestContext(ctx).SessionID
+	if _, err := r.DataProvider.RevokeSession(
+		ctx, id, r.TimeProvider.Now(),
+	); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// RevokeSession is the resolver for the revokeSession field.
+func (r *mutationResolver) RevokeSession(ctx context.Context, id string) (*model.Session, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := auth.RequireScope(ctx, model.APIKeyScopeAdmin); err != nil {
+		return nil, err
+	}
+	return r.DataProvider.RevokeSession(ctx, id, r.TimeProvider.Now())
+}
+
+// RevokeAllSessions is the resolver for the revokeAllSessions field.
+func (r *mutationResolver) RevokeAllSessions(ctx context.Context) (int, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return 0, err
+	}
+	if err := auth.RequireScope(ctx, model.APIKeyScopeAdmin); err != nil {
+		return 0, err
+	}
+	return r.DataProvider.RevokeUserSessions(
+		ctx, reqctx.GetRequestContext(ctx).UserID, r.TimeProvider.Now(),
+	)
+}
+
+// EnrollTwoFactor is the resolver for the enrollTwoFactor field.
+func (r *mutationResolver) EnrollTwoFactor(ctx context.Context) (*model.TwoFactorEnrollment, error) {
+	user, err := r.twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.DataProvider.SetTOTPSecret(ctx, user.ID, secret); err != nil {
+		return nil, err
+	}
+	return &model.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(TwoFactorIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor is the resolver for the confirmTwoFactor field.
+func (r *mutationResolver) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	user, err := r.twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor authentication not enrolled")
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, r.TimeProvider.Now())
+	if !ok {
+		return nil, ErrTwoFactorCodeInvalid
+	}
+	codes, hashes, err := r.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.DataProvider.EnableTwoFactor(
+		ctx, user.ID, step, hashes,
+	); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes is the resolver for the regenerateRecoveryCodes field.
+func (r *mutationResolver) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	user, err := r.twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.verifyTwoFactorCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := r.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.DataProvider.SetRecoveryCodes(ctx, user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor is the resolver for the disableTwoFactor field.
+func (r *mutationResolver) DisableTwoFactor(ctx context.Context, code string) (*model.User, error) {
+	user, err := r.twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Fail before the code is used up.
+	o, err := r.DataProvider.Organization(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if o.TwoFactorRequired {
+		return nil, errors.New(
+			"two-factor authentication is required by the organization",
+		)
+	}
+	if err := r.verifyTwoFactorCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	updated, err := r.DataProvider.DisableTwoFactor(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	r.notifyAccessChange()
+	return updated, nil
+}
+
+// ResetTwoFactor is the resolver for the resetTwoFactor field.
+func (r *mutationResolver) ResetTwoFactor(ctx context.Context, user string) (*model.User, error) {
+	client, err := r.twoFactorUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Admin {
+		return nil, auth.ErrUnauthorized
+	}
+	updated, err := r.DataProvider.DisableTwoFactor(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	r.notifyAccessChange()
+	return updated, nil
+}
+
+// SetTwoFactorRequired is the resolver for the setTwoFactorRequired field.
+func (r *mutationResolver) SetTwoFactorRequired(ctx context.Context, required bool) (*model.Organization, error) {
+	o, err := r.DataProvider.SetTwoFactorRequired(ctx, required)
+	if err != nil {
+		return nil, err
+	}
+	r.notifyAccessChange()
+	return o, nil
+}
+
+// CreateAPIKey is the resolver for the createApiKey field.
+func (r *mutationResolver) CreateAPIKey(ctx context.Context, name string, scopes []model.APIKeyScope, user *string) (*model.CreatedAPIKey, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := auth.RequireScope(ctx, model.APIKeyScopeAdmin); err != nil {
+		return nil, err
+	}
+	if err := validate.APIKeyName(name); err != nil {
+		return nil, err
+	}
+	return r.createAPIKey(ctx, name, scopes, user)
+}
+
+// RevokeAPIKey is the resolver for the revokeApiKey field.
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id string) (*model.APIKey, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := auth.RequireScope(ctx, model.APIKeyScopeAdmin); err != nil {
+		return nil, err
+	}
+	revoked, err := r.DataProvider.RevokeAPIKey(ctx, id, r.TimeProvider.Now())
+	if err != nil {
+		return nil, err
+	}
+	r.notifyAccessChange()
+	return revoked, nil
+}
+
 // CreateUser is the resolver for the createUser field.
 func (r *mutationResolver) CreateUser(ctx context.Context, email string, password string, displayName string, role string, location string, manager *string, subordinates []string) (*model.User, error) {
 	if err := validate.EmailAddress(email); err != nil {
@@ -45,7 +279,7 @@ func (r *mutationResolver) CreateUser(ctx context.Context, email string, passwor
 
 // UpdateUser is the resolver for the updateUser field.
 func (r *mutationResolver) UpdateUser(ctx context.Context, id string, email string, displayName string, role string, location string, personalStatus *string, manager *string, subordinates []string) (*model.User, error) {
-	if err := auth.RequireOwner(ctx, id); err != nil {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
 		return nil, err
 	}
 	if err := validate.EmailAddress(email); err != nil {
@@ -113,7 +347,7 @@ func (r *mutationResolver) CreateTask(ctx context.Context, title string, project
 		return nil, err
 	}
 
-	go r.broadcastTaskUpsert.Notify(context.Background(), newTask)
+	r.broadcastTaskUpsert.Notify(ctx, newTask)
 
 	return newTask, nil
 }
@@ -151,7 +385,7 @@ func (r *mutationResolver) UpdateTask(ctx context.Context, id string, title stri
 		return nil, err
 	}
 
-	go r.broadcastTaskUpsert.Notify(context.Background(), updated)
+	r.broadcastTaskUpsert.Notify(ctx, updated)
 
 	return updated, nil
 }
@@ -183,7 +417,7 @@ func (r *mutationResolver) CreateProject(ctx context.Context, name string, descr
 		return nil, err
 	}
 
-	go r.broadcastProjectUpsert.Notify(context.Background(), newProject)
+	r.broadcastProjectUpsert.Notify(ctx, newProject)
 
 	return newProject, nil
 }
@@ -215,8 +449,164 @@ func (r *mutationResolver) UpdateProject(ctx context.Context, id string, name st
 		return nil, err
 	}
 
-	go r.broadcastProjectUpsert.Notify(context.Background(), updated)
+	r.broadcastProjectUpsert.Notify(ctx, updated)
+
+	return updated, nil
+}
+
+// DeleteTask is the resolver for the deleteTask field.
+func (r *mutationResolver) DeleteTask(ctx context.Context, id string) (string, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return "", err
+	}
+
+	// Subscribers are notified only if they can view the task's project.
+	task, err := r.DataProvider.TaskByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if err := r.DataProvider.DeleteTask(ctx, id); err != nil {
+		return "", err
+	}
+
+	r.broadcastTaskDelete.Notify(ctx, task)
+
+	return id, nil
+}
+
+// ArchiveProject is the resolver for the archiveProject field.
+func (r *mutationResolver) ArchiveProject(ctx context.Context, id string) (*model.Project, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	updated, err := r.DataProvider.ArchiveProject(ctx, id, r.TimeProvider.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	r.broadcastProjectUpsert.Notify(ctx, updated)
+
+	return updated, nil
+}
+
+// UnarchiveProject is the resolver for the unarchiveProject field.
+func (r *mutationResolver) UnarchiveProject(ctx context.Context, id string) (*model.Project, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	updated, err := r.DataProvider.UnarchiveProject(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.broadcastProjectUpsert.Notify(ctx, updated)
+
+	return updated, nil
+}
+
+// DeactivateUser is the resolver for the deactivateUser field.
+func (r *mutationResolver) DeactivateUser(ctx context.Context, id string) (*model.User, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := auth.RequireScope(ctx, model.APIKeyScopeAdmin); err != nil {
+		return nil, err
+	}
+
+	updated, err := r.DataProvider.DeactivateUser(ctx, id, r.TimeProvider.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	r.broadcastUserDeactivate.Notify(ctx, updated)
+	r.notifyAccessChange()
+
+	return updated, nil
+}
+
+// AddComment is the resolver for the addComment field.
+func (r *mutationResolver) AddComment(ctx context.Context, task string, body string, parent *string) (*model.Comment, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := validate.CommentBody(body); err != nil {
+		return nil, err
+	}
+
+	newComment, err := r.DataProvider.AddComment(
+		ctx, r.TimeProvider.Now(), task, body, parent,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.broadcastCommentAdded.Notify(ctx, newComment)
+
+	return newComment, nil
+}
+
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, id string, body string) (*model.Comment, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := validate.CommentBody(body); err != nil {
+		return nil, err
+	}
+	return r.DataProvider.EditComment(ctx, id, body, r.TimeProvider.Now())
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (string, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return "", err
+	}
+	if err := r.DataProvider.DeleteComment(ctx, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// GrantProjectRole is the resolver for the grantProjectRole field.
+func (r *mutationResolver) GrantProjectRole(ctx context.Context, project string, user string, role model.ProjectRole) (*model.Project, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	updated, err := r.DataProvider.GrantProjectRole(ctx, project, user, role)
+	if err != nil {
+		return nil, err
+	}
+	r.broadcastProjectUpsert.Notify(ctx, updated)
+	r.notifyAccessChange()
+	return updated, nil
+}
+
+// RevokeProjectRole is the resolver for the revokeProjectRole field.
+func (r *mutationResolver) RevokeProjectRole(ctx context.Context, project string, user string) (*model.Project, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	updated, err := r.DataProvider.RevokeProjectRole(ctx, project, user)
+	if err != nil {
+		return nil, err
+	}
+	r.broadcastProjectUpsert.Notify(ctx, updated)
+	r.notifyAccessChange()
+	return updated, nil
+}
 
+// SetUserAdmin is the resolver for the setUserAdmin field.
+func (r *mutationResolver) SetUserAdmin(ctx context.Context, id string, admin bool) (*model.User, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	updated, err := r.DataProvider.SetUserAdmin(ctx, id, admin)
+	if err != nil {
+		return nil, err
+	}
+	r.notifyAccessChange()
 	return updated, nil
 }
 
@@ -6,30 +6,43 @@ package graph
 
 import (
 	"context"
-	"time"
 
 	"github.com/romshark/taskhub/api/auth"
 	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
 )
 
 // AccessToken is the resolver for the accessToken field.
 func (r *queryResolver) AccessToken(ctx context.Context, email string, password string) (string, error) {
-	user, err := r.DataProvider.UserByEmail(ctx, email)
+	user, err := r.signIn(ctx, email, password)
 	if err != nil {
 		return "", err
 	}
+	if user.TwoFactorEnabled {
+		return "", ErrTwoFactorRequired
+	}
 
-	ok, err := r.Resolver.PasswordHasher.ComparePassword(
-		[]byte(password), []byte(user.PasswordHash),
+	// Legacy tokens are bound to a session that can't be refreshed
+	// but can be revoked.
+	now := r.TimeProvider.Now()
+	session, err := r.DataProvider.CreateSession(
+		ctx, now, user.ID, reqctx.GetRequestContext(ctx).UserAgent,
+		"", now.Add(LegacyAccessTokenLifetime),
 	)
 	if err != nil {
 		return "", err
 	}
-	if !ok {
-		return "", auth.ErrUnauthorized
-	}
+	return r.Resolver.JWTGenerator.GenerateJWT(
+		user.ID, session.ID, now, LegacyAccessTokenLifetime,
+	)
+}
 
-	return r.Resolver.JWTGenerator.GenerateJWT(user.ID, 24*time.Hour)
+// Organization is the resolver for the organization field.
+func (r *queryResolver) Organization(ctx context.Context) (*model.Organization, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	return r.DataProvider.Organization(ctx)
 }
 
 // Task is the resolver for the task field.
@@ -57,27 +70,39 @@ func (r *queryResolver) Project(ctx context.Context, id string) (*model.Project,
 }
 
 // Tasks is the resolver for the tasks field.
-func (r *queryResolver) Tasks(ctx context.Context, filters *model.TasksFilters, order *model.TasksOrder, orderAsc bool, limit *int) ([]*model.Task, error) {
+func (r *queryResolver) Tasks(ctx context.Context, filters *model.TasksFilters, order *model.TasksOrder, orderAsc bool, first *int, after *string, last *int, before *string) (*model.TaskConnection, error) {
 	if err := auth.RequireAuthenticated(ctx); err != nil {
 		return nil, err
 	}
-	return r.DataProvider.GetTasks(ctx, filters, order, orderAsc, limit)
+	page, err := newPage(order, orderAsc, first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+	return r.DataProvider.GetTasks(ctx, filters, order, orderAsc, page)
 }
 
 // Users is the resolver for the users field.
-func (r *queryResolver) Users(ctx context.Context, filters *model.UsersFilters, order *model.UsersOrder, orderAsc bool, limit *int) ([]*model.User, error) {
+func (r *queryResolver) Users(ctx context.Context, filters *model.UsersFilters, order *model.UsersOrder, orderAsc bool, first *int, after *string, last *int, before *string) (*model.UserConnection, error) {
 	if err := auth.RequireAuthenticated(ctx); err != nil {
 		return nil, err
 	}
-	return r.DataProvider.GetUsers(ctx, filters, order, orderAsc, limit)
+	page, err := newPage(order, orderAsc, first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+	return r.DataProvider.GetUsers(ctx, filters, order, orderAsc, page)
 }
 
 // Projects is the resolver for the projects field.
-func (r *queryResolver) Projects(ctx context.Context, filters *model.ProjectsFilters, order *model.ProjectsOrder, orderAsc bool, limit *int) ([]*model.Project, error) {
+func (r *queryResolver) Projects(ctx context.Context, filters *model.ProjectsFilters, order *model.ProjectsOrder, orderAsc bool, first *int, after *string, last *int, before *string) (*model.ProjectConnection, error) {
 	if err := auth.RequireAuthenticated(ctx); err != nil {
 		return nil, err
 	}
-	return r.DataProvider.GetProjects(ctx, filters, order, orderAsc, limit)
+	page, err := newPage(order, orderAsc, first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+	return r.DataProvider.GetProjects(ctx, filters, order, orderAsc, page)
 }
 
 // Query returns QueryResolver implementation.
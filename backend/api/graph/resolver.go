@@ -2,8 +2,12 @@ package graph
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
+	"github.com/romshark/taskhub/api/auth"
 	"github.com/romshark/taskhub/api/broadcast"
 	"github.com/romshark/taskhub/api/dataprovider"
 	"github.com/romshark/taskhub/api/graph/model"
@@ -21,8 +25,17 @@ type Resolver struct {
 	PasswordHasher PasswordHasher
 	TimeProvider   TimeProvider
 
-	broadcastTaskUpsert    *broadcast.Broadcast[*model.Task]
-	broadcastProjectUpsert *broadcast.Broadcast[*model.Project]
+	broadcastTaskUpsert     *broadcast.Broadcast[*model.Task]
+	broadcastTaskDelete     *broadcast.Broadcast[*model.Task]
+	broadcastProjectUpsert  *broadcast.Broadcast[*model.Project]
+	broadcastUserDeactivate *broadcast.Broadcast[*model.User]
+	broadcastCommentAdded   *broadcast.Broadcast[*model.Comment]
+
+	signInChallenges  *signInChallenges
+	twoFactorFailures *twoFactorFailures
+
+	accessChangeLock      sync.Mutex
+	accessChangeObservers []func()
 }
 
 func NewResolver(
@@ -30,24 +43,80 @@ func NewResolver(
 	jWTGenerator JWTGenerator,
 	passwordHasher PasswordHasher,
 	timeProvider TimeProvider,
+	broadcastOptions broadcast.Options,
 ) *Resolver {
 	return &Resolver{
-		DataProvider:           dataProvider,
-		JWTGenerator:           jWTGenerator,
-		PasswordHasher:         passwordHasher,
-		TimeProvider:           timeProvider,
-		broadcastTaskUpsert:    broadcast.New[*model.Task](),
-		broadcastProjectUpsert: broadcast.New[*model.Project](),
+		DataProvider:            dataProvider,
+		JWTGenerator:            jWTGenerator,
+		PasswordHasher:          passwordHasher,
+		TimeProvider:            timeProvider,
+		broadcastTaskUpsert:     broadcast.New[*model.Task](broadcastOptions),
+		broadcastTaskDelete:     broadcast.New[*model.Task](broadcastOptions),
+		broadcastProjectUpsert:  broadcast.New[*model.Project](broadcastOptions),
+		broadcastUserDeactivate: broadcast.New[*model.User](broadcastOptions),
+		broadcastCommentAdded:   broadcast.New[*model.Comment](broadcastOptions),
+		signInChallenges:        newSignInChallenges(),
+		twoFactorFailures:       newTwoFactorFailures(),
+	}
+}
+
+// BroadcastStats returns the metrics of all subscription
+// broadcasts by subscription name.
+func (r *Resolver) BroadcastStats() map[string]broadcast.Stats {
+	return map[string]broadcast.Stats{
+		"taskUpsert":     r.broadcastTaskUpsert.Stats(),
+		"taskDelete":     r.broadcastTaskDelete.Stats(),
+		"projectUpsert":  r.broadcastProjectUpsert.Stats(),
+		"userDeactivate": r.broadcastUserDeactivate.Stats(),
+		"commentAdded":   r.broadcastCommentAdded.Stats(),
+	}
+}
+
+// OnTaskOrProjectChange registers fn to be called synchronously
+// whenever a task or project is created, updated or deleted.
+func (r *Resolver) OnTaskOrProjectChange(fn func()) {
+	r.broadcastTaskUpsert.Observe(func(*model.Task) { fn() })
+	r.broadcastTaskDelete.Observe(func(*model.Task) { fn() })
+	r.broadcastProjectUpsert.Observe(func(*model.Project) { fn() })
+}
+
+// OnAccessChange registers fn to be called synchronously whenever
+// what users are allowed to access might have changed, which is when
+// project roles are granted or revoked, admins are appointed or dismissed,
+// users are deactivated, API keys are revoked, two-factor authentication
+// is disabled or the organization changes whether it requires it.
+func (r *Resolver) OnAccessChange(fn func()) {
+	r.accessChangeLock.Lock()
+	defer r.accessChangeLock.Unlock()
+	r.accessChangeObservers = append(r.accessChangeObservers, fn)
+}
+
+// notifyAccessChange calls all functions registered by OnAccessChange.
+func (r *Resolver) notifyAccessChange() {
+	r.accessChangeLock.Lock()
+	defer r.accessChangeLock.Unlock()
+	for _, fn := range r.accessChangeObservers {
+		fn()
 	}
 }
 
+// DefaultPageSize is the number of elements returned by
+// paginated fields if neither first nor last is specified.
+const DefaultPageSize = 10
+
+// MaxPageSize is the maximum number of elements
+// paginated fields return per page.
+const MaxPageSize = 100
+
 type Subscriber[T any] interface {
 	Notify(context.Context, T) error
 	Subscribe(context.Context) (<-chan T, error)
 }
 
 type JWTGenerator interface {
-	GenerateJWT(userID string, expiration time.Duration) (string, error)
+	GenerateJWT(
+		userID, sessionID string, issuedAt time.Time, expiration time.Duration,
+	) (string, error)
 }
 type PasswordHasher interface {
 	HashPassword(plainText []byte) (hash string, err error)
@@ -57,3 +126,143 @@ type PasswordHasher interface {
 type TimeProvider interface {
 	Now() time.Time
 }
+
+// usersByRef resolves the given user references through the data provider.
+// Data providers may return relations as shallow references carrying
+// only the ID.
+func (r *Resolver) usersByRef(
+	ctx context.Context, refs []*model.User,
+) ([]*model.User, error) {
+	if refs == nil {
+		return nil, nil
+	}
+	users := make([]*model.User, len(refs))
+	for i, ref := range refs {
+		u, err := r.DataProvider.UserByID(ctx, ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		users[i] = u
+	}
+	return users, nil
+}
+
+// tasksByRef resolves the given task references through the data provider.
+// Data providers may return relations as shallow references carrying
+// only the ID.
+func (r *Resolver) tasksByRef(
+	ctx context.Context, refs []*model.Task,
+) ([]*model.Task, error) {
+	if refs == nil {
+		return nil, nil
+	}
+	tasks := make([]*model.Task, 0, len(refs))
+	for _, ref := range refs {
+		t, err := r.DataProvider.TaskByID(ctx, ref.ID)
+		if errors.Is(err, auth.ErrUnauthorized) {
+			// Tasks of projects the client isn't allowed to view are hidden.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+// canViewProject returns true if the client is allowed
+// to view the project with the given ID.
+func (r *Resolver) canViewProject(ctx context.Context, id string) bool {
+	_, err := r.DataProvider.ProjectByID(ctx, id)
+	return err == nil
+}
+
+// filter forwards the values received from c that match to
+// the returned channel, which is closed once c is closed.
+// Values are discarded once ctx is canceled.
+func filter[T any](
+	ctx context.Context, c <-chan T, match func(T) bool,
+) <-chan T {
+	r := make(chan T, 1)
+	go func() {
+		defer close(r)
+		for x := range c {
+			if !match(x) {
+				continue
+			}
+			select {
+			case r <- x:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return r
+}
+
+// mapValues forwards fn applied to the values received from c to
+// the returned channel, which is closed once c is closed.
+// Values are discarded once ctx is canceled.
+func mapValues[T, U any](
+	ctx context.Context, c <-chan T, fn func(T) U,
+) <-chan U {
+	r := make(chan U, 1)
+	go func() {
+		defer close(r)
+		for x := range c {
+			select {
+			case r <- fn(x):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return r
+}
+
+// newPage parses the arguments of a paginated field ordered by order.
+func newPage[O ~string](
+	order *O, orderAsc bool,
+	first *int, after *string, last *int, before *string,
+) (page dataprovider.Page, err error) {
+	switch {
+	case first != nil && last != nil:
+		return page, errors.New("first and last are mutually exclusive")
+	case first != nil && *first < 0:
+		return page, errors.New("first must not be negative")
+	case last != nil && *last < 0:
+		return page, errors.New("last must not be negative")
+	case first != nil && *first > MaxPageSize:
+		return page, fmt.Errorf("first must not exceed %d", MaxPageSize)
+	case last != nil && *last > MaxPageSize:
+		return page, fmt.Errorf("last must not exceed %d", MaxPageSize)
+	case first == nil && last == nil:
+		first = new(int)
+		*first = DefaultPageSize
+	}
+	page.First, page.Last = first, last
+
+	var orderName string
+	if order != nil {
+		orderName = string(*order)
+	}
+	parse := func(s *string) (*dataprovider.Cursor, error) {
+		if s == nil {
+			return nil, nil
+		}
+		c, err := dataprovider.ParseCursor(*s)
+		if err != nil {
+			return nil, err
+		}
+		if c.Order != orderName || c.Desc == orderAsc {
+			return nil, errors.New("cursor doesn't match the order")
+		}
+		return c, nil
+	}
+	if page.After, err = parse(after); err != nil {
+		return page, err
+	}
+	if page.Before, err = parse(before); err != nil {
+		return page, err
+	}
+	return page, nil
+}
@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPage(t *testing.T) {
+	priority := model.TasksOrderPriority
+	cursor := func(c dataprovider.Cursor) *string {
+		s := c.Encode()
+		return &s
+	}
+	for _, td := range []struct {
+		name        string
+		order       *model.TasksOrder
+		orderAsc    bool
+		first       *int
+		after       *string
+		last        *int
+		before      *string
+		expectFirst *int
+		expectErr   string
+	}{
+		{
+			name:        "default_page_size",
+			orderAsc:    true,
+			expectFirst: ptr(DefaultPageSize),
+		},
+		{
+			name:        "max_page_size",
+			orderAsc:    true,
+			first:       ptr(MaxPageSize),
+			expectFirst: ptr(MaxPageSize),
+		},
+		{
+			name:      "first_exceeds_max",
+			orderAsc:  true,
+			first:     ptr(MaxPageSize + 1),
+			expectErr: "first must not exceed 100",
+		},
+		{
+			name:      "last_exceeds_max",
+			orderAsc:  true,
+			last:      ptr(MaxPageSize + 1),
+			expectErr: "last must not exceed 100",
+		},
+		{
+			name:      "first_and_last",
+			orderAsc:  true,
+			first:     ptr(1),
+			last:      ptr(1),
+			expectErr: "first and last are mutually exclusive",
+		},
+		{
+			name:     "cursor",
+			order:    &priority,
+			orderAsc: false,
+			after: cursor(dataprovider.Cursor{
+				Order: "PRIORITY", Desc: true, ID: "x",
+			}),
+			expectFirst: ptr(DefaultPageSize),
+		},
+		{
+			name:      "cursor_order_mismatch",
+			orderAsc:  true,
+			after:     cursor(dataprovider.Cursor{Order: "PRIORITY", ID: "x"}),
+			expectErr: "cursor doesn't match the order",
+		},
+		{
+			name:      "cursor_direction_mismatch",
+			order:     &priority,
+			orderAsc:  false,
+			after:     cursor(dataprovider.Cursor{Order: "PRIORITY", ID: "x"}),
+			expectErr: "cursor doesn't match the order",
+		},
+		{
+			name:      "cursor_direction_mismatch_before",
+			orderAsc:  true,
+			before:    cursor(dataprovider.Cursor{Desc: true, ID: "x"}),
+			expectErr: "cursor doesn't match the order",
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			page, err := newPage(
+				td.order, td.orderAsc, td.first, td.after, td.last, td.before,
+			)
+			if td.expectErr != "" {
+				require.EqualError(t, err, td.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, td.expectFirst, page.First)
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
+)
+
+const (
+	// AccessTokenLifetime is the time access tokens are valid for.
+	AccessTokenLifetime = 15 * time.Minute
+
+	// RefreshTokenLifetime is the time sessions are valid for
+	// after they were created or last refreshed.
+	RefreshTokenLifetime = 30 * 24 * time.Hour
+
+	// LegacyAccessTokenLifetime is the time access tokens issued by
+	// the deprecated Query.accessToken are valid for.
+	LegacyAccessTokenLifetime = 24 * time.Hour
+)
+
+// signIn returns the user identified by email and password.
+// Returns auth.ErrUnauthorized if the password doesn't match
+// or the user is deactivated.
+func (r *Resolver) signIn(
+	ctx context.Context, email, password string,
+) (*model.User, error) {
+	user, err := r.DataProvider.UserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := r.PasswordHasher.ComparePassword(
+		[]byte(password), []byte(user.PasswordHash),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || user.Deactivated != nil {
+		return nil, auth.ErrUnauthorized
+	}
+	return user, nil
+}
+
+// createSession creates a session for the user that expires after lifetime
+// and returns an access token and a refresh token for it.
+func (r *Resolver) createSession(
+	ctx context.Context, userID string, lifetime time.Duration,
+) (*model.AuthTokens, error) {
+	secret, hash, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := r.TimeProvider.Now()
+	session, err := r.DataProvider.CreateSession(
+		ctx, now, userID, reqctx.GetRequestContext(ctx).UserAgent,
+		hash, now.Add(lifetime),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.issueTokens(session, secret, now)
+}
+
+// refreshSession rotates the refresh token of the session it refers to
+// and returns a new access token and refresh token.
+func (r *Resolver) refreshSession(
+	ctx context.Context, refreshToken string,
+) (*model.AuthTokens, error) {
+	sessionID, secret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return nil, dataprovider.ErrRefreshTokenInvalid
+	}
+	newSecret, newHash, err := newRefreshSecret()
+	if err != nil {
+		return nil, err
+	}
+	now := r.TimeProvider.Now()
+	session, err := r.DataProvider.RotateRefreshToken(
+		ctx, sessionID, hashRefreshSecret(secret), newHash,
+		now, now.Add(RefreshTokenLifetime),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return r.issueTokens(session, newSecret, now)
+}
+
+func (r *Resolver) issueTokens(
+	session *model.Session, refreshSecret string, now time.Time,
+) (*model.AuthTokens, error) {
+	accessToken, err := r.JWTGenerator.GenerateJWT(
+		session.User.ID, session.ID, now, AccessTokenLifetime,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AuthTokens{
+		AccessToken:        accessToken,
+		AccessTokenExpires: now.Add(AccessTokenLifetime),
+		RefreshToken:       session.ID + "." + refreshSecret,
+		Session:            session,
+	}, nil
+}
+
+// newRefreshSecret returns a new random refresh token secret
+// and its hash. Only the hash is stored.
+func newRefreshSecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generating refresh token: %w", err)
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return secret, hashRefreshSecret(secret), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/slices"
+)
+
+// taskUpsertFilter selects the tasks delivered to a taskUpsert subscriber.
+// Nil fields don't filter.
+type taskUpsertFilter struct {
+	Projects []string
+	Assignee *string
+	Reporter *string
+	Tags     []string
+	Status   []model.TaskStatus
+}
+
+// Match returns true if t matches all filters.
+func (f taskUpsertFilter) Match(t *model.Task) bool {
+	if f.Projects != nil && !slices.Contains(f.Projects, t.Project.ID) {
+		return false
+	}
+	if f.Assignee != nil &&
+		!slices.IsSubsetGet([]string{*f.Assignee}, t.Assignees, getUserID) {
+		return false
+	}
+	if f.Reporter != nil &&
+		!slices.IsSubsetGet([]string{*f.Reporter}, t.Reporters, getUserID) {
+		return false
+	}
+	if f.Status != nil && !slices.Contains(f.Status, t.Status) {
+		return false
+	}
+	return slices.IsSubset(f.Tags, t.Tags)
+}
+
+// projectUpsertFilter selects the projects delivered
+// to a projectUpsert subscriber. Nil fields don't filter.
+type projectUpsertFilter struct {
+	Projects []string
+}
+
+// Match returns true if p matches all filters.
+func (f projectUpsertFilter) Match(p *model.Project) bool {
+	return f.Projects == nil || slices.Contains(f.Projects, p.ID)
+}
+
+func getUserID(u *model.User) string { return u.ID }
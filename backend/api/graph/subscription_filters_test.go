@@ -0,0 +1,135 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"github.com/romshark/taskhub/api/graph/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskUpsertFilter(t *testing.T) {
+	task := &model.Task{
+		ID:        "t",
+		Status:    model.TaskStatusInProgress,
+		Tags:      []string{"backend", "frontend"},
+		Project:   &model.Project{ID: "p1"},
+		Assignees: []*model.User{{ID: "u1"}, {ID: "u2"}},
+		Reporters: []*model.User{{ID: "u3"}},
+	}
+	ptr := func(s string) *string { return &s }
+
+	for _, td := range []struct {
+		name   string
+		filter taskUpsertFilter
+		expect bool
+	}{
+		{name: "none", expect: true},
+		{
+			name:   "projects_any_of",
+			filter: taskUpsertFilter{Projects: []string{"p2", "p1"}},
+			expect: true,
+		},
+		{
+			name:   "projects_mismatch",
+			filter: taskUpsertFilter{Projects: []string{"p2"}},
+		},
+		{
+			name:   "projects_empty",
+			filter: taskUpsertFilter{Projects: []string{}},
+		},
+		{
+			name:   "assignee",
+			filter: taskUpsertFilter{Assignee: ptr("u2")},
+			expect: true,
+		},
+		{
+			name:   "assignee_mismatch",
+			filter: taskUpsertFilter{Assignee: ptr("u3")},
+		},
+		{
+			name:   "reporter",
+			filter: taskUpsertFilter{Reporter: ptr("u3")},
+			expect: true,
+		},
+		{
+			name:   "reporter_mismatch",
+			filter: taskUpsertFilter{Reporter: ptr("u1")},
+		},
+		{
+			name:   "tags_all_of",
+			filter: taskUpsertFilter{Tags: []string{"frontend", "backend"}},
+			expect: true,
+		},
+		{
+			name:   "tags_mismatch",
+			filter: taskUpsertFilter{Tags: []string{"backend", "design"}},
+		},
+		{
+			name: "status_any_of",
+			filter: taskUpsertFilter{Status: []model.TaskStatus{
+				model.TaskStatusTodo, model.TaskStatusInProgress,
+			}},
+			expect: true,
+		},
+		{
+			name: "status_mismatch",
+			filter: taskUpsertFilter{
+				Status: []model.TaskStatus{model.TaskStatusDone},
+			},
+		},
+		{
+			name: "all",
+			filter: taskUpsertFilter{
+				Projects: []string{"p1"},
+				Assignee: ptr("u1"),
+				Reporter: ptr("u3"),
+				Tags:     []string{"backend"},
+				Status:   []model.TaskStatus{model.TaskStatusInProgress},
+			},
+			expect: true,
+		},
+		{
+			name: "all_but_one",
+			filter: taskUpsertFilter{
+				Projects: []string{"p1"},
+				Assignee: ptr("u1"),
+				Reporter: ptr("u2"),
+				Tags:     []string{"backend"},
+				Status:   []model.TaskStatus{model.TaskStatusInProgress},
+			},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			require.Equal(t, td.expect, td.filter.Match(task))
+		})
+	}
+}
+
+func TestProjectUpsertFilter(t *testing.T) {
+	p := &model.Project{ID: "p1"}
+	require.True(t, projectUpsertFilter{}.Match(p))
+	require.True(t, projectUpsertFilter{Projects: []string{"p2", "p1"}}.Match(p))
+	require.False(t, projectUpsertFilter{Projects: []string{"p2"}}.Match(p))
+}
+
+func TestFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := make(chan int)
+	f := filter(ctx, c, func(x int) bool { return x%2 == 0 })
+	go func() {
+		for i := 1; i <= 4; i++ {
+			c <- i
+		}
+		close(c)
+	}()
+
+	var received []int
+	for x := range f {
+		received = append(received, x)
+	}
+	require.Equal(t, []int{2, 4}, received)
+}
@@ -15,27 +15,87 @@ import (
 )
 
 // TaskUpsert is the resolver for the taskUpsert field.
-func (r *subscriptionResolver) TaskUpsert(ctx context.Context) (<-chan *model.Task, error) {
+func (r *subscriptionResolver) TaskUpsert(ctx context.Context, projects []string, assignee *string, reporter *string, tags []string, status []model.TaskStatus) (<-chan *model.Task, error) {
 	if err := auth.RequireAuthenticated(ctx); err != nil {
 		return nil, err
 	}
+	f := taskUpsertFilter{
+		Projects: projects,
+		Assignee: assignee,
+		Reporter: reporter,
+		Tags:     tags,
+		Status:   status,
+	}
 	c := make(chan *model.Task, 1)
 	r.broadcastTaskUpsert.Subscribe(ctx, c)
 	go logSubscriptionTermination(ctx, "taskUpsert")
-	return c, nil
+	return filter(ctx, c, func(t *model.Task) bool {
+		return f.Match(t) && r.canViewProject(ctx, t.Project.ID)
+	}), nil
 }
 
 // ProjectUpsert is the resolver for the projectUpsert field.
-func (r *subscriptionResolver) ProjectUpsert(ctx context.Context) (<-chan *model.Project, error) {
+func (r *subscriptionResolver) ProjectUpsert(ctx context.Context, projects []string) (<-chan *model.Project, error) {
 	if err := auth.RequireAuthenticated(ctx); err != nil {
 		return nil, err
 	}
+	f := projectUpsertFilter{Projects: projects}
 	c := make(chan *model.Project, 1)
 	r.broadcastProjectUpsert.Subscribe(ctx, c)
 	go logSubscriptionTermination(ctx, "projectUpsert")
+	return filter(ctx, c, func(p *model.Project) bool {
+		return f.Match(p) && r.canViewProject(ctx, p.ID)
+	}), nil
+}
+
+// TaskDelete is the resolver for the taskDelete field.
+func (r *subscriptionResolver) TaskDelete(ctx context.Context) (<-chan string, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	c := make(chan *model.Task, 1)
+	r.broadcastTaskDelete.Subscribe(ctx, c)
+	go logSubscriptionTermination(ctx, "taskDelete")
+	deleted := filter(ctx, c, func(t *model.Task) bool {
+		return r.canViewProject(ctx, t.Project.ID)
+	})
+	return mapValues(ctx, deleted, func(t *model.Task) string {
+		return t.ID
+	}), nil
+}
+
+// UserDeactivate is the resolver for the userDeactivate field.
+func (r *subscriptionResolver) UserDeactivate(ctx context.Context) (<-chan *model.User, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	c := make(chan *model.User, 1)
+	r.broadcastUserDeactivate.Subscribe(ctx, c)
+	go logSubscriptionTermination(ctx, "userDeactivate")
 	return c, nil
 }
 
+// CommentAdded is the resolver for the commentAdded field.
+func (r *subscriptionResolver) CommentAdded(ctx context.Context, taskID string) (<-chan *model.Comment, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := r.DataProvider.TaskByID(ctx, taskID); err != nil {
+		return nil, err
+	}
+	c := make(chan *model.Comment, 1)
+	r.broadcastCommentAdded.Subscribe(ctx, c)
+	go logSubscriptionTermination(ctx, "commentAdded")
+	return filter(ctx, c, func(c *model.Comment) bool {
+		if c.Task.ID != taskID {
+			return false
+		}
+		// The client might have lost its role since subscribing.
+		_, err := r.DataProvider.TaskByID(ctx, taskID)
+		return err == nil
+	}), nil
+}
+
 // Subscription returns SubscriptionResolver implementation.
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
@@ -0,0 +1,69 @@
+package graph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/broadcast"
+	"github.com/romshark/taskhub/api/dataprovider/inmem"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/jwt"
+	"github.com/romshark/taskhub/api/reqctx"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+// TestSubscriptionsRequireView makes sure that events of projects
+// are only delivered to subscribers allowed to view them.
+func TestSubscriptionsRequireView(t *testing.T) {
+	p := &inmem.Inmem{Users: []*model.User{
+		{ID: "owner", Email: "owner@test.com", DisplayName: "Owner"},
+		{ID: "stranger", Email: "stranger@test.com", DisplayName: "Stranger"},
+	}}
+	r := NewResolver(
+		p, jwt.NewJWTGenerator(jwt.NewKeySetHS256([]byte("secret"))),
+		plainHasher{}, &testClock{now: time.Now()}, broadcast.Options{},
+	)
+	asUser := func(userID string) (context.Context, context.CancelFunc) {
+		return context.WithCancel(reqctx.WithRequestContext(
+			context.Background(), slog.Default(), userID, "", time.Now(),
+		))
+	}
+	owner, cancelOwner := asUser("owner")
+	defer cancelOwner()
+	stranger, cancelStranger := asUser("stranger")
+	defer cancelStranger()
+
+	project, err := r.Mutation().CreateProject(owner, "Project", "", "PROJ", nil)
+	require.NoError(t, err)
+
+	s := r.Subscription()
+	ownerUpserts, err := s.TaskUpsert(owner, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	strangerUpserts, err := s.TaskUpsert(stranger, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	ownerDeletes, err := s.TaskDelete(owner)
+	require.NoError(t, err)
+	strangerDeletes, err := s.TaskDelete(stranger)
+	require.NoError(t, err)
+
+	task, err := r.Mutation().CreateTask(
+		owner, "Task", project.ID, model.TaskStatusTodo, model.TaskPriorityLow,
+		nil, nil, nil, nil, nil, nil, nil,
+	)
+	require.NoError(t, err)
+	_, err = r.Mutation().DeleteTask(owner, task.ID)
+	require.NoError(t, err)
+
+	require.Equal(t, task.ID, (<-ownerUpserts).ID)
+	require.Equal(t, task.ID, <-ownerDeletes)
+	select {
+	case x := <-strangerUpserts:
+		t.Fatalf("unexpected task upsert: %#v", x)
+	case x := <-strangerDeletes:
+		t.Fatalf("unexpected task delete: %q", x)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,324 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/api/totp"
+)
+
+const (
+	// TwoFactorIssuer is the issuer authenticator apps display
+	// next to the codes of enrolled users.
+	TwoFactorIssuer = "TaskHub"
+
+	// SignInChallengeLifetime is the time a sign-in challenge
+	// can be completed within.
+	SignInChallengeLifetime = 5 * time.Minute
+
+	// SignInChallengeMaxAttempts is the number of codes a sign-in
+	// challenge can be completed with before it's invalidated.
+	SignInChallengeMaxAttempts = 5
+
+	// MaxPendingSignInChallenges is the number of sign-in challenges
+	// a user can have pending at once. Creating another one
+	// invalidates the one that expires first.
+	MaxPendingSignInChallenges = 3
+
+	// TwoFactorMaxFailures is the number of invalid two-factor codes
+	// a user can submit within TwoFactorFailureWindow across all
+	// sign-in challenges and sessions before being locked out
+	// until the window ends.
+	TwoFactorMaxFailures = 10
+
+	// TwoFactorFailureWindow is the time failed two-factor code
+	// verifications are counted within, starting with the first failure.
+	TwoFactorFailureWindow = 15 * time.Minute
+
+	// RecoveryCodes is the number of recovery codes issued at once.
+	RecoveryCodes = 10
+)
+
+var (
+	// ErrTwoFactorRequired is returned when signing in with only
+	// the password of a user that enabled two-factor authentication.
+	ErrTwoFactorRequired = errors.New(
+		"two-factor authentication enabled, use beginSignIn",
+	)
+
+	// ErrSignInChallengeInvalid is returned for sign-in challenges that
+	// don't exist, expired or exceeded SignInChallengeMaxAttempts.
+	ErrSignInChallengeInvalid = errors.New("sign-in challenge invalid")
+
+	// ErrTwoFactorCodeInvalid is returned for TOTP codes and
+	// recovery codes that don't match.
+	ErrTwoFactorCodeInvalid = errors.New("two-factor code invalid")
+
+	// ErrTwoFactorLocked is returned for users that exceeded
+	// TwoFactorMaxFailures within TwoFactorFailureWindow.
+	ErrTwoFactorLocked = errors.New(
+		"too many invalid two-factor codes, try again later",
+	)
+)
+
+// signInChallenges keeps the pending sign-in challenges of users
+// that passed the password check. Only hashes of challenge tokens
+// are kept.
+type signInChallenges struct {
+	lock   sync.Mutex
+	byHash map[string]*signInChallenge
+}
+
+type signInChallenge struct {
+	userID   string
+	expires  time.Time
+	attempts int
+}
+
+func newSignInChallenges() *signInChallenges {
+	return &signInChallenges{byHash: map[string]*signInChallenge{}}
+}
+
+// Create creates a challenge for the given user and returns its token.
+func (c *signInChallenges) Create(
+	userID string, now time.Time,
+) (*model.SignInChallenge, error) {
+	token, hash, err := newRefreshSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generating sign-in challenge: %w", err)
+	}
+	expires := now.Add(SignInChallengeLifetime)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var pending int
+	var first string
+	for h, x := range c.byHash {
+		switch {
+		case !now.Before(x.expires):
+			delete(c.byHash, h)
+		case x.userID == userID:
+			pending++
+			if first == "" || x.expires.Before(c.byHash[first].expires) {
+				first = h
+			}
+		}
+	}
+	if pending >= MaxPendingSignInChallenges {
+		delete(c.byHash, first)
+	}
+	c.byHash[hash] = &signInChallenge{userID: userID, expires: expires}
+	return &model.SignInChallenge{Token: token, Expires: expires}, nil
+}
+
+// Attempt counts an attempt to complete the challenge identified by token
+// and returns the ID of its user. Returns ok=false if the challenge
+// doesn't exist, expired or exceeded SignInChallengeMaxAttempts.
+func (c *signInChallenges) Attempt(
+	token string, now time.Time,
+) (userID string, ok bool) {
+	hash := hashRefreshSecret(token)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	x := c.byHash[hash]
+	if x == nil {
+		return "", false
+	}
+	if !now.Before(x.expires) || x.attempts >= SignInChallengeMaxAttempts {
+		delete(c.byHash, hash)
+		return "", false
+	}
+	x.attempts++
+	return x.userID, true
+}
+
+// Remove removes the challenge identified by token.
+func (c *signInChallenges) Remove(token string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.byHash, hashRefreshSecret(token))
+}
+
+// twoFactorFailures counts the invalid two-factor codes of users
+// across all their sign-in challenges and sessions.
+type twoFactorFailures struct {
+	lock   sync.Mutex
+	byUser map[string]*failureWindow
+}
+
+type failureWindow struct {
+	start time.Time
+	count int
+}
+
+func newTwoFactorFailures() *twoFactorFailures {
+	return &twoFactorFailures{byUser: map[string]*failureWindow{}}
+}
+
+// Locked returns true if the given user exceeded TwoFactorMaxFailures
+// within the current window.
+func (f *twoFactorFailures) Locked(userID string, now time.Time) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w := f.byUser[userID]
+	return w != nil && now.Before(w.start.Add(TwoFactorFailureWindow)) &&
+		w.count >= TwoFactorMaxFailures
+}
+
+// Fail counts an invalid code of the given user.
+func (f *twoFactorFailures) Fail(userID string, now time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for id, w := range f.byUser {
+		if !now.Before(w.start.Add(TwoFactorFailureWindow)) {
+			delete(f.byUser, id)
+		}
+	}
+	w := f.byUser[userID]
+	if w == nil {
+		w = &failureWindow{start: now}
+		f.byUser[userID] = w
+	}
+	w.count++
+}
+
+// Reset forgets the failures of the given user.
+func (f *twoFactorFailures) Reset(userID string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.byUser, userID)
+}
+
+// completeSignIn creates a session for the user of the given challenge
+// if code is a valid TOTP code or recovery code of the user.
+func (r *Resolver) completeSignIn(
+	ctx context.Context, challengeToken, code string,
+) (*model.AuthTokens, error) {
+	userID, ok := r.signInChallenges.Attempt(
+		challengeToken, r.TimeProvider.Now(),
+	)
+	if !ok {
+		return nil, ErrSignInChallengeInvalid
+	}
+	user, err := r.DataProvider.UserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Deactivated != nil {
+		r.signInChallenges.Remove(challengeToken)
+		return nil, auth.ErrUnauthorized
+	}
+	if err := r.verifyTwoFactorCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+	r.signInChallenges.Remove(challengeToken)
+	return r.createSession(ctx, user.ID, RefreshTokenLifetime)
+}
+
+// twoFactorUser returns the user the client is authenticated as.
+// Managing two-factor authentication requires API keys
+// to have the ADMIN scope.
+func (r *Resolver) twoFactorUser(ctx context.Context) (*model.User, error) {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+	if err := auth.RequireScope(ctx, model.APIKeyScopeAdmin); err != nil {
+		return nil, err
+	}
+	return r.DataProvider.UserByID(ctx, reqctx.GetRequestContext(ctx).UserID)
+}
+
+// verifyTwoFactorCode returns nil if code is a TOTP code of user that
+// wasn't used before or one of its remaining recovery codes, which is
+// used up. Returns ErrTwoFactorCodeInvalid if code doesn't match and
+// ErrTwoFactorLocked if the user submitted too many invalid codes.
+func (r *Resolver) verifyTwoFactorCode(
+	ctx context.Context, user *model.User, code string,
+) error {
+	if !user.TwoFactorEnabled {
+		return errors.New("two-factor authentication not enabled")
+	}
+	now := r.TimeProvider.Now()
+	if r.twoFactorFailures.Locked(user.ID, now) {
+		return ErrTwoFactorLocked
+	}
+	err := r.checkTwoFactorCode(ctx, user, code)
+	switch {
+	case errors.Is(err, ErrTwoFactorCodeInvalid),
+		errors.Is(err, dataprovider.ErrTwoFactorCodeUsed):
+		r.twoFactorFailures.Fail(user.ID, now)
+	case err == nil:
+		r.twoFactorFailures.Reset(user.ID)
+	}
+	return err
+}
+
+// checkTwoFactorCode implements verifyTwoFactorCode
+// without counting failures.
+func (r *Resolver) checkTwoFactorCode(
+	ctx context.Context, user *model.User, code string,
+) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, r.TimeProvider.Now())
+		if !ok {
+			return ErrTwoFactorCodeInvalid
+		}
+		return r.DataProvider.UseTOTPStep(ctx, user.ID, step)
+	}
+
+	code = normalizeRecoveryCode(code)
+	for _, h := range user.RecoveryCodeHashes {
+		ok, _ := r.PasswordHasher.ComparePassword([]byte(code), []byte(h))
+		if !ok {
+			continue
+		}
+		err := r.DataProvider.UseRecoveryCode(ctx, user.ID, h)
+		if errors.Is(err, dataprovider.ErrTwoFactorCodeUsed) {
+			// Used concurrently.
+			return ErrTwoFactorCodeInvalid
+		}
+		return err
+	}
+	return ErrTwoFactorCodeInvalid
+}
+
+// newRecoveryCodes returns RecoveryCodes new random recovery codes
+// and their hashes. Only the hashes are stored.
+func (r *Resolver) newRecoveryCodes() (codes, hashes []string, err error) {
+	codes = make([]string, RecoveryCodes)
+	hashes = make([]string, RecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+		s := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+		h, err := r.PasswordHasher.HashPassword(
+			[]byte(normalizeRecoveryCode(codes[i])),
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("hashing recovery code: %w", err)
+		}
+		hashes[i] = h
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode returns code without separators
+// and surrounding space in lower case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
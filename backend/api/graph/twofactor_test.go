@@ -0,0 +1,203 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/broadcast"
+	"github.com/romshark/taskhub/api/dataprovider/inmem"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/jwt"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/api/totp"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestSignInChallenges(t *testing.T) {
+	c := newSignInChallenges()
+	now := time.Now()
+
+	ch, err := c.Create("u1", now)
+	require.NoError(t, err)
+	require.NotEmpty(t, ch.Token)
+	require.Equal(t, now.Add(SignInChallengeLifetime), ch.Expires)
+
+	_, ok := c.Attempt("unknown", now)
+	require.False(t, ok)
+
+	for i := 0; i < SignInChallengeMaxAttempts; i++ {
+		userID, ok := c.Attempt(ch.Token, now)
+		require.True(t, ok, i)
+		require.Equal(t, "u1", userID)
+	}
+	_, ok = c.Attempt(ch.Token, now)
+	require.False(t, ok, "expected max attempts exceeded")
+
+	ch, err = c.Create("u2", now)
+	require.NoError(t, err)
+	_, ok = c.Attempt(ch.Token, ch.Expires)
+	require.False(t, ok, "expected expired")
+
+	ch, err = c.Create("u3", now)
+	require.NoError(t, err)
+	c.Remove(ch.Token)
+	_, ok = c.Attempt(ch.Token, now)
+	require.False(t, ok, "expected removed")
+}
+
+func TestSignInChallengesPerUser(t *testing.T) {
+	c := newSignInChallenges()
+	now := time.Now()
+
+	var tokens []string
+	for i := 0; i < MaxPendingSignInChallenges+1; i++ {
+		ch, err := c.Create("u1", now.Add(time.Duration(i)*time.Second))
+		require.NoError(t, err)
+		tokens = append(tokens, ch.Token)
+	}
+	other, err := c.Create("u2", now)
+	require.NoError(t, err)
+
+	_, ok := c.Attempt(tokens[0], now)
+	require.False(t, ok, "expected the oldest challenge invalidated")
+	for _, token := range append(tokens[1:], other.Token) {
+		_, ok := c.Attempt(token, now)
+		require.True(t, ok)
+	}
+}
+
+func TestTwoFactorFailures(t *testing.T) {
+	f := newTwoFactorFailures()
+	now := time.Now()
+
+	for i := 0; i < TwoFactorMaxFailures; i++ {
+		require.False(t, f.Locked("u1", now), i)
+		f.Fail("u1", now.Add(time.Duration(i)*time.Second))
+	}
+	require.True(t, f.Locked("u1", now))
+	require.False(t, f.Locked("u2", now))
+	require.False(t, f.Locked("u1", now.Add(TwoFactorFailureWindow)),
+		"expected the lock to end with the window")
+
+	f.Fail("u2", now)
+	f.Reset("u2")
+	for i := 0; i < TwoFactorMaxFailures-1; i++ {
+		f.Fail("u2", now)
+	}
+	require.False(t, f.Locked("u2", now), "expected reset")
+}
+
+// TestCompleteSignInLockout makes sure that invalid codes are counted
+// per user rather than per challenge, so requesting new challenges
+// doesn't allow for brute-forcing codes.
+func TestCompleteSignInLockout(t *testing.T) {
+	secret, err := totp.NewSecret()
+	require.NoError(t, err)
+	p := &inmem.Inmem{Users: []*model.User{{
+		ID: "u1", Email: "u1@test.com", DisplayName: "User One",
+		PasswordHash: "password", TwoFactorEnabled: true, TOTPSecret: secret,
+	}}}
+	clock := &testClock{now: time.Now()}
+	r := NewResolver(
+		p, jwt.NewJWTGenerator(jwt.NewKeySetHS256([]byte("secret"))),
+		plainHasher{}, clock, broadcast.Options{},
+	)
+	m := r.Mutation()
+	ctx := reqctx.WithRequestContext(
+		context.Background(), slog.Default(), "", "", clock.now,
+	)
+	begin := func() (string, error) {
+		res, err := m.BeginSignIn(ctx, "u1@test.com", "password")
+		if err != nil {
+			return "", err
+		}
+		return res.Challenge.Token, nil
+	}
+
+	// Codes of 6 letters never match.
+	var failures int
+	var token string
+	for failures < TwoFactorMaxFailures {
+		token, err = begin()
+		require.NoError(t, err)
+		for i := 0; i < SignInChallengeMaxAttempts-1; i++ {
+			_, err := m.CompleteSignIn(ctx, token, "abcdef")
+			require.ErrorIs(t, err, ErrTwoFactorCodeInvalid)
+			if failures++; failures == TwoFactorMaxFailures {
+				break
+			}
+		}
+	}
+
+	// Even the valid code is rejected on a challenge with attempts left.
+	code, err := totp.Code(secret, totp.Step(clock.now))
+	require.NoError(t, err)
+	_, err = m.CompleteSignIn(ctx, token, code)
+	require.ErrorIs(t, err, ErrTwoFactorLocked)
+	_, err = begin()
+	require.ErrorIs(t, err, ErrTwoFactorLocked,
+		"expected new challenges refused while locked")
+
+	clock.now = clock.now.Add(TwoFactorFailureWindow)
+	token, err = begin()
+	require.NoError(t, err)
+	code, err = totp.Code(secret, totp.Step(clock.now))
+	require.NoError(t, err)
+	tokens, err := m.CompleteSignIn(ctx, token, code)
+	require.NoError(t, err)
+	require.Equal(t, "u1", tokens.Session.User.ID)
+}
+
+func TestSetTwoFactorRequiredNotifiesAccessChange(t *testing.T) {
+	p := &inmem.Inmem{Users: []*model.User{
+		{ID: "admin", Email: "admin@test.com", Admin: true},
+		{ID: "u1", Email: "u1@test.com"},
+	}}
+	r := NewResolver(
+		p, jwt.NewJWTGenerator(jwt.NewKeySetHS256([]byte("secret"))),
+		plainHasher{}, &testClock{now: time.Now()}, broadcast.Options{},
+	)
+	var notified int
+	r.OnAccessChange(func() { notified++ })
+	asUser := func(userID string) context.Context {
+		return reqctx.WithRequestContext(
+			context.Background(), slog.Default(), userID, "", time.Now(),
+		)
+	}
+
+	_, err := r.Mutation().SetTwoFactorRequired(asUser("u1"), true)
+	require.Error(t, err)
+	require.Zero(t, notified)
+
+	o, err := r.Mutation().SetTwoFactorRequired(asUser("admin"), true)
+	require.NoError(t, err)
+	require.True(t, o.TwoFactorRequired)
+	require.Equal(t, 1, notified)
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+// plainHasher doesn't hash at all to keep tests fast.
+type plainHasher struct{}
+
+func (plainHasher) HashPassword(plainText []byte) (string, error) {
+	return string(plainText), nil
+}
+
+func (plainHasher) ComparePassword(plainText, hash []byte) (bool, error) {
+	if string(plainText) != string(hash) {
+		return false, errors.New("mismatch")
+	}
+	return true, nil
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	require.Equal(t, "abcde12345", normalizeRecoveryCode(" ABCDE-12345\n"))
+	require.Equal(t, "abcde12345", normalizeRecoveryCode("abcde12345"))
+}
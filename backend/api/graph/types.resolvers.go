@@ -8,11 +8,49 @@ import (
 	"context"
 
 	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
 )
 
+// User is the resolver for the user field.
+func (r *aPIKeyResolver) User(ctx context.Context, obj *model.APIKey) (*model.User, error) {
+	return r.DataProvider.UserByID(ctx, obj.User.ID)
+}
+
+// Task is the resolver for the task field.
+func (r *commentResolver) Task(ctx context.Context, obj *model.Comment) (*model.Task, error) {
+	return r.DataProvider.TaskByID(ctx, obj.Task.ID)
+}
+
+// Author is the resolver for the author field.
+func (r *commentResolver) Author(ctx context.Context, obj *model.Comment) (*model.User, error) {
+	return r.DataProvider.UserByID(ctx, obj.Author.ID)
+}
+
+// Parent is the resolver for the parent field.
+func (r *commentResolver) Parent(ctx context.Context, obj *model.Comment) (*model.Comment, error) {
+	if obj.Parent == nil {
+		return nil, nil
+	}
+	return r.DataProvider.CommentByID(ctx, obj.Parent.ID)
+}
+
+// Replies is the resolver for the replies field.
+func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment) ([]*model.Comment, error) {
+	return r.DataProvider.GetCommentReplies(ctx, obj.ID)
+}
+
 // Tasks is the resolver for the tasks field.
-func (r *projectResolver) Tasks(ctx context.Context, obj *model.Project) ([]*model.Task, error) {
-	return r.DataProvider.GetTasksByProject(ctx, obj.ID)
+func (r *projectResolver) Tasks(ctx context.Context, obj *model.Project, order *model.TasksOrder, orderAsc bool, first *int, after *string, last *int, before *string) (*model.TaskConnection, error) {
+	page, err := newPage(order, orderAsc, first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+	return r.DataProvider.GetTasksByProject(ctx, obj.ID, order, orderAsc, page)
+}
+
+// Owners is the resolver for the owners field.
+func (r *projectResolver) Owners(ctx context.Context, obj *model.Project) ([]*model.User, error) {
+	return r.usersByRef(ctx, obj.Owners())
 }
 
 // Members is the resolver for the members field.
@@ -20,24 +58,78 @@ func (r *projectResolver) Members(ctx context.Context, obj *model.Project) ([]*m
 	return r.DataProvider.GetProjectMembers(ctx, obj.ID)
 }
 
+// User is the resolver for the user field.
+func (r *projectRoleGrantResolver) User(ctx context.Context, obj *model.ProjectRoleGrant) (*model.User, error) {
+	return r.DataProvider.UserByID(ctx, obj.User.ID)
+}
+
+// Current is the resolver for the current field.
+func (r *sessionResolver) Current(ctx context.Context, obj *model.Session) (bool, error) {
+	return obj.ID == reqctx.GetRequestContext(ctx).SessionID, nil
+}
+
+// Project is the resolver for the project field.
+func (r *taskResolver) Project(ctx context.Context, obj *model.Task) (*model.Project, error) {
+	return r.DataProvider.ProjectByID(ctx, obj.Project.ID)
+}
+
+// Assignees is the resolver for the assignees field.
+func (r *taskResolver) Assignees(ctx context.Context, obj *model.Task) ([]*model.User, error) {
+	return r.usersByRef(ctx, obj.Assignees)
+}
+
+// Reporters is the resolver for the reporters field.
+func (r *taskResolver) Reporters(ctx context.Context, obj *model.Task) ([]*model.User, error) {
+	return r.usersByRef(ctx, obj.Reporters)
+}
+
 // IsBlockedBy is the resolver for the isBlockedBy field.
 func (r *taskResolver) IsBlockedBy(ctx context.Context, obj *model.Task) ([]*model.Task, error) {
 	return r.DataProvider.GetBlockingTasks(ctx, obj.ID)
 }
 
+// Blocks is the resolver for the blocks field.
+func (r *taskResolver) Blocks(ctx context.Context, obj *model.Task) ([]*model.Task, error) {
+	return r.tasksByRef(ctx, obj.Blocks)
+}
+
 // RelatesTo is the resolver for the relatesTo field.
 func (r *taskResolver) RelatesTo(ctx context.Context, obj *model.Task) ([]*model.Task, error) {
 	return r.DataProvider.GetRelatedTasks(ctx, obj.ID)
 }
 
+// Comments is the resolver for the comments field.
+func (r *taskResolver) Comments(ctx context.Context, obj *model.Task) ([]*model.Comment, error) {
+	return r.DataProvider.GetTaskComments(ctx, obj.ID)
+}
+
+// Manager is the resolver for the manager field.
+func (r *userResolver) Manager(ctx context.Context, obj *model.User) (*model.User, error) {
+	if obj.Manager == nil {
+		return nil, nil
+	}
+	return r.DataProvider.UserByID(ctx, obj.Manager.ID)
+}
+
+// Subordinates is the resolver for the subordinates field.
+func (r *userResolver) Subordinates(ctx context.Context, obj *model.User) ([]*model.User, error) {
+	return r.usersByRef(ctx, obj.Subordinates)
+}
+
 // Projects is the resolver for the projects field.
 func (r *userResolver) Projects(ctx context.Context, obj *model.User) ([]*model.Project, error) {
 	return r.DataProvider.GetUserProjects(ctx, obj.ID)
 }
 
 // TasksAssigned is the resolver for the tasksAssigned field.
-func (r *userResolver) TasksAssigned(ctx context.Context, obj *model.User) ([]*model.Task, error) {
-	return r.DataProvider.GetTasksAssignedToUser(ctx, obj.ID)
+func (r *userResolver) TasksAssigned(ctx context.Context, obj *model.User, order *model.TasksOrder, orderAsc bool, first *int, after *string, last *int, before *string) (*model.TaskConnection, error) {
+	page, err := newPage(order, orderAsc, first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+	return r.DataProvider.GetTasksAssignedToUser(
+		ctx, obj.ID, order, orderAsc, page,
+	)
 }
 
 // TasksReported is the resolver for the tasksReported field.
@@ -45,15 +137,52 @@ func (r *userResolver) TasksReported(ctx context.Context, obj *model.User) ([]*m
 	return r.DataProvider.GetTasksReportedByUser(ctx, obj.ID)
 }
 
+// Sessions is the resolver for the sessions field.
+func (r *userResolver) Sessions(ctx context.Context, obj *model.User) ([]*model.Session, error) {
+	sessions, err := r.DataProvider.GetUserSessions(ctx, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := r.TimeProvider.Now()
+	active := make([]*model.Session, 0, len(sessions))
+	for _, s := range sessions {
+		if s.Expires.After(now) {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// APIKeys is the resolver for the apiKeys field.
+func (r *userResolver) APIKeys(ctx context.Context, obj *model.User) ([]*model.APIKey, error) {
+	return r.DataProvider.GetUserAPIKeys(ctx, obj.ID)
+}
+
+// APIKey returns APIKeyResolver implementation.
+func (r *Resolver) APIKey() APIKeyResolver { return &aPIKeyResolver{r} }
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // Project returns ProjectResolver implementation.
 func (r *Resolver) Project() ProjectResolver { return &projectResolver{r} }
 
+// ProjectRoleGrant returns ProjectRoleGrantResolver implementation.
+func (r *Resolver) ProjectRoleGrant() ProjectRoleGrantResolver { return &projectRoleGrantResolver{r} }
+
+// Session returns SessionResolver implementation.
+func (r *Resolver) Session() SessionResolver { return &sessionResolver{r} }
+
 // Task returns TaskResolver implementation.
 func (r *Resolver) Task() TaskResolver { return &taskResolver{r} }
 
 // User returns UserResolver implementation.
 func (r *Resolver) User() UserResolver { return &userResolver{r} }
 
+type aPIKeyResolver struct{ *Resolver }
+type commentResolver struct{ *Resolver }
 type projectResolver struct{ *Resolver }
+type projectRoleGrantResolver struct{ *Resolver }
+type sessionResolver struct{ *Resolver }
 type taskResolver struct{ *Resolver }
 type userResolver struct{ *Resolver }
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/reqctx"
+)
+
+// serveCacheable executes the persisted query q requested via GET
+// and sets the ETag and Cache-Control headers computed from the response.
+// Responds with 304 Not Modified if the ETag matches If-None-Match.
+func (s *ServerProduction) serveCacheable(
+	w http.ResponseWriter, r *http.Request, q *gqlpq.Query, variables []byte,
+) {
+	b := s.execute(r, q, variables)
+
+	h := w.Header()
+	cc := "no-store"
+	if b.status == http.StatusOK {
+		cc = cacheControl(r, q, b.body.Bytes())
+	}
+	if cc == "no-store" {
+		b.header.Set("Cache-Control", cc)
+		b.writeTo(w)
+		return
+	}
+
+	for k, v := range b.header {
+		h[k] = v
+	}
+	h.Set("Cache-Control", cc)
+
+	h.Set("Vary", "Authorization")
+	etag := etag(b.body.Bytes())
+	h.Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b.body.Bytes())
+}
+
+// cacheControl returns the Cache-Control header value for response.
+// Responses containing errors aren't cached, responses to authenticated
+// requests may only be cached by the client.
+func cacheControl(r *http.Request, q *gqlpq.Query, response []byte) string {
+	if !isSuccessful(response) {
+		return "no-store"
+	}
+	c := "public"
+	if q.Metadata.AuthRequired ||
+		reqctx.GetRequestContext(r.Context()).UserID != "" {
+		c = "private"
+	}
+	if q.Metadata.MaxAge > 0 {
+		return c + ", max-age=" + strconv.Itoa(int(q.Metadata.MaxAge.Seconds()))
+	}
+	return c + ", no-cache"
+}
+
+// isSuccessful returns true if response is a valid
+// GraphQL response without errors.
+func isSuccessful(response []byte) bool {
+	var resp struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	err := json.Unmarshal(response, &resp)
+	return err == nil && len(resp.Errors) == 0
+}
+
+// etag returns a strong entity tag for the response body.
+func etag(body []byte) string {
+	h := sha256.Sum256(body)
+	return `"` + base64.RawURLEncoding.EncodeToString(h[:18]) + `"`
+}
+
+// etagMatches returns true if the If-None-Match header value
+// contains etag or is "*". Weak comparison is used as defined
+// in RFC 9110 section 13.1.2.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// responseBuffer is an http.ResponseWriter buffering the response.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: http.Header{}, status: http.StatusOK}
+}
+
+// writeTo writes the buffered response to w.
+func (b *responseBuffer) writeTo(w http.ResponseWriter) {
+	h := w.Header()
+	for k, v := range b.header {
+		h[k] = v
+	}
+	w.WriteHeader(b.status)
+	_, _ = w.Write(b.body.Bytes())
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) WriteHeader(status int) { b.status = status }
+
+func (b *responseBuffer) Write(p []byte) (int, error) { return b.body.Write(p) }
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestCacheControl(t *testing.T) {
+	anonymous := httptest.NewRequest("GET", "/e/q", nil)
+	anonymous = anonymous.WithContext(reqctx.WithRequestContext(
+		context.Background(), slog.Default(), "", "q", time.Now(),
+	))
+	authenticated := httptest.NewRequest("GET", "/e/q", nil)
+	authenticated = authenticated.WithContext(reqctx.WithRequestContext(
+		context.Background(), slog.Default(), "user_1", "q", time.Now(),
+	))
+	const ok = `{"data":{"foo":"bar"}}`
+
+	for _, td := range []struct {
+		name     string
+		r        *http.Request
+		metadata gqlpq.Metadata
+		response string
+		expect   string
+	}{
+		{"public", anonymous, gqlpq.Metadata{}, ok, "public, no-cache"},
+		{"authenticated", authenticated, gqlpq.Metadata{}, ok, "private, no-cache"},
+		{
+			"auth_required", anonymous,
+			gqlpq.Metadata{AuthRequired: true}, ok, "private, no-cache",
+		},
+		{
+			"max_age", anonymous,
+			gqlpq.Metadata{MaxAge: time.Minute}, ok, "public, max-age=60",
+		},
+		{
+			"errors", anonymous, gqlpq.Metadata{},
+			`{"errors":[{"message":"x"}],"data":null}`, "no-store",
+		},
+		{"invalid", anonymous, gqlpq.Metadata{}, `{`, "no-store"},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			q := &gqlpq.Query{Key: "q", Metadata: td.metadata}
+			require.Equal(t, td.expect, cacheControl(td.r, q, []byte(td.response)))
+		})
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	e := etag([]byte("foo"))
+	require.NotEqual(t, e, etag([]byte("bar")))
+	require.True(t, etagMatches(e, e))
+	require.True(t, etagMatches(`"x", W/`+e, e))
+	require.True(t, etagMatches("*", e))
+	require.False(t, etagMatches("", e))
+	require.False(t, etagMatches(`"x"`, e))
+}
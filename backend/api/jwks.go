@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// serveJWKS serves the public keys access tokens can be verified with
+// on GET /.well-known/jwks.json.
+func (s *ServerProduction) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpNotFound(w)
+		return
+	}
+	b, err := json.Marshal(s.jwtKeys.JWKS(time.Now()))
+	if err != nil {
+		s.log.Error("encoding JWKS", slog.Any("error", err))
+		http.Error(
+			w,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	// Keys are published before they're used for signing,
+	// so verifiers may cache them for a while.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write(b)
+}
@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -10,62 +11,121 @@ import (
 	"github.com/golang-jwt/jwt"
 )
 
-func NewJWTGenerator(secret []byte) *JWTGenerator {
+func NewJWTGenerator(keys *KeySet) *JWTGenerator {
 	return &JWTGenerator{
-		secret: secret,
+		keys: keys,
 	}
 }
 
-// JWTGenerator generates JWT access tokens based on the given secret.
-type JWTGenerator struct{ secret []byte }
+// JWTGenerator generates JWT access tokens signed with the key
+// of the given key set that is active at the time of issuance.
+type JWTGenerator struct{ keys *KeySet }
 
+// Claims are the claims of an access token.
+type Claims struct {
+	jwt.StandardClaims
+
+	// SessionID is the ID of the session the token was issued for.
+	SessionID string `json:"sid"`
+}
+
+// GenerateJWT generates an access token for the session sessionID
+// of the user userID issued at issuedAt that expires after expiration.
 func (g *JWTGenerator) GenerateJWT(
-	userID string, expiration time.Duration,
+	userID, sessionID string, issuedAt time.Time, expiration time.Duration,
 ) (string, error) {
-	now := time.Now()
-	claims := &jwt.StandardClaims{
-		IssuedAt:  now.Unix(),
-		ExpiresAt: now.Add(expiration).Unix(),
-		Issuer:    userID,
+	claims := &Claims{
+		StandardClaims: jwt.StandardClaims{
+			IssuedAt:  issuedAt.Unix(),
+			ExpiresAt: issuedAt.Add(expiration).Unix(),
+			Issuer:    userID,
+		},
+		SessionID: sessionID,
+	}
+	key, err := g.keys.signingKey(issuedAt)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(g.secret)
+	return token.SignedString(key.PrivateKey)
 }
 
-func GetUserID(secret []byte, r *http.Request, timeNow time.Time) (string, error) {
+// RevocationStore reports whether sessions were revoked.
+type RevocationStore interface {
+	// IsRevoked returns true if the session sessionID of the user userID
+	// was revoked or doesn't exist.
+	IsRevoked(ctx context.Context, userID, sessionID string) (bool, error)
+}
+
+// GetUserID returns the user and session ID of the bearer token
+// in the Authorization header of r or "" if r has no such header.
+// The token must be signed with the key of keys identified by
+// the "kid" header that is valid for verification at timeNow.
+// Returns ErrTokenRevoked if revocations reports the session as revoked.
+func GetUserID(
+	keys *KeySet, r *http.Request, timeNow time.Time,
+	revocations RevocationStore,
+) (userID, sessionID string, err error) {
 	h := r.Header.Get("Authorization")
 	if h == "" {
 		// Unauthenticated client
-		return "", nil
+		return "", "", nil
 	}
 	p := strings.Split(h, " ")
 	if len(p) != 2 || p[0] != "Bearer" {
-		return "", ErrTokenInvalid
+		return "", "", ErrTokenInvalid
+	}
+	var claims Claims
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(
+		p[1], &claims, func(token *jwt.Token) (interface{}, error) {
+			var id string
+			if v, ok := token.Header["kid"]; ok {
+				if id, ok = v.(string); !ok {
+					return nil, errors.New("invalid key ID")
+				}
+			}
+			key := keys.verificationKey(id, timeNow)
+			if key == nil {
+				return nil, fmt.Errorf("unknown key ID %q", id)
+			}
+			// Prevent algorithm confusion by only accepting
+			// the algorithm of the key.
+			if token.Method.Alg() != key.Algorithm {
+				return nil, fmt.Errorf(
+					"unexpected signing method: %v", token.Header["alg"],
+				)
+			}
+			return key.PublicKey, nil
+		},
+	)
+	if err != nil || !token.Valid {
+		return "", "", ErrTokenInvalid
+	}
+	if !claims.VerifyExpiresAt(timeNow.Unix(), false) {
+		return "", "", ErrTokenExpired
+	}
+	if claims.Issuer == "" || claims.SessionID == "" {
+		return "", "", ErrTokenInvalid
 	}
-	token, _ := jwt.Parse(p[1], func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf(
-				"unexpected signing method: %v", token.Header["alg"],
-			)
-		}
-		return secret, nil
-	})
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", ErrTokenInvalid
+	revoked, err := revocations.IsRevoked(
+		r.Context(), claims.Issuer, claims.SessionID,
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("checking revocation: %w", err)
 	}
-	if !token.Valid {
-		if exp := claims["exp"]; exp != nil &&
-			timeNow.Unix() > int64(claims["exp"].(float64)) {
-			return "", ErrTokenExpired
-		}
-		return "", ErrTokenInvalid
+	if revoked {
+		return "", "", ErrTokenRevoked
 	}
-	return claims["iss"].(string), nil
+	return claims.Issuer, claims.SessionID, nil
 }
 
 var (
 	ErrTokenInvalid = errors.New("token invalid")
 	ErrTokenExpired = errors.New("token expired")
+	ErrTokenRevoked = errors.New("token revoked")
 )
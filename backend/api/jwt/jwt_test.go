@@ -0,0 +1,110 @@
+package jwt_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/jwt"
+
+	"github.com/stretchr/testify/require"
+)
+
+type revocations map[string]bool
+
+func (r revocations) IsRevoked(
+	_ context.Context, userID, sessionID string,
+) (bool, error) {
+	if sessionID == "failing" {
+		return false, errors.New("store unavailable")
+	}
+	return r[userID+"/"+sessionID], nil
+}
+
+func TestGetUserID(t *testing.T) {
+	keys := jwt.NewKeySetHS256([]byte("secret"))
+	g := jwt.NewJWTGenerator(keys)
+	now := time.Unix(1700000000, 0)
+	store := revocations{"user_b/session_2": true}
+
+	request := func(authorization string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/query", nil)
+		if authorization != "" {
+			r.Header.Set("Authorization", authorization)
+		}
+		return r
+	}
+	token := func(t *testing.T, userID, sessionID string) string {
+		t.Helper()
+		s, err := g.GenerateJWT(userID, sessionID, now, 15*time.Minute)
+		require.NoError(t, err)
+		return "Bearer " + s
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		userID, sessionID, err := jwt.GetUserID(keys, request(""), now, store)
+		require.NoError(t, err)
+		require.Zero(t, userID)
+		require.Zero(t, sessionID)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		userID, sessionID, err := jwt.GetUserID(
+			keys, request(token(t, "user_a", "session_1")),
+			now.Add(15*time.Minute), store,
+		)
+		require.NoError(t, err)
+		require.Equal(t, "user_a", userID)
+		require.Equal(t, "session_1", sessionID)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, _, err := jwt.GetUserID(
+			keys, request(token(t, "user_a", "session_1")),
+			now.Add(15*time.Minute+time.Second), store,
+		)
+		require.ErrorIs(t, err, jwt.ErrTokenExpired)
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		_, _, err := jwt.GetUserID(
+			keys, request(token(t, "user_b", "session_2")), now, store,
+		)
+		require.ErrorIs(t, err, jwt.ErrTokenRevoked)
+	})
+
+	t.Run("store_error", func(t *testing.T) {
+		_, _, err := jwt.GetUserID(
+			keys, request(token(t, "user_a", "failing")), now, store,
+		)
+		require.Error(t, err)
+		require.NotErrorIs(t, err, jwt.ErrTokenRevoked)
+		require.NotErrorIs(t, err, jwt.ErrTokenInvalid)
+	})
+
+	for _, tt := range []struct {
+		name          string
+		authorization string
+	}{
+		{"malformed_header", "Token abc"},
+		{"malformed_token", "Bearer abc"},
+		{"wrong_secret", func() string {
+			s, err := jwt.NewJWTGenerator(jwt.NewKeySetHS256([]byte("other"))).GenerateJWT(
+				"user_a", "session_1", now, time.Minute,
+			)
+			require.NoError(t, err)
+			return "Bearer " + s
+		}()},
+		{"missing_session", token(t, "user_a", "")},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := jwt.GetUserID(
+				keys, request(tt.authorization), now, store,
+			)
+			require.ErrorIs(t, err, jwt.ErrTokenInvalid)
+		})
+	}
+}
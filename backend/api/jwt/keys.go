@@ -0,0 +1,315 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Key is a key used for signing and verifying access tokens.
+type Key struct {
+	// ID is the key ID ("kid") written to the header of the tokens signed
+	// with this key. Tokens without a key ID are verified by the key with
+	// the empty ID.
+	ID string
+
+	// Algorithm is any of AlgHS256, AlgRS256 and AlgEdDSA.
+	Algorithm string
+
+	// PrivateKey is the []byte secret for AlgHS256,
+	// the *rsa.PrivateKey for AlgRS256 and the ed25519.PrivateKey for
+	// AlgEdDSA. Keys without a private key are only used for verification.
+	PrivateKey any
+
+	// PublicKey is the []byte secret for AlgHS256,
+	// the *rsa.PublicKey for AlgRS256 and the ed25519.PublicKey for AlgEdDSA.
+	PublicKey any
+
+	// SignFrom is the time the key is used for signing from on
+	// until a key with a later SignFrom becomes active.
+	// Zero means the key is active from the beginning.
+	SignFrom time.Time
+
+	// VerifyUntil is the time tokens signed with the key are rejected from
+	// on. Zero means forever.
+	VerifyUntil time.Time
+}
+
+func (k *Key) canSign(now time.Time) bool {
+	return k.PrivateKey != nil && !now.Before(k.SignFrom) && k.canVerify(now)
+}
+
+func (k *Key) canVerify(now time.Time) bool {
+	return k.VerifyUntil.IsZero() || now.Before(k.VerifyUntil)
+}
+
+// KeySet is an immutable set of signing and verification keys
+// rotated according to their schedule.
+type KeySet struct{ keys []Key }
+
+// NewKeySet returns a key set of keys.
+// Returns an error if any key ID is used more than once,
+// any key doesn't match its algorithm or none of the keys can sign.
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	ids := make(map[string]struct{}, len(keys))
+	var signing bool
+	for _, k := range keys {
+		if _, ok := ids[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		ids[k.ID] = struct{}{}
+		if err := checkKeyTypes(k); err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.ID, err)
+		}
+		if k.PrivateKey != nil {
+			signing = true
+		}
+	}
+	if !signing {
+		return nil, errors.New("no signing key")
+	}
+	return &KeySet{keys: append([]Key(nil), keys...)}, nil
+}
+
+// NewKeySetHS256 returns a key set of a single HS256 secret
+// without key ID.
+func NewKeySetHS256(secret []byte) *KeySet {
+	return &KeySet{keys: []Key{{
+		Algorithm:  AlgHS256,
+		PrivateKey: secret,
+		PublicKey:  secret,
+	}}}
+}
+
+func checkKeyTypes(k Key) error {
+	var privOK, pubOK bool
+	switch k.Algorithm {
+	case AlgHS256:
+		_, privOK = k.PrivateKey.([]byte)
+		_, pubOK = k.PublicKey.([]byte)
+	case AlgRS256:
+		_, privOK = k.PrivateKey.(*rsa.PrivateKey)
+		_, pubOK = k.PublicKey.(*rsa.PublicKey)
+	case AlgEdDSA:
+		_, privOK = k.PrivateKey.(ed25519.PrivateKey)
+		_, pubOK = k.PublicKey.(ed25519.PublicKey)
+	default:
+		return fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+	if k.PrivateKey != nil && !privOK {
+		return fmt.Errorf("invalid private key type %T", k.PrivateKey)
+	}
+	if !pubOK {
+		return fmt.Errorf("invalid public key type %T", k.PublicKey)
+	}
+	return nil
+}
+
+// signingKey returns the active signing key with the latest SignFrom
+// at now. Of multiple keys with the same SignFrom the last one is returned.
+func (s *KeySet) signingKey(now time.Time) (*Key, error) {
+	var active *Key
+	for i := range s.keys {
+		k := &s.keys[i]
+		if k.canSign(now) &&
+			(active == nil || !k.SignFrom.Before(active.SignFrom)) {
+			active = k
+		}
+	}
+	if active == nil {
+		return nil, ErrNoSigningKey
+	}
+	return active, nil
+}
+
+// verificationKey returns the key identified by id
+// or nil if there's no such key or it's no longer valid at now.
+func (s *KeySet) verificationKey(id string, now time.Time) *Key {
+	for i := range s.keys {
+		if k := &s.keys[i]; k.ID == id && k.canVerify(now) {
+			return k
+		}
+	}
+	return nil
+}
+
+// JWK is a public JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// Curve and X are the parameters of Ed25519 keys (RFC 8037).
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+
+	// N and E are the modulus and exponent of RSA keys (RFC 7518).
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set (RFC 7517).
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of all asymmetric keys that are valid
+// for verification at now, including keys scheduled for signing
+// in the future so that verifiers can cache them in advance.
+// HS256 secrets are never included.
+func (s *KeySet) JWKS(now time.Time) JWKS {
+	set := JWKS{Keys: []JWK{}}
+	b64 := base64.RawURLEncoding.EncodeToString
+	for _, k := range s.keys {
+		if !k.canVerify(now) {
+			continue
+		}
+		switch pub := k.PublicKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     k.ID,
+				Use:       "sig",
+				Algorithm: k.Algorithm,
+				N:         b64(pub.N.Bytes()),
+				E:         b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     k.ID,
+				Use:       "sig",
+				Algorithm: k.Algorithm,
+				Curve:     "Ed25519",
+				X:         b64(pub),
+			})
+		}
+	}
+	return set
+}
+
+// keyManifest is the JSON manifest read by LoadKeys.
+type keyManifest struct {
+	Keys []struct {
+		ID string `json:"kid"`
+
+		// PrivateKeyFile is the path of the PEM encoded PKCS #8
+		// (or PKCS #1 for RSA) private key file.
+		PrivateKeyFile string `json:"privateKeyFile"`
+
+		// PublicKeyFile is the path of the PEM encoded PKIX public key
+		// file of verification-only keys.
+		PublicKeyFile string `json:"publicKeyFile"`
+
+		SignFrom    time.Time `json:"signFrom"`
+		VerifyUntil time.Time `json:"verifyUntil"`
+	} `json:"keys"`
+}
+
+// LoadKeys loads the keys listed in the JSON manifest file at path.
+// Key file paths are relative to the directory of the manifest.
+// The algorithm is inferred from the key type:
+// RSA keys are used with AlgRS256 and Ed25519 keys with AlgEdDSA.
+func LoadKeys(path string) ([]Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key manifest: %w", err)
+	}
+	var m keyManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("decoding key manifest: %w", err)
+	}
+	dir := filepath.Dir(path)
+	keys := make([]Key, len(m.Keys))
+	for i, e := range m.Keys {
+		if e.ID == "" {
+			return nil, fmt.Errorf("key at index %d: missing kid", i)
+		}
+		k := Key{ID: e.ID, SignFrom: e.SignFrom, VerifyUntil: e.VerifyUntil}
+		switch {
+		case e.PrivateKeyFile != "" && e.PublicKeyFile != "":
+			return nil, fmt.Errorf(
+				"key %q: privateKeyFile and publicKeyFile are mutually exclusive",
+				e.ID,
+			)
+		case e.PrivateKeyFile != "":
+			k.PrivateKey, err = readPEM(
+				filepath.Join(dir, e.PrivateKeyFile), parsePrivateKey,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", e.ID, err)
+			}
+			switch priv := k.PrivateKey.(type) {
+			case *rsa.PrivateKey:
+				k.PublicKey = &priv.PublicKey
+			case ed25519.PrivateKey:
+				k.PublicKey = priv.Public()
+			}
+		case e.PublicKeyFile != "":
+			k.PublicKey, err = readPEM(
+				filepath.Join(dir, e.PublicKeyFile), x509.ParsePKIXPublicKey,
+			)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", e.ID, err)
+			}
+		default:
+			return nil, fmt.Errorf(
+				"key %q: missing privateKeyFile or publicKeyFile", e.ID,
+			)
+		}
+		switch k.PublicKey.(type) {
+		case *rsa.PublicKey:
+			k.Algorithm = AlgRS256
+		case ed25519.PublicKey:
+			k.Algorithm = AlgEdDSA
+		default:
+			return nil, fmt.Errorf(
+				"key %q: unsupported key type %T", e.ID, k.PublicKey,
+			)
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+func readPEM(path string, parse func([]byte) (any, error)) (any, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+	k, err := parse(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return k, nil
+}
+
+func parsePrivateKey(der []byte) (any, error) {
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	return x509.ParsePKCS8PrivateKey(der)
+}
+
+var ErrNoSigningKey = errors.New("no active signing key")
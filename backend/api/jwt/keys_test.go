@@ -0,0 +1,279 @@
+package jwt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/jwt"
+
+	gojwt "github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(30 * 24 * time.Hour)
+	keys, err := jwt.NewKeySet(
+		jwt.Key{
+			ID:          "rsa",
+			Algorithm:   jwt.AlgRS256,
+			PrivateKey:  rsaKey,
+			PublicKey:   &rsaKey.PublicKey,
+			VerifyUntil: t1.Add(time.Hour),
+		},
+		jwt.Key{
+			ID:         "ed",
+			Algorithm:  jwt.AlgEdDSA,
+			PrivateKey: edPriv,
+			PublicKey:  edPub,
+			SignFrom:   t1,
+		},
+	)
+	require.NoError(t, err)
+	g := jwt.NewJWTGenerator(keys)
+
+	generate := func(t *testing.T, issuedAt time.Time) string {
+		t.Helper()
+		s, err := g.GenerateJWT("user_a", "session_1", issuedAt, time.Hour)
+		require.NoError(t, err)
+		return s
+	}
+	verify := func(token string, now time.Time) error {
+		r := httptest.NewRequest(http.MethodPost, "/query", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		_, _, err := jwt.GetUserID(keys, r, now, revocations{})
+		return err
+	}
+
+	tokenRSA := generate(t, t0)
+	require.Equal(t, "RS256", header(t, tokenRSA)["alg"])
+	require.Equal(t, "rsa", header(t, tokenRSA)["kid"])
+	require.NoError(t, verify(tokenRSA, t0))
+
+	// The Ed25519 key replaces the RSA key for signing at t1.
+	tokenEd := generate(t, t1)
+	require.Equal(t, "EdDSA", header(t, tokenEd)["alg"])
+	require.Equal(t, "ed", header(t, tokenEd)["kid"])
+	require.NoError(t, verify(tokenEd, t1))
+
+	// Tokens signed with the old key remain valid until it's retired.
+	tokenRSA = generate(t, t1.Add(-time.Minute))
+	require.Equal(t, "rsa", header(t, tokenRSA)["kid"])
+	require.NoError(t, verify(tokenRSA, t1.Add(30*time.Minute)))
+	require.ErrorIs(t, verify(tokenRSA, t1.Add(time.Hour)), jwt.ErrTokenInvalid)
+
+	jwks := keys.JWKS(t0)
+	require.Len(t, jwks.Keys, 2, "keys are published before they're used")
+	require.Equal(t, jwt.JWK{
+		KeyType:   "RSA",
+		KeyID:     "rsa",
+		Use:       "sig",
+		Algorithm: "RS256",
+		N:         base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+		E:         "AQAB",
+	}, jwks.Keys[0])
+	require.Equal(t, jwt.JWK{
+		KeyType:   "OKP",
+		KeyID:     "ed",
+		Use:       "sig",
+		Algorithm: "EdDSA",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(edPub),
+	}, jwks.Keys[1])
+	jwks = keys.JWKS(t1.Add(time.Hour))
+	require.Len(t, jwks.Keys, 1, "retired keys aren't published")
+	require.Equal(t, "ed", jwks.Keys[0].KeyID)
+
+	t.Run("unknown_key", func(t *testing.T) {
+		other, err := jwt.NewKeySet(jwt.Key{
+			ID:         "other",
+			Algorithm:  jwt.AlgEdDSA,
+			PrivateKey: edPriv,
+			PublicKey:  edPub,
+		})
+		require.NoError(t, err)
+		s, err := jwt.NewJWTGenerator(other).GenerateJWT(
+			"user_a", "session_1", t1, time.Hour,
+		)
+		require.NoError(t, err)
+		require.ErrorIs(t, verify(s, t1), jwt.ErrTokenInvalid)
+	})
+
+	t.Run("algorithm_confusion", func(t *testing.T) {
+		// A token signed with HS256 using the public RSA key as the secret
+		// must be rejected.
+		pub, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+		require.NoError(t, err)
+		token := gojwt.NewWithClaims(gojwt.SigningMethodHS256, &jwt.Claims{
+			StandardClaims: gojwt.StandardClaims{Issuer: "user_a"},
+			SessionID:      "session_1",
+		})
+		token.Header["kid"] = "rsa"
+		s, err := token.SignedString(pub)
+		require.NoError(t, err)
+		require.ErrorIs(t, verify(s, t0), jwt.ErrTokenInvalid)
+	})
+
+	t.Run("no_active_signing_key", func(t *testing.T) {
+		scheduled, err := jwt.NewKeySet(jwt.Key{
+			ID:         "ed",
+			Algorithm:  jwt.AlgEdDSA,
+			PrivateKey: edPriv,
+			PublicKey:  edPub,
+			SignFrom:   t1,
+		})
+		require.NoError(t, err)
+		_, err = jwt.NewJWTGenerator(scheduled).GenerateJWT(
+			"user_a", "session_1", t0, time.Hour,
+		)
+		require.ErrorIs(t, err, jwt.ErrNoSigningKey)
+	})
+}
+
+func TestNewKeySetErr(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name   string
+		keys   []jwt.Key
+		expect string
+	}{
+		{"duplicate_id", []jwt.Key{
+			{ID: "a", Algorithm: jwt.AlgEdDSA, PrivateKey: edPriv, PublicKey: edPub},
+			{ID: "a", Algorithm: jwt.AlgEdDSA, PublicKey: edPub},
+		}, `duplicate key ID "a"`},
+		{"no_signing_key", []jwt.Key{
+			{ID: "a", Algorithm: jwt.AlgEdDSA, PublicKey: edPub},
+		}, "no signing key"},
+		{"unsupported_algorithm", []jwt.Key{
+			{ID: "a", Algorithm: "none", PrivateKey: edPriv, PublicKey: edPub},
+		}, `key "a": unsupported algorithm "none"`},
+		{"mismatching_key", []jwt.Key{
+			{ID: "a", Algorithm: jwt.AlgRS256, PrivateKey: edPriv, PublicKey: edPub},
+		}, `key "a": invalid private key type ed25519.PrivateKey`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.NewKeySet(tt.keys...)
+			require.EqualError(t, err, tt.expect)
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content []byte) {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, content, 0o600))
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	write("keys/rsa.pem", pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+	}))
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edPriv)
+	require.NoError(t, err)
+	write("keys/ed.pem", pem.EncodeToMemory(&pem.Block{
+		Type: "PRIVATE KEY", Bytes: der,
+	}))
+	oldPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(oldPub)
+	require.NoError(t, err)
+	write("keys/old.pub.pem", pem.EncodeToMemory(&pem.Block{
+		Type: "PUBLIC KEY", Bytes: der,
+	}))
+	write("keys.json", []byte(`{"keys": [
+		{"kid": "old", "publicKeyFile": "keys/old.pub.pem",
+		 "verifyUntil": "2024-02-01T00:00:00Z"},
+		{"kid": "rsa", "privateKeyFile": "keys/rsa.pem"},
+		{"kid": "ed", "privateKeyFile": "keys/ed.pem",
+		 "signFrom": "2024-01-01T00:00:00Z"}
+	]}`))
+
+	keys, err := jwt.LoadKeys(filepath.Join(dir, "keys.json"))
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+
+	require.Equal(t, "old", keys[0].ID)
+	require.Equal(t, jwt.AlgEdDSA, keys[0].Algorithm)
+	require.Nil(t, keys[0].PrivateKey)
+	require.Equal(t, oldPub, keys[0].PublicKey)
+	require.Equal(t,
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), keys[0].VerifyUntil,
+	)
+
+	require.Equal(t, "rsa", keys[1].ID)
+	require.Equal(t, jwt.AlgRS256, keys[1].Algorithm)
+	require.Equal(t, rsaKey.N, keys[1].PrivateKey.(*rsa.PrivateKey).N)
+	require.Equal(t, &rsaKey.PublicKey, keys[1].PublicKey)
+
+	require.Equal(t, "ed", keys[2].ID)
+	require.Equal(t, jwt.AlgEdDSA, keys[2].Algorithm)
+	require.Equal(t, edPriv, keys[2].PrivateKey)
+	require.Equal(t, edPub, keys[2].PublicKey)
+	require.Equal(t,
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), keys[2].SignFrom,
+	)
+
+	_, err = jwt.NewKeySet(keys...)
+	require.NoError(t, err)
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			name     string
+			manifest string
+			expect   string
+		}{
+			{"missing_kid", `{"keys": [{"privateKeyFile": "keys/ed.pem"}]}`,
+				"key at index 0: missing kid"},
+			{"missing_file", `{"keys": [{"kid": "a"}]}`,
+				`key "a": missing privateKeyFile or publicKeyFile`},
+			{"both_files", `{"keys": [{"kid": "a",
+				"privateKeyFile": "keys/ed.pem",
+				"publicKeyFile": "keys/old.pub.pem"}]}`,
+				`key "a": privateKeyFile and publicKeyFile are mutually exclusive`},
+			{"not_pem", `{"keys": [{"kid": "a", "privateKeyFile": "keys.json"}]}`,
+				"no PEM block found"},
+			{"not_found", `{"keys": [{"kid": "a", "privateKeyFile": "nope.pem"}]}`,
+				"no such file or directory"},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				p := filepath.Join(dir, tt.name+".json")
+				require.NoError(t, os.WriteFile(p, []byte(tt.manifest), 0o600))
+				_, err := jwt.LoadKeys(p)
+				require.ErrorContains(t, err, tt.expect)
+			})
+		}
+	})
+}
+
+// header returns the decoded header of token.
+func header(t *testing.T, token string) map[string]any {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	require.NoError(t, err)
+	var h map[string]any
+	require.NoError(t, json.Unmarshal(b, &h))
+	return h
+}
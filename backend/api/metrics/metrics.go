@@ -0,0 +1,312 @@
+// Package metrics provides thread-safe usage statistics of GraphQL
+// operations per persisted query and per operation type as well as
+// subscription broadcast statistics written in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/romshark/taskhub/api/broadcast"
+)
+
+// bucketBounds are the upper bounds of the latency histogram
+// buckets in seconds.
+var bucketBounds = [...]float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// Operation identifies a persisted query.
+type Operation struct {
+	// Name is the name of the persisted query.
+	Name string
+
+	// Type is the operation type, such as "query".
+	Type string
+}
+
+// Metrics records calls, errors, latencies and the time of last use
+// of operations.
+type Metrics struct {
+	lock       sync.Mutex
+	queries    map[Operation]*series
+	types      map[string]*series
+	broadcasts func() map[string]broadcast.Stats
+}
+
+type series struct {
+	calls    uint64
+	errors   uint64
+	sum      float64
+	buckets  [len(bucketBounds) + 1]uint64 // The last bucket is +Inf
+	lastUsed time.Time
+}
+
+func (s *series) observe(took time.Duration, failed bool, now time.Time) {
+	s.calls++
+	if failed {
+		s.errors++
+	}
+	sec := took.Seconds()
+	s.sum += sec
+	i := sort.SearchFloat64s(bucketBounds[:], sec)
+	s.buckets[i]++
+	if now.After(s.lastUsed) {
+		s.lastUsed = now
+	}
+}
+
+// New creates a new empty set of metrics.
+func New() *Metrics {
+	return &Metrics{
+		queries: make(map[Operation]*series),
+		types:   make(map[string]*series),
+	}
+}
+
+// Observe records a call of an operation of type opType that took took,
+// failed if the response contained errors, at now.
+// query is the name of the persisted query or "" if the operation
+// wasn't persisted, in which case only the operation type is recorded.
+// Observe is safe for concurrent use.
+func (m *Metrics) Observe(
+	query, opType string, took time.Duration, failed bool, now time.Time,
+) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	t := m.types[opType]
+	if t == nil {
+		t = new(series)
+		m.types[opType] = t
+	}
+	t.observe(took, failed, now)
+	if query == "" {
+		return
+	}
+	k := Operation{Name: query, Type: opType}
+	q := m.queries[k]
+	if q == nil {
+		q = new(series)
+		m.queries[k] = q
+	}
+	q.observe(took, failed, now)
+}
+
+// ObserveBroadcasts registers fn to be called by WritePrometheus
+// to obtain the subscription broadcast statistics by subscription name.
+// ObserveBroadcasts is safe for concurrent use.
+func (m *Metrics) ObserveBroadcasts(fn func() map[string]broadcast.Stats) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.broadcasts = fn
+}
+
+// WritePrometheus writes all metrics to w in the Prometheus
+// text exposition format. Series of all persisted operations are written
+// even if they were never called to make unused persisted queries visible.
+// The last-used timestamp is only written for operations called at least once.
+// WritePrometheus is safe for concurrent use.
+func (m *Metrics) WritePrometheus(w io.Writer, persisted []Operation) error {
+	m.lock.Lock()
+	queries := make(map[Operation]series, len(m.queries)+len(persisted))
+	for _, o := range persisted {
+		queries[o] = series{}
+	}
+	for k, s := range m.queries {
+		queries[k] = *s
+	}
+	types := make(map[string]series, len(m.types))
+	for k, s := range m.types {
+		types[k] = *s
+	}
+	broadcasts := m.broadcasts
+	m.lock.Unlock()
+
+	queryKeys := make([]Operation, 0, len(queries))
+	for k := range queries {
+		queryKeys = append(queryKeys, k)
+	}
+	sort.Slice(queryKeys, func(i, j int) bool {
+		if queryKeys[i].Name != queryKeys[j].Name {
+			return queryKeys[i].Name < queryKeys[j].Name
+		}
+		return queryKeys[i].Type < queryKeys[j].Type
+	})
+	typeKeys := make([]string, 0, len(types))
+	for k := range types {
+		typeKeys = append(typeKeys, k)
+	}
+	sort.Strings(typeKeys)
+
+	querySeries := make([]labeledSeries, len(queryKeys))
+	for i, k := range queryKeys {
+		querySeries[i] = labeledSeries{
+			labels: fmt.Sprintf(
+				`query="%s",type="%s"`, escape(k.Name), escape(k.Type),
+			),
+			series: queries[k],
+		}
+	}
+	typeSeries := make([]labeledSeries, len(typeKeys))
+	for i, k := range typeKeys {
+		typeSeries[i] = labeledSeries{
+			labels: fmt.Sprintf(`type="%s"`, escape(k)),
+			series: types[k],
+		}
+	}
+
+	b := bufio.NewWriter(w)
+	writeFamily(
+		b, "taskhub_persisted_query_calls_total", "counter",
+		"Total number of calls per persisted query.",
+		querySeries, func(s series) uint64 { return s.calls },
+	)
+	writeFamily(
+		b, "taskhub_persisted_query_errors_total", "counter",
+		"Total number of calls per persisted query "+
+			"responded to with GraphQL errors.",
+		querySeries, func(s series) uint64 { return s.errors },
+	)
+	writeHistogram(
+		b, "taskhub_persisted_query_duration_seconds",
+		"Latency of the calls per persisted query in seconds.",
+		querySeries,
+	)
+	writeLastUsed(
+		b, "taskhub_persisted_query_last_used_timestamp_seconds",
+		"Unix time of the last call per persisted query.",
+		querySeries,
+	)
+	writeFamily(
+		b, "taskhub_operation_calls_total", "counter",
+		"Total number of calls per operation type.",
+		typeSeries, func(s series) uint64 { return s.calls },
+	)
+	writeFamily(
+		b, "taskhub_operation_errors_total", "counter",
+		"Total number of calls per operation type "+
+			"responded to with GraphQL errors.",
+		typeSeries, func(s series) uint64 { return s.errors },
+	)
+	writeHistogram(
+		b, "taskhub_operation_duration_seconds",
+		"Latency of the calls per operation type in seconds.",
+		typeSeries,
+	)
+	writeLastUsed(
+		b, "taskhub_operation_last_used_timestamp_seconds",
+		"Unix time of the last call per operation type.",
+		typeSeries,
+	)
+	if broadcasts != nil {
+		writeBroadcasts(b, broadcasts())
+	}
+	return b.Flush()
+}
+
+// writeBroadcasts writes the subscription broadcast statistics
+// sorted by subscription name.
+func writeBroadcasts(b *bufio.Writer, stats map[string]broadcast.Stats) {
+	names := make([]string, 0, len(stats))
+	for n := range stats {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	write := func(name, typ, help string, value func(broadcast.Stats) uint64) {
+		writeHeader(b, name, typ, help)
+		for _, n := range names {
+			fmt.Fprintf(
+				b, "%s{subscription=\"%s\"} %d\n",
+				name, escape(n), value(stats[n]),
+			)
+		}
+	}
+	write(
+		"taskhub_subscription_subscribers", "gauge",
+		"Number of active subscribers per subscription.",
+		func(s broadcast.Stats) uint64 { return uint64(s.Subscribers) },
+	)
+	write(
+		"taskhub_subscription_events_delivered_total", "counter",
+		"Total number of events delivered to subscribers per subscription.",
+		func(s broadcast.Stats) uint64 { return s.Delivered },
+	)
+	write(
+		"taskhub_subscription_events_dropped_total", "counter",
+		"Total number of events dropped due to full subscriber queues "+
+			"per subscription.",
+		func(s broadcast.Stats) uint64 { return s.Dropped },
+	)
+	write(
+		"taskhub_subscription_disconnected_total", "counter",
+		"Total number of subscribers disconnected due to full queues "+
+			"per subscription.",
+		func(s broadcast.Stats) uint64 { return s.Disconnected },
+	)
+}
+
+type labeledSeries struct {
+	labels string
+	series series
+}
+
+func writeHeader(b *bufio.Writer, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeFamily(
+	b *bufio.Writer, name, typ, help string,
+	s []labeledSeries, value func(series) uint64,
+) {
+	writeHeader(b, name, typ, help)
+	for _, s := range s {
+		fmt.Fprintf(b, "%s{%s} %d\n", name, s.labels, value(s.series))
+	}
+}
+
+func writeHistogram(b *bufio.Writer, name, help string, s []labeledSeries) {
+	writeHeader(b, name, "histogram", help)
+	for _, s := range s {
+		var cumulative uint64
+		for i, c := range s.series.buckets {
+			cumulative += c
+			le := "+Inf"
+			if i < len(bucketBounds) {
+				le = strconv.FormatFloat(bucketBounds[i], 'g', -1, 64)
+			}
+			fmt.Fprintf(
+				b, "%s_bucket{%s,le=\"%s\"} %d\n",
+				name, s.labels, le, cumulative,
+			)
+		}
+		fmt.Fprintf(
+			b, "%s_sum{%s} %s\n", name, s.labels,
+			strconv.FormatFloat(s.series.sum, 'g', -1, 64),
+		)
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, s.labels, s.series.calls)
+	}
+}
+
+func writeLastUsed(b *bufio.Writer, name, help string, s []labeledSeries) {
+	writeHeader(b, name, "gauge", help)
+	for _, s := range s {
+		if s.series.lastUsed.IsZero() {
+			continue
+		}
+		fmt.Fprintf(
+			b, "%s{%s} %d\n", name, s.labels, s.series.lastUsed.Unix(),
+		)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes a label value.
+func escape(v string) string { return labelEscaper.Replace(v) }
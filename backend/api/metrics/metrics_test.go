@@ -0,0 +1,92 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/broadcast"
+	"github.com/romshark/taskhub/api/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	m := metrics.New()
+	now := time.Unix(1700000000, 0)
+	m.Observe("qry_a", "query", 3*time.Millisecond, false, now.Add(-time.Hour))
+	m.Observe("qry_a", "query", 200*time.Millisecond, true, now)
+	m.Observe("", "query", 20*time.Second, false, now)
+	m.Observe("mut_b", "mutation", 10*time.Millisecond, false, now)
+
+	var b strings.Builder
+	require.NoError(t, m.WritePrometheus(&b, []metrics.Operation{
+		{Name: "qry_a", Type: "query"},
+		{Name: "qry_unused", Type: "query"},
+	}))
+	s := b.String()
+
+	for _, line := range []string{
+		"# TYPE taskhub_persisted_query_calls_total counter",
+		`taskhub_persisted_query_calls_total{query="mut_b",type="mutation"} 1`,
+		`taskhub_persisted_query_calls_total{query="qry_a",type="query"} 2`,
+		`taskhub_persisted_query_calls_total{query="qry_unused",type="query"} 0`,
+		`taskhub_persisted_query_errors_total{query="qry_a",type="query"} 1`,
+		`taskhub_persisted_query_errors_total{query="qry_unused",type="query"} 0`,
+
+		"# TYPE taskhub_persisted_query_duration_seconds histogram",
+		`taskhub_persisted_query_duration_seconds_bucket{query="qry_a",type="query",le="0.005"} 1`,
+		`taskhub_persisted_query_duration_seconds_bucket{query="qry_a",type="query",le="0.1"} 1`,
+		`taskhub_persisted_query_duration_seconds_bucket{query="qry_a",type="query",le="0.25"} 2`,
+		`taskhub_persisted_query_duration_seconds_bucket{query="qry_a",type="query",le="+Inf"} 2`,
+		`taskhub_persisted_query_duration_seconds_sum{query="qry_a",type="query"} 0.203`,
+		`taskhub_persisted_query_duration_seconds_count{query="qry_a",type="query"} 2`,
+		`taskhub_persisted_query_duration_seconds_count{query="qry_unused",type="query"} 0`,
+
+		`taskhub_persisted_query_last_used_timestamp_seconds{query="qry_a",type="query"} 1700000000`,
+
+		`taskhub_operation_calls_total{type="mutation"} 1`,
+		`taskhub_operation_calls_total{type="query"} 3`,
+		`taskhub_operation_errors_total{type="query"} 1`,
+		`taskhub_operation_duration_seconds_bucket{type="query",le="10"} 2`,
+		`taskhub_operation_duration_seconds_bucket{type="query",le="+Inf"} 3`,
+		`taskhub_operation_last_used_timestamp_seconds{type="query"} 1700000000`,
+	} {
+		require.Contains(t, s, line+"\n")
+	}
+	require.NotContains(t, s,
+		`taskhub_persisted_query_last_used_timestamp_seconds{query="qry_unused"`,
+		"never used queries have no last-used timestamp",
+	)
+	require.NotContains(t, s, `query=""`)
+}
+
+func TestWritePrometheusBroadcasts(t *testing.T) {
+	m := metrics.New()
+	m.ObserveBroadcasts(func() map[string]broadcast.Stats {
+		return map[string]broadcast.Stats{
+			"taskUpsert": {
+				Subscribers: 2, Delivered: 10, Dropped: 3, Disconnected: 1,
+			},
+			"taskDelete": {},
+		}
+	})
+
+	var b strings.Builder
+	require.NoError(t, m.WritePrometheus(&b, nil))
+	s := b.String()
+
+	for _, line := range []string{
+		"# TYPE taskhub_subscription_subscribers gauge",
+		`taskhub_subscription_subscribers{subscription="taskUpsert"} 2`,
+		`taskhub_subscription_subscribers{subscription="taskDelete"} 0`,
+		"# TYPE taskhub_subscription_events_delivered_total counter",
+		`taskhub_subscription_events_delivered_total{subscription="taskUpsert"} 10`,
+		"# TYPE taskhub_subscription_events_dropped_total counter",
+		`taskhub_subscription_events_dropped_total{subscription="taskUpsert"} 3`,
+		`taskhub_subscription_events_dropped_total{subscription="taskDelete"} 0`,
+		"# TYPE taskhub_subscription_disconnected_total counter",
+		`taskhub_subscription_disconnected_total{subscription="taskUpsert"} 1`,
+	} {
+		require.Contains(t, s, line+"\n")
+	}
+}
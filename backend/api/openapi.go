@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/romshark/taskhub/api/pqgen"
+	"golang.org/x/exp/slog"
+)
+
+// openAPIDocument is the OpenAPI document of the persisted queries,
+// which is regenerated after every successful reload.
+type openAPIDocument struct {
+	lock        sync.Mutex
+	lastSuccess time.Time
+	doc         []byte
+}
+
+// serveOpenAPI serves the OpenAPI document of the persisted queries
+// on GET /openapi.json.
+func (s *ServerProduction) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpNotFound(w)
+		return
+	}
+	doc, err := s.openAPIDocument()
+	if err != nil {
+		s.log.Error("generating OpenAPI document", slog.Any("error", err))
+		http.Error(
+			w,
+			http.StatusText(http.StatusInternalServerError),
+			http.StatusInternalServerError,
+		)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(doc)
+}
+
+func (s *ServerProduction) openAPIDocument() ([]byte, error) {
+	lastSuccess := s.persistedQueries.Status().LastSuccess
+	s.openAPI.lock.Lock()
+	defer s.openAPI.lock.Unlock()
+	if s.openAPI.doc != nil && s.openAPI.lastSuccess.Equal(lastSuccess) {
+		return s.openAPI.doc, nil
+	}
+	doc, err := pqgen.GenerateOpenAPI(
+		s.persistedQueries.Schema(),
+		pqgen.Operations(s.persistedQueries),
+		pqgen.OpenAPIInfo{
+			Title:   "TaskHub",
+			Version: lastSuccess.UTC().Format(time.RFC3339),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.openAPI.doc, s.openAPI.lastSuccess = doc, lastSuccess
+	return doc, nil
+}
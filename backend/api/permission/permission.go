@@ -0,0 +1,147 @@
+// Package permission defines which actions clients are allowed to perform
+// on a project depending on their project role and global admin role.
+package permission
+
+import (
+	"context"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/graph/model"
+)
+
+// Action is an action performed on a project or its tasks and comments.
+type Action int8
+
+const (
+	// View reads the project, its tasks and comments
+	// and receives their subscription events.
+	View Action = iota + 1
+
+	// Comment adds comments to the tasks of the project.
+	Comment
+
+	// CreateTask creates tasks in the project or moves tasks into it.
+	CreateTask
+
+	// UpdateTask updates the tasks of the project
+	// or moves them out of it.
+	UpdateTask
+
+	// DeleteTask deletes the tasks of the project.
+	DeleteTask
+
+	// ModerateComments deletes comments of other authors.
+	ModerateComments
+
+	// UpdateProject updates the name, description and slug of the project.
+	UpdateProject
+
+	// ArchiveProject archives and unarchives the project.
+	ArchiveProject
+
+	// ManageRoles grants and revokes project roles.
+	ManageRoles
+)
+
+// Actions are all actions.
+var Actions = []Action{
+	View, Comment, CreateTask, UpdateTask, DeleteTask,
+	ModerateComments, UpdateProject, ArchiveProject, ManageRoles,
+}
+
+func (a Action) String() string {
+	switch a {
+	case View:
+		return "View"
+	case Comment:
+		return "Comment"
+	case CreateTask:
+		return "CreateTask"
+	case UpdateTask:
+		return "UpdateTask"
+	case DeleteTask:
+		return "DeleteTask"
+	case ModerateComments:
+		return "ModerateComments"
+	case UpdateProject:
+		return "UpdateProject"
+	case ArchiveProject:
+		return "ArchiveProject"
+	case ManageRoles:
+		return "ManageRoles"
+	}
+	return ""
+}
+
+// minRole is the least project role required per action.
+// An empty role means any authenticated user is allowed.
+var minRole = map[Action]model.ProjectRole{
+	View:             model.ProjectRoleViewer,
+	Comment:          model.ProjectRoleViewer,
+	CreateTask:       model.ProjectRoleMember,
+	UpdateTask:       model.ProjectRoleMember,
+	DeleteTask:       model.ProjectRoleMaintainer,
+	ModerateComments: model.ProjectRoleMaintainer,
+	UpdateProject:    model.ProjectRoleMaintainer,
+	ArchiveProject:   model.ProjectRoleMaintainer,
+	ManageRoles:      model.ProjectRoleOwner,
+}
+
+// Rank returns the rank of role, higher roles include the permissions
+// of all lower roles. The rank of the empty role is 0.
+func Rank(role model.ProjectRole) int {
+	switch role {
+	case model.ProjectRoleViewer:
+		return 1
+	case model.ProjectRoleMember:
+		return 2
+	case model.ProjectRoleMaintainer:
+		return 3
+	case model.ProjectRoleOwner:
+		return 4
+	}
+	return 0
+}
+
+// Subject is the relation of a client to a project.
+type Subject struct {
+	// Admin is true if the client has the global admin role,
+	// which allows all actions on all projects.
+	Admin bool
+
+	// Role is the role of the client in the project,
+	// empty if the client has no role.
+	Role model.ProjectRole
+}
+
+// Allowed returns true if s is allowed to perform action a.
+func Allowed(s Subject, a Action) bool {
+	min, ok := minRole[a]
+	if !ok {
+		return false
+	}
+	return s.Admin || Rank(s.Role) >= Rank(min)
+}
+
+// Require returns nil if the client is authenticated and s is allowed
+// to perform action a, otherwise returns auth.ErrUnauthenticated or
+// auth.ErrUnauthorized respectively.
+func Require(ctx context.Context, s Subject, a Action) error {
+	if err := auth.RequireAuthenticated(ctx); err != nil {
+		return err
+	}
+	if !Allowed(s, a) {
+		return auth.ErrUnauthorized
+	}
+	return nil
+}
+
+// RoleOf returns the role of the user in project or "" if it has none.
+func RoleOf(project *model.Project, userID string) model.ProjectRole {
+	for _, g := range project.Roles {
+		if g.User.ID == userID {
+			return g.Role
+		}
+	}
+	return ""
+}
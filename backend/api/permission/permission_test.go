@@ -0,0 +1,89 @@
+package permission_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/permission"
+	"github.com/romshark/taskhub/api/reqctx"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestAllowed(t *testing.T) {
+	const (
+		Y = true
+		N = false
+	)
+	// The columns follow the order of permission.Actions.
+	for _, td := range []struct {
+		subject permission.Subject
+		expect  [9]bool
+	}{
+		{permission.Subject{},
+			[9]bool{N, N, N, N, N, N, N, N, N}},
+		{permission.Subject{Role: model.ProjectRoleViewer},
+			[9]bool{Y, Y, N, N, N, N, N, N, N}},
+		{permission.Subject{Role: model.ProjectRoleMember},
+			[9]bool{Y, Y, Y, Y, N, N, N, N, N}},
+		{permission.Subject{Role: model.ProjectRoleMaintainer},
+			[9]bool{Y, Y, Y, Y, Y, Y, Y, Y, N}},
+		{permission.Subject{Role: model.ProjectRoleOwner},
+			[9]bool{Y, Y, Y, Y, Y, Y, Y, Y, Y}},
+		{permission.Subject{Admin: true},
+			[9]bool{Y, Y, Y, Y, Y, Y, Y, Y, Y}},
+		{permission.Subject{Admin: true, Role: model.ProjectRoleViewer},
+			[9]bool{Y, Y, Y, Y, Y, Y, Y, Y, Y}},
+		{permission.Subject{Role: "UNKNOWN"},
+			[9]bool{N, N, N, N, N, N, N, N, N}},
+	} {
+		require.Len(t, permission.Actions, len(td.expect))
+		for i, a := range permission.Actions {
+			name := fmt.Sprintf("%s/admin=%t/%s", td.subject.Role, td.subject.Admin, a)
+			t.Run(name, func(t *testing.T) {
+				require.Equal(t, td.expect[i], permission.Allowed(td.subject, a))
+			})
+		}
+	}
+
+	t.Run("unknown_action", func(t *testing.T) {
+		require.False(t, permission.Allowed(
+			permission.Subject{Admin: true}, permission.Action(0),
+		))
+	})
+}
+
+func TestRequire(t *testing.T) {
+	s := permission.Subject{Role: model.ProjectRoleMember}
+
+	ctx := reqctx.WithRequestContext(
+		context.Background(), slog.Default(), "", "", time.Now(),
+	)
+	err := permission.Require(ctx, s, permission.View)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+
+	ctx = reqctx.WithRequestContext(
+		context.Background(), slog.Default(), "user_a", "", time.Now(),
+	)
+	require.NoError(t, permission.Require(ctx, s, permission.UpdateTask))
+	require.ErrorIs(t,
+		permission.Require(ctx, s, permission.DeleteTask), auth.ErrUnauthorized,
+	)
+}
+
+func TestRoleOf(t *testing.T) {
+	a, b := &model.User{ID: "a"}, &model.User{ID: "b"}
+	p := &model.Project{Roles: []*model.ProjectRoleGrant{
+		{User: a, Role: model.ProjectRoleMaintainer},
+		{User: b, Role: model.ProjectRoleOwner},
+	}}
+	require.Equal(t, model.ProjectRoleMaintainer, permission.RoleOf(p, "a"))
+	require.Equal(t, model.ProjectRoleOwner, permission.RoleOf(p, "b"))
+	require.Equal(t, model.ProjectRole(""), permission.RoleOf(p, "c"))
+	require.Equal(t, []*model.User{b}, p.Owners())
+}
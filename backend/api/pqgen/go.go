@@ -0,0 +1,287 @@
+package pqgen
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// GenerateGo generates the source of a Go client package providing
+// one method per persisted query with typed variables and response structs.
+// Subscriptions are skipped.
+func GenerateGo(
+	schema *ast.Schema, ops []*Operation, packageName string,
+) ([]byte, error) {
+	g := &goGen{schema: schema}
+	c := newTypeCollector(schema)
+	c.collect(ops)
+
+	var body strings.Builder
+	for _, d := range sortedDefinitions(c.enums) {
+		g.writeEnum(&body, d)
+	}
+	for _, d := range sortedDefinitions(c.inputs) {
+		g.writeInput(&body, d)
+	}
+	for _, o := range ops {
+		if o.Definition.Operation == ast.Subscription {
+			continue
+		}
+		g.writeOperation(&body, o)
+	}
+
+	var b strings.Builder
+	b.WriteString("// Code generated by pqgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "// Package %s is a typed client for the persisted queries.\n", packageName)
+	fmt.Fprintf(&b, "package %s\n\nimport (\n", packageName)
+	for _, i := range []string{
+		"bytes", "context", "encoding/json", "fmt", "io", "net/http",
+	} {
+		fmt.Fprintf(&b, "\t%q\n", i)
+	}
+	if g.usesTime {
+		b.WriteString("\t\"time\"\n")
+	}
+	b.WriteString(")\n")
+	b.WriteString(goRuntime)
+	b.WriteString(body.String())
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+type goGen struct {
+	schema   *ast.Schema
+	usesTime bool
+}
+
+func (g *goGen) writeEnum(b *strings.Builder, d *ast.Definition) {
+	fmt.Fprintf(b, "\ntype %s string\n\nconst (\n", d.Name)
+	for _, v := range d.EnumValues {
+		fmt.Fprintf(b, "\t%s%s %s = %q\n", d.Name, enumValueName(v.Name), d.Name, v.Name)
+	}
+	b.WriteString(")\n")
+}
+
+func (g *goGen) writeInput(b *strings.Builder, d *ast.Definition) {
+	fmt.Fprintf(b, "\ntype %s struct {\n", d.Name)
+	for _, f := range d.Fields {
+		optional := !f.Type.NonNull || f.DefaultValue != nil
+		g.writeField(b, f.Name, f.Type, "", optional)
+	}
+	b.WriteString("}\n")
+}
+
+func (g *goGen) writeOperation(b *strings.Builder, o *Operation) {
+	name := typeName(o.Name)
+	vars := o.Definition.VariableDefinitions
+
+	if len(vars) > 0 {
+		fmt.Fprintf(b, "\n// %sVariables are the variables of %s.\n", name, o.Name)
+		fmt.Fprintf(b, "type %sVariables struct {\n", name)
+		for _, v := range vars {
+			g.writeField(b, v.Variable, v.Type, "", isOptional(v))
+		}
+		b.WriteString("}\n")
+	}
+
+	fmt.Fprintf(b, "\n// %sResponse is the data returned by %s.\n", name, o.Name)
+	g.writeStruct(b, name+"Response", o.Definition.SelectionSet)
+
+	fmt.Fprintf(b, "\n// %s executes the persisted %s %s.\n", name, o.Definition.Operation, o.Name)
+	if n := deprecationNote(o.Metadata); n != "" {
+		fmt.Fprintf(b, "//\n// Deprecated: %s\n", n)
+	}
+	if len(vars) > 0 {
+		fmt.Fprintf(b, "func (c *Client) %s(ctx context.Context, v %sVariables) (*%sResponse, error) {\n", name, name, name)
+		fmt.Fprintf(b, "\tr := new(%sResponse)\n", name)
+		fmt.Fprintf(b, "\terr := c.execute(ctx, %q, v, r)\n", o.Name)
+	} else {
+		fmt.Fprintf(b, "func (c *Client) %s(ctx context.Context) (*%sResponse, error) {\n", name, name)
+		fmt.Fprintf(b, "\tr := new(%sResponse)\n", name)
+		fmt.Fprintf(b, "\terr := c.execute(ctx, %q, nil, r)\n", o.Name)
+	}
+	b.WriteString("\treturn r, err\n}\n")
+}
+
+// writeStruct writes the struct type for the selection set s
+// followed by the struct types of the nested selection sets.
+func (g *goGen) writeStruct(
+	b *strings.Builder, structName string, s ast.SelectionSet,
+) {
+	type nested struct {
+		name       string
+		selections ast.SelectionSet
+	}
+	var children []nested
+	fmt.Fprintf(b, "type %s struct {\n", structName)
+	for _, f := range collectFields(s) {
+		var elem string
+		if len(f.Selections) > 0 {
+			elem = strings.TrimSuffix(structName, "Response") + exported(f.Key)
+			children = append(children, nested{elem, f.Selections})
+		}
+		g.writeField(b, f.Key, f.Definition.Type, elem, false)
+	}
+	b.WriteString("}\n")
+	for _, c := range children {
+		b.WriteString("\n")
+		g.writeStruct(b, c.name, c.selections)
+	}
+}
+
+func (g *goGen) writeField(
+	b *strings.Builder, name string, t *ast.Type, elem string, optional bool,
+) {
+	tag := name
+	if optional {
+		tag += ",omitempty"
+		if t.NonNull && t.Elem == nil {
+			c := *t
+			c.NonNull = false
+			t = &c
+		}
+	}
+	fmt.Fprintf(b, "\t%s %s `json:%q`\n", exported(name), g.typeRef(t, elem), tag)
+}
+
+// typeRef returns the Go type for t.
+// elem is the struct type name of selected objects.
+func (g *goGen) typeRef(t *ast.Type, elem string) string {
+	if t.Elem != nil {
+		return "[]" + g.typeRef(t.Elem, elem)
+	}
+	base := elem
+	if base == "" {
+		base = g.namedType(t.NamedType)
+	}
+	if !t.NonNull {
+		return "*" + base
+	}
+	return base
+}
+
+func (g *goGen) namedType(name string) string {
+	switch name {
+	case "ID", "String":
+		return "string"
+	case "Int":
+		return "int"
+	case "Float":
+		return "float64"
+	case "Boolean":
+		return "bool"
+	case "Time":
+		g.usesTime = true
+		return "time.Time"
+	}
+	if d := g.schema.Types[name]; d != nil && d.Kind != ast.Scalar {
+		return name
+	}
+	return "json.RawMessage"
+}
+
+// enumValueName converts IN_PROGRESS to InProgress.
+func enumValueName(v string) string {
+	var b strings.Builder
+	for _, p := range strings.Split(strings.ToLower(v), "_") {
+		b.WriteString(exported(p))
+	}
+	return b.String()
+}
+
+const goRuntime = `
+// Client executes persisted queries via POST /e/<name>.
+type Client struct {
+	// Endpoint is the base URL of the API, such as "https://example.com".
+	Endpoint string
+
+	// HTTPClient performs the requests, http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+
+	// BearerToken is sent as Authorization header if not empty.
+	BearerToken string
+}
+
+// Error is a GraphQL error.
+type Error struct {
+	Message string ` + "`json:\"message\"`" + `
+	Path    []any  ` + "`json:\"path,omitempty\"`" + `
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Errors is returned if the response contains GraphQL errors,
+// the response data may still be partially available.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Message
+	}
+	return fmt.Sprintf("%d errors, first: %s", len(e), e[0].Message)
+}
+
+// HTTPError is returned if the server responds with a status other than 200.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+func (c *Client) execute(
+	ctx context.Context, name string, variables, data any,
+) error {
+	var body io.Reader
+	if variables != nil {
+		b, err := json.Marshal(variables)
+		if err != nil {
+			return fmt.Errorf("encoding variables: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.Endpoint+"/e/"+name, body,
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	var r struct {
+		Data   any    ` + "`json:\"data\"`" + `
+		Errors Errors ` + "`json:\"errors\"`" + `
+	}
+	r.Data = data
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	return nil
+}
+`
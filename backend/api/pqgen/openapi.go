@@ -0,0 +1,332 @@
+package pqgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// OpenAPIInfo is the metadata of a generated OpenAPI document.
+type OpenAPIInfo struct {
+	Title   string
+	Version string
+}
+
+// GenerateOpenAPI generates an OpenAPI 3 document in JSON describing
+// one path per persisted query with the request body schema derived from
+// the variable definitions and the response schema from the selection set.
+// Queries are additionally documented as GET operations.
+// Subscriptions are skipped.
+func GenerateOpenAPI(
+	schema *ast.Schema, ops []*Operation, info OpenAPIInfo,
+) ([]byte, error) {
+	g := &openAPIGen{schema: schema}
+	c := newTypeCollector(schema)
+	c.collect(ops)
+
+	doc := openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    map[string]string{"title": info.Title, "version": info.Version},
+		Paths:   map[string]map[string]*openAPIOperation{},
+	}
+	doc.Components.Schemas = map[string]*openAPISchema{
+		"GraphQLError": {
+			Type:     "object",
+			Required: []string{"message"},
+			Properties: map[string]*openAPISchema{
+				"message":    {Type: "string"},
+				"path":       {Type: "array", Items: &openAPISchema{}},
+				"extensions": {Type: "object"},
+			},
+		},
+	}
+	doc.Components.SecuritySchemes = map[string]any{
+		"bearerAuth": map[string]string{
+			"type": "http", "scheme": "bearer", "bearerFormat": "JWT",
+		},
+	}
+	for _, d := range sortedDefinitions(c.enums) {
+		s := &openAPISchema{Type: "string"}
+		for _, v := range d.EnumValues {
+			s.Enum = append(s.Enum, v.Name)
+		}
+		doc.Components.Schemas[d.Name] = s
+	}
+	for _, d := range sortedDefinitions(c.inputs) {
+		s := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+		for _, f := range d.Fields {
+			s.Properties[f.Name] = g.typeRef(f.Type, nil)
+			if f.Type.NonNull && f.DefaultValue == nil {
+				s.Required = append(s.Required, f.Name)
+			}
+		}
+		doc.Components.Schemas[d.Name] = s
+	}
+
+	for _, o := range ops {
+		if o.Definition.Operation == ast.Subscription {
+			continue
+		}
+		name := typeName(o.Name)
+		path := map[string]*openAPIOperation{}
+		doc.Paths["/e/"+o.Name] = path
+
+		doc.Components.Schemas[name+"Response"] = &openAPISchema{
+			Type: "object",
+			Properties: map[string]*openAPISchema{
+				"data": g.selectionSet(o.Definition.SelectionSet),
+				"errors": {
+					Type:  "array",
+					Items: ref("GraphQLError"),
+				},
+			},
+		}
+		var variables *openAPISchema
+		if vars := o.Definition.VariableDefinitions; len(vars) > 0 {
+			variables = &openAPISchema{
+				Type: "object", Properties: map[string]*openAPISchema{},
+			}
+			for _, v := range vars {
+				s := g.typeRef(v.Type, nil)
+				if v.DefaultValue != nil {
+					if s.Ref != "" {
+						s = &openAPISchema{AllOf: []*openAPISchema{s}}
+					}
+					s.Default, _ = v.DefaultValue.Value(nil)
+				}
+				variables.Properties[v.Variable] = s
+				if !isOptional(v) {
+					variables.Required = append(variables.Required, v.Variable)
+				}
+			}
+			doc.Components.Schemas[name+"Variables"] = variables
+		}
+
+		post := g.operation(o, name, "")
+		if variables != nil {
+			post.RequestBody = &openAPIRequestBody{
+				Required: len(variables.Required) > 0,
+				Content:  jsonContent(ref(name + "Variables")),
+			}
+		}
+		if o.Metadata.MaxBodySize > 0 {
+			post.Responses[strconv.Itoa(http.StatusRequestEntityTooLarge)] = &openAPIResponse{
+				Description: fmt.Sprintf(
+					"The request body exceeds %d bytes.", o.Metadata.MaxBodySize,
+				),
+			}
+		}
+		path["post"] = post
+
+		if o.Definition.Operation != ast.Query {
+			continue
+		}
+		get := g.operation(o, name, "Get")
+		if variables != nil {
+			get.Parameters = []*openAPIParameter{{
+				Name:     "variables",
+				In:       "query",
+				Required: len(variables.Required) > 0,
+				Content:  jsonContent(ref(name + "Variables")),
+			}}
+		}
+		get.Parameters = append(get.Parameters, &openAPIParameter{
+			Name:   "If-None-Match",
+			In:     "header",
+			Schema: &openAPISchema{Type: "string"},
+		})
+		get.Responses[strconv.Itoa(http.StatusNotModified)] = &openAPIResponse{
+			Description: "The response matches the ETag in If-None-Match.",
+		}
+		path["get"] = get
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encoding OpenAPI document: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       map[string]string                       `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas         map[string]*openAPISchema `json:"schemas"`
+		SecuritySchemes map[string]any            `json:"securitySchemes"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string                       `json:"name"`
+	In       string                       `json:"in"`
+	Required bool                         `json:"required,omitempty"`
+	Schema   *openAPISchema               `json:"schema,omitempty"`
+	Content  map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref        string                    `json:"$ref,omitempty"`
+	AllOf      []*openAPISchema          `json:"allOf,omitempty"`
+	Type       string                    `json:"type,omitempty"`
+	Format     string                    `json:"format,omitempty"`
+	Nullable   bool                      `json:"nullable,omitempty"`
+	Enum       []string                  `json:"enum,omitempty"`
+	Items      *openAPISchema            `json:"items,omitempty"`
+	Properties map[string]*openAPISchema `json:"properties,omitempty"`
+	Required   []string                  `json:"required,omitempty"`
+	Default    any                       `json:"default,omitempty"`
+}
+
+func ref(name string) *openAPISchema {
+	return &openAPISchema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(s *openAPISchema) map[string]*openAPIMediaType {
+	return map[string]*openAPIMediaType{"application/json": {Schema: s}}
+}
+
+type openAPIGen struct{ schema *ast.Schema }
+
+// operation returns the operation of o with the responses
+// shared by all methods.
+func (g *openAPIGen) operation(
+	o *Operation, name, methodSuffix string,
+) *openAPIOperation {
+	op := &openAPIOperation{
+		OperationID: lowerFirst(name) + methodSuffix,
+		Summary:     fmt.Sprintf("Persisted %s %s", o.Definition.Operation, o.Name),
+		Responses: map[string]*openAPIResponse{
+			"200": {
+				Description: "The GraphQL response.",
+				Content:     jsonContent(ref(name + "Response")),
+			},
+		},
+	}
+	m := o.Metadata
+	if m.AuthRequired {
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+		op.Responses[strconv.Itoa(http.StatusUnauthorized)] = &openAPIResponse{
+			Description: "Authentication is required.",
+		}
+	}
+	if len(m.Roles) > 0 {
+		op.Description = "Requires any of the roles: " + strings.Join(m.Roles, ", ") + "."
+		op.Responses[strconv.Itoa(http.StatusForbidden)] = &openAPIResponse{
+			Description: "The user's role isn't allowed.",
+		}
+	}
+	if n := deprecationNote(m); n != "" {
+		op.Deprecated = true
+		op.Description = strings.TrimSpace(op.Description + " Deprecated: " + n)
+	}
+	if !m.Sunset.IsZero() {
+		op.Responses[strconv.Itoa(http.StatusGone)] = &openAPIResponse{
+			Description: "The query is past its sunset date.",
+		}
+	}
+	if m.RateLimit.Requests > 0 {
+		op.Responses[strconv.Itoa(http.StatusTooManyRequests)] = &openAPIResponse{
+			Description: fmt.Sprintf(
+				"The rate limit of %d requests per %s is exceeded.",
+				m.RateLimit.Requests, m.RateLimit.Period,
+			),
+		}
+	}
+	return op
+}
+
+// selectionSet returns the object schema for the selection set s.
+func (g *openAPIGen) selectionSet(s ast.SelectionSet) *openAPISchema {
+	o := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+	for _, f := range collectFields(s) {
+		var obj *openAPISchema
+		if len(f.Selections) > 0 {
+			obj = g.selectionSet(f.Selections)
+		}
+		o.Properties[f.Key] = g.typeRef(f.Definition.Type, obj)
+		if f.Definition.Type.NonNull {
+			o.Required = append(o.Required, f.Key)
+		}
+	}
+	return o
+}
+
+// typeRef returns the schema for t.
+// obj is the object schema of selected objects.
+func (g *openAPIGen) typeRef(t *ast.Type, obj *openAPISchema) *openAPISchema {
+	var s *openAPISchema
+	switch {
+	case t.Elem != nil:
+		s = &openAPISchema{Type: "array", Items: g.typeRef(t.Elem, obj)}
+	case obj != nil:
+		c := *obj
+		s = &c
+	default:
+		s = g.namedType(t.NamedType)
+	}
+	if !t.NonNull {
+		if s.Ref != "" {
+			// Siblings of $ref are ignored in OpenAPI 3.0
+			s = &openAPISchema{AllOf: []*openAPISchema{s}}
+		}
+		s.Nullable = true
+	}
+	return s
+}
+
+func (g *openAPIGen) namedType(name string) *openAPISchema {
+	switch name {
+	case "ID", "String":
+		return &openAPISchema{Type: "string"}
+	case "Int":
+		return &openAPISchema{Type: "integer", Format: "int32"}
+	case "Float":
+		return &openAPISchema{Type: "number", Format: "double"}
+	case "Boolean":
+		return &openAPISchema{Type: "boolean"}
+	case "Time":
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	}
+	if d := g.schema.Types[name]; d != nil && d.Kind != ast.Scalar {
+		return ref(name)
+	}
+	return &openAPISchema{}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,217 @@
+// Package pqgen generates typed clients and OpenAPI documents
+// for the persisted queries.
+package pqgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Operation is a persisted query to generate a client function for.
+type Operation struct {
+	// Name is the key of the persisted query.
+	Name string
+
+	Definition *ast.OperationDefinition
+	Metadata   gqlpq.Metadata
+}
+
+// Load reads all persisted queries in dirPath and validates them
+// against schema. Returns an error if any of the queries is invalid.
+func Load(schema *ast.Schema, dirPath string) ([]*Operation, error) {
+	_, diagnostics, err := gqlpq.Lint(schema, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range diagnostics {
+		if d.Severity == gqlpq.SeverityError {
+			return nil, fmt.Errorf("invalid persisted query: %s", d)
+		}
+	}
+	pq := gqlpq.NewWithSchema(schema)
+	if err := pq.Load(dirPath); err != nil {
+		return nil, err
+	}
+	return Operations(pq), nil
+}
+
+// Operations returns the operations of all persisted queries
+// currently loaded in pq sorted by name.
+func Operations(pq *gqlpq.PersistedQueries) []*Operation {
+	queries := pq.Queries()
+	ops := make([]*Operation, len(queries))
+	for i, q := range queries {
+		ops[i] = &Operation{
+			Name:       q.Key,
+			Definition: q.Document.Operations[0],
+			Metadata:   q.Metadata,
+		}
+	}
+	return ops
+}
+
+// selectedField is a field selected in a selection set
+// with all selections of the same response key merged.
+type selectedField struct {
+	// Key is the alias or the name of the field.
+	Key        string
+	Definition *ast.FieldDefinition
+	Selections ast.SelectionSet
+}
+
+// collectFields flattens fragments and merges fields by response key
+// preserving the order of the first occurrence.
+func collectFields(s ast.SelectionSet) []*selectedField {
+	var fields []*selectedField
+	byKey := map[string]*selectedField{}
+	var walk func(ast.SelectionSet)
+	walk = func(s ast.SelectionSet) {
+		for _, sel := range s {
+			switch sel := sel.(type) {
+			case *ast.Field:
+				key := sel.Alias
+				if key == "" {
+					key = sel.Name
+				}
+				if f, ok := byKey[key]; ok {
+					f.Selections = append(f.Selections, sel.SelectionSet...)
+					continue
+				}
+				f := &selectedField{
+					Key:        key,
+					Definition: sel.Definition,
+					Selections: append(ast.SelectionSet{}, sel.SelectionSet...),
+				}
+				byKey[key] = f
+				fields = append(fields, f)
+			case *ast.InlineFragment:
+				walk(sel.SelectionSet)
+			case *ast.FragmentSpread:
+				if sel.Definition != nil {
+					walk(sel.Definition.SelectionSet)
+				}
+			}
+		}
+	}
+	walk(s)
+	return fields
+}
+
+// typeCollector collects the enum and input types
+// referenced by the generated operations.
+type typeCollector struct {
+	schema *ast.Schema
+	enums  map[string]*ast.Definition
+	inputs map[string]*ast.Definition
+}
+
+func newTypeCollector(schema *ast.Schema) *typeCollector {
+	return &typeCollector{
+		schema: schema,
+		enums:  map[string]*ast.Definition{},
+		inputs: map[string]*ast.Definition{},
+	}
+}
+
+func (c *typeCollector) collectType(t *ast.Type) {
+	for t.Elem != nil {
+		t = t.Elem
+	}
+	d := c.schema.Types[t.NamedType]
+	if d == nil {
+		return
+	}
+	switch d.Kind {
+	case ast.Enum:
+		c.enums[d.Name] = d
+	case ast.InputObject:
+		if _, ok := c.inputs[d.Name]; ok {
+			return
+		}
+		c.inputs[d.Name] = d
+		for _, f := range d.Fields {
+			c.collectType(f.Type)
+		}
+	}
+}
+
+func (c *typeCollector) collectSelections(s ast.SelectionSet) {
+	for _, f := range collectFields(s) {
+		c.collectType(f.Definition.Type)
+		c.collectSelections(f.Selections)
+	}
+}
+
+func (c *typeCollector) collect(ops []*Operation) {
+	for _, o := range ops {
+		for _, v := range o.Definition.VariableDefinitions {
+			c.collectType(v.Type)
+		}
+		c.collectSelections(o.Definition.SelectionSet)
+	}
+}
+
+func sortedDefinitions(m map[string]*ast.Definition) []*ast.Definition {
+	s := make([]*ast.Definition, 0, len(m))
+	for _, d := range m {
+		s = append(s, d)
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Name < s[j].Name })
+	return s
+}
+
+// isOptional returns true if the variable can be omitted.
+func isOptional(v *ast.VariableDefinition) bool {
+	return !v.Type.NonNull || v.DefaultValue != nil
+}
+
+// deprecationNote describes the deprecation and sunset declared in m,
+// such as "Since 2024-01-31, sunset on 2024-06-30. Use qry_users instead.".
+// Returns "" if the query is neither deprecated nor sunset.
+func deprecationNote(m gqlpq.Metadata) string {
+	var parts []string
+	if !m.Deprecated.IsZero() {
+		parts = append(parts, "since "+m.Deprecated.Format(time.DateOnly))
+	}
+	if !m.Sunset.IsZero() {
+		parts = append(parts, "sunset on "+m.Sunset.Format(time.DateOnly))
+	}
+	if len(parts) < 1 {
+		return ""
+	}
+	n := exported(strings.Join(parts, ", ")) + "."
+	if m.DeprecationReason != "" {
+		n += " " + m.DeprecationReason
+	}
+	return n
+}
+
+// typeName returns the exported identifier for the persisted query name,
+// such as QryTask for qry_task.
+func typeName(name string) string {
+	var b strings.Builder
+	for _, p := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == '/' || r == '.'
+	}) {
+		b.WriteString(exported(p))
+	}
+	return b.String()
+}
+
+// exported returns s with the first letter capitalized
+// and common initialisms upper-cased.
+func exported(s string) string {
+	switch strings.ToLower(s) {
+	case "id", "url", "api":
+		return strings.ToUpper(s)
+	}
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,209 @@
+package pqgen_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/pqgen"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+const testSchema = `
+	scalar Time
+	enum Status { TODO IN_PROGRESS }
+	input Filter { status: [Status!], after: Time }
+	type Item { id: ID! title: String! status: Status! due: Time tags: [String!]! }
+	type Query { items(filter: Filter, limit: Int = 10): [Item!]! }
+	type Mutation { rename(id: ID!, title: String!): Item }
+	type Subscription { itemUpdated: Item! }
+`
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+	require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+}
+
+func loadTestOperations(t *testing.T) (*ast.Schema, []*pqgen.Operation) {
+	t.Helper()
+	schemaDir, dir := t.TempDir(), t.TempDir()
+	writeFile(t, schemaDir, "schema.graphqls", testSchema)
+	writeFile(t, dir, "qry_items.graphql", `
+		query ($filter: Filter, $limit: Int) {
+			items(filter: $filter, limit: $limit) { ...I due }
+		}
+		fragment I on Item { id title: title status tags }
+	`)
+	writeFile(t, dir, "mut_rename.graphql", `
+		# @roles admin
+		# @maxBodySize 1KiB
+		mutation ($id: ID!, $title: String!) {
+			rename(id: $id, title: $title) { id }
+		}
+	`)
+	writeFile(t, dir, "sub_item.graphql", "subscription { itemUpdated { id } }")
+	writeFile(t, dir, "legacy/v1/qry_items.graphql", `
+		# @deprecated 2024-01-31 Use qry_items instead.
+		# @sunset 2024-06-30
+		query { items { id } }
+	`)
+	schema, err := gqlpq.LoadSchema(schemaDir)
+	require.NoError(t, err)
+	ops, err := pqgen.Load(schema, dir)
+	require.NoError(t, err)
+	return schema, ops
+}
+
+func TestLoadInvalid(t *testing.T) {
+	schemaDir, dir := t.TempDir(), t.TempDir()
+	writeFile(t, schemaDir, "schema.graphqls", testSchema)
+	writeFile(t, dir, "invalid.graphql", "query { nope }")
+	schema, err := gqlpq.LoadSchema(schemaDir)
+	require.NoError(t, err)
+	_, err = pqgen.Load(schema, dir)
+	require.ErrorContains(t, err, "invalid.graphql")
+}
+
+func TestGenerateGo(t *testing.T) {
+	schema, ops := loadTestOperations(t)
+
+	src, err := pqgen.GenerateGo(schema, ops, "testclient")
+	require.NoError(t, err)
+	s := string(src)
+	require.Contains(t, s, "package testclient")
+	require.Contains(t, s, `StatusInProgress Status = "IN_PROGRESS"`)
+	require.Contains(t, s, "type Filter struct {\n\tStatus []Status   `json:\"status,omitempty\"`\n\tAfter  *time.Time `json:\"after,omitempty\"`\n}")
+	require.Contains(t, s, "type QryItemsVariables struct {\n\tFilter *Filter `json:\"filter,omitempty\"`\n\tLimit  *int    `json:\"limit,omitempty\"`\n}")
+	require.Contains(t, s, "type QryItemsItems struct {\n"+
+		"\tID     string     `json:\"id\"`\n"+
+		"\tTitle  string     `json:\"title\"`\n"+
+		"\tStatus Status     `json:\"status\"`\n"+
+		"\tTags   []string   `json:\"tags\"`\n"+
+		"\tDue    *time.Time `json:\"due\"`\n}")
+	require.Contains(t, s, "func (c *Client) MutRename(ctx context.Context, v MutRenameVariables) (*MutRenameResponse, error)")
+	require.Contains(t, s, "Rename *MutRenameRename `json:\"rename\"`")
+	require.NotContains(t, s, "SubItem")
+	require.Contains(t, s, "// LegacyV1QryItems executes the persisted query legacy/v1/qry_items.\n"+
+		"//\n// Deprecated: Since 2024-01-31, sunset on 2024-06-30. Use qry_items instead.\n"+
+		"func (c *Client) LegacyV1QryItems(ctx context.Context) (*LegacyV1QryItemsResponse, error)")
+	require.Contains(t, s, `c.execute(ctx, "legacy/v1/qry_items", nil, r)`)
+}
+
+func TestGenerateTS(t *testing.T) {
+	schema, ops := loadTestOperations(t)
+
+	src, err := pqgen.GenerateTS(schema, ops)
+	require.NoError(t, err)
+	s := string(src)
+	require.Contains(t, s, `export type Status = "TODO" | "IN_PROGRESS";`)
+	require.Contains(t, s, "export interface QryItemsVariables {\n"+
+		"  filter?: Filter | null;\n"+
+		"  limit?: number | null;\n}")
+	require.Contains(t, s, "export interface QryItemsResponse {\n"+
+		"  items: Array<{\n"+
+		"    id: string;\n"+
+		"    title: string;\n"+
+		"    status: Status;\n"+
+		"    tags: Array<string>;\n"+
+		"    due: string | null;\n"+
+		"  }>;\n}")
+	require.Contains(t, s, "  \"sub_item\": {\n"+
+		"    type: \"subscription\";\n"+
+		"    variables: Record<string, never>;\n"+
+		"    response: SubItemResponse;\n  };")
+	require.Contains(t, s, "  /** @deprecated Since 2024-01-31, sunset on 2024-06-30. "+
+		"Use qry_items instead. */\n"+
+		"  \"legacy/v1/qry_items\": {\n")
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	schema, ops := loadTestOperations(t)
+
+	src, err := pqgen.GenerateOpenAPI(schema, ops, pqgen.OpenAPIInfo{
+		Title: "Test", Version: "1",
+	})
+	require.NoError(t, err)
+
+	var doc struct {
+		Info  map[string]string `json:"info"`
+		Paths map[string]map[string]struct {
+			OperationID string                     `json:"operationId"`
+			Description string                     `json:"description"`
+			Deprecated  bool                       `json:"deprecated"`
+			Security    []map[string][]string      `json:"security"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(src, &doc))
+	require.Equal(t, map[string]string{"title": "Test", "version": "1"}, doc.Info)
+
+	require.Len(t, doc.Paths, 3, "subscriptions are skipped")
+	require.Len(t, doc.Paths["/e/qry_items"], 2)
+	require.Equal(t, "qryItemsGet", doc.Paths["/e/qry_items"]["get"].OperationID)
+	require.Nil(t, doc.Paths["/e/qry_items"]["post"].Security)
+
+	rename := doc.Paths["/e/mut_rename"]
+	require.Len(t, rename, 1, "mutations aren't available via GET")
+	require.Equal(t, "mutRename", rename["post"].OperationID)
+	require.Equal(t, []map[string][]string{{"bearerAuth": {}}}, rename["post"].Security)
+	require.Contains(t, rename["post"].Responses, "401")
+	require.Contains(t, rename["post"].Responses, "403")
+	require.Contains(t, rename["post"].Responses, "413")
+	require.False(t, rename["post"].Deprecated)
+
+	legacy := doc.Paths["/e/legacy/v1/qry_items"]
+	require.Len(t, legacy, 2)
+	for _, op := range legacy {
+		require.True(t, op.Deprecated)
+		require.Equal(t, "Deprecated: Since 2024-01-31, sunset on 2024-06-30. "+
+			"Use qry_items instead.", op.Description)
+		require.Contains(t, op.Responses, "410")
+	}
+
+	require.JSONEq(t, `{"type": "string", "enum": ["TODO", "IN_PROGRESS"]}`,
+		string(doc.Components.Schemas["Status"]))
+	require.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"filter": {"allOf": [{"$ref": "#/components/schemas/Filter"}], "nullable": true},
+			"limit": {"type": "integer", "format": "int32", "nullable": true}
+		}
+	}`, string(doc.Components.Schemas["QryItemsVariables"]))
+	require.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"data": {
+				"type": "object",
+				"properties": {
+					"items": {
+						"type": "array",
+						"items": {
+							"type": "object",
+							"properties": {
+								"id": {"type": "string"},
+								"title": {"type": "string"},
+								"status": {"$ref": "#/components/schemas/Status"},
+								"tags": {"type": "array", "items": {"type": "string"}},
+								"due": {"type": "string", "format": "date-time", "nullable": true}
+							},
+							"required": ["id", "title", "status", "tags"]
+						}
+					}
+				},
+				"required": ["items"]
+			},
+			"errors": {
+				"type": "array",
+				"items": {"$ref": "#/components/schemas/GraphQLError"}
+			}
+		}
+	}`, string(doc.Components.Schemas["QryItemsResponse"]))
+}
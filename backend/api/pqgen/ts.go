@@ -0,0 +1,141 @@
+package pqgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// GenerateTS generates TypeScript definitions of the variables and
+// responses of all persisted queries and an Operations interface
+// mapping each persisted query name to its types.
+func GenerateTS(schema *ast.Schema, ops []*Operation) ([]byte, error) {
+	g := &tsGen{schema: schema}
+	c := newTypeCollector(schema)
+	c.collect(ops)
+
+	var b strings.Builder
+	b.WriteString("// Code generated by pqgen. DO NOT EDIT.\n")
+	for _, d := range sortedDefinitions(c.enums) {
+		g.writeEnum(&b, d)
+	}
+	for _, d := range sortedDefinitions(c.inputs) {
+		g.writeInput(&b, d)
+	}
+	for _, o := range ops {
+		g.writeOperation(&b, o)
+	}
+
+	b.WriteString("\nexport interface Operations {\n")
+	for _, o := range ops {
+		name := typeName(o.Name)
+		vars := "Record<string, never>"
+		if len(o.Definition.VariableDefinitions) > 0 {
+			vars = name + "Variables"
+		}
+		if n := deprecationNote(o.Metadata); n != "" {
+			fmt.Fprintf(&b, "  /** @deprecated %s */\n", n)
+		}
+		fmt.Fprintf(
+			&b, "  %q: {\n    type: %q;\n    variables: %s;\n    response: %sResponse;\n  };\n",
+			o.Name, o.Definition.Operation, vars, name,
+		)
+	}
+	b.WriteString("}\n\nexport type OperationName = keyof Operations;\n")
+	return []byte(b.String()), nil
+}
+
+type tsGen struct{ schema *ast.Schema }
+
+func (g *tsGen) writeEnum(b *strings.Builder, d *ast.Definition) {
+	v := make([]string, len(d.EnumValues))
+	for i, e := range d.EnumValues {
+		v[i] = fmt.Sprintf("%q", e.Name)
+	}
+	fmt.Fprintf(b, "\nexport type %s = %s;\n", d.Name, strings.Join(v, " | "))
+}
+
+func (g *tsGen) writeInput(b *strings.Builder, d *ast.Definition) {
+	fmt.Fprintf(b, "\nexport interface %s {\n", d.Name)
+	for _, f := range d.Fields {
+		optional := !f.Type.NonNull || f.DefaultValue != nil
+		g.writeProperty(b, "  ", f.Name, g.typeRef(f.Type, ""), optional)
+	}
+	b.WriteString("}\n")
+}
+
+func (g *tsGen) writeOperation(b *strings.Builder, o *Operation) {
+	name := typeName(o.Name)
+	if vars := o.Definition.VariableDefinitions; len(vars) > 0 {
+		fmt.Fprintf(b, "\n/** Variables of %s. */\n", o.Name)
+		fmt.Fprintf(b, "export interface %sVariables {\n", name)
+		for _, v := range vars {
+			g.writeProperty(b, "  ", v.Variable, g.typeRef(v.Type, ""), isOptional(v))
+		}
+		b.WriteString("}\n")
+	}
+	fmt.Fprintf(b, "\n/** Data returned by %s. */\n", o.Name)
+	fmt.Fprintf(b, "export interface %sResponse ", name)
+	g.writeObject(b, "", o.Definition.SelectionSet)
+	b.WriteString("\n")
+}
+
+// writeObject writes an inline object type for the selection set s.
+func (g *tsGen) writeObject(
+	b *strings.Builder, indent string, s ast.SelectionSet,
+) {
+	b.WriteString("{\n")
+	for _, f := range collectFields(s) {
+		var obj string
+		if len(f.Selections) > 0 {
+			var o strings.Builder
+			g.writeObject(&o, indent+"  ", f.Selections)
+			obj = o.String()
+		}
+		g.writeProperty(b, indent+"  ", f.Key, g.typeRef(f.Definition.Type, obj), false)
+	}
+	b.WriteString(indent + "}")
+}
+
+func (g *tsGen) writeProperty(
+	b *strings.Builder, indent, name, typ string, optional bool,
+) {
+	if optional {
+		fmt.Fprintf(b, "%s%s?: %s;\n", indent, name, strings.TrimSuffix(typ, " | null")+" | null")
+		return
+	}
+	fmt.Fprintf(b, "%s%s: %s;\n", indent, name, typ)
+}
+
+// typeRef returns the TypeScript type for t.
+// obj is the inline object type of selected objects.
+func (g *tsGen) typeRef(t *ast.Type, obj string) string {
+	var s string
+	if t.Elem != nil {
+		s = "Array<" + g.typeRef(t.Elem, obj) + ">"
+	} else if obj != "" {
+		s = obj
+	} else {
+		s = g.namedType(t.NamedType)
+	}
+	if !t.NonNull {
+		return s + " | null"
+	}
+	return s
+}
+
+func (g *tsGen) namedType(name string) string {
+	switch name {
+	case "ID", "String", "Time":
+		return "string"
+	case "Int", "Float":
+		return "number"
+	case "Boolean":
+		return "boolean"
+	}
+	if d := g.schema.Types[name]; d != nil && d.Kind != ast.Scalar {
+		return name
+	}
+	return "unknown"
+}
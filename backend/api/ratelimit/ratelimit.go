@@ -0,0 +1,82 @@
+// Package ratelimit provides a thread-safe keyed token bucket rate limiter.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter limits the rate of events per key.
+// Every key is given a token bucket of capacity requests
+// that's refilled within period.
+type Limiter struct {
+	lock    sync.Mutex
+	buckets map[string]*bucket
+	lastGC  time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration
+}
+
+// New creates a new limiter.
+func New() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket)}
+}
+
+// Allow reports whether an event for key may happen at now
+// given a limit of requests per period, and consumes a token if so.
+// Allow is safe for concurrent use.
+func (l *Limiter) Allow(
+	key string, requests int, period time.Duration, now time.Time,
+) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.collectGarbage(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(requests), last: now}
+		l.buckets[key] = b
+	}
+	b.period = period
+
+	// Refill
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(requests) * float64(elapsed) / float64(period)
+		if b.tokens > float64(requests) {
+			b.tokens = float64(requests)
+		}
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Len returns the number of buckets tracked.
+func (l *Limiter) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.buckets)
+}
+
+// collectGarbage removes buckets that have been
+// completely refilled at most once per minute.
+func (l *Limiter) collectGarbage(now time.Time) {
+	if now.Sub(l.lastGC) < time.Minute {
+		return
+	}
+	l.lastGC = now
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= b.period {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	l := ratelimit.New()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		require.True(t, l.Allow("a", 3, time.Minute, now), i)
+	}
+	require.False(t, l.Allow("a", 3, time.Minute, now))
+
+	// Other keys are not affected.
+	require.True(t, l.Allow("b", 3, time.Minute, now))
+
+	// One token is refilled every 20 seconds.
+	now = now.Add(19 * time.Second)
+	require.False(t, l.Allow("a", 3, time.Minute, now))
+	now = now.Add(time.Second)
+	require.True(t, l.Allow("a", 3, time.Minute, now))
+	require.False(t, l.Allow("a", 3, time.Minute, now))
+
+	// The bucket never exceeds its capacity.
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		require.True(t, l.Allow("a", 3, time.Minute, now), i)
+	}
+	require.False(t, l.Allow("a", 3, time.Minute, now))
+}
+
+func TestGarbageCollection(t *testing.T) {
+	l := ratelimit.New()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.True(t, l.Allow("a", 1, time.Second, now))
+	require.True(t, l.Allow("b", 1, time.Hour, now))
+	require.Equal(t, 2, l.Len())
+
+	// a is refilled and removed, b isn't.
+	now = now.Add(2 * time.Minute)
+	require.True(t, l.Allow("c", 1, time.Second, now))
+	require.Equal(t, 2, l.Len())
+	require.False(t, l.Allow("b", 1, time.Hour, now))
+}
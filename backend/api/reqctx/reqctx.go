@@ -5,6 +5,8 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/romshark/taskhub/api/graph/model"
+
 	"github.com/oklog/ulid"
 	"golang.org/x/exp/slog"
 )
@@ -32,6 +34,16 @@ func WithRequestContext(
 	})
 }
 
+// WithPersistedQueryName returns a copy of ctx with a copy of
+// its request context referring to the persisted query name.
+func WithPersistedQueryName(
+	ctx context.Context, persistedQueryName string,
+) context.Context {
+	c := *GetRequestContext(ctx)
+	c.PersistedQueryName = persistedQueryName
+	return context.WithValue(ctx, ctxKeyRequestContext, &c)
+}
+
 func GetRequestContext(ctx context.Context) *RequestContext {
 	c := ctx.Value(ctxKeyRequestContext)
 	if c != nil {
@@ -41,8 +53,18 @@ func GetRequestContext(ctx context.Context) *RequestContext {
 }
 
 type RequestContext struct {
-	RequestID          string
-	UserID             string
+	RequestID string
+	UserID    string
+	// SessionID is the ID of the session the access token
+	// of an authenticated client was issued for.
+	SessionID string
+	// APIKeyID is the ID of the API key an authenticated client
+	// authenticated with, empty for access tokens.
+	APIKeyID string
+	// APIKeyScopes are the scopes of the API key
+	// the client authenticated with.
+	APIKeyScopes       []model.APIKeyScope
+	UserAgent          string
 	Log                *slog.Logger
 	PersistedQueryName string
 	Start              time.Time
@@ -0,0 +1,162 @@
+// Package respcache provides a thread-safe in-process cache
+// for persisted query responses.
+package respcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Key identifies a cached response.
+type Key struct {
+	// Query is the name of the persisted query.
+	Query string
+
+	// Hash is the hash of the persisted query, which makes sure
+	// responses of a previous version of a reloaded query aren't served.
+	Hash string
+
+	// UserID is the ID of the authenticated user or "" if anonymous.
+	UserID string
+
+	// APIKeyID is the ID of the API key the client is authenticated by
+	// or "" if none, since the scopes of API keys can restrict
+	// what the user is allowed to see.
+	APIKeyID string
+
+	// Variables are the normalized variables.
+	Variables string
+}
+
+// Stats is a snapshot of the metrics of a Cache.
+type Stats struct {
+	// Entries is the number of cached responses including expired ones
+	// that haven't been evicted yet.
+	Entries int `json:"entries"`
+
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"`
+
+	// Queries are the hits and misses by persisted query name.
+	Queries map[string]QueryStats `json:"queries"`
+}
+
+// QueryStats are the metrics of a single persisted query.
+type QueryStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// Cache is a response cache with a limited number of entries.
+// When the cache is full, expired entries are evicted first,
+// then entries closest to expiry.
+type Cache struct {
+	maxEntries int
+
+	lock          sync.Mutex
+	entries       map[Key]entry
+	generation    uint64
+	invalidations uint64
+	queries       map[string]QueryStats
+}
+
+type entry struct {
+	value   []byte
+	expires time.Time
+}
+
+// New creates a new cache of at most maxEntries responses.
+// Panics if maxEntries is smaller than 1.
+func New(maxEntries int) *Cache {
+	if maxEntries < 1 {
+		panic("maxEntries must be positive")
+	}
+	return &Cache{
+		maxEntries: maxEntries,
+		entries:    make(map[Key]entry),
+		queries:    make(map[string]QueryStats),
+	}
+}
+
+// Get returns the response cached for k unless it expired at now
+// and the current generation, which must be passed to Set when
+// caching the response of a miss.
+// Get is safe for concurrent use.
+func (c *Cache) Get(k Key, now time.Time) (value []byte, generation uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s := c.queries[k.Query]
+	defer func() { c.queries[k.Query] = s }()
+	e, ok := c.entries[k]
+	if !ok || !now.Before(e.expires) {
+		s.Misses++
+		return nil, c.generation
+	}
+	s.Hits++
+	return e.value, c.generation
+}
+
+// Set caches value for k for ttl from now unless the cache was invalidated
+// since generation was returned by Get, in which case value may be stale.
+// Set is safe for concurrent use.
+func (c *Cache) Set(
+	k Key, value []byte, now time.Time, ttl time.Duration, generation uint64,
+) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if _, ok := c.entries[k]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[k] = entry{value: value, expires: now.Add(ttl)}
+}
+
+// evict removes all expired entries, or the entry
+// closest to expiry if none expired.
+func (c *Cache) evict(now time.Time) {
+	var first Key
+	var firstExpires time.Time
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if firstExpires.IsZero() || e.expires.Before(firstExpires) {
+			first, firstExpires = k, e.expires
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, first)
+	}
+}
+
+// Invalidate removes all cached responses.
+// Invalidate is safe for concurrent use.
+func (c *Cache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.generation++
+	c.invalidations++
+	c.entries = make(map[Key]entry)
+}
+
+// Stats returns the current metrics.
+// Stats is safe for concurrent use.
+func (c *Cache) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	s := Stats{
+		Entries:       len(c.entries),
+		Invalidations: c.invalidations,
+		Queries:       make(map[string]QueryStats, len(c.queries)),
+	}
+	for q, qs := range c.queries {
+		s.Hits += qs.Hits
+		s.Misses += qs.Misses
+		s.Queries[q] = qs
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package respcache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/respcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSet(t *testing.T) {
+	c := respcache.New(8)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	k := respcache.Key{Query: "q", Hash: "h", UserID: "u", Variables: "{}"}
+
+	v, gen := c.Get(k, now)
+	require.Nil(t, v)
+	c.Set(k, []byte("a"), now, time.Minute, gen)
+
+	v, _ = c.Get(k, now.Add(59*time.Second))
+	require.Equal(t, []byte("a"), v)
+
+	other := k
+	other.UserID = "other"
+	v, _ = c.Get(other, now)
+	require.Nil(t, v)
+
+	v, _ = c.Get(k, now.Add(time.Minute))
+	require.Nil(t, v, "expired")
+
+	require.Equal(t, respcache.Stats{
+		Entries: 1,
+		Hits:    1,
+		Misses:  3,
+		Queries: map[string]respcache.QueryStats{
+			"q": {Hits: 1, Misses: 3},
+		},
+	}, c.Stats())
+}
+
+func TestInvalidate(t *testing.T) {
+	c := respcache.New(8)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	k := respcache.Key{Query: "q"}
+
+	_, gen := c.Get(k, now)
+	c.Set(k, []byte("a"), now, time.Minute, gen)
+	c.Invalidate()
+	v, _ := c.Get(k, now)
+	require.Nil(t, v)
+
+	// A response computed before the invalidation must not be cached
+	c.Set(k, []byte("stale"), now, time.Minute, gen)
+	v, gen = c.Get(k, now)
+	require.Nil(t, v)
+
+	c.Set(k, []byte("b"), now, time.Minute, gen)
+	v, _ = c.Get(k, now)
+	require.Equal(t, []byte("b"), v)
+	require.Equal(t, uint64(1), c.Stats().Invalidations)
+}
+
+func TestEviction(t *testing.T) {
+	c := respcache.New(2)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	a, b, d := respcache.Key{Query: "a"}, respcache.Key{Query: "b"}, respcache.Key{Query: "d"}
+
+	c.Set(a, []byte("a"), now, time.Hour, 0)
+	c.Set(b, []byte("b"), now, time.Minute, 0)
+	c.Set(d, []byte("d"), now, time.Hour, 0)
+	require.Equal(t, 2, c.Stats().Entries)
+
+	v, _ := c.Get(b, now)
+	require.Nil(t, v, "entry closest to expiry evicted")
+	v, _ = c.Get(a, now)
+	require.Equal(t, []byte("a"), v)
+	v, _ = c.Get(d, now)
+	require.Equal(t, []byte("d"), v)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/romshark/taskhub/api/auth"
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/api/respcache"
+)
+
+// execute executes the persisted query q buffering the response.
+// If q declares @cache, the response is served from the response cache
+// when possible and successful responses are cached per user
+// and variables. Responses are never served from the cache to users
+// that must enable two-factor authentication first, which the GraphQL
+// handler rejects. The X-Cache header reports either HIT or MISS.
+func (s *ServerProduction) execute(
+	r *http.Request, q *gqlpq.Query, variables []byte,
+) *responseBuffer {
+	b := newResponseBuffer()
+	if s.responseCache == nil || q.Metadata.CacheTTL == 0 ||
+		// Let the GraphQL handler reject API keys lacking the read scope.
+		!auth.HasScope(r.Context(), model.APIKeyScopeReadTasks) {
+		s.gqlHandler.ServeHTTP(b, r)
+		return b
+	}
+	if pending, err := twoFactorPending(
+		r.Context(), s.dataProvider,
+	); err != nil || pending {
+		s.gqlHandler.ServeHTTP(b, r)
+		return b
+	}
+	vars, err := normalizeVariables(variables)
+	if err != nil {
+		s.gqlHandler.ServeHTTP(b, r)
+		return b
+	}
+	c := reqctx.GetRequestContext(r.Context())
+	k := respcache.Key{
+		Query:     q.Key,
+		Hash:      q.Hash,
+		UserID:    c.UserID,
+		APIKeyID:  c.APIKeyID,
+		Variables: vars,
+	}
+
+	now := time.Now()
+	cached, generation := s.responseCache.Get(k, now)
+	if cached != nil {
+		// Cached responses bypass the GraphQL handler and its metrics.
+		s.metrics.Observe(
+			q.Key, string(q.Operation),
+			time.Since(c.Start),
+			false, time.Now(),
+		)
+		b.header.Set("Content-Type", "application/json")
+		b.header.Set("X-Cache", "HIT")
+		b.body.Write(cached)
+		return b
+	}
+
+	s.gqlHandler.ServeHTTP(b, r)
+	b.header.Set("X-Cache", "MISS")
+	if b.status == http.StatusOK && isSuccessful(b.body.Bytes()) {
+		s.responseCache.Set(
+			k, b.body.Bytes(), now, q.Metadata.CacheTTL, generation,
+		)
+	}
+	return b
+}
+
+// normalizeVariables returns the variables JSON object
+// with sorted keys and without insignificant whitespace.
+func normalizeVariables(variables []byte) (string, error) {
+	if len(bytes.TrimSpace(variables)) == 0 {
+		return "{}", nil
+	}
+	d := json.NewDecoder(bytes.NewReader(variables))
+	d.UseNumber()
+	var v map[string]any
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+	if v == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/dataprovider/inmem"
+	"github.com/romshark/taskhub/api/graph/model"
+	"github.com/romshark/taskhub/api/metrics"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/api/respcache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeVariables(t *testing.T) {
+	for _, td := range []struct {
+		input  string
+		expect string
+	}{
+		{"", "{}"},
+		{" ", "{}"},
+		{"null", "{}"},
+		{"{}", "{}"},
+		{`{ "b": 1, "a": [ "x" ] }`, `{"a":["x"],"b":1}`},
+		{`{"n":12345678901234567890}`, `{"n":12345678901234567890}`},
+		{`{"o":{"z":null,"y":true}}`, `{"o":{"y":true,"z":null}}`},
+	} {
+		t.Run(td.input, func(t *testing.T) {
+			v, err := normalizeVariables([]byte(td.input))
+			require.NoError(t, err)
+			require.Equal(t, td.expect, v)
+		})
+	}
+
+	_, err := normalizeVariables([]byte("[1]"))
+	require.Error(t, err)
+}
+
+// TestExecuteTwoFactorRequired makes sure that cached responses aren't
+// served to users the GraphQL handler would reject for not having
+// enabled two-factor authentication required by the organization.
+func TestExecuteTwoFactorRequired(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"a": "# @cache 1m\nquery A { foo }",
+	})
+	dataProvider := &inmem.Inmem{Users: []*model.User{
+		{ID: "u1", Email: "u1@test.com", DisplayName: "User One"},
+	}}
+	s.dataProvider = dataProvider
+	s.responseCache = respcache.New(10)
+	s.metrics = metrics.New()
+	var executed int
+	h := s.gqlHandler
+	s.gqlHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		executed++
+		h.ServeHTTP(w, r)
+	})
+
+	q := s.persistedQueries.Get("a")
+	require.NotNil(t, q)
+	execute := func() *responseBuffer {
+		r := httptest.NewRequest(http.MethodPost, "/e/a", nil)
+		r = r.WithContext(reqctx.WithRequestContext(
+			context.Background(), s.log, "u1", "a", time.Now(),
+		))
+		return s.execute(r, q, nil)
+	}
+
+	require.Equal(t, "MISS", execute().header.Get("X-Cache"))
+	require.Equal(t, "HIT", execute().header.Get("X-Cache"))
+	require.Equal(t, 1, executed)
+
+	dataProvider.OrganizationSettings.TwoFactorRequired = true
+	b := execute()
+	require.Empty(t, b.header.Get("X-Cache"))
+	require.Equal(t, 2, executed, "expected the GraphQL handler to run")
+}
@@ -0,0 +1,312 @@
+// Package schemadiff compares two versions of a GraphQL schema
+// and classifies the changes by their impact on existing clients.
+package schemadiff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/romshark/taskhub/slices"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Severity is the impact of a Change on existing clients.
+type Severity int8
+
+const (
+	// Safe changes can't break existing clients.
+	Safe Severity = 0
+
+	// Dangerous changes don't break existing queries but can
+	// change the behavior of clients, such as a new enum value
+	// that isn't handled by an exhaustive switch.
+	Dangerous Severity = 1
+
+	// Breaking changes make existing queries invalid.
+	Breaking Severity = 2
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Safe:
+		return "safe"
+	case Dangerous:
+		return "dangerous"
+	case Breaking:
+		return "breaking"
+	}
+	return fmt.Sprintf("Severity(%d)", s)
+}
+
+// Change is a difference between two schema versions.
+type Change struct {
+	Severity Severity
+
+	// Coordinate identifies the changed schema member,
+	// such as Task, Task.title, Query.task(id:) or TaskStatus.DONE.
+	Coordinate string
+
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Severity, c.Coordinate, c.Message)
+}
+
+// Diff returns the changes from oldSchema to newSchema
+// ordered by severity (breaking first) and coordinate.
+// Built-in types are ignored.
+func Diff(oldSchema, newSchema *ast.Schema) []Change {
+	d := new(differ)
+	for name, o := range oldSchema.Types {
+		if o.BuiltIn {
+			continue
+		}
+		n, ok := newSchema.Types[name]
+		if !ok {
+			d.add(Breaking, name, "type removed")
+			continue
+		}
+		d.diffType(o, n)
+	}
+	for name, n := range newSchema.Types {
+		if n.BuiltIn {
+			continue
+		}
+		if _, ok := oldSchema.Types[name]; !ok {
+			d.add(Safe, name, "type added")
+		}
+	}
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		a, b := d.changes[i], d.changes[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		return a.Coordinate < b.Coordinate
+	})
+	return d.changes
+}
+
+type differ struct{ changes []Change }
+
+func (d *differ) add(s Severity, coordinate, format string, v ...any) {
+	d.changes = append(d.changes, Change{
+		Severity:   s,
+		Coordinate: coordinate,
+		Message:    fmt.Sprintf(format, v...),
+	})
+}
+
+func (d *differ) diffType(o, n *ast.Definition) {
+	if o.Kind != n.Kind {
+		d.add(
+			Breaking, o.Name, "kind changed from %s to %s",
+			kindName(o.Kind), kindName(n.Kind),
+		)
+		return
+	}
+	switch o.Kind {
+	case ast.Object, ast.Interface:
+		d.diffFields(o, n)
+		d.diffMembers(o.Name, o.Interfaces, n.Interfaces, "interface")
+	case ast.InputObject:
+		d.diffInputFields(o, n)
+	case ast.Enum:
+		d.diffEnumValues(o, n)
+	case ast.Union:
+		d.diffMembers(o.Name, o.Types, n.Types, "member type")
+	}
+}
+
+func (d *differ) diffFields(o, n *ast.Definition) {
+	for _, of := range o.Fields {
+		c := o.Name + "." + of.Name
+		nf := n.Fields.ForName(of.Name)
+		if nf == nil {
+			d.add(Breaking, c, "field removed")
+			continue
+		}
+		if !safeOutputChange(of.Type, nf.Type) {
+			d.add(
+				Breaking, c, "type changed from %s to %s",
+				of.Type, nf.Type,
+			)
+		} else if of.Type.String() != nf.Type.String() {
+			d.add(Safe, c, "type changed from %s to %s", of.Type, nf.Type)
+		}
+		d.diffDeprecation(c, of.Directives, nf.Directives)
+		d.diffArguments(c, of.Arguments, nf.Arguments)
+	}
+	for _, nf := range n.Fields {
+		if o.Fields.ForName(nf.Name) == nil {
+			d.add(Safe, o.Name+"."+nf.Name, "field added")
+		}
+	}
+}
+
+func (d *differ) diffArguments(
+	field string, o, n ast.ArgumentDefinitionList,
+) {
+	for _, oa := range o {
+		c := field + "(" + oa.Name + ":)"
+		na := n.ForName(oa.Name)
+		if na == nil {
+			d.add(Breaking, c, "argument removed")
+			continue
+		}
+		d.diffInputValue(c, oa.Type, na.Type, oa.DefaultValue, na.DefaultValue)
+	}
+	for _, na := range n {
+		if o.ForName(na.Name) != nil {
+			continue
+		}
+		c := field + "(" + na.Name + ":)"
+		if na.Type.NonNull && na.DefaultValue == nil {
+			d.add(Breaking, c, "required argument added")
+		} else {
+			d.add(Dangerous, c, "optional argument added")
+		}
+	}
+}
+
+func (d *differ) diffInputFields(o, n *ast.Definition) {
+	for _, of := range o.Fields {
+		c := o.Name + "." + of.Name
+		nf := n.Fields.ForName(of.Name)
+		if nf == nil {
+			d.add(Breaking, c, "input field removed")
+			continue
+		}
+		d.diffInputValue(c, of.Type, nf.Type, of.DefaultValue, nf.DefaultValue)
+		d.diffDeprecation(c, of.Directives, nf.Directives)
+	}
+	for _, nf := range n.Fields {
+		if o.Fields.ForName(nf.Name) != nil {
+			continue
+		}
+		c := o.Name + "." + nf.Name
+		if nf.Type.NonNull && nf.DefaultValue == nil {
+			d.add(Breaking, c, "required input field added")
+		} else {
+			d.add(Dangerous, c, "optional input field added")
+		}
+	}
+}
+
+func (d *differ) diffInputValue(
+	coordinate string, ot, nt *ast.Type, od, nd *ast.Value,
+) {
+	if !safeInputChange(ot, nt) {
+		d.add(Breaking, coordinate, "type changed from %s to %s", ot, nt)
+	} else if ot.String() != nt.String() {
+		d.add(Safe, coordinate, "type changed from %s to %s", ot, nt)
+	}
+	if valueString(od) != valueString(nd) {
+		d.add(
+			Dangerous, coordinate, "default value changed from %s to %s",
+			valueString(od), valueString(nd),
+		)
+	}
+}
+
+func (d *differ) diffEnumValues(o, n *ast.Definition) {
+	for _, ov := range o.EnumValues {
+		c := o.Name + "." + ov.Name
+		nv := n.EnumValues.ForName(ov.Name)
+		if nv == nil {
+			d.add(Breaking, c, "enum value removed")
+			continue
+		}
+		d.diffDeprecation(c, ov.Directives, nv.Directives)
+	}
+	for _, nv := range n.EnumValues {
+		if o.EnumValues.ForName(nv.Name) == nil {
+			d.add(Dangerous, o.Name+"."+nv.Name, "enum value added")
+		}
+	}
+}
+
+// diffMembers compares union member types and implemented interfaces.
+func (d *differ) diffMembers(typeName string, o, n []string, what string) {
+	for _, m := range o {
+		if !slices.Contains(n, m) {
+			d.add(Breaking, typeName, "%s %s removed", what, m)
+		}
+	}
+	for _, m := range n {
+		if !slices.Contains(o, m) {
+			d.add(Dangerous, typeName, "%s %s added", what, m)
+		}
+	}
+}
+
+func (d *differ) diffDeprecation(coordinate string, o, n ast.DirectiveList) {
+	od, nd := o.ForName("deprecated"), n.ForName("deprecated")
+	switch {
+	case od == nil && nd != nil:
+		d.add(Safe, coordinate, "deprecated")
+	case od != nil && nd == nil:
+		d.add(Safe, coordinate, "deprecation removed")
+	}
+}
+
+// safeOutputChange returns true if clients expecting
+// values of type o can handle values of type n.
+func safeOutputChange(o, n *ast.Type) bool {
+	switch {
+	case o.NonNull:
+		return n.NonNull && safeOutputChange(nullable(o), nullable(n))
+	case n.NonNull:
+		// Nullable to non-null is safe
+		return safeOutputChange(o, nullable(n))
+	case o.Elem != nil:
+		return n.Elem != nil && safeOutputChange(o.Elem, n.Elem)
+	}
+	return n.Elem == nil && o.NamedType == n.NamedType
+}
+
+// safeInputChange returns true if all values
+// clients provide for type o are valid for type n.
+func safeInputChange(o, n *ast.Type) bool {
+	switch {
+	case n.NonNull:
+		return o.NonNull && safeInputChange(nullable(o), nullable(n))
+	case o.NonNull:
+		// Non-null to nullable is safe
+		return safeInputChange(nullable(o), n)
+	case o.Elem != nil:
+		return n.Elem != nil && safeInputChange(o.Elem, n.Elem)
+	}
+	return n.Elem == nil && o.NamedType == n.NamedType
+}
+
+func nullable(t *ast.Type) *ast.Type {
+	c := *t
+	c.NonNull = false
+	return &c
+}
+
+func valueString(v *ast.Value) string {
+	if v == nil {
+		return "none"
+	}
+	return v.String()
+}
+
+func kindName(k ast.DefinitionKind) string {
+	switch k {
+	case ast.Scalar:
+		return "scalar"
+	case ast.Object:
+		return "type"
+	case ast.Interface:
+		return "interface"
+	case ast.Union:
+		return "union"
+	case ast.Enum:
+		return "enum"
+	case ast.InputObject:
+		return "input"
+	}
+	return string(k)
+}
@@ -0,0 +1,90 @@
+package schemadiff_test
+
+import (
+	"testing"
+
+	"github.com/romshark/taskhub/api/schemadiff"
+	"github.com/stretchr/testify/require"
+	gqlparse "github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func loadSchema(t *testing.T, s string) *ast.Schema {
+	t.Helper()
+	schema, err := gqlparse.LoadSchema(&ast.Source{Input: s})
+	require.NoError(t, err)
+	return schema
+}
+
+func TestDiff(t *testing.T) {
+	oldSchema := loadSchema(t, `
+		type Query {
+			task(id: ID!): Task
+			tasks(first: Int = 10, status: Status): [Task!]!
+			removed: Int
+		}
+		interface Node { id: ID! }
+		type Task implements Node {
+			id: ID!
+			title: String
+			tags: [String!]!
+			priority: Int!
+			old: String
+		}
+		enum Status { TODO DONE ARCHIVED }
+		input TaskInput { title: String! tags: [String!] }
+		union Item = Task
+		scalar Removed
+	`)
+	newSchema := loadSchema(t, `
+		type Query {
+			task(id: ID!, deleted: Boolean!): Task
+			tasks(first: Int = 20, status: Status, after: String): [Task!]!
+		}
+		interface Node { id: ID! }
+		type Task {
+			id: ID!
+			title: String!
+			tags: [String]!
+			priority: String!
+			old: String @deprecated
+			due: String
+		}
+		enum Status { TODO DONE IN_PROGRESS }
+		input TaskInput { title: String tags: [String!]! due: String }
+		union Item = Task | Project
+		type Project { id: ID! }
+		type Removed { id: ID! }
+	`)
+
+	changes := schemadiff.Diff(oldSchema, newSchema)
+	s := make([]string, len(changes))
+	for i, c := range changes {
+		s[i] = c.String()
+	}
+	require.Equal(t, []string{
+		"breaking: Query.removed: field removed",
+		"breaking: Query.task(deleted:): required argument added",
+		"breaking: Removed: kind changed from scalar to type",
+		"breaking: Status.ARCHIVED: enum value removed",
+		"breaking: Task: interface Node removed",
+		"breaking: Task.priority: type changed from Int! to String!",
+		"breaking: Task.tags: type changed from [String!]! to [String]!",
+		"breaking: TaskInput.tags: type changed from [String!] to [String!]!",
+		"dangerous: Item: member type Project added",
+		"dangerous: Query.tasks(after:): optional argument added",
+		"dangerous: Query.tasks(first:): default value changed from 10 to 20",
+		"dangerous: Status.IN_PROGRESS: enum value added",
+		"dangerous: TaskInput.due: optional input field added",
+		"safe: Project: type added",
+		"safe: Task.due: field added",
+		"safe: Task.old: deprecated",
+		"safe: Task.title: type changed from String to String!",
+		"safe: TaskInput.title: type changed from String! to String",
+	}, s)
+}
+
+func TestDiffNoChanges(t *testing.T) {
+	s := `type Query { foo(x: [Int!] = [1]): [String!] }`
+	require.Empty(t, schemadiff.Diff(loadSchema(t, s), loadSchema(t, s)))
+}
@@ -0,0 +1,86 @@
+package totp_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/romshark/taskhub/api/totp"
+
+	"github.com/stretchr/testify/require"
+)
+
+// secret is the base32 encoded SHA1 seed "12345678901234567890"
+// of the test vectors of RFC 6238.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// The expected codes are the last 6 digits of the
+	// 8 digit codes listed in appendix B of RFC 6238.
+	for _, td := range []struct {
+		unix   int64
+		expect string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	} {
+		c, err := totp.Code(secret, totp.Step(time.Unix(td.unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, td.expect, c, td.unix)
+	}
+
+	_, err := totp.Code("not base32!", 1)
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := totp.Step(now)
+	for _, d := range []int64{-1, 0, 1} {
+		c, err := totp.Code(secret, current+d)
+		require.NoError(t, err)
+		step, ok := totp.Validate(secret, c, now)
+		require.True(t, ok, d)
+		require.Equal(t, current+d, step)
+	}
+	for _, d := range []int64{-2, 2} {
+		c, err := totp.Code(secret, current+d)
+		require.NoError(t, err)
+		_, ok := totp.Validate(secret, c, now)
+		require.False(t, ok, d)
+	}
+	for _, c := range []string{"", "12345", "1234567", "abcdef"} {
+		_, ok := totp.Validate(secret, c, now)
+		require.False(t, ok, c)
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	s, err := totp.NewSecret()
+	require.NoError(t, err)
+	require.Len(t, s, 32)
+	s2, err := totp.NewSecret()
+	require.NoError(t, err)
+	require.NotEqual(t, s, s2)
+	_, err = totp.Code(s, 1)
+	require.NoError(t, err)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(totp.URI("TaskHub", "ryan@company.com", secret))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/TaskHub:ryan@company.com", u.Path)
+	require.Equal(t, url.Values{
+		"secret":    {secret},
+		"issuer":    {"TaskHub"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, u.Query())
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/romshark/taskhub/api/dataprovider"
+	"github.com/romshark/taskhub/api/reqctx"
+	"github.com/romshark/taskhub/slices"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// twoFactorEnrollmentFields are the root fields users are allowed to select
+// while the organization requires them to enable two-factor authentication.
+var twoFactorEnrollmentFields = []string{
+	"organization",
+	"enrollTwoFactor",
+	"confirmTwoFactor",
+	"refreshAccessToken",
+	"logout",
+}
+
+// newGQLMiddlewareRequireTwoFactor rejects operations of users signed in
+// with a password that didn't enable two-factor authentication while the
+// organization requires it, except for operations selecting only
+// twoFactorEnrollmentFields and introspection fields.
+// Clients authenticated by API keys aren't affected.
+func newGQLMiddlewareRequireTwoFactor(
+	dataProvider dataprovider.DataProvider,
+) graphql.OperationMiddleware {
+	return func(
+		ctx context.Context, next graphql.OperationHandler,
+	) graphql.ResponseHandler {
+		pending, err := twoFactorPending(ctx, dataProvider)
+		if err != nil {
+			return graphql.OneShot(graphql.ErrorResponse(ctx, "%v", err))
+		}
+		if !pending || selectsOnly(ctx, twoFactorEnrollmentFields) {
+			return next(ctx)
+		}
+		return graphql.OneShot(graphql.ErrorResponse(
+			ctx, "two-factor authentication required by the organization",
+		))
+	}
+}
+
+// twoFactorPending returns true if the client is a user signed in with
+// a password that didn't enable two-factor authentication while the
+// organization requires it.
+func twoFactorPending(
+	ctx context.Context, dataProvider dataprovider.DataProvider,
+) (bool, error) {
+	c := reqctx.GetRequestContext(ctx)
+	if c.UserID == "" || c.APIKeyID != "" {
+		return false, nil
+	}
+	o, err := dataProvider.Organization(ctx)
+	if err != nil {
+		return false, fmt.Errorf("reading organization: %w", err)
+	}
+	if !o.TwoFactorRequired {
+		return false, nil
+	}
+	user, err := dataProvider.UserByID(ctx, c.UserID)
+	if err != nil {
+		return false, fmt.Errorf("reading user: %w", err)
+	}
+	return !user.TwoFactorEnabled, nil
+}
+
+// selectsOnly returns true if the operation in ctx selects
+// only the given root fields and introspection fields.
+func selectsOnly(ctx context.Context, fields []string) bool {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return false
+	}
+	root := "Query"
+	switch opCtx.Operation.Operation {
+	case ast.Mutation:
+		root = "Mutation"
+	case ast.Subscription:
+		root = "Subscription"
+	}
+	for _, f := range graphql.CollectFields(
+		opCtx, opCtx.Operation.SelectionSet, []string{root},
+	) {
+		if !strings.HasPrefix(f.Name, "__") &&
+			!slices.Contains(fields, f.Name) {
+			return false
+		}
+	}
+	return true
+}
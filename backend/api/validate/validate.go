@@ -99,3 +99,23 @@ func TaskTag(s string) error {
 	}
 	return nil
 }
+
+func APIKeyName(s string) error {
+	if len(s) < 1 {
+		return errors.New("API key name too short")
+	}
+	if len(s) > 256 {
+		return errors.New("API key name too long")
+	}
+	return nil
+}
+
+func CommentBody(s string) error {
+	if len(s) < 1 {
+		return errors.New("comment body too short")
+	}
+	if len(s) > 1024*64 {
+		return errors.New("comment body too long")
+	}
+	return nil
+}
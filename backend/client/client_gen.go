@@ -0,0 +1,715 @@
+// Code generated by pqgen. DO NOT EDIT.
+
+// Package client is a typed client for the persisted queries.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client executes persisted queries via POST /e/<name>.
+type Client struct {
+	// Endpoint is the base URL of the API, such as "https://example.com".
+	Endpoint string
+
+	// HTTPClient performs the requests, http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+
+	// BearerToken is sent as Authorization header if not empty.
+	BearerToken string
+}
+
+// Error is a GraphQL error.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Errors is returned if the response contains GraphQL errors,
+// the response data may still be partially available.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Message
+	}
+	return fmt.Sprintf("%d errors, first: %s", len(e), e[0].Message)
+}
+
+// HTTPError is returned if the server responds with a status other than 200.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+func (c *Client) execute(
+	ctx context.Context, name string, variables, data any,
+) error {
+	var body io.Reader
+	if variables != nil {
+		b, err := json.Marshal(variables)
+		if err != nil {
+			return fmt.Errorf("encoding variables: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.Endpoint+"/e/"+name, body,
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &HTTPError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	var r struct {
+		Data   any    `json:"data"`
+		Errors Errors `json:"errors"`
+	}
+	r.Data = data
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	return nil
+}
+
+type APIKeyKind string
+
+const (
+	APIKeyKindPersonal APIKeyKind = "PERSONAL"
+	APIKeyKindService  APIKeyKind = "SERVICE"
+)
+
+type APIKeyScope string
+
+const (
+	APIKeyScopeReadTasks  APIKeyScope = "READ_TASKS"
+	APIKeyScopeWriteTasks APIKeyScope = "WRITE_TASKS"
+	APIKeyScopeAdmin      APIKeyScope = "ADMIN"
+)
+
+type ProjectRole string
+
+const (
+	ProjectRoleOwner      ProjectRole = "OWNER"
+	ProjectRoleMaintainer ProjectRole = "MAINTAINER"
+	ProjectRoleMember     ProjectRole = "MEMBER"
+	ProjectRoleViewer     ProjectRole = "VIEWER"
+)
+
+type TaskPriority string
+
+const (
+	TaskPriorityBlocker TaskPriority = "BLOCKER"
+	TaskPriorityHigh    TaskPriority = "HIGH"
+	TaskPriorityMedium  TaskPriority = "MEDIUM"
+	TaskPriorityLow     TaskPriority = "LOW"
+)
+
+type TaskStatus string
+
+const (
+	TaskStatusTodo       TaskStatus = "TODO"
+	TaskStatusInProgress TaskStatus = "IN_PROGRESS"
+	TaskStatusDone       TaskStatus = "DONE"
+)
+
+type TasksOrder string
+
+const (
+	TasksOrderPriority     TasksOrder = "PRIORITY"
+	TasksOrderCreationTime TasksOrder = "CREATION_TIME"
+	TasksOrderDueTime      TasksOrder = "DUE_TIME"
+	TasksOrderTitleAlpha   TasksOrder = "TITLE_ALPHA"
+)
+
+type TasksFilters struct {
+	Assignees     []string     `json:"assignees,omitempty"`
+	Reporters     []string     `json:"reporters,omitempty"`
+	Projects      []string     `json:"projects,omitempty"`
+	Status        []TaskStatus `json:"status,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+	CreatedBefore *time.Time   `json:"createdBefore,omitempty"`
+	CreatedAfter  *time.Time   `json:"createdAfter,omitempty"`
+	Archived      *bool        `json:"archived,omitempty"`
+}
+
+// MutBeginSignInVariables are the variables of mut_begin_sign_in.
+type MutBeginSignInVariables struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// MutBeginSignInResponse is the data returned by mut_begin_sign_in.
+type MutBeginSignInResponse struct {
+	BeginSignIn MutBeginSignInBeginSignIn `json:"beginSignIn"`
+}
+
+type MutBeginSignInBeginSignIn struct {
+	Tokens    *MutBeginSignInBeginSignInTokens    `json:"tokens"`
+	Challenge *MutBeginSignInBeginSignInChallenge `json:"challenge"`
+}
+
+type MutBeginSignInBeginSignInTokens struct {
+	AccessToken        string                                 `json:"accessToken"`
+	AccessTokenExpires time.Time                              `json:"accessTokenExpires"`
+	RefreshToken       string                                 `json:"refreshToken"`
+	Session            MutBeginSignInBeginSignInTokensSession `json:"session"`
+}
+
+type MutBeginSignInBeginSignInTokensSession struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+type MutBeginSignInBeginSignInChallenge struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// MutBeginSignIn executes the persisted mutation mut_begin_sign_in.
+func (c *Client) MutBeginSignIn(ctx context.Context, v MutBeginSignInVariables) (*MutBeginSignInResponse, error) {
+	r := new(MutBeginSignInResponse)
+	err := c.execute(ctx, "mut_begin_sign_in", v, r)
+	return r, err
+}
+
+// MutCompleteSignInVariables are the variables of mut_complete_sign_in.
+type MutCompleteSignInVariables struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+// MutCompleteSignInResponse is the data returned by mut_complete_sign_in.
+type MutCompleteSignInResponse struct {
+	CompleteSignIn MutCompleteSignInCompleteSignIn `json:"completeSignIn"`
+}
+
+type MutCompleteSignInCompleteSignIn struct {
+	AccessToken        string                                 `json:"accessToken"`
+	AccessTokenExpires time.Time                              `json:"accessTokenExpires"`
+	RefreshToken       string                                 `json:"refreshToken"`
+	Session            MutCompleteSignInCompleteSignInSession `json:"session"`
+}
+
+type MutCompleteSignInCompleteSignInSession struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// MutCompleteSignIn executes the persisted mutation mut_complete_sign_in.
+func (c *Client) MutCompleteSignIn(ctx context.Context, v MutCompleteSignInVariables) (*MutCompleteSignInResponse, error) {
+	r := new(MutCompleteSignInResponse)
+	err := c.execute(ctx, "mut_complete_sign_in", v, r)
+	return r, err
+}
+
+// MutConfirmTwoFactorVariables are the variables of mut_confirm_two_factor.
+type MutConfirmTwoFactorVariables struct {
+	Code string `json:"code"`
+}
+
+// MutConfirmTwoFactorResponse is the data returned by mut_confirm_two_factor.
+type MutConfirmTwoFactorResponse struct {
+	ConfirmTwoFactor []string `json:"confirmTwoFactor"`
+}
+
+// MutConfirmTwoFactor executes the persisted mutation mut_confirm_two_factor.
+func (c *Client) MutConfirmTwoFactor(ctx context.Context, v MutConfirmTwoFactorVariables) (*MutConfirmTwoFactorResponse, error) {
+	r := new(MutConfirmTwoFactorResponse)
+	err := c.execute(ctx, "mut_confirm_two_factor", v, r)
+	return r, err
+}
+
+// MutCreateAPIKeyVariables are the variables of mut_create_api_key.
+type MutCreateAPIKeyVariables struct {
+	Name   string        `json:"name"`
+	Scopes []APIKeyScope `json:"scopes"`
+	User   *string       `json:"user,omitempty"`
+}
+
+// MutCreateAPIKeyResponse is the data returned by mut_create_api_key.
+type MutCreateAPIKeyResponse struct {
+	CreateApiKey MutCreateAPIKeyCreateApiKey `json:"createApiKey"`
+}
+
+type MutCreateAPIKeyCreateApiKey struct {
+	Key    string                            `json:"key"`
+	ApiKey MutCreateAPIKeyCreateApiKeyApiKey `json:"apiKey"`
+}
+
+type MutCreateAPIKeyCreateApiKeyApiKey struct {
+	ID       string        `json:"id"`
+	Name     string        `json:"name"`
+	Kind     APIKeyKind    `json:"kind"`
+	Scopes   []APIKeyScope `json:"scopes"`
+	Creation time.Time     `json:"creation"`
+}
+
+// MutCreateAPIKey executes the persisted mutation mut_create_api_key.
+func (c *Client) MutCreateAPIKey(ctx context.Context, v MutCreateAPIKeyVariables) (*MutCreateAPIKeyResponse, error) {
+	r := new(MutCreateAPIKeyResponse)
+	err := c.execute(ctx, "mut_create_api_key", v, r)
+	return r, err
+}
+
+// MutCreateTaskVariables are the variables of mut_create_task.
+type MutCreateTaskVariables struct {
+	Title        string        `json:"title"`
+	ProjectID    string        `json:"projectID"`
+	Status       *TaskStatus   `json:"status,omitempty"`
+	TaskPriority *TaskPriority `json:"taskPriority,omitempty"`
+	Description  *string       `json:"description,omitempty"`
+	Due          *time.Time    `json:"due,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+	Assignees    []string      `json:"assignees,omitempty"`
+	Reporters    []string      `json:"reporters,omitempty"`
+	Blocks       []string      `json:"blocks,omitempty"`
+	RelatesTo    []string      `json:"relatesTo,omitempty"`
+}
+
+// MutCreateTaskResponse is the data returned by mut_create_task.
+type MutCreateTaskResponse struct {
+	CreateTask MutCreateTaskCreateTask `json:"createTask"`
+}
+
+type MutCreateTaskCreateTask struct {
+	ID string `json:"id"`
+}
+
+// MutCreateTask executes the persisted mutation mut_create_task.
+func (c *Client) MutCreateTask(ctx context.Context, v MutCreateTaskVariables) (*MutCreateTaskResponse, error) {
+	r := new(MutCreateTaskResponse)
+	err := c.execute(ctx, "mut_create_task", v, r)
+	return r, err
+}
+
+// MutEnrollTwoFactorResponse is the data returned by mut_enroll_two_factor.
+type MutEnrollTwoFactorResponse struct {
+	EnrollTwoFactor MutEnrollTwoFactorEnrollTwoFactor `json:"enrollTwoFactor"`
+}
+
+type MutEnrollTwoFactorEnrollTwoFactor struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+// MutEnrollTwoFactor executes the persisted mutation mut_enroll_two_factor.
+func (c *Client) MutEnrollTwoFactor(ctx context.Context) (*MutEnrollTwoFactorResponse, error) {
+	r := new(MutEnrollTwoFactorResponse)
+	err := c.execute(ctx, "mut_enroll_two_factor", nil, r)
+	return r, err
+}
+
+// MutLogoutResponse is the data returned by mut_logout.
+type MutLogoutResponse struct {
+	Logout string `json:"logout"`
+}
+
+// MutLogout executes the persisted mutation mut_logout.
+func (c *Client) MutLogout(ctx context.Context) (*MutLogoutResponse, error) {
+	r := new(MutLogoutResponse)
+	err := c.execute(ctx, "mut_logout", nil, r)
+	return r, err
+}
+
+// MutRefreshAccessTokenVariables are the variables of mut_refresh_access_token.
+type MutRefreshAccessTokenVariables struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// MutRefreshAccessTokenResponse is the data returned by mut_refresh_access_token.
+type MutRefreshAccessTokenResponse struct {
+	RefreshAccessToken MutRefreshAccessTokenRefreshAccessToken `json:"refreshAccessToken"`
+}
+
+type MutRefreshAccessTokenRefreshAccessToken struct {
+	AccessToken        string                                         `json:"accessToken"`
+	AccessTokenExpires time.Time                                      `json:"accessTokenExpires"`
+	RefreshToken       string                                         `json:"refreshToken"`
+	Session            MutRefreshAccessTokenRefreshAccessTokenSession `json:"session"`
+}
+
+type MutRefreshAccessTokenRefreshAccessTokenSession struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// MutRefreshAccessToken executes the persisted mutation mut_refresh_access_token.
+func (c *Client) MutRefreshAccessToken(ctx context.Context, v MutRefreshAccessTokenVariables) (*MutRefreshAccessTokenResponse, error) {
+	r := new(MutRefreshAccessTokenResponse)
+	err := c.execute(ctx, "mut_refresh_access_token", v, r)
+	return r, err
+}
+
+// MutRevokeAPIKeyVariables are the variables of mut_revoke_api_key.
+type MutRevokeAPIKeyVariables struct {
+	ID string `json:"id"`
+}
+
+// MutRevokeAPIKeyResponse is the data returned by mut_revoke_api_key.
+type MutRevokeAPIKeyResponse struct {
+	RevokeApiKey MutRevokeAPIKeyRevokeApiKey `json:"revokeApiKey"`
+}
+
+type MutRevokeAPIKeyRevokeApiKey struct {
+	ID string `json:"id"`
+}
+
+// MutRevokeAPIKey executes the persisted mutation mut_revoke_api_key.
+func (c *Client) MutRevokeAPIKey(ctx context.Context, v MutRevokeAPIKeyVariables) (*MutRevokeAPIKeyResponse, error) {
+	r := new(MutRevokeAPIKeyResponse)
+	err := c.execute(ctx, "mut_revoke_api_key", v, r)
+	return r, err
+}
+
+// MutSignInVariables are the variables of mut_sign_in.
+type MutSignInVariables struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// MutSignInResponse is the data returned by mut_sign_in.
+type MutSignInResponse struct {
+	SignIn MutSignInSignIn `json:"signIn"`
+}
+
+type MutSignInSignIn struct {
+	AccessToken        string                 `json:"accessToken"`
+	AccessTokenExpires time.Time              `json:"accessTokenExpires"`
+	RefreshToken       string                 `json:"refreshToken"`
+	Session            MutSignInSignInSession `json:"session"`
+}
+
+type MutSignInSignInSession struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// MutSignIn executes the persisted mutation mut_sign_in.
+func (c *Client) MutSignIn(ctx context.Context, v MutSignInVariables) (*MutSignInResponse, error) {
+	r := new(MutSignInResponse)
+	err := c.execute(ctx, "mut_sign_in", v, r)
+	return r, err
+}
+
+// QryProjectVariables are the variables of qry_project.
+type QryProjectVariables struct {
+	ProjectID  string  `json:"projectID"`
+	TasksFirst *int    `json:"tasksFirst,omitempty"`
+	TasksAfter *string `json:"tasksAfter,omitempty"`
+}
+
+// QryProjectResponse is the data returned by qry_project.
+type QryProjectResponse struct {
+	Project *QryProjectProject `json:"project"`
+}
+
+type QryProjectProject struct {
+	ID          string                     `json:"id"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Slug        string                     `json:"slug"`
+	Creation    time.Time                  `json:"creation"`
+	Owners      []QryProjectProjectOwners  `json:"owners"`
+	Members     []QryProjectProjectMembers `json:"members"`
+	Roles       []QryProjectProjectRoles   `json:"roles"`
+	Tasks       QryProjectProjectTasks     `json:"tasks"`
+}
+
+type QryProjectProjectOwners struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryProjectProjectMembers struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryProjectProjectRoles struct {
+	Role ProjectRole                `json:"role"`
+	User QryProjectProjectRolesUser `json:"user"`
+}
+
+type QryProjectProjectRolesUser struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryProjectProjectTasks struct {
+	TotalCount int                            `json:"totalCount"`
+	PageInfo   QryProjectProjectTasksPageInfo `json:"pageInfo"`
+	Edges      []QryProjectProjectTasksEdges  `json:"edges"`
+}
+
+type QryProjectProjectTasksPageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+type QryProjectProjectTasksEdges struct {
+	Node QryProjectProjectTasksEdgesNode `json:"node"`
+}
+
+type QryProjectProjectTasksEdgesNode struct {
+	ID          string                                     `json:"id"`
+	Title       string                                     `json:"title"`
+	Description *string                                    `json:"description"`
+	Priority    TaskPriority                               `json:"priority"`
+	Due         *time.Time                                 `json:"due"`
+	Assignees   []QryProjectProjectTasksEdgesNodeAssignees `json:"assignees"`
+	Reporters   []QryProjectProjectTasksEdgesNodeReporters `json:"reporters"`
+}
+
+type QryProjectProjectTasksEdgesNodeAssignees struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryProjectProjectTasksEdgesNodeReporters struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// QryProject executes the persisted query qry_project.
+func (c *Client) QryProject(ctx context.Context, v QryProjectVariables) (*QryProjectResponse, error) {
+	r := new(QryProjectResponse)
+	err := c.execute(ctx, "qry_project", v, r)
+	return r, err
+}
+
+// QryTaskVariables are the variables of qry_task.
+type QryTaskVariables struct {
+	TaskID string `json:"taskID"`
+}
+
+// QryTaskResponse is the data returned by qry_task.
+type QryTaskResponse struct {
+	Task *QryTaskTask `json:"task"`
+}
+
+type QryTaskTask struct {
+	ID          string                   `json:"id"`
+	Title       string                   `json:"title"`
+	Description *string                  `json:"description"`
+	Priority    TaskPriority             `json:"priority"`
+	Status      TaskStatus               `json:"status"`
+	Creation    time.Time                `json:"creation"`
+	Due         *time.Time               `json:"due"`
+	Tags        []string                 `json:"tags"`
+	Project     QryTaskTaskProject       `json:"project"`
+	Assignees   []QryTaskTaskAssignees   `json:"assignees"`
+	Reporters   []QryTaskTaskReporters   `json:"reporters"`
+	IsBlockedBy []QryTaskTaskIsBlockedBy `json:"isBlockedBy"`
+	RelatesTo   []QryTaskTaskRelatesTo   `json:"relatesTo"`
+}
+
+type QryTaskTaskProject struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type QryTaskTaskAssignees struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryTaskTaskReporters struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryTaskTaskIsBlockedBy struct {
+	ID          string  `json:"id"`
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+}
+
+type QryTaskTaskRelatesTo struct {
+	ID          string  `json:"id"`
+	Title       string  `json:"title"`
+	Description *string `json:"description"`
+}
+
+// QryTask executes the persisted query qry_task.
+func (c *Client) QryTask(ctx context.Context, v QryTaskVariables) (*QryTaskResponse, error) {
+	r := new(QryTaskResponse)
+	err := c.execute(ctx, "qry_task", v, r)
+	return r, err
+}
+
+// QryTasksVariables are the variables of qry_tasks.
+type QryTasksVariables struct {
+	First    *int          `json:"first,omitempty"`
+	After    *string       `json:"after,omitempty"`
+	Filters  *TasksFilters `json:"filters,omitempty"`
+	Order    *TasksOrder   `json:"order,omitempty"`
+	OrderAsc *bool         `json:"orderAsc,omitempty"`
+}
+
+// QryTasksResponse is the data returned by qry_tasks.
+type QryTasksResponse struct {
+	Tasks QryTasksTasks `json:"tasks"`
+}
+
+type QryTasksTasks struct {
+	TotalCount int                   `json:"totalCount"`
+	PageInfo   QryTasksTasksPageInfo `json:"pageInfo"`
+	Edges      []QryTasksTasksEdges  `json:"edges"`
+}
+
+type QryTasksTasksPageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+type QryTasksTasksEdges struct {
+	Cursor string                 `json:"cursor"`
+	Node   QryTasksTasksEdgesNode `json:"node"`
+}
+
+type QryTasksTasksEdgesNode struct {
+	ID        string                            `json:"id"`
+	Title     string                            `json:"title"`
+	Priority  TaskPriority                      `json:"priority"`
+	Due       *time.Time                        `json:"due"`
+	Project   QryTasksTasksEdgesNodeProject     `json:"project"`
+	Reporters []QryTasksTasksEdgesNodeReporters `json:"reporters"`
+	Assignees []QryTasksTasksEdgesNodeAssignees `json:"assignees"`
+}
+
+type QryTasksTasksEdgesNodeProject struct {
+	Name string `json:"name"`
+}
+
+type QryTasksTasksEdgesNodeReporters struct {
+	DisplayName string `json:"displayName"`
+}
+
+type QryTasksTasksEdgesNodeAssignees struct {
+	DisplayName string `json:"displayName"`
+}
+
+// QryTasks executes the persisted query qry_tasks.
+func (c *Client) QryTasks(ctx context.Context, v QryTasksVariables) (*QryTasksResponse, error) {
+	r := new(QryTasksResponse)
+	err := c.execute(ctx, "qry_tasks", v, r)
+	return r, err
+}
+
+// QryUserVariables are the variables of qry_user.
+type QryUserVariables struct {
+	UserID             string  `json:"userID"`
+	TasksAssignedFirst *int    `json:"tasksAssignedFirst,omitempty"`
+	TasksAssignedAfter *string `json:"tasksAssignedAfter,omitempty"`
+}
+
+// QryUserResponse is the data returned by qry_user.
+type QryUserResponse struct {
+	User *QryUserUser `json:"user"`
+}
+
+type QryUserUser struct {
+	ID             string                     `json:"id"`
+	Email          string                     `json:"email"`
+	DisplayName    string                     `json:"displayName"`
+	Role           string                     `json:"role"`
+	Location       string                     `json:"location"`
+	PersonalStatus *string                    `json:"personalStatus"`
+	Manager        *QryUserUserManager        `json:"manager"`
+	Subordinates   []QryUserUserSubordinates  `json:"subordinates"`
+	Projects       []QryUserUserProjects      `json:"projects"`
+	TasksAssigned  QryUserUserTasksAssigned   `json:"tasksAssigned"`
+	TasksReported  []QryUserUserTasksReported `json:"tasksReported"`
+}
+
+type QryUserUserManager struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryUserUserSubordinates struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryUserUserProjects struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type QryUserUserTasksAssigned struct {
+	TotalCount int                              `json:"totalCount"`
+	PageInfo   QryUserUserTasksAssignedPageInfo `json:"pageInfo"`
+	Edges      []QryUserUserTasksAssignedEdges  `json:"edges"`
+}
+
+type QryUserUserTasksAssignedPageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+type QryUserUserTasksAssignedEdges struct {
+	Node QryUserUserTasksAssignedEdgesNode `json:"node"`
+}
+
+type QryUserUserTasksAssignedEdgesNode struct {
+	ID          string                                       `json:"id"`
+	Title       string                                       `json:"title"`
+	Description *string                                      `json:"description"`
+	Reporters   []QryUserUserTasksAssignedEdgesNodeReporters `json:"reporters"`
+}
+
+type QryUserUserTasksAssignedEdgesNodeReporters struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type QryUserUserTasksReported struct {
+	ID          string                              `json:"id"`
+	Title       string                              `json:"title"`
+	Description *string                             `json:"description"`
+	Assignees   []QryUserUserTasksReportedAssignees `json:"assignees"`
+}
+
+type QryUserUserTasksReportedAssignees struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// QryUser executes the persisted query qry_user.
+func (c *Client) QryUser(ctx context.Context, v QryUserVariables) (*QryUserResponse, error) {
+	r := new(QryUserResponse)
+	err := c.execute(ctx, "qry_user", v, r)
+	return r, err
+}
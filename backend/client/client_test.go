@@ -0,0 +1,72 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/romshark/taskhub/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQryTask(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/e/qry_task", r.URL.Path)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"taskID":"task_1"}`, string(b))
+		_, _ = w.Write([]byte(`{"data":{"task":{
+			"id":"task_1","title":"Title","priority":"HIGH","status":"TODO",
+			"creation":"2023-01-02T03:04:05Z","tags":["a"]
+		}}}`))
+	}))
+	defer s.Close()
+
+	c := &client.Client{Endpoint: s.URL, BearerToken: "token"}
+	r, err := c.QryTask(context.Background(), client.QryTaskVariables{
+		TaskID: "task_1",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, r.Task)
+	require.Equal(t, "task_1", r.Task.ID)
+	require.Equal(t, client.TaskPriorityHigh, r.Task.Priority)
+	require.Equal(t, client.TaskStatusTodo, r.Task.Status)
+	require.Equal(t, 2023, r.Task.Creation.Year())
+	require.Nil(t, r.Task.Description)
+	require.Equal(t, []string{"a"}, r.Task.Tags)
+}
+
+func TestErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/e/qry_task":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"data":   map[string]any{"task": nil},
+				"errors": []map[string]any{{"message": "task not found"}},
+			})
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte("authentication required"))
+		}
+	}))
+	defer s.Close()
+
+	c := &client.Client{Endpoint: s.URL}
+	_, err := c.QryTask(context.Background(), client.QryTaskVariables{})
+	var gqlErrs client.Errors
+	require.ErrorAs(t, err, &gqlErrs)
+	require.EqualError(t, err, "task not found")
+
+	_, err = c.QryUser(context.Background(), client.QryUserVariables{})
+	var httpErr *client.HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	require.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+	require.Equal(t, "authentication required", httpErr.Body)
+	require.False(t, errors.As(err, &gqlErrs))
+}
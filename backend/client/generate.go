@@ -0,0 +1,3 @@
+package client
+
+//go:generate go run ../cmd/pqgen -schema ../api/graph -queries ../persisted_queries -go client_gen.go -go-package client -ts ts/taskhub.ts
@@ -0,0 +1,470 @@
+// Code generated by pqgen. DO NOT EDIT.
+
+export type APIKeyKind = "PERSONAL" | "SERVICE";
+
+export type APIKeyScope = "READ_TASKS" | "WRITE_TASKS" | "ADMIN";
+
+export type ProjectRole = "OWNER" | "MAINTAINER" | "MEMBER" | "VIEWER";
+
+export type TaskPriority = "BLOCKER" | "HIGH" | "MEDIUM" | "LOW";
+
+export type TaskStatus = "TODO" | "IN_PROGRESS" | "DONE";
+
+export type TasksOrder = "PRIORITY" | "CREATION_TIME" | "DUE_TIME" | "TITLE_ALPHA";
+
+export interface TasksFilters {
+  assignees?: Array<string> | null;
+  reporters?: Array<string> | null;
+  projects?: Array<string> | null;
+  status?: Array<TaskStatus> | null;
+  tags?: Array<string> | null;
+  createdBefore?: string | null;
+  createdAfter?: string | null;
+  archived?: boolean | null;
+}
+
+/** Variables of mut_begin_sign_in. */
+export interface MutBeginSignInVariables {
+  email: string;
+  password: string;
+}
+
+/** Data returned by mut_begin_sign_in. */
+export interface MutBeginSignInResponse {
+  beginSignIn: {
+    tokens: {
+      accessToken: string;
+      accessTokenExpires: string;
+      refreshToken: string;
+      session: {
+        id: string;
+        expires: string;
+      };
+    } | null;
+    challenge: {
+      token: string;
+      expires: string;
+    } | null;
+  };
+}
+
+/** Variables of mut_complete_sign_in. */
+export interface MutCompleteSignInVariables {
+  challengeToken: string;
+  code: string;
+}
+
+/** Data returned by mut_complete_sign_in. */
+export interface MutCompleteSignInResponse {
+  completeSignIn: {
+    accessToken: string;
+    accessTokenExpires: string;
+    refreshToken: string;
+    session: {
+      id: string;
+      expires: string;
+    };
+  };
+}
+
+/** Variables of mut_confirm_two_factor. */
+export interface MutConfirmTwoFactorVariables {
+  code: string;
+}
+
+/** Data returned by mut_confirm_two_factor. */
+export interface MutConfirmTwoFactorResponse {
+  confirmTwoFactor: Array<string>;
+}
+
+/** Variables of mut_create_api_key. */
+export interface MutCreateAPIKeyVariables {
+  name: string;
+  scopes: Array<APIKeyScope>;
+  user?: string | null;
+}
+
+/** Data returned by mut_create_api_key. */
+export interface MutCreateAPIKeyResponse {
+  createApiKey: {
+    key: string;
+    apiKey: {
+      id: string;
+      name: string;
+      kind: APIKeyKind;
+      scopes: Array<APIKeyScope>;
+      creation: string;
+    };
+  };
+}
+
+/** Variables of mut_create_task. */
+export interface MutCreateTaskVariables {
+  title: string;
+  projectID: string;
+  status?: TaskStatus | null;
+  taskPriority?: TaskPriority | null;
+  description?: string | null;
+  due?: string | null;
+  tags?: Array<string> | null;
+  assignees?: Array<string> | null;
+  reporters?: Array<string> | null;
+  blocks?: Array<string> | null;
+  relatesTo?: Array<string> | null;
+}
+
+/** Data returned by mut_create_task. */
+export interface MutCreateTaskResponse {
+  createTask: {
+    id: string;
+  };
+}
+
+/** Data returned by mut_enroll_two_factor. */
+export interface MutEnrollTwoFactorResponse {
+  enrollTwoFactor: {
+    secret: string;
+    uri: string;
+  };
+}
+
+/** Data returned by mut_logout. */
+export interface MutLogoutResponse {
+  logout: string;
+}
+
+/** Variables of mut_refresh_access_token. */
+export interface MutRefreshAccessTokenVariables {
+  refreshToken: string;
+}
+
+/** Data returned by mut_refresh_access_token. */
+export interface MutRefreshAccessTokenResponse {
+  refreshAccessToken: {
+    accessToken: string;
+    accessTokenExpires: string;
+    refreshToken: string;
+    session: {
+      id: string;
+      expires: string;
+    };
+  };
+}
+
+/** Variables of mut_revoke_api_key. */
+export interface MutRevokeAPIKeyVariables {
+  id: string;
+}
+
+/** Data returned by mut_revoke_api_key. */
+export interface MutRevokeAPIKeyResponse {
+  revokeApiKey: {
+    id: string;
+  };
+}
+
+/** Variables of mut_sign_in. */
+export interface MutSignInVariables {
+  email: string;
+  password: string;
+}
+
+/** Data returned by mut_sign_in. */
+export interface MutSignInResponse {
+  signIn: {
+    accessToken: string;
+    accessTokenExpires: string;
+    refreshToken: string;
+    session: {
+      id: string;
+      expires: string;
+    };
+  };
+}
+
+/** Variables of qry_project. */
+export interface QryProjectVariables {
+  projectID: string;
+  tasksFirst?: number | null;
+  tasksAfter?: string | null;
+}
+
+/** Data returned by qry_project. */
+export interface QryProjectResponse {
+  project: {
+    id: string;
+    name: string;
+    description: string;
+    slug: string;
+    creation: string;
+    owners: Array<{
+      id: string;
+      displayName: string;
+    }> | null;
+    members: Array<{
+      id: string;
+      displayName: string;
+    }>;
+    roles: Array<{
+      role: ProjectRole;
+      user: {
+        id: string;
+        displayName: string;
+      };
+    }>;
+    tasks: {
+      totalCount: number;
+      pageInfo: {
+        hasNextPage: boolean;
+        endCursor: string | null;
+      };
+      edges: Array<{
+        node: {
+          id: string;
+          title: string;
+          description: string | null;
+          priority: TaskPriority;
+          due: string | null;
+          assignees: Array<{
+            id: string;
+            displayName: string;
+          }>;
+          reporters: Array<{
+            id: string;
+            displayName: string;
+          }>;
+        };
+      }>;
+    };
+  } | null;
+}
+
+/** Variables of qry_task. */
+export interface QryTaskVariables {
+  taskID: string;
+}
+
+/** Data returned by qry_task. */
+export interface QryTaskResponse {
+  task: {
+    id: string;
+    title: string;
+    description: string | null;
+    priority: TaskPriority;
+    status: TaskStatus;
+    creation: string;
+    due: string | null;
+    tags: Array<string>;
+    project: {
+      id: string;
+      name: string;
+    };
+    assignees: Array<{
+      id: string;
+      displayName: string;
+    }>;
+    reporters: Array<{
+      id: string;
+      displayName: string;
+    }>;
+    isBlockedBy: Array<{
+      id: string;
+      title: string;
+      description: string | null;
+    }>;
+    relatesTo: Array<{
+      id: string;
+      title: string;
+      description: string | null;
+    }>;
+  } | null;
+}
+
+/** Variables of qry_tasks. */
+export interface QryTasksVariables {
+  first?: number | null;
+  after?: string | null;
+  filters?: TasksFilters | null;
+  order?: TasksOrder | null;
+  orderAsc?: boolean | null;
+}
+
+/** Data returned by qry_tasks. */
+export interface QryTasksResponse {
+  tasks: {
+    totalCount: number;
+    pageInfo: {
+      hasNextPage: boolean;
+      endCursor: string | null;
+    };
+    edges: Array<{
+      cursor: string;
+      node: {
+        id: string;
+        title: string;
+        priority: TaskPriority;
+        due: string | null;
+        project: {
+          name: string;
+        };
+        reporters: Array<{
+          displayName: string;
+        }>;
+        assignees: Array<{
+          displayName: string;
+        }>;
+      };
+    }>;
+  };
+}
+
+/** Variables of qry_user. */
+export interface QryUserVariables {
+  userID: string;
+  tasksAssignedFirst?: number | null;
+  tasksAssignedAfter?: string | null;
+}
+
+/** Data returned by qry_user. */
+export interface QryUserResponse {
+  user: {
+    id: string;
+    email: string;
+    displayName: string;
+    role: string;
+    location: string;
+    personalStatus: string | null;
+    manager: {
+      id: string;
+      displayName: string;
+    } | null;
+    subordinates: Array<{
+      id: string;
+      displayName: string;
+    }> | null;
+    projects: Array<{
+      id: string;
+      name: string;
+    }>;
+    tasksAssigned: {
+      totalCount: number;
+      pageInfo: {
+        hasNextPage: boolean;
+        endCursor: string | null;
+      };
+      edges: Array<{
+        node: {
+          id: string;
+          title: string;
+          description: string | null;
+          reporters: Array<{
+            id: string;
+            displayName: string;
+          }>;
+        };
+      }>;
+    };
+    tasksReported: Array<{
+      id: string;
+      title: string;
+      description: string | null;
+      assignees: Array<{
+        id: string;
+        displayName: string;
+      }>;
+    }>;
+  } | null;
+}
+
+/** Data returned by sub_task_upsert. */
+export interface SubTaskUpsertResponse {
+  taskUpsert: {
+    id: string;
+    title: string;
+    description: string | null;
+    reporters: Array<{
+      id: string;
+      displayName: string;
+    }>;
+  };
+}
+
+export interface Operations {
+  "mut_begin_sign_in": {
+    type: "mutation";
+    variables: MutBeginSignInVariables;
+    response: MutBeginSignInResponse;
+  };
+  "mut_complete_sign_in": {
+    type: "mutation";
+    variables: MutCompleteSignInVariables;
+    response: MutCompleteSignInResponse;
+  };
+  "mut_confirm_two_factor": {
+    type: "mutation";
+    variables: MutConfirmTwoFactorVariables;
+    response: MutConfirmTwoFactorResponse;
+  };
+  "mut_create_api_key": {
+    type: "mutation";
+    variables: MutCreateAPIKeyVariables;
+    response: MutCreateAPIKeyResponse;
+  };
+  "mut_create_task": {
+    type: "mutation";
+    variables: MutCreateTaskVariables;
+    response: MutCreateTaskResponse;
+  };
+  "mut_enroll_two_factor": {
+    type: "mutation";
+    variables: Record<string, never>;
+    response: MutEnrollTwoFactorResponse;
+  };
+  "mut_logout": {
+    type: "mutation";
+    variables: Record<string, never>;
+    response: MutLogoutResponse;
+  };
+  "mut_refresh_access_token": {
+    type: "mutation";
+    variables: MutRefreshAccessTokenVariables;
+    response: MutRefreshAccessTokenResponse;
+  };
+  "mut_revoke_api_key": {
+    type: "mutation";
+    variables: MutRevokeAPIKeyVariables;
+    response: MutRevokeAPIKeyResponse;
+  };
+  "mut_sign_in": {
+    type: "mutation";
+    variables: MutSignInVariables;
+    response: MutSignInResponse;
+  };
+  "qry_project": {
+    type: "query";
+    variables: QryProjectVariables;
+    response: QryProjectResponse;
+  };
+  "qry_task": {
+    type: "query";
+    variables: QryTaskVariables;
+    response: QryTaskResponse;
+  };
+  "qry_tasks": {
+    type: "query";
+    variables: QryTasksVariables;
+    response: QryTasksResponse;
+  };
+  "qry_user": {
+    type: "query";
+    variables: QryUserVariables;
+    response: QryUserResponse;
+  };
+  "sub_task_upsert": {
+    type: "subscription";
+    variables: Record<string, never>;
+    response: SubTaskUpsertResponse;
+  };
+}
+
+export type OperationName = keyof Operations;
@@ -8,12 +8,19 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/romshark/taskhub/api"
+	"github.com/romshark/taskhub/api/broadcast"
+	"github.com/romshark/taskhub/api/dataprovider"
 	"github.com/romshark/taskhub/api/dataprovider/inmem"
+	"github.com/romshark/taskhub/api/dataprovider/sqlite"
 	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/jwt"
+	"github.com/romshark/taskhub/api/metrics"
+	"github.com/romshark/taskhub/api/respcache"
 	"golang.org/x/exp/slog"
 )
 
@@ -35,6 +42,12 @@ func main() {
 
 	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt)
 
+	jwtKeys, err := loadJWTKeys(config)
+	if err != nil {
+		log.Error("loading JWT keys", slog.Any("error", err))
+		return
+	}
+
 	persistedQueries, err := gqlpq.New(
 		config.GQLSchemaPath,
 	)
@@ -45,7 +58,7 @@ func main() {
 
 	err = persistedQueries.Load(config.PersistedQueriesDirPath)
 	if err != nil {
-		log.Error("loading persisted queries", slog.Any("error", err))
+		logLoadError(log, "loading persisted queries", err)
 		return
 	}
 
@@ -54,15 +67,26 @@ func main() {
 			err := persistedQueries.Watch(
 				ctx, config.GQLSchemaPath, config.PersistedQueriesDirPath,
 				config.PersistedQueriesReloadDebounce,
-				func() {
+				func(err error) {
+					if err != nil {
+						logLoadError(
+							log, "reloading persisted queries, "+
+								"keeping the last good set", err,
+						)
+						return
+					}
 					log.Info(
 						"reloaded persisted queries",
 						slog.Int("totalQueries", persistedQueries.Len()),
 					)
 				},
 			)
-			if err != nil {
-				panic(fmt.Errorf("watching persisted queries dir changes: %w", err))
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Error(
+					"watching persisted queries dir changes, "+
+						"hot reloading disabled",
+					slog.Any("error", err),
+				)
 			}
 		}()
 	}
@@ -79,14 +103,73 @@ func main() {
 		slog.Int("totalQueries", persistedQueries.Len()),
 	)
 
-	inmemDataProvider := inmem.NewFake()
+	var dataProvider dataprovider.DataProvider
+	switch config.DataProvider {
+	case DataProviderSQLite:
+		db, err := sqlite.Open(ctx, config.SQLitePath)
+		if err != nil {
+			log.Error("opening sqlite database", slog.Any("error", err))
+			return
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				log.Error("closing sqlite database", slog.Any("error", err))
+			}
+		}()
+		log.Info("using sqlite data provider", slog.String("path", config.SQLitePath))
+		dataProvider = db
+	default:
+		if config.InmemJournalPath == "" {
+			log.Info("using in-memory data provider")
+			dataProvider = inmem.NewFake()
+			break
+		}
+		p, err := inmem.OpenJournaled(inmem.JournalOptions{
+			DirPath:          config.InmemJournalPath,
+			CompactThreshold: config.InmemJournalCompactThreshold,
+			OnCompaction: func(err error) {
+				if err != nil {
+					log.Error("compacting journal", slog.Any("error", err))
+					return
+				}
+				log.Info("compacted journal")
+			},
+		}, inmem.NewFake)
+		if err != nil {
+			log.Error("opening in-memory data provider journal", slog.Any("error", err))
+			return
+		}
+		defer func() {
+			if err := p.Close(); err != nil {
+				log.Error("closing journal", slog.Any("error", err))
+			}
+		}()
+		log.Info(
+			"using journaled in-memory data provider",
+			slog.String("path", config.InmemJournalPath),
+		)
+		dataProvider = p
+	}
+
+	var responseCache *respcache.Cache
+	if config.ResponseCacheSize > 0 {
+		responseCache = respcache.New(config.ResponseCacheSize)
+	}
+
+	operationMetrics := metrics.New()
 
 	apiServer, err := api.NewServer(
 		log,
 		config.APIMode,
-		[]byte(config.JWTSecret),
-		inmemDataProvider,
+		jwtKeys,
+		dataProvider,
 		persistedQueries,
+		broadcast.Options{
+			QueueSize: config.BroadcastQueueSize,
+			Policy:    config.BroadcastPolicy,
+		},
+		responseCache,
+		operationMetrics,
 	)
 	if err != nil {
 		log.Error("initializing api server", slog.Any("error", err))
@@ -113,22 +196,101 @@ func main() {
 		}
 	}()
 
+	var adminServer *http.Server
+	if config.AdminHost != "" {
+		adminHandler := api.NewAdminServer(
+			log, persistedQueries, responseCache, operationMetrics,
+		)
+		adminServer = &http.Server{
+			Addr:    config.AdminHost,
+			Handler: adminHandler,
+		}
+		go func() {
+			log.Info("admin listening", slog.String("addr", config.AdminHost))
+			err := adminServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				panic(fmt.Errorf("listening admin HTTP: %w", err))
+			}
+		}()
+	}
+
 	<-ctx.Done()
 	err = httpServer.Shutdown(context.Background())
 	if err != nil {
 		log.Error("shutting down http server", slog.Any("error", err))
 	}
+	if adminServer != nil {
+		err = adminServer.Shutdown(context.Background())
+		if err != nil {
+			log.Error("shutting down admin http server", slog.Any("error", err))
+		}
+	}
 }
 
+// loadJWTKeys returns the key set of the keys listed in the
+// JWT_KEYS_PATH manifest or the JWT_SECRET if no manifest is configured.
+// If both are configured the secret is only used to verify
+// previously issued tokens without key ID.
+func loadJWTKeys(config *Config) (*jwt.KeySet, error) {
+	if config.JWTKeysPath == "" {
+		return jwt.NewKeySetHS256([]byte(config.JWTSecret)), nil
+	}
+	keys, err := jwt.LoadKeys(config.JWTKeysPath)
+	if err != nil {
+		return nil, err
+	}
+	if config.JWTSecret != "" {
+		keys = append(keys, jwt.Key{
+			Algorithm: jwt.AlgHS256,
+			PublicKey: []byte(config.JWTSecret),
+		})
+	}
+	return jwt.NewKeySet(keys...)
+}
+
+// logLoadError logs err and all invalid query files
+// if err is a *gqlpq.LoadError.
+func logLoadError(log *slog.Logger, msg string, err error) {
+	var errLoad *gqlpq.LoadError
+	if !errors.As(err, &errLoad) {
+		log.Error(msg, slog.Any("error", err))
+		return
+	}
+	for _, f := range errLoad.Files {
+		log.Error(
+			"invalid persisted query file",
+			slog.String("file", f.File),
+			slog.Any("error", f.Err),
+		)
+	}
+	log.Error(msg, slog.Int("invalidFiles", len(errLoad.Files)))
+}
+
+type DataProviderType int8
+
+const (
+	DataProviderInmem  DataProviderType = 0
+	DataProviderSQLite DataProviderType = 1
+)
+
 type Config struct {
 	LogLevel                       slog.Level
 	Host                           string
+	AdminHost                      string
 	JWTSecret                      string
+	JWTKeysPath                    string
 	GQLSchemaPath                  string
 	PersistedQueriesDirPath        string
 	PersistedQueriesReloadDebounce time.Duration
 	PersistedQueriesHotReload      bool
 	APIMode                        api.Mode
+	DataProvider                   DataProviderType
+	SQLitePath                     string
+	InmemJournalPath               string
+	InmemJournalCompactThreshold   int
+	BroadcastQueueSize             int
+	BroadcastPolicy                broadcast.Policy
+	ResponseCacheSize              int
 }
 
 func loadConfig() (*Config, error) {
@@ -152,9 +314,17 @@ func loadConfig() (*Config, error) {
 		c.Host = "localhost:8080"
 	}
 
+	c.AdminHost = os.Getenv("ADMIN_HOST")
+	if c.AdminHost == "" {
+		c.AdminHost = "localhost:8081"
+	} else if strings.EqualFold(c.AdminHost, "OFF") {
+		c.AdminHost = ""
+	}
+
 	c.JWTSecret = os.Getenv("JWT_SECRET")
-	if c.JWTSecret == "" {
-		return nil, fmt.Errorf("missing JWT_SECRET")
+	c.JWTKeysPath = os.Getenv("JWT_KEYS_PATH")
+	if c.JWTSecret == "" && c.JWTKeysPath == "" {
+		return nil, fmt.Errorf("missing JWT_SECRET or JWT_KEYS_PATH")
 	}
 
 	c.GQLSchemaPath = os.Getenv("GQL_SCHEMA_PATH")
@@ -199,5 +369,74 @@ func loadConfig() (*Config, error) {
 	default:
 		return nil, fmt.Errorf("invalid MODE %q; use either DEBUG or PRODUCTION", v)
 	}
+
+	switch v := os.Getenv("DATA_PROVIDER"); {
+	case v == "":
+		c.DataProvider = DataProviderInmem
+	case strings.EqualFold(v, "INMEM"):
+		c.DataProvider = DataProviderInmem
+	case strings.EqualFold(v, "SQLITE"):
+		c.DataProvider = DataProviderSQLite
+	default:
+		return nil, fmt.Errorf(
+			"invalid DATA_PROVIDER %q; use either INMEM or SQLITE", v,
+		)
+	}
+
+	c.SQLitePath = os.Getenv("SQLITE_PATH")
+	if c.SQLitePath == "" {
+		c.SQLitePath = "taskhub.db"
+	}
+
+	c.InmemJournalPath = os.Getenv("INMEM_JOURNAL_PATH")
+
+	if v := os.Getenv("INMEM_JOURNAL_COMPACT_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf(
+				"invalid INMEM_JOURNAL_COMPACT_THRESHOLD %q; "+
+					"use a positive integer", v,
+			)
+		}
+		c.InmemJournalCompactThreshold = n
+	}
+
+	if v := os.Getenv("BROADCAST_QUEUE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf(
+				"invalid BROADCAST_QUEUE_SIZE %q; use a positive integer", v,
+			)
+		}
+		c.BroadcastQueueSize = n
+	}
+
+	switch v := os.Getenv("BROADCAST_POLICY"); {
+	case v == "":
+		c.BroadcastPolicy = broadcast.PolicyDropOldest
+	case strings.EqualFold(v, "DROP_OLDEST"):
+		c.BroadcastPolicy = broadcast.PolicyDropOldest
+	case strings.EqualFold(v, "DROP_NEWEST"):
+		c.BroadcastPolicy = broadcast.PolicyDropNewest
+	case strings.EqualFold(v, "DISCONNECT"):
+		c.BroadcastPolicy = broadcast.PolicyDisconnect
+	default:
+		return nil, fmt.Errorf(
+			"invalid BROADCAST_POLICY %q; "+
+				"use either DROP_OLDEST, DROP_NEWEST or DISCONNECT", v,
+		)
+	}
+
+	c.ResponseCacheSize = 1024
+	if v := os.Getenv("RESPONSE_CACHE_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf(
+				"invalid RESPONSE_CACHE_SIZE %q; "+
+					"use a non-negative integer", v,
+			)
+		}
+		c.ResponseCacheSize = n
+	}
 	return c, nil
 }
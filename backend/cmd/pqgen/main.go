@@ -0,0 +1,107 @@
+// pqgen generates a typed Go client package, TypeScript definitions
+// and an OpenAPI document for the persisted queries.
+//
+// Usage:
+//
+//	pqgen [-schema api/graph] [-queries persisted_queries]
+//	      [-go client_gen.go] [-go-package client] [-ts taskhub.ts]
+//	      [-openapi openapi.json]
+//
+// pqgen exits with code 1 if any of the persisted queries is invalid,
+// 2 on invalid usage.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/pqgen"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	f := flag.NewFlagSet("pqgen", flag.ContinueOnError)
+	f.SetOutput(stderr)
+	schemaPath := f.String(
+		"schema", "api/graph", "schema directory path",
+	)
+	queriesPath := f.String(
+		"queries", "persisted_queries", "persisted queries directory path",
+	)
+	goPath := f.String("go", "", "Go client output file path")
+	goPackage := f.String("go-package", "client", "Go client package name")
+	tsPath := f.String("ts", "", "TypeScript definitions output file path")
+	openAPIPath := f.String("openapi", "", "OpenAPI document output file path")
+	if err := f.Parse(args); err != nil {
+		return 2
+	}
+	if *goPath == "" && *tsPath == "" && *openAPIPath == "" {
+		fmt.Fprintln(stderr, "at least one of -go, -ts or -openapi is required")
+		return 2
+	}
+
+	schema, err := gqlpq.LoadSchema(*schemaPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	ops, err := pqgen.Load(schema, *queriesPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *goPath != "" {
+		src, err := pqgen.GenerateGo(schema, ops, *goPackage)
+		if err != nil {
+			fmt.Fprintln(stderr, "generating Go client:", err)
+			return 1
+		}
+		if err := writeFile(*goPath, src); err != nil {
+			fmt.Fprintln(stderr, "writing Go client:", err)
+			return 1
+		}
+	}
+
+	if *tsPath != "" {
+		src, err := pqgen.GenerateTS(schema, ops)
+		if err != nil {
+			fmt.Fprintln(stderr, "generating TypeScript definitions:", err)
+			return 1
+		}
+		if err := writeFile(*tsPath, src); err != nil {
+			fmt.Fprintln(stderr, "writing TypeScript definitions:", err)
+			return 1
+		}
+	}
+
+	if *openAPIPath != "" {
+		src, err := pqgen.GenerateOpenAPI(schema, ops, pqgen.OpenAPIInfo{
+			Title: "TaskHub", Version: "unversioned",
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, "generating OpenAPI document:", err)
+			return 1
+		}
+		if err := writeFile(*openAPIPath, src); err != nil {
+			fmt.Fprintln(stderr, "writing OpenAPI document:", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func writeFile(path string, src []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, src, 0o644)
+}
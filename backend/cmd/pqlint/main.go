@@ -0,0 +1,100 @@
+// pqlint validates the persisted queries against the GraphQL schema
+// and optionally writes a JSON manifest of all valid queries.
+//
+// Usage:
+//
+//	pqlint [-schema api/graph] [-queries persisted_queries]
+//	       [-manifest manifest.json] [-strict]
+//
+// Diagnostics are printed to stderr. pqlint exits with code 1 if any errors
+// (or warnings in strict mode) are found, 2 on invalid usage.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	f := flag.NewFlagSet("pqlint", flag.ContinueOnError)
+	f.SetOutput(stderr)
+	schemaPath := f.String(
+		"schema", "api/graph", "schema directory path",
+	)
+	queriesPath := f.String(
+		"queries", "persisted_queries", "persisted queries directory path",
+	)
+	manifestPath := f.String(
+		"manifest", "", `manifest output file path ("-" for stdout)`,
+	)
+	strict := f.Bool("strict", false, "treat warnings as errors")
+	if err := f.Parse(args); err != nil {
+		return 2
+	}
+
+	schema, err := gqlpq.LoadSchema(*schemaPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	manifest, diagnostics, err := gqlpq.Lint(schema, *queriesPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var errs, warnings int
+	for _, d := range diagnostics {
+		fmt.Fprintln(stderr, d)
+		switch d.Severity {
+		case gqlpq.SeverityError:
+			errs++
+		case gqlpq.SeverityWarning:
+			warnings++
+		}
+	}
+	fmt.Fprintf(
+		stderr, "%d queries, %d errors, %d warnings\n",
+		len(manifest), errs, warnings,
+	)
+
+	if *manifestPath != "" {
+		if err := writeManifest(*manifestPath, stdout, manifest); err != nil {
+			fmt.Fprintln(stderr, "writing manifest:", err)
+			return 1
+		}
+	}
+
+	if errs > 0 || (*strict && warnings > 0) {
+		return 1
+	}
+	return 0
+}
+
+func writeManifest(
+	path string, stdout io.Writer, manifest []gqlpq.ManifestEntry,
+) error {
+	if manifest == nil {
+		manifest = []gqlpq.ManifestEntry{}
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if path == "-" {
+		_, err = stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
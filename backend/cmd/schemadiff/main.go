@@ -0,0 +1,122 @@
+// schemadiff compares two versions of the GraphQL schema, classifies the
+// changes as breaking, dangerous or safe and lists the persisted queries
+// that are valid against the old schema but invalid against the new one.
+//
+// Usage:
+//
+//	schemadiff -old <schema dir> [-new api/graph] [-queries persisted_queries]
+//
+// schemadiff exits with code 1 if there are breaking changes
+// or broken persisted queries, 2 on invalid usage.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/romshark/taskhub/api/gqlpq"
+	"github.com/romshark/taskhub/api/schemadiff"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	f := flag.NewFlagSet("schemadiff", flag.ContinueOnError)
+	f.SetOutput(stderr)
+	oldPath := f.String("old", "", "old schema directory path (required)")
+	newPath := f.String("new", "api/graph", "new schema directory path")
+	queriesPath := f.String(
+		"queries", "persisted_queries", "persisted queries directory path",
+	)
+	if err := f.Parse(args); err != nil {
+		return 2
+	}
+	if *oldPath == "" {
+		fmt.Fprintln(stderr, "missing -old")
+		f.Usage()
+		return 2
+	}
+
+	oldSchema, err := gqlpq.LoadSchema(*oldPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "old schema:", err)
+		return 1
+	}
+	newSchema, err := gqlpq.LoadSchema(*newPath)
+	if err != nil {
+		fmt.Fprintln(stderr, "new schema:", err)
+		return 1
+	}
+
+	changes := schemadiff.Diff(oldSchema, newSchema)
+	var breaking, dangerous, safe int
+	for _, c := range changes {
+		fmt.Fprintln(stdout, c)
+		switch c.Severity {
+		case schemadiff.Breaking:
+			breaking++
+		case schemadiff.Dangerous:
+			dangerous++
+		case schemadiff.Safe:
+			safe++
+		}
+	}
+	fmt.Fprintf(
+		stdout, "%d breaking, %d dangerous, %d safe changes\n",
+		breaking, dangerous, safe,
+	)
+
+	broken, err := brokenQueries(oldSchema, newSchema, *queriesPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if len(broken) > 0 {
+		files := map[string]struct{}{}
+		for _, d := range broken {
+			files[d.File] = struct{}{}
+		}
+		fmt.Fprintf(stdout, "\nbroken persisted queries: %d\n", len(files))
+		for _, d := range broken {
+			fmt.Fprintln(stdout, d)
+		}
+	}
+
+	if breaking > 0 || len(broken) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// brokenQueries returns the errors of the persisted queries in dirPath
+// that are valid against oldSchema but invalid against newSchema.
+func brokenQueries(
+	oldSchema, newSchema *ast.Schema, dirPath string,
+) ([]gqlpq.Diagnostic, error) {
+	_, oldDiagnostics, err := gqlpq.Lint(oldSchema, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	_, newDiagnostics, err := gqlpq.Lint(newSchema, dirPath)
+	if err != nil {
+		return nil, err
+	}
+	invalid := map[string]bool{}
+	for _, d := range oldDiagnostics {
+		if d.Severity == gqlpq.SeverityError {
+			invalid[d.File] = true
+		}
+	}
+	var broken []gqlpq.Diagnostic
+	for _, d := range newDiagnostics {
+		if d.Severity == gqlpq.SeverityError && !invalid[d.File] {
+			broken = append(broken, d)
+		}
+	}
+	return broken, nil
+}
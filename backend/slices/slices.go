@@ -97,12 +97,13 @@ func Copy[T any](s []T) []T {
 }
 
 // SortAndLimit applies sortFnLess if != nil and limit if != nil to s.
+// The sort is stable, equal elements keep their original order.
 func SortAndLimit[T any](s []T, sortFnLess func(a, b T) bool, limit int) []T {
 	if s == nil {
 		return nil
 	}
 	if sortFnLess != nil {
-		slices.SortFunc(s, sortFnLess)
+		slices.SortStableFunc(s, sortFnLess)
 	}
 	if limit >= 0 && limit < len(s) {
 		s = s[:limit]